@@ -9,9 +9,15 @@ import (
 
 	"github.com/LeandroDeJesus-S/quicknote/config"
 	"github.com/LeandroDeJesus-S/quicknote/config/db"
+	"github.com/LeandroDeJesus-S/quicknote/internal/blob"
+	"github.com/LeandroDeJesus-S/quicknote/internal/events"
 	"github.com/LeandroDeJesus-S/quicknote/internal/handler"
+	"github.com/LeandroDeJesus-S/quicknote/internal/jobs"
 	"github.com/LeandroDeJesus-S/quicknote/internal/mail"
+	"github.com/LeandroDeJesus-S/quicknote/internal/models"
+	"github.com/LeandroDeJesus-S/quicknote/internal/render"
 	"github.com/LeandroDeJesus-S/quicknote/internal/repo"
+	"github.com/LeandroDeJesus-S/quicknote/internal/support"
 	"github.com/LeandroDeJesus-S/quicknote/internal/support/authutil"
 	"github.com/alexedwards/scs/pgxstore"
 	"github.com/alexedwards/scs/v2"
@@ -21,7 +27,10 @@ import (
 func main() {
 	conf := config.MustLoadConfig()
 
-	pool := db.MustConnect(context.Background(), conf.DatabaseURL)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	pool := db.MustConnect(ctx, conf.DatabaseURL)
 	defer pool.Close()
 
 	slog.SetDefault(config.NewLogger(conf.LoggerOut(), conf.LoggerLevel()))
@@ -39,21 +48,57 @@ func main() {
 	)
 
 	noteRepo := repo.NewNoteRepo(pool)
-	userRepo := repo.NewUserRepo(pool)
+	tagRepo := repo.NewTagRepo(pool)
+	notebookRepo := repo.NewNotebookRepo(pool)
+	revisionRepo := repo.NewRevisionRepo(pool)
+	checklistRepo := repo.NewChecklistRepo(pool)
+	attachmentRepo := repo.NewAttachmentRepo(pool)
+	noteShareRepo := repo.NewNoteShareRepo(pool)
+	reminderRepo := repo.NewReminderRepo(pool)
+	tx := repo.NewTransactor(pool)
+	userRepo := repo.NewUserRepo(pool, repo.TokenTTLs{
+		models.TokenPurposeConfirmation:  conf.ConfirmationTokenTTLDuration(),
+		models.TokenPurposePasswordReset: conf.PasswordResetTokenTTLDuration(),
+		models.TokenPurposeEmailChange:   conf.EmailChangeTokenTTLDuration(),
+	})
+	tokenRepo := repo.NewAPITokenRepo(pool)
+	shareRepo := repo.NewShareLinkRepo(pool)
+	workspaceRepo := repo.NewWorkspaceRepo(pool)
+	calendarRepo := repo.NewCalendarFeedRepo(pool)
+	totpRepo := repo.NewTOTPRepo(pool)
+	exportRepo := repo.NewDataExportRepo(pool)
+
+	blobs := mustBlobStore(ctx, conf)
+
+	hub := events.NewHub()
+	go events.NewPGBridge(pool, hub).Run(ctx)
+
+	go jobs.NewTrashPurger(noteRepo, conf.TrashRetentionDuration(), conf.TrashPurgeIntervalDuration()).Run(ctx)
+	go jobs.NewAttachmentSweeper(attachmentRepo, blobs, conf.AttachmentSweepIntervalDuration()).Run(ctx)
+	go jobs.NewAccountDeleter(userRepo, conf.AccountDeletionIntervalDuration()).Run(ctx)
 
 	pwHasher := authutil.NewBcryptHasher()
+	cipher := authutil.NewAESCipher(conf.SecretKey)
+	signer := authutil.NewHMACSigner(conf.SecretKey)
 	sessionMng := scs.New()
 	sessionMng.Lifetime = 1 * time.Hour
 	sessionMng.Store = pgxstore.New(pool)
 	pgxstore.NewWithCleanupInterval(pool, 12*time.Hour)
 
-	mux := handler.NewMux(noteRepo, userRepo, pwHasher, sessionMng, mailer, conf)
+	mailRenderer := render.NewTemplateRender(sessionMng).WithEmbedFS(true)
+	go jobs.NewReminderSender(reminderRepo, mailer, mailRenderer, conf.AppDomain(), conf.ReminderPollIntervalDuration()).Run(ctx)
+	go jobs.NewDataExporter(exportRepo, authutil.NewSessionLister(sessionMng), blobs, mailer, mailRenderer, signer, conf.AppDomain(), conf.DataExportTTLDuration(), conf.DataExportPollIntervalDuration()).Run(ctx)
+
+	mux := handler.NewMux(noteRepo, tagRepo, notebookRepo, revisionRepo, checklistRepo, attachmentRepo, noteShareRepo, reminderRepo, tx, blobs, hub, userRepo, tokenRepo, shareRepo, workspaceRepo, calendarRepo, totpRepo, exportRepo, pwHasher, cipher, signer, sessionMng, mailer, conf)
 	muxH := mux.WithMiddleware(
+		support.MaxBodySize(conf.UploadMaxSizeBytes()),
 		sessionMng.LoadAndSave,
+		authutil.NewBearerAuthMiddleware(tokenRepo).Authenticate,
 		csrf.Protect(
 			[]byte(conf.SecretKey),
 			csrf.TrustedOrigins([]string{"localhost:8000", "127.0.0.1:8000"}),
 		),
+		support.MethodOverride,
 		func(h http.Handler) http.Handler {
 			return http.HandlerFunc((func(w http.ResponseWriter, r *http.Request) {
 				slog.Debug("[log]", "method", r.Method, "pattern", r.URL.Path)
@@ -67,3 +112,36 @@ func main() {
 		muxH,
 	)
 }
+
+// mustBlobStore creates the blob store of the attachments chosen in the config.
+func mustBlobStore(ctx context.Context, conf *config.Config) blob.BlobStore {
+	switch conf.BlobStorage {
+	case "local":
+		store, err := blob.NewLocalStore(conf.BlobLocalDir)
+		if err != nil {
+			slog.Error("couldn't create the local blob store", "error", err.Error())
+			panic(err)
+		}
+		return store
+
+	case "s3":
+		store, err := blob.NewS3Store(ctx, blob.S3Config{
+			Endpoint:  conf.S3Endpoint,
+			Region:    conf.S3Region,
+			Bucket:    conf.S3Bucket,
+			AccessKey: conf.S3AccessKey,
+			SecretKey: conf.S3SecretKey,
+			UseSSL:    conf.S3UseSSLBool(),
+		})
+		if err != nil {
+			slog.Error("couldn't connect to the S3 blob store", "error", err.Error())
+			panic(err)
+		}
+		return store
+
+	case "memory":
+		slog.Warn("attachments are kept in memory and will be lost on exit")
+		return blob.NewMemoryStore()
+	}
+	panic(fmt.Sprintf("unknown blob storage: %q", conf.BlobStorage))
+}
@@ -9,6 +9,7 @@ import (
 	"reflect"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/joho/godotenv"
 )
@@ -33,6 +34,44 @@ type Config struct {
 	MailUsername    string `env:"MAIL_USERNAME,required"`
 	MailPassword    string `env:"MAIL_PASSWORD,required"`
 	MailDefaultFrom string `env:"MAIL_DEFAULT_FROM,required"`
+
+	// trash configs
+	TrashRetention     string `env:"TRASH_RETENTION,720h" kind:"duration"`    // how long deleted notes stay in the trash before being purged
+	TrashPurgeInterval string `env:"TRASH_PURGE_INTERVAL,1h" kind:"duration"` // how often the trash is checked for notes to purge
+
+	// attachment configs
+	BlobStorage             string `env:"BLOB_STORAGE,local"`              // where attachments are stored: local, s3 or memory
+	BlobLocalDir            string `env:"BLOB_LOCAL_DIR,data/attachments"` // the directory of the local storage
+	S3Endpoint              string `env:"S3_ENDPOINT,localhost:9000"`      // the host and port of the S3 compatible storage, such as a local MinIO
+	S3Region                string `env:"S3_REGION,us-east-1"`
+	S3Bucket                string `env:"S3_BUCKET,quicknote"` // created on startup if it doesn't exist
+	S3AccessKey             string `env:"S3_ACCESS_KEY,"`
+	S3SecretKey             string `env:"S3_SECRET_KEY,"`
+	S3UseSSL                string `env:"S3_USE_SSL,false"`
+	AttachmentMaxSize       string `env:"ATTACHMENT_MAX_SIZE,10485760" kind:"bytes"`                                             // the maximum size in bytes of an attachment
+	AttachmentQuota         string `env:"ATTACHMENT_QUOTA,104857600" kind:"bytes"`                                               // how many bytes the attachments of each user can take
+	AttachmentTypes         string `env:"ATTACHMENT_TYPES,image/png image/jpeg image/gif image/webp application/pdf text/plain"` // the content types allowed as attachments, separated by spaces or commas
+	UploadMaxSize           string `env:"UPLOAD_MAX_SIZE,52428800" kind:"bytes"`                                                 // the maximum size in bytes of a request body, which bounds the uploads
+	AttachmentSweepInterval string `env:"ATTACHMENT_SWEEP_INTERVAL,1h" kind:"duration"`                                          // how often the blobs of the deleted attachments are looked for and deleted
+
+	// reminder configs
+	ReminderPollInterval string `env:"REMINDER_POLL_INTERVAL,1m" kind:"duration"` // how often the due reminders of the notes are looked for and mailed
+
+	// account configs
+	AccountDeletionGrace    string `env:"ACCOUNT_DELETION_GRACE,168h" kind:"duration"`  // how long after asking the account is deleted for good, signing in before cancels it
+	AccountDeletionInterval string `env:"ACCOUNT_DELETION_INTERVAL,1h" kind:"duration"` // how often the accounts due to be deleted are looked for
+
+	// account token configs
+	ConfirmationTokenTTL  string `env:"CONFIRMATION_TOKEN_TTL,24h" kind:"duration"`  // how long the link mailed to confirm the signup works
+	PasswordResetTokenTTL string `env:"PASSWORD_RESET_TOKEN_TTL,1h" kind:"duration"` // how long the link mailed to reset a forgotten password works
+	EmailChangeTokenTTL   string `env:"EMAIL_CHANGE_TOKEN_TTL,24h" kind:"duration"`  // how long the link mailed to confirm a new email works
+
+	// data export configs
+	DataExportTTL          string `env:"DATA_EXPORT_TTL,72h" kind:"duration"`          // how long the archives with the personal data of the users can be downloaded
+	DataExportPollInterval string `env:"DATA_EXPORT_POLL_INTERVAL,1m" kind:"duration"` // how often the archives asked for are looked for and generated
+
+	// collaborative editing configs
+	CollabSaveInterval string `env:"COLLAB_SAVE_INTERVAL,30s" kind:"duration"` // how often the notes edited together are saved, each save recording a revision
 }
 
 func (c Config) String() (vars string) {
@@ -49,7 +88,8 @@ func (c Config) String() (vars string) {
 // LoadFromEnv populates the Config struct fields with environment variables.
 // It reads the 'env' tag of each field to determine the environment variable name
 // and its default value. If an environment variable is not set and the default value
-// is marked as "required", it collects an error message.
+// is marked as "required", it collects an error message. The fields with a 'kind' tag
+// must hold a value of the kind, see [checkKind], or an error message naming the variable is collected too.
 func (c *Config) LoadFromEnv() error {
 	s := reflect.ValueOf(c)
 	st := reflect.TypeOf(*c)
@@ -60,16 +100,19 @@ func (c *Config) LoadFromEnv() error {
 		values := strings.Split(f.Tag.Get("env"), ",")
 		varName, varDefault := values[0], values[1]
 
-		envValue := os.Getenv(varName)
-		if envValue != "" {
-			s.Elem().Field(i).SetString(envValue)
-			continue
+		value := os.Getenv(varName)
+		if value == "" {
+			if varDefault == "required" {
+				errorMsgs = append(errorMsgs, fmt.Sprintf("%s is required", varName))
+				continue
+			}
+			value = varDefault
 		}
-		if varDefault == "required" {
-			errorMsgs = append(errorMsgs, fmt.Sprintf("%s is required", varName))
+		if err := checkKind(f.Tag.Get("kind"), value); err != nil {
+			errorMsgs = append(errorMsgs, fmt.Sprintf("%s %s, got %q", varName, err, value))
 			continue
 		}
-		s.Elem().Field(i).SetString(varDefault)
+		s.Elem().Field(i).SetString(value)
 	}
 	if len(errorMsgs) > 0 {
 		return errors.New(strings.Join(errorMsgs, "\n"))
@@ -77,6 +120,22 @@ func (c *Config) LoadFromEnv() error {
 	return nil
 }
 
+// checkKind checks that a value is of the kind of its field: a positive duration such as "1h30m" for "duration",
+// and a positive number of bytes for "bytes". Any value is of the empty kind.
+func checkKind(kind, value string) error {
+	switch kind {
+	case "duration":
+		if d, err := time.ParseDuration(value); err != nil || d <= 0 {
+			return errors.New("must be a positive duration, such as 90s or 1h30m")
+		}
+	case "bytes":
+		if n, err := strconv.ParseInt(value, 10, 64); err != nil || n <= 0 {
+			return errors.New("must be a positive number of bytes")
+		}
+	}
+	return nil
+}
+
 func (c Config) LoggerLevel() slog.Level {
 	switch c.LogLevel {
 	case "debug":
@@ -111,10 +170,114 @@ func (c Config) MailPortInt() int {
 	return p
 }
 
+func (c Config) TrashRetentionDuration() time.Duration {
+	return mustDuration("TRASH_RETENTION", c.TrashRetention)
+}
+
+func (c Config) TrashPurgeIntervalDuration() time.Duration {
+	return mustDuration("TRASH_PURGE_INTERVAL", c.TrashPurgeInterval)
+}
+
+func (c Config) AttachmentSweepIntervalDuration() time.Duration {
+	return mustDuration("ATTACHMENT_SWEEP_INTERVAL", c.AttachmentSweepInterval)
+}
+
+func (c Config) ReminderPollIntervalDuration() time.Duration {
+	return mustDuration("REMINDER_POLL_INTERVAL", c.ReminderPollInterval)
+}
+
+func (c Config) AccountDeletionGraceDuration() time.Duration {
+	return mustDuration("ACCOUNT_DELETION_GRACE", c.AccountDeletionGrace)
+}
+
+func (c Config) AccountDeletionIntervalDuration() time.Duration {
+	return mustDuration("ACCOUNT_DELETION_INTERVAL", c.AccountDeletionInterval)
+}
+
+func (c Config) ConfirmationTokenTTLDuration() time.Duration {
+	return mustDuration("CONFIRMATION_TOKEN_TTL", c.ConfirmationTokenTTL)
+}
+
+func (c Config) PasswordResetTokenTTLDuration() time.Duration {
+	return mustDuration("PASSWORD_RESET_TOKEN_TTL", c.PasswordResetTokenTTL)
+}
+
+func (c Config) EmailChangeTokenTTLDuration() time.Duration {
+	return mustDuration("EMAIL_CHANGE_TOKEN_TTL", c.EmailChangeTokenTTL)
+}
+
+func (c Config) DataExportTTLDuration() time.Duration {
+	return mustDuration("DATA_EXPORT_TTL", c.DataExportTTL)
+}
+
+func (c Config) DataExportPollIntervalDuration() time.Duration {
+	return mustDuration("DATA_EXPORT_POLL_INTERVAL", c.DataExportPollInterval)
+}
+
+func (c Config) CollabSaveIntervalDuration() time.Duration {
+	return mustDuration("COLLAB_SAVE_INTERVAL", c.CollabSaveInterval)
+}
+
+func (c Config) S3UseSSLBool() bool {
+	return c.S3UseSSL == "true"
+}
+
+func (c Config) AttachmentMaxSizeBytes() int64 {
+	return mustParseBytes("ATTACHMENT_MAX_SIZE", c.AttachmentMaxSize)
+}
+
+func (c Config) AttachmentQuotaBytes() int64 {
+	return mustParseBytes("ATTACHMENT_QUOTA", c.AttachmentQuota)
+}
+
+func (c Config) UploadMaxSizeBytes() int64 {
+	return mustParseBytes("UPLOAD_MAX_SIZE", c.UploadMaxSize)
+}
+
+func (c Config) AttachmentAllowedTypes() []string {
+	return strings.FieldsFunc(c.AttachmentTypes, func(r rune) bool {
+		return r == ',' || r == ' '
+	})
+}
+
+// mustDuration parses the duration set to the variable name, panicking with the name if it's not valid.
+// [Config.LoadFromEnv] already checked it, so it only panics for a Config filled otherwise.
+func mustDuration(name, value string) time.Duration {
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		panic(fmt.Errorf("%s: %w", name, err))
+	}
+	return d
+}
+
+// mustParseBytes parses the number of bytes set to the variable name, panicking with the name if it's not valid.
+func mustParseBytes(name, value string) int64 {
+	n, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		panic(fmt.Errorf("%s: %w", name, err))
+	}
+	return n
+}
+
 func (c Config) DebugMode() bool {
 	return c.Debug == "true"
 }
 
+// AppDomain returns the base URL of the app, which the links sent by mail point to.
+func (c Config) AppDomain() string {
+	scheme := "https"
+	if c.DebugMode() {
+		scheme = "http"
+	}
+
+	host := c.ServerHost
+	if c.DebugMode() {
+		host = "localhost"
+	}
+
+	return fmt.Sprintf("%s://%s:%s", scheme, host, c.ServerPort)
+}
+
 func MustLoadConfig() *Config {
 	if os.Getenv("GODOTENV") == "1" {
 		slog.Info("loading env vars from .env file")
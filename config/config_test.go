@@ -0,0 +1,58 @@
+package config
+
+import (
+	"strings"
+	"testing"
+)
+
+// setRequired sets the required variables, so only the ones under test can fail.
+func setRequired(t *testing.T) {
+	t.Helper()
+	for _, name := range []string{"SECRET_KEY", "DATABASE_URL", "MAIL_SERVER", "MAIL_PORT", "MAIL_USERNAME", "MAIL_PASSWORD", "MAIL_DEFAULT_FROM"} {
+		t.Setenv(name, "set")
+	}
+}
+
+func TestLoadFromEnvChecksKinds(t *testing.T) {
+	tests := []struct {
+		name    string
+		varName string
+		value   string
+		wantErr bool
+	}{
+		{"default", "TRASH_PURGE_INTERVAL", "", false},
+		{"positive duration", "TRASH_PURGE_INTERVAL", "90s", false},
+		{"zero duration", "TRASH_PURGE_INTERVAL", "0s", true},
+		{"negative duration", "REMINDER_POLL_INTERVAL", "-1m", true},
+		{"duration without unit", "COLLAB_SAVE_INTERVAL", "30", true},
+		{"positive bytes", "ATTACHMENT_QUOTA", "1048576", false},
+		{"zero bytes", "UPLOAD_MAX_SIZE", "0", true},
+		{"bytes with unit", "ATTACHMENT_MAX_SIZE", "10MB", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setRequired(t)
+			t.Setenv(tt.varName, tt.value)
+
+			var conf Config
+			err := conf.LoadFromEnv()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("LoadFromEnv() error = %v, want an error: %v", err, tt.wantErr)
+			}
+			if err != nil && !strings.Contains(err.Error(), tt.varName) {
+				t.Errorf("LoadFromEnv() error = %q, want it to name %s", err, tt.varName)
+			}
+		})
+	}
+}
+
+func TestLoadFromEnvDefaultsAreValid(t *testing.T) {
+	setRequired(t)
+	var conf Config
+	if err := conf.LoadFromEnv(); err != nil {
+		t.Fatalf("LoadFromEnv() error = %v", err)
+	}
+	if conf.TrashPurgeIntervalDuration() <= 0 || conf.AttachmentQuotaBytes() <= 0 {
+		t.Errorf("LoadFromEnv() defaults = %s and %d bytes, want positive ones", conf.TrashPurgeIntervalDuration(), conf.AttachmentQuotaBytes())
+	}
+}
@@ -0,0 +1,39 @@
+// Package blob provides a simple interface to store files, such as the note attachments.
+package blob
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"io"
+	"path"
+	"strings"
+)
+
+// ErrNotFound is returned when there is no blob stored under a key.
+var ErrNotFound = errors.New("blob not found")
+
+// BlobStore stores blobs under keys made of slash separated segments, such as "42/a1b2c3".
+type BlobStore interface {
+	Put(ctx context.Context, key string, r io.Reader, size int64, contentType string) error // stores the blob read from r, replacing any blob under the key
+	Get(ctx context.Context, key string) (io.ReadCloser, error)                             // returns [ErrNotFound] if there is no blob under the key
+	Delete(ctx context.Context, key string) error                                           // deleting a missing blob is not an error
+}
+
+// NewKey returns a new random key under the prefix.
+func NewKey(prefix string) string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return path.Join(prefix, hex.EncodeToString(b))
+}
+
+// VariantKey returns the key of a variant of the blob under key, such as a resized copy of an image.
+func VariantKey(key, variant string) string {
+	return key + "." + variant
+}
+
+// validKey reports whether the key is clean and relative, so it can't escape the store.
+func validKey(key string) bool {
+	return key != "" && path.Clean(key) == key && !path.IsAbs(key) && key != ".." && !strings.HasPrefix(key, "../")
+}
@@ -0,0 +1,169 @@
+package blob
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/minio/minio-go/v7"
+)
+
+// testS3EndpointEnv is the environment variable with the host and port of the S3 compatible storage,
+// such as a local MinIO, the S3 store is tested on. Its keys are read from TEST_S3_ACCESS_KEY and
+// TEST_S3_SECRET_KEY, TLS is used if TEST_S3_USE_SSL is "true", and the test is skipped if it's not set.
+const testS3EndpointEnv = "TEST_S3_ENDPOINT"
+
+// testBlobStore checks the behaviour every [BlobStore] must have on a new, empty store.
+func testBlobStore(t *testing.T, store BlobStore) {
+	ctx := context.Background()
+	prefix := NewKey("test")
+
+	put := func(t *testing.T, key, content string) {
+		t.Helper()
+		if err := store.Put(ctx, key, strings.NewReader(content), int64(len(content)), "text/plain"); err != nil {
+			t.Fatalf("Put(%q) error = %v", key, err)
+		}
+	}
+	get := func(t *testing.T, key string) string {
+		t.Helper()
+		rc, err := store.Get(ctx, key)
+		if err != nil {
+			t.Fatalf("Get(%q) error = %v", key, err)
+		}
+		defer rc.Close()
+		b, err := io.ReadAll(rc)
+		if err != nil {
+			t.Fatalf("reading %q: %v", key, err)
+		}
+		return string(b)
+	}
+	missing := func(t *testing.T, key string) {
+		t.Helper()
+		if rc, err := store.Get(ctx, key); !errors.Is(err, ErrNotFound) {
+			if rc != nil {
+				rc.Close()
+			}
+			t.Fatalf("Get(%q) error = %v, want ErrNotFound", key, err)
+		}
+	}
+
+	t.Run("put and get", func(t *testing.T) {
+		key := NewKey(prefix)
+		put(t, key, "hello")
+		if got := get(t, key); got != "hello" {
+			t.Errorf("Get() = %q, want %q", got, "hello")
+		}
+	})
+
+	t.Run("put replaces", func(t *testing.T) {
+		key := NewKey(prefix)
+		put(t, key, "first")
+		put(t, key, "second")
+		if got := get(t, key); got != "second" {
+			t.Errorf("Get() = %q, want %q", got, "second")
+		}
+	})
+
+	t.Run("empty blob", func(t *testing.T) {
+		key := NewKey(prefix)
+		put(t, key, "")
+		if got := get(t, key); got != "" {
+			t.Errorf("Get() = %q, want an empty blob", got)
+		}
+	})
+
+	t.Run("missing key", func(t *testing.T) {
+		missing(t, NewKey(prefix))
+	})
+
+	t.Run("delete", func(t *testing.T) {
+		key := NewKey(prefix)
+		put(t, key, "hello")
+		if err := store.Delete(ctx, key); err != nil {
+			t.Fatalf("Delete() error = %v", err)
+		}
+		missing(t, key)
+	})
+
+	t.Run("delete missing key", func(t *testing.T) {
+		if err := store.Delete(ctx, NewKey(prefix)); err != nil {
+			t.Errorf("Delete() error = %v, want nil", err)
+		}
+	})
+
+	t.Run("variants are blobs of their own", func(t *testing.T) {
+		key := NewKey(prefix)
+		variant := VariantKey(key, "thumb")
+		put(t, key, "original")
+		put(t, variant, "thumbnail")
+		if got := get(t, variant); got != "thumbnail" {
+			t.Errorf("Get(variant) = %q, want %q", got, "thumbnail")
+		}
+
+		if err := store.Delete(ctx, key); err != nil {
+			t.Fatalf("Delete() error = %v", err)
+		}
+		missing(t, key)
+		if got := get(t, variant); got != "thumbnail" {
+			t.Errorf("Get(variant) after deleting the original = %q, want %q", got, "thumbnail")
+		}
+	})
+}
+
+func TestMemoryStore(t *testing.T) {
+	testBlobStore(t, NewMemoryStore())
+}
+
+func TestLocalStore(t *testing.T) {
+	store, err := NewLocalStore(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewLocalStore() error = %v", err)
+	}
+	testBlobStore(t, store)
+}
+
+func TestLocalStoreRejectsEscapingKeys(t *testing.T) {
+	store, err := NewLocalStore(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewLocalStore() error = %v", err)
+	}
+
+	for _, key := range []string{"", "/etc/passwd", "..", "../x", "a/../../x", "a//b"} {
+		if err := store.Put(context.Background(), key, strings.NewReader("x"), 1, "text/plain"); err == nil {
+			t.Errorf("Put(%q) error = nil, want an invalid key error", key)
+		}
+	}
+}
+
+func TestS3Store(t *testing.T) {
+	endpoint := os.Getenv(testS3EndpointEnv)
+	if endpoint == "" {
+		t.Skipf("%s not set", testS3EndpointEnv)
+	}
+
+	// every run gets a bucket of its own, so the keys of other runs are never seen
+	bucket := strings.ReplaceAll(NewKey("quicknote-test"), "/", "-")[:40]
+	store, err := NewS3Store(context.Background(), S3Config{
+		Endpoint:  endpoint,
+		Region:    "us-east-1",
+		Bucket:    bucket,
+		AccessKey: os.Getenv("TEST_S3_ACCESS_KEY"),
+		SecretKey: os.Getenv("TEST_S3_SECRET_KEY"),
+		UseSSL:    os.Getenv("TEST_S3_USE_SSL") == "true",
+	})
+	if err != nil {
+		t.Fatalf("NewS3Store() error = %v", err)
+	}
+	t.Cleanup(func() {
+		ctx := context.Background()
+		for obj := range store.client.ListObjects(ctx, bucket, minio.ListObjectsOptions{Recursive: true}) {
+			store.client.RemoveObject(ctx, bucket, obj.Key, minio.RemoveObjectOptions{})
+		}
+		store.client.RemoveBucket(ctx, bucket)
+	})
+
+	testBlobStore(t, store)
+}
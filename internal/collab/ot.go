@@ -0,0 +1,317 @@
+// Package collab provides the collaborative editing of the note contents, merging the concurrent
+// edits of the people working on a note with operational transformation.
+//
+// Texts are measured in UTF-16 code units, as the browsers do, so the positions sent by them
+// can be used as they are.
+package collab
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"unicode/utf16"
+
+	"github.com/LeandroDeJesus-S/quicknote/internal/support/diff"
+)
+
+var (
+	ErrInvalidOperation = errors.New("invalid operation")
+	ErrBaseLength       = errors.New("the operation doesn't apply to a text of this length")
+)
+
+type componentKind int
+
+const (
+	retainComponent componentKind = iota
+	insertComponent
+	deleteComponent
+)
+
+// component is a step of an [Operation].
+type component struct {
+	kind componentKind
+	n    int    // how many code units are retained or deleted
+	text string // the text inserted
+}
+
+// Operation is an edit of a text, made of steps going through the whole text: retaining
+// characters, inserting text and deleting characters. Its zero value is the no-op of an empty text.
+//
+// It's encoded in JSON as a list where a positive number retains characters, a negative one
+// deletes them and a string is inserted, such as [3, "abc", -2].
+type Operation struct {
+	components []component
+	baseLen    int // the length of the text the operation applies to
+	targetLen  int // the length of the text after the operation is applied
+}
+
+// textLen returns the length of a text in UTF-16 code units.
+func textLen(s string) int {
+	n := 0
+	for _, r := range s {
+		n += utf16.RuneLen(r)
+	}
+	return n
+}
+
+// BaseLen returns the length of the texts the operation applies to.
+func (o *Operation) BaseLen() int {
+	return o.baseLen
+}
+
+// TargetLen returns the length of the texts resulting from the operation.
+func (o *Operation) TargetLen() int {
+	return o.targetLen
+}
+
+// IsNoop reports whether the operation leaves the text unchanged.
+func (o *Operation) IsNoop() bool {
+	return len(o.components) == 0 || (len(o.components) == 1 && o.components[0].kind == retainComponent)
+}
+
+func (o *Operation) last(back int) *component {
+	if len(o.components) < back {
+		return nil
+	}
+	return &o.components[len(o.components)-back]
+}
+
+// Retain adds the step of keeping the next n characters.
+func (o *Operation) Retain(n int) *Operation {
+	if n <= 0 {
+		return o
+	}
+	o.baseLen += n
+	o.targetLen += n
+	if last := o.last(1); last != nil && last.kind == retainComponent {
+		last.n += n
+		return o
+	}
+	o.components = append(o.components, component{kind: retainComponent, n: n})
+	return o
+}
+
+// Insert adds the step of inserting the text.
+// An insert following a delete is put before it, so equal operations are built the same.
+func (o *Operation) Insert(s string) *Operation {
+	if s == "" {
+		return o
+	}
+	o.targetLen += textLen(s)
+
+	last := o.last(1)
+	switch {
+	case last != nil && last.kind == insertComponent:
+		last.text += s
+	case last != nil && last.kind == deleteComponent:
+		if prev := o.last(2); prev != nil && prev.kind == insertComponent {
+			prev.text += s
+			return o
+		}
+		del := *last
+		*last = component{kind: insertComponent, text: s}
+		o.components = append(o.components, del)
+	default:
+		o.components = append(o.components, component{kind: insertComponent, text: s})
+	}
+	return o
+}
+
+// Delete adds the step of deleting the next n characters.
+func (o *Operation) Delete(n int) *Operation {
+	if n <= 0 {
+		return o
+	}
+	o.baseLen += n
+	if last := o.last(1); last != nil && last.kind == deleteComponent {
+		last.n += n
+		return o
+	}
+	o.components = append(o.components, component{kind: deleteComponent, n: n})
+	return o
+}
+
+// Apply applies the operation to the text, returning [ErrBaseLength] if it's not of the operation base length.
+func (o *Operation) Apply(text []uint16) ([]uint16, error) {
+	if len(text) != o.baseLen {
+		return nil, fmt.Errorf("%w: %d, want %d", ErrBaseLength, len(text), o.baseLen)
+	}
+
+	out := make([]uint16, 0, o.targetLen)
+	i := 0
+	for _, c := range o.components {
+		switch c.kind {
+		case retainComponent:
+			out = append(out, text[i:i+c.n]...)
+			i += c.n
+		case insertComponent:
+			out = append(out, utf16.Encode([]rune(c.text))...)
+		case deleteComponent:
+			i += c.n
+		}
+	}
+	return out, nil
+}
+
+// TransformIndex returns where a position of the text the operation applies to,
+// such as a cursor, is moved to by the operation.
+func (o *Operation) TransformIndex(index int) int {
+	moved := index
+	for _, c := range o.components {
+		switch c.kind {
+		case retainComponent:
+			index -= c.n
+		case insertComponent:
+			moved += textLen(c.text)
+		case deleteComponent:
+			moved -= min(index, c.n)
+			index -= c.n
+		}
+		if index < 0 {
+			break
+		}
+	}
+	return moved
+}
+
+// Transform transforms two concurrent operations a and b, made on the same text, into a' and b'
+// such that applying a then b' gives the same text as applying b then a'. When both insert at the
+// same position, the text inserted by a comes first.
+func Transform(a, b Operation) (Operation, Operation, error) {
+	var aPrime, bPrime Operation
+	if a.baseLen != b.baseLen {
+		return aPrime, bPrime, fmt.Errorf("%w: concurrent operations of texts of lengths %d and %d", ErrInvalidOperation, a.baseLen, b.baseLen)
+	}
+
+	as, bs := a.components, b.components
+	var ac, bc *component
+	next := func(cs *[]component) *component {
+		if len(*cs) == 0 {
+			return nil
+		}
+		c := (*cs)[0]
+		*cs = (*cs)[1:]
+		return &c
+	}
+	ac, bc = next(&as), next(&bs)
+
+	for ac != nil || bc != nil {
+		if ac != nil && ac.kind == insertComponent {
+			aPrime.Insert(ac.text)
+			bPrime.Retain(textLen(ac.text))
+			ac = next(&as)
+			continue
+		}
+		if bc != nil && bc.kind == insertComponent {
+			aPrime.Retain(textLen(bc.text))
+			bPrime.Insert(bc.text)
+			bc = next(&bs)
+			continue
+		}
+		if ac == nil || bc == nil {
+			return aPrime, bPrime, fmt.Errorf("%w: the operations are of different lengths", ErrInvalidOperation)
+		}
+
+		n := min(ac.n, bc.n)
+		switch {
+		case ac.kind == retainComponent && bc.kind == retainComponent:
+			aPrime.Retain(n)
+			bPrime.Retain(n)
+		case ac.kind == deleteComponent && bc.kind == retainComponent:
+			aPrime.Delete(n)
+		case ac.kind == retainComponent && bc.kind == deleteComponent:
+			bPrime.Delete(n)
+		}
+		// both deleting the same characters leaves nothing more to delete
+
+		ac.n -= n
+		bc.n -= n
+		if ac.n == 0 {
+			ac = next(&as)
+		}
+		if bc.n == 0 {
+			bc = next(&bs)
+		}
+	}
+	return aPrime, bPrime, nil
+}
+
+// Diff returns an operation turning oldText into newText, replacing the lines that differ as a whole.
+func Diff(oldText, newText string) Operation {
+	a, b := splitLines(oldText), splitLines(newText)
+
+	var op Operation
+	i, j := 0, 0
+	for _, step := range diff.Ops(a, b) {
+		switch step {
+		case diff.Equal:
+			op.Retain(textLen(a[i]))
+			i++
+			j++
+		case diff.Delete:
+			op.Delete(textLen(a[i]))
+			i++
+		case diff.Insert:
+			op.Insert(b[j])
+			j++
+		}
+	}
+	return op
+}
+
+// splitLines splits a text into lines, each keeping its line ending.
+func splitLines(text string) []string {
+	lines := strings.SplitAfter(text, "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
+
+// MarshalJSON implements the json.Marshaler interface.
+func (o Operation) MarshalJSON() ([]byte, error) {
+	steps := make([]any, 0, len(o.components))
+	for _, c := range o.components {
+		switch c.kind {
+		case retainComponent:
+			steps = append(steps, c.n)
+		case insertComponent:
+			steps = append(steps, c.text)
+		case deleteComponent:
+			steps = append(steps, -c.n)
+		}
+	}
+	return json.Marshal(steps)
+}
+
+// UnmarshalJSON implements the json.Unmarshaler interface.
+func (o *Operation) UnmarshalJSON(data []byte) error {
+	var steps []json.RawMessage
+	if err := json.Unmarshal(data, &steps); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidOperation, err)
+	}
+
+	*o = Operation{}
+	for _, step := range steps {
+		var text string
+		if err := json.Unmarshal(step, &text); err == nil {
+			if text == "" {
+				return fmt.Errorf("%w: empty insert", ErrInvalidOperation)
+			}
+			o.Insert(text)
+			continue
+		}
+
+		var n int
+		if err := json.Unmarshal(step, &n); err != nil || n == 0 {
+			return fmt.Errorf("%w: invalid step %s", ErrInvalidOperation, step)
+		}
+		if n > 0 {
+			o.Retain(n)
+		} else {
+			o.Delete(-n)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,64 @@
+package collab
+
+import (
+	"testing"
+	"unicode/utf16"
+)
+
+func TestDiff(t *testing.T) {
+	tests := []struct {
+		name     string
+		old, new string
+	}{
+		{"same", "a\nb\n", "a\nb\n"},
+		{"from empty", "", "a\nb"},
+		{"to empty", "a\nb", ""},
+		{"line changed", "a\nb\nc\n", "a\nB\nc\n"},
+		{"line added", "a\nc\n", "a\nb\nc\n"},
+		{"line removed", "a\nb\nc\n", "a\nc\n"},
+		{"last line without ending", "a\nb", "a\nb\n"},
+		{"windows endings", "a\r\nb\r\n", "a\r\nb\r\nc\r\n"},
+		{"surrogate pairs", "😀\nb\n", "😀😀\nb\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			op := Diff(tt.old, tt.new)
+			got, err := op.Apply(utf16.Encode([]rune(tt.old)))
+			if err != nil {
+				t.Fatalf("Apply() error = %v", err)
+			}
+			if string(utf16.Decode(got)) != tt.new {
+				t.Errorf("Diff() applied = %q, want %q", string(utf16.Decode(got)), tt.new)
+			}
+			if tt.old == tt.new && !op.IsNoop() {
+				t.Errorf("Diff() of equal texts is not a no-op")
+			}
+		})
+	}
+}
+
+func TestDiffMergesConcurrentChanges(t *testing.T) {
+	base := "one\ntwo\nthree\n"
+	a, b := Diff(base, "ONE\ntwo\nthree\n"), Diff(base, "one\ntwo\nTHREE\nfour\n")
+	aPrime, bPrime, err := Transform(a, b)
+	if err != nil {
+		t.Fatalf("Transform() error = %v", err)
+	}
+
+	text := utf16.Encode([]rune(base))
+	afterA, _ := a.Apply(text)
+	afterB, _ := b.Apply(text)
+	ab, err := bPrime.Apply(afterA)
+	if err != nil {
+		t.Fatalf("Apply(b') error = %v", err)
+	}
+	ba, err := aPrime.Apply(afterB)
+	if err != nil {
+		t.Fatalf("Apply(a') error = %v", err)
+	}
+
+	const want = "ONE\ntwo\nTHREE\nfour\n"
+	if string(utf16.Decode(ab)) != want || string(utf16.Decode(ba)) != want {
+		t.Errorf("merged = %q and %q, want %q", string(utf16.Decode(ab)), string(utf16.Decode(ba)), want)
+	}
+}
@@ -0,0 +1,473 @@
+package collab
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+	"unicode/utf16"
+
+	"github.com/LeandroDeJesus-S/quicknote/internal/models"
+	"github.com/LeandroDeJesus-S/quicknote/internal/repo"
+)
+
+const (
+	// historyLimit is how many of the latest operations of a document are kept to transform the
+	// late ones. A client whose edits are based on an older revision must start over.
+	historyLimit = 500
+
+	// maxTextLen is the maximum length of a document, in UTF-16 code units.
+	maxTextLen = 1 << 20
+
+	// clientBuffer is how many messages a client holds until they are sent.
+	// A client falling further behind is dropped, as it can't miss any operation.
+	clientBuffer = 256
+
+	// saveTimeout bounds how long saving a document may take.
+	saveTimeout = 10 * time.Second
+)
+
+var (
+	ErrStaleRevision = errors.New("the revision is no longer known, reload the note")
+	ErrTextTooLong   = errors.New("the note content is too long")
+)
+
+// The types of the messages exchanged with the clients.
+const (
+	MsgInit   = "init"   // sent to a client joining, with the document, its revision and the other clients
+	MsgOp     = "op"     // an operation, sent by a client based on the revision it knows, then to the others
+	MsgAck    = "ack"    // sent to a client when its operation was applied, with the new revision
+	MsgCursor = "cursor" // the selection of a client, sent by it based on the revision it knows, then to the others
+	MsgJoin   = "join"   // sent to the clients when someone joins
+	MsgLeave  = "leave"  // sent to the clients when someone leaves
+	MsgSaved  = "saved"  // sent to the clients when the document was saved, with the note version
+	MsgError  = "error"  // sent to a client before it's dropped
+)
+
+// Cursor is the selection of a client, from Anchor to Head. Both are the same for a plain cursor.
+type Cursor struct {
+	Anchor int `json:"anchor"`
+	Head   int `json:"head"`
+}
+
+// transform moves the cursor through the operation.
+func (c Cursor) transform(op *Operation) Cursor {
+	return Cursor{Anchor: op.TransformIndex(c.Anchor), Head: op.TransformIndex(c.Head)}
+}
+
+// clamp keeps the cursor within a text of the given length.
+func (c Cursor) clamp(length int) Cursor {
+	return Cursor{Anchor: max(0, min(c.Anchor, length)), Head: max(0, min(c.Head, length))}
+}
+
+// Peer is a client working on the same document.
+type Peer struct {
+	Client int     `json:"client"`
+	Email  string  `json:"email"`
+	Cursor *Cursor `json:"cursor,omitempty"`
+}
+
+// Message is a message exchanged with the clients. Each type uses some of the fields.
+type Message struct {
+	Type    string     `json:"type"`
+	Rev     int        `json:"rev"`
+	Op      *Operation `json:"op,omitempty"`
+	Client  int        `json:"client,omitempty"`
+	Email   string     `json:"email,omitempty"`
+	Cursor  *Cursor    `json:"cursor,omitempty"`
+	Doc     string     `json:"doc,omitempty"`
+	Peers   []Peer     `json:"peers,omitempty"`
+	Version int        `json:"version,omitempty"`
+	Error   string     `json:"error,omitempty"`
+}
+
+// NoteStore is what the collaborative editing needs from the notes repository.
+type NoteStore interface {
+	ReadOne(ctx context.Context, scope repo.Scope, id int) (*models.Note, error)
+	Update(ctx context.Context, scope repo.Scope, id, version int, data map[string]any) (*models.Note, error)
+}
+
+// Server keeps the documents being edited, one for each note with someone working on it.
+// The documents live in the memory of the app instance, so the people working on a note together
+// must be served by the same instance.
+type Server struct {
+	notes        NoteStore
+	saveInterval time.Duration
+	onSave       func(ctx context.Context, scope repo.Scope, noteID int)
+
+	mu   sync.Mutex
+	docs map[int]*document
+}
+
+// NewServer creates a new Server saving the edited documents to the notes on every interval.
+func NewServer(notes NoteStore, saveInterval time.Duration) *Server {
+	return &Server{notes: notes, saveInterval: saveInterval, docs: make(map[int]*document)}
+}
+
+// OnSave sets a function called whenever a document is saved to its note, the scope being the one of the
+// note's workspace with the last person who edited it.
+func (s *Server) OnSave(fn func(ctx context.Context, scope repo.Scope, noteID int)) *Server {
+	s.onSave = fn
+	return s
+}
+
+// Join adds a client to the document of the note, loading it from the note if nobody is working on it yet.
+// The scope must be the one of the note's workspace, with the user joining, who must be allowed to edit it.
+// Returns [repo.ErrNoteNotFound] if the note is not in the workspace.
+func (s *Server) Join(ctx context.Context, scope repo.Scope, noteID int, email string) (*Client, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	doc, ok := s.docs[noteID]
+	if !ok {
+		note, err := s.notes.ReadOne(ctx, scope, noteID)
+		if err != nil {
+			return nil, err
+		}
+
+		doc = &document{
+			server:  s,
+			noteID:  noteID,
+			scope:   scope,
+			text:    utf16.Encode([]rune(note.Content.String)),
+			saved:   note.Content.String,
+			version: int(note.Version.Int32),
+			clients: make(map[int]*Client),
+			flush:   make(chan struct{}, 1),
+		}
+		s.docs[noteID] = doc
+		go doc.run()
+	}
+	return doc.join(scope.UserID, email), nil
+}
+
+// Client is a person working on a document through a connection.
+type Client struct {
+	ID     int
+	UserID int64
+	Email  string
+	doc    *document
+	cursor *Cursor
+	send   chan Message
+	closed bool
+}
+
+// Messages returns the channel of the messages to send to the client,
+// closed when it leaves or is dropped, after which its connection should be closed.
+func (c *Client) Messages() <-chan Message {
+	return c.send
+}
+
+// Handle handles a message received from the client. An error means the client is out of
+// sync with the document and must leave, after being sent the error.
+func (c *Client) Handle(msg Message) error {
+	switch msg.Type {
+	case MsgOp:
+		if msg.Op == nil {
+			return fmt.Errorf("%w: missing operation", ErrInvalidOperation)
+		}
+		return c.doc.apply(c, msg.Rev, *msg.Op)
+	case MsgCursor:
+		if msg.Cursor == nil {
+			return errors.New("missing cursor")
+		}
+		return c.doc.moveCursor(c, msg.Rev, *msg.Cursor)
+	}
+	return fmt.Errorf("unknown message type: %q", msg.Type)
+}
+
+// Leave removes the client from the document. It's safe to call it more than once.
+func (c *Client) Leave() {
+	c.doc.leave(c)
+}
+
+// document is the content of a note being edited, with the operations that led to it.
+type document struct {
+	server *Server
+	noteID int
+	flush  chan struct{} // asks for the document to be saved right away
+
+	mu       sync.Mutex
+	scope    repo.Scope // the scope of the note's workspace, with the last person who edited it
+	text     []uint16
+	rev      int         // the revision of text, counting the operations applied
+	history  []Operation // the latest operations, the last one leading to rev
+	version  int         // the note version the document was last saved as
+	saved    string      // the note content of version, which the document was based on
+	dirty    bool        // whether the document changed since it was last saved
+	clients  map[int]*Client
+	lastID   int
+	finished bool // whether the note can't be saved anymore
+}
+
+// join adds a client and tells it and the others about one another.
+func (d *document) join(userID int64, email string) *Client {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.lastID++
+	c := &Client{ID: d.lastID, UserID: userID, Email: email, doc: d, send: make(chan Message, clientBuffer)}
+
+	peers := make([]Peer, 0, len(d.clients))
+	for _, other := range d.clients {
+		peers = append(peers, Peer{Client: other.ID, Email: other.Email, Cursor: other.cursor})
+	}
+	d.broadcast(c, Message{Type: MsgJoin, Client: c.ID, Email: email})
+
+	d.clients[c.ID] = c
+	d.sendTo(c, Message{Type: MsgInit, Rev: d.rev, Client: c.ID, Doc: string(utf16.Decode(d.text)), Peers: peers})
+	return c
+}
+
+// leave removes a client, saving the document right away if it was the last one.
+func (d *document) leave(c *Client) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if _, ok := d.clients[c.ID]; !ok {
+		return
+	}
+	d.drop(c)
+	d.broadcast(nil, Message{Type: MsgLeave, Client: c.ID})
+
+	if len(d.clients) == 0 {
+		select {
+		case d.flush <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// drop removes a client and closes its messages. The document must be locked.
+func (d *document) drop(c *Client) {
+	delete(d.clients, c.ID)
+	if !c.closed {
+		c.closed = true
+		close(c.send)
+	}
+}
+
+// sendTo sends a message to a client, dropping it if it fell too far behind. The document must be locked.
+func (d *document) sendTo(c *Client, msg Message) {
+	select {
+	case c.send <- msg:
+	default:
+		slog.Warn("collaborative editing client dropped for falling behind", "note_id", d.noteID, "client", c.ID)
+		d.drop(c)
+	}
+}
+
+// broadcast sends a message to every client but one. The document must be locked.
+func (d *document) broadcast(except *Client, msg Message) {
+	for _, c := range d.clients {
+		if c != except {
+			d.sendTo(c, msg)
+		}
+	}
+}
+
+// since returns the operations applied after the revision.
+func (d *document) since(rev int) ([]Operation, error) {
+	first := d.rev - len(d.history)
+	if rev < first || rev > d.rev {
+		return nil, fmt.Errorf("%w: %d", ErrStaleRevision, rev)
+	}
+	return d.history[rev-first:], nil
+}
+
+// apply transforms an operation of a client, based on the revision it knew, against the operations
+// applied since then, applies it and sends it to the other clients.
+func (d *document) apply(c *Client, rev int, op Operation) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.finished {
+		return repo.ErrNoteNotFound
+	}
+	concurrent, err := d.since(rev)
+	if err != nil {
+		return err
+	}
+	for _, other := range concurrent {
+		if op, _, err = Transform(op, other); err != nil {
+			return err
+		}
+	}
+	if op.TargetLen() > maxTextLen {
+		return ErrTextTooLong
+	}
+
+	text, err := op.Apply(d.text)
+	if err != nil {
+		return err
+	}
+
+	d.text = text
+	d.rev++
+	d.history = append(d.history, op)
+	if len(d.history) > historyLimit {
+		d.history = d.history[len(d.history)-historyLimit:]
+	}
+	d.dirty = true
+	d.scope.UserID = c.UserID
+
+	for _, other := range d.clients {
+		if other.cursor != nil {
+			moved := other.cursor.transform(&op)
+			other.cursor = &moved
+		}
+	}
+
+	d.sendTo(c, Message{Type: MsgAck, Rev: d.rev})
+	d.broadcast(c, Message{Type: MsgOp, Rev: d.rev, Op: &op, Client: c.ID})
+	return nil
+}
+
+// moveCursor transforms the cursor of a client, based on the revision it knew, and sends it to the other clients.
+func (d *document) moveCursor(c *Client, rev int, cursor Cursor) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	concurrent, err := d.since(rev)
+	if err != nil {
+		return err
+	}
+	for i := range concurrent {
+		cursor = cursor.transform(&concurrent[i])
+	}
+	cursor = cursor.clamp(len(d.text))
+
+	c.cursor = &cursor
+	d.broadcast(c, Message{Type: MsgCursor, Rev: d.rev, Client: c.ID, Email: c.Email, Cursor: &cursor})
+	return nil
+}
+
+// run saves the document on every interval, or as soon as the last client leaves, until nobody is working on it.
+func (d *document) run() {
+	ticker := time.NewTicker(d.server.saveInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+		case <-d.flush:
+		}
+
+		d.save()
+		if d.closeIfIdle() {
+			return
+		}
+	}
+}
+
+// closeIfIdle forgets the document if nobody is working on it and it has no unsaved changes, reporting whether it did.
+func (d *document) closeIfIdle() bool {
+	d.server.mu.Lock()
+	defer d.server.mu.Unlock()
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if len(d.clients) > 0 || (d.dirty && !d.finished) {
+		return false
+	}
+	delete(d.server.docs, d.noteID)
+	return true
+}
+
+// save saves the document to the note if it changed. When the note was changed out of the collaborative
+// editing meanwhile, the document is rebased on it instead, merging both changes, and saved on the next try.
+func (d *document) save() {
+	d.mu.Lock()
+	if !d.dirty || d.finished {
+		d.mu.Unlock()
+		return
+	}
+	scope, version, content := d.scope, d.version, string(utf16.Decode(d.text))
+	d.dirty = false
+	d.mu.Unlock()
+
+	ctx, cancel := context.WithTimeout(context.Background(), saveTimeout)
+	defer cancel()
+
+	note, err := d.server.notes.Update(ctx, scope, d.noteID, version, map[string]any{"content": content})
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	var conflict *repo.NoteConflictError
+	if errors.As(err, &conflict) {
+		slog.Info("note changed out of the collaborative editing, merging the changes", "note_id", d.noteID, "version", conflict.Current.Version.Int32)
+		d.dirty = true
+		if err := d.rebase(conflict.Current); err != nil {
+			slog.Error("failed to merge the changes made to a note out of the collaborative editing", "err", err, "note_id", d.noteID)
+			return
+		}
+		// the merged document is saved right away, before more changes are made to the note
+		select {
+		case d.flush <- struct{}{}:
+		default:
+		}
+		return
+	}
+	if errors.Is(err, repo.ErrNoteNotFound) {
+		// the note was moved to the trash or the user lost access to it
+		d.finished = true
+		for _, c := range d.clients {
+			d.sendTo(c, Message{Type: MsgError, Error: "this note was deleted"})
+			d.drop(c)
+		}
+		return
+	}
+	if err != nil {
+		slog.Error("failed to save the collaborative editing of a note", "err", err, "note_id", d.noteID)
+		d.dirty = true
+		return
+	}
+
+	d.version = int(note.Version.Int32)
+	d.saved = content
+	d.broadcast(nil, Message{Type: MsgSaved, Rev: d.rev, Version: d.version})
+	if d.server.onSave != nil {
+		go d.server.onSave(context.Background(), scope, d.noteID)
+	}
+}
+
+// rebase bases the document on a newer version of its note, applying the changes made to the note since
+// the version the document was based on as an operation of the server, which is sent to the clients.
+// The document must be locked.
+func (d *document) rebase(note *models.Note) error {
+	theirs := Diff(d.saved, note.Content.String)
+	ours := Diff(d.saved, string(utf16.Decode(d.text)))
+	_, op, err := Transform(ours, theirs)
+	if err != nil {
+		return err
+	}
+
+	d.version = int(note.Version.Int32)
+	d.saved = note.Content.String
+	if op.IsNoop() {
+		return nil
+	}
+
+	text, err := op.Apply(d.text)
+	if err != nil {
+		return err
+	}
+	d.text = text
+	d.rev++
+	d.history = append(d.history, op)
+	if len(d.history) > historyLimit {
+		d.history = d.history[len(d.history)-historyLimit:]
+	}
+
+	for _, c := range d.clients {
+		if c.cursor != nil {
+			moved := c.cursor.transform(&op)
+			c.cursor = &moved
+		}
+	}
+	d.broadcast(nil, Message{Type: MsgOp, Rev: d.rev, Op: &op})
+	return nil
+}
@@ -0,0 +1,276 @@
+package collab
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"sync"
+	"testing"
+	"time"
+	"unicode/utf16"
+
+	"github.com/LeandroDeJesus-S/quicknote/internal/models"
+	"github.com/LeandroDeJesus-S/quicknote/internal/repo"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// noteStore is a [NoteStore] of a single note, checking its version like the repository does.
+type noteStore struct {
+	mu      sync.Mutex
+	content string
+	version int32
+}
+
+func (s *noteStore) note() *models.Note {
+	return &models.Note{Content: pgtype.Text{String: s.content, Valid: true}, Version: pgtype.Int4{Int32: s.version, Valid: true}}
+}
+
+func (s *noteStore) ReadOne(ctx context.Context, scope repo.Scope, id int) (*models.Note, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.note(), nil
+}
+
+func (s *noteStore) Update(ctx context.Context, scope repo.Scope, id, version int, data map[string]any) (*models.Note, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if version != 0 && int32(version) != s.version {
+		return nil, &repo.NoteConflictError{Current: s.note()}
+	}
+	s.content = data["content"].(string)
+	s.version++
+	return s.note(), nil
+}
+
+// set changes the note as if it was edited out of the collaborative editing.
+func (s *noteStore) set(content string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.content = content
+	s.version++
+}
+
+func (s *noteStore) get() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.content
+}
+
+// editor works on a document like the browsers do: its own operations are applied right away and
+// sent one at a time, the next one once the previous is acknowledged, while the operations of the
+// others are transformed against the ones not acknowledged yet.
+type editor struct {
+	t      *testing.T
+	client *Client
+	out    chan Message
+
+	mu       sync.Mutex
+	text     []uint16
+	rev      int
+	pending  []Operation // the operations not acknowledged yet, the first one sent
+	inflight bool
+}
+
+func newEditor(t *testing.T, srv *Server, userID int64) *editor {
+	t.Helper()
+	c, err := srv.Join(context.Background(), repo.Scope{WorkspaceID: 1, UserID: userID}, 7, fmt.Sprintf("user%d@example.com", userID))
+	if err != nil {
+		t.Fatalf("Join() error = %v", err)
+	}
+	e := &editor{t: t, client: c, out: make(chan Message, clientBuffer)}
+	e.receive(<-c.Messages())
+	return e
+}
+
+// sendNext sends the first pending operation unless one is waiting to be acknowledged. The editor must be locked.
+func (e *editor) sendNext() {
+	if e.inflight || len(e.pending) == 0 {
+		return
+	}
+	e.inflight = true
+	op := e.pending[0]
+	e.out <- Message{Type: MsgOp, Rev: e.rev, Op: &op}
+}
+
+// edit makes a random edit of the text.
+func (e *editor) edit(r *rand.Rand) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	var op Operation
+	n := len(e.text)
+	pos := r.Intn(n + 1)
+	op.Retain(pos)
+	del := 0
+	if n > pos && r.Intn(3) == 0 {
+		del = r.Intn(min(5, n-pos)) + 1
+		op.Delete(del)
+	}
+	if del == 0 || r.Intn(2) == 0 {
+		words := []string{"a", "bc", "déf", "😀", "\n", "xyz "}
+		op.Insert(words[r.Intn(len(words))])
+	}
+	op.Retain(n - pos - del)
+
+	text, err := op.Apply(e.text)
+	if err != nil {
+		e.t.Errorf("applying own operation: %v", err)
+		return
+	}
+	e.text = text
+	e.pending = append(e.pending, op)
+	e.sendNext()
+}
+
+func (e *editor) receive(msg Message) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	switch msg.Type {
+	case MsgInit:
+		e.rev = msg.Rev
+		e.text = utf16.Encode([]rune(msg.Doc))
+	case MsgAck:
+		e.rev = msg.Rev
+		e.pending = e.pending[1:]
+		e.inflight = false
+		e.sendNext()
+	case MsgOp:
+		e.rev = msg.Rev
+		op := *msg.Op
+		for i := range e.pending {
+			var err error
+			if e.pending[i], op, err = Transform(e.pending[i], op); err != nil {
+				e.t.Errorf("transforming pending operation: %v", err)
+				return
+			}
+		}
+		text, err := op.Apply(e.text)
+		if err != nil {
+			e.t.Errorf("applying operation of another client: %v", err)
+			return
+		}
+		e.text = text
+	case MsgError:
+		e.t.Errorf("client %d dropped: %s", e.client.ID, msg.Error)
+	}
+}
+
+// connect passes the messages between the editor and the server with random delays, as a network would.
+func (e *editor) connect(seed int64) {
+	delay := func(r *rand.Rand) { time.Sleep(time.Duration(r.Intn(1000)) * time.Microsecond) }
+	go func() {
+		r := rand.New(rand.NewSource(seed))
+		for msg := range e.out {
+			delay(r)
+			if err := e.client.Handle(msg); err != nil {
+				e.t.Errorf("Handle() error = %v", err)
+			}
+		}
+	}()
+	go func() {
+		r := rand.New(rand.NewSource(seed + 1))
+		for msg := range e.client.Messages() {
+			delay(r)
+			e.receive(msg)
+		}
+	}()
+}
+
+func (e *editor) state() (text string, pending int) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return string(utf16.Decode(e.text)), len(e.pending)
+}
+
+// eventually waits for cond to hold, failing the test if it doesn't within a few seconds.
+func eventually(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); time.Sleep(10 * time.Millisecond) {
+		if cond() {
+			return
+		}
+	}
+	t.Fatalf("timed out waiting for %s", what)
+}
+
+func TestEditorsConverge(t *testing.T) {
+	const (
+		editors = 5
+		edits   = 100
+	)
+	store := &noteStore{content: "Meeting notes\n", version: 1}
+	srv := NewServer(store, 20*time.Millisecond)
+	seeds := rand.New(rand.NewSource(1))
+
+	var (
+		all []*editor
+		wg  sync.WaitGroup
+	)
+	for i := range editors {
+		e := newEditor(t, srv, int64(i+1))
+		e.connect(seeds.Int63())
+		all = append(all, e)
+
+		r := rand.New(rand.NewSource(seeds.Int63()))
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for range edits {
+				e.edit(r)
+				time.Sleep(time.Duration(r.Intn(500)) * time.Microsecond)
+			}
+		}()
+	}
+	wg.Wait()
+
+	eventually(t, "the edits to be acknowledged", func() bool {
+		want, _ := all[0].state()
+		for _, e := range all {
+			if text, pending := e.state(); pending > 0 || text != want {
+				return false
+			}
+		}
+		return true
+	})
+	want, _ := all[0].state()
+
+	for _, e := range all {
+		e.client.Leave()
+	}
+	eventually(t, "the document to be saved", func() bool { return store.get() == want })
+}
+
+func TestSaveMergesChangesMadeOutOfTheEditing(t *testing.T) {
+	store := &noteStore{content: "one\ntwo\nthree\n", version: 1}
+	srv := NewServer(store, time.Hour)
+	e := newEditor(t, srv, 1)
+
+	op := Diff("one\ntwo\nthree\n", "one\ntwo\nthree\nfour\n")
+	if err := e.client.Handle(Message{Type: MsgOp, Rev: 0, Op: &op}); err != nil {
+		t.Fatalf("Handle() error = %v", err)
+	}
+	e.pending, e.inflight = []Operation{op}, true
+	e.text, _ = op.Apply(e.text)
+	e.receive(<-e.client.Messages())
+
+	store.set("ONE\ntwo\nthree\n")
+	e.client.doc.save()
+
+	msg := <-e.client.Messages()
+	if msg.Type != MsgOp {
+		t.Fatalf("message after the conflict = %q, want the changes out of the editing", msg.Type)
+	}
+	e.receive(msg)
+	const want = "ONE\ntwo\nthree\nfour\n"
+	if text, _ := e.state(); text != want {
+		t.Errorf("editor text = %q, want %q", text, want)
+	}
+
+	// the merged document is saved right away
+	eventually(t, "the merged document to be saved", func() bool { return store.get() == want })
+	if msg := <-e.client.Messages(); msg.Type != MsgSaved || msg.Version != 3 {
+		t.Errorf("message after saving = %+v, want saved as version 3", msg)
+	}
+	e.client.Leave()
+}
@@ -0,0 +1,37 @@
+// Package events provides the streaming of the changes made to notes to the users who can access them,
+// within the app instance and across the instances sharing the database.
+package events
+
+import "time"
+
+// NoteEventType is the kind of change made to a note.
+type NoteEventType string
+
+const (
+	NoteCreated NoteEventType = "note.created" // a note was created or taken out of the trash
+	NoteUpdated NoteEventType = "note.updated" // a note was edited, moved or had a checklist item toggled
+	NoteDeleted NoteEventType = "note.deleted" // a note was moved to the trash
+)
+
+// NoteEvent is a change made to a note, seen by the members of its workspace and the users it's shared with.
+type NoteEvent struct {
+	Type        NoteEventType `json:"type"`
+	NoteID      int64         `json:"note_id"`
+	WorkspaceID int64         `json:"workspace_id"`
+	SharedWith  []int64       `json:"shared_with,omitempty"` // the users the note is shared with out of its workspace
+	UserID      int64         `json:"user_id"`               // who made the change
+	At          time.Time     `json:"at"`
+}
+
+// VisibleTo reports whether the user, working on the workspace, can access the note of the event.
+func (e NoteEvent) VisibleTo(workspaceID, userID int64) bool {
+	if e.WorkspaceID == workspaceID {
+		return true
+	}
+	for _, id := range e.SharedWith {
+		if id == userID {
+			return true
+		}
+	}
+	return false
+}
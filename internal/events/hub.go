@@ -0,0 +1,102 @@
+package events
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+)
+
+// subscriptionBuffer is how many events a subscription holds until its subscriber reads them.
+// The events sent to a subscriber that falls further behind are dropped.
+const subscriptionBuffer = 32
+
+// Relay sends the events published in the hub to the other app instances.
+type Relay interface {
+	Relay(ctx context.Context, e NoteEvent) error
+}
+
+// Subscription receives the events of a hub matching its filter, until it's closed.
+type Subscription struct {
+	events chan NoteEvent
+	filter func(NoteEvent) bool
+	hub    *Hub
+}
+
+// Events returns the channel the events are received on, closed along with the subscription.
+func (s *Subscription) Events() <-chan NoteEvent {
+	return s.events
+}
+
+// Close stops receiving events. It's safe to call it more than once.
+func (s *Subscription) Close() {
+	s.hub.unsubscribe(s)
+}
+
+// Hub is an in-process pub/sub of note events. The events published in it are delivered to its
+// subscribers and, if it has a [Relay], sent to the other app instances.
+type Hub struct {
+	mu    sync.RWMutex
+	subs  map[*Subscription]struct{}
+	relay Relay
+}
+
+// NewHub creates a new Hub delivering the events to its own subscribers only.
+func NewHub() *Hub {
+	return &Hub{subs: make(map[*Subscription]struct{})}
+}
+
+// WithRelay sets the relay sending the published events to the other app instances.
+func (h *Hub) WithRelay(relay Relay) *Hub {
+	h.relay = relay
+	return h
+}
+
+// Subscribe creates a subscription to the events matching the filter.
+func (h *Hub) Subscribe(filter func(NoteEvent) bool) *Subscription {
+	s := &Subscription{events: make(chan NoteEvent, subscriptionBuffer), filter: filter, hub: h}
+
+	h.mu.Lock()
+	h.subs[s] = struct{}{}
+	h.mu.Unlock()
+	return s
+}
+
+func (h *Hub) unsubscribe(s *Subscription) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if _, ok := h.subs[s]; ok {
+		delete(h.subs, s)
+		close(s.events)
+	}
+}
+
+// Publish delivers the event to the subscribers and relays it to the other app instances.
+// A failure to relay is logged, the event still being delivered within the instance.
+func (h *Hub) Publish(ctx context.Context, e NoteEvent) {
+	h.Deliver(e)
+
+	if h.relay == nil {
+		return
+	}
+	if err := h.relay.Relay(ctx, e); err != nil {
+		slog.Error("failed to relay the note event", "err", err, "type", e.Type, "note_id", e.NoteID)
+	}
+}
+
+// Deliver delivers the event to the subscribers of the instance only, as the events relayed by other instances.
+func (h *Hub) Deliver(e NoteEvent) {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	for s := range h.subs {
+		if s.filter != nil && !s.filter(e) {
+			continue
+		}
+		select {
+		case s.events <- e:
+		default:
+			slog.Warn("note event dropped for a slow subscriber", "type", e.Type, "note_id", e.NoteID)
+		}
+	}
+}
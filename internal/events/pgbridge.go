@@ -0,0 +1,115 @@
+package events
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"log/slog"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+const (
+	// pgChannel is the Postgres channel the note events are notified on.
+	pgChannel = "note_events"
+
+	// pgRetryDelay is how long the bridge waits to listen again after losing its connection.
+	pgRetryDelay = 5 * time.Second
+)
+
+// pgMessage is the payload of the notifications, telling the instance it came from.
+type pgMessage struct {
+	Origin string    `json:"origin"`
+	Event  NoteEvent `json:"event"`
+}
+
+// PGBridge fans out the note events across the app instances through Postgres LISTEN/NOTIFY.
+// It relays the events published in the hub of its instance and delivers to it the ones published by the others.
+type PGBridge struct {
+	db     *pgxpool.Pool
+	hub    *Hub
+	origin string
+}
+
+// NewPGBridge creates a new PGBridge and sets it as the relay of the hub.
+func NewPGBridge(db *pgxpool.Pool, hub *Hub) *PGBridge {
+	b := &PGBridge{db: db, hub: hub, origin: newOrigin()}
+	hub.WithRelay(b)
+	return b
+}
+
+// newOrigin returns a random ID telling the notifications of the instance apart.
+func newOrigin() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// Relay notifies the other instances of the event.
+func (b *PGBridge) Relay(ctx context.Context, e NoteEvent) error {
+	payload, err := json.Marshal(pgMessage{Origin: b.origin, Event: e})
+	if err != nil {
+		return err
+	}
+
+	_, err = b.db.Exec(ctx, "SELECT pg_notify($1, $2)", pgChannel, string(payload))
+	return err
+}
+
+// Run listens to the events notified by the other instances and delivers them to the hub, until ctx is done.
+// It listens again whenever the connection is lost, the events notified meanwhile being missed.
+func (b *PGBridge) Run(ctx context.Context) {
+	slog.Info("note events bridge started", "channel", pgChannel)
+
+	for {
+		err := b.listen(ctx)
+		if ctx.Err() != nil {
+			slog.Info("note events bridge stopped")
+			return
+		}
+		slog.Error("the note events bridge lost its connection", "err", err, "retry_in", pgRetryDelay)
+
+		select {
+		case <-ctx.Done():
+			slog.Info("note events bridge stopped")
+			return
+		case <-time.After(pgRetryDelay):
+		}
+	}
+}
+
+// listen delivers the notified events to the hub until the connection fails or ctx is done.
+func (b *PGBridge) listen(ctx context.Context) error {
+	conn, err := b.db.Acquire(ctx)
+	if err != nil {
+		return err
+	}
+	// the connection is closed rather than given back to the pool still listening
+	defer func() {
+		conn.Conn().Close(context.Background())
+		conn.Release()
+	}()
+
+	if _, err := conn.Exec(ctx, "LISTEN "+pgChannel); err != nil {
+		return err
+	}
+
+	for {
+		n, err := conn.Conn().WaitForNotification(ctx)
+		if err != nil {
+			return err
+		}
+
+		var msg pgMessage
+		if err := json.Unmarshal([]byte(n.Payload), &msg); err != nil {
+			slog.Error("invalid note event notified", "err", err, "payload", n.Payload)
+			continue
+		}
+		if msg.Origin == b.origin {
+			continue
+		}
+		b.hub.Deliver(msg.Event)
+	}
+}
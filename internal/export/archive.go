@@ -0,0 +1,155 @@
+// Package export writes the archives with the personal data of the users.
+package export
+
+import (
+	"archive/zip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/LeandroDeJesus-S/quicknote/internal/models"
+)
+
+// maxSlugLen is the maximum length of the title part of the note file names.
+const maxSlugLen = 50
+
+// tokens is the content of the tokens file of an archive.
+type tokens struct {
+	APITokens     []models.APIToken     `json:"api_tokens"`
+	AccountTokens []models.AccountToken `json:"account_tokens"`
+	CalendarFeed  *models.CalendarFeed  `json:"calendar_feed"`
+	TwoFactor     *models.UserTOTP      `json:"two_factor"`
+}
+
+// WriteArchive writes the zip archive of the personal data of a user, with the profile, the notes,
+// the tokens and the sessions as JSON files, and every note as a markdown file in the notes folder.
+func WriteArchive(w io.Writer, data *models.PersonalData, now time.Time) error {
+	zw := zip.NewWriter(w)
+
+	files := []struct {
+		name string
+		v    any
+	}{
+		{"profile.json", data.Profile},
+		{"notes.json", nonNil(data.Notes)},
+		{"tokens.json", tokens{
+			APITokens:     nonNil(data.APITokens),
+			AccountTokens: nonNil(data.AccountTokens),
+			CalendarFeed:  data.CalendarFeed,
+			TwoFactor:     data.TwoFactor,
+		}},
+		{"sessions.json", nonNil(data.Sessions)},
+	}
+	for _, f := range files {
+		fw, err := zw.CreateHeader(&zip.FileHeader{Name: f.name, Method: zip.Deflate, Modified: now})
+		if err != nil {
+			return err
+		}
+		enc := json.NewEncoder(fw)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(f.v); err != nil {
+			return fmt.Errorf("failed to encode %s: %w", f.name, err)
+		}
+	}
+
+	for _, note := range data.Notes {
+		fw, err := zw.CreateHeader(&zip.FileHeader{Name: noteFileName(note), Method: zip.Deflate, Modified: now})
+		if err != nil {
+			return err
+		}
+		if err := writeNoteMarkdown(fw, note); err != nil {
+			return err
+		}
+	}
+
+	return zw.Close()
+}
+
+// nonNil returns s, or an empty slice if it's nil, so it's encoded as an empty JSON array.
+func nonNil[T any](s []T) []T {
+	if s == nil {
+		return []T{}
+	}
+	return s
+}
+
+// noteFileName returns the path of the markdown file of a note in the archive, such as
+// "notes/12-shopping-list.md". The id keeps the names of notes with the same title apart.
+func noteFileName(note models.Note) string {
+	id := note.ID.Int.Int64()
+	if slug := slugify(note.Title.String); slug != "" {
+		return fmt.Sprintf("notes/%d-%s.md", id, slug)
+	}
+	return fmt.Sprintf("notes/%d.md", id)
+}
+
+// slugify returns the letters and digits of s in lower case, the other runs of characters replaced by a dash.
+func slugify(s string) string {
+	var b strings.Builder
+	dash := false
+	for _, r := range strings.ToLower(s) {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			if dash && b.Len() > 0 {
+				b.WriteByte('-')
+			}
+			b.WriteRune(r)
+			dash = false
+		} else {
+			dash = true
+		}
+		if b.Len() >= maxSlugLen {
+			break
+		}
+	}
+	return b.String()
+}
+
+// writeNoteMarkdown writes a note as markdown, its metadata in a YAML front matter.
+// The text notes are written as they are, as their content is markdown already,
+// and the checklists as task lists.
+func writeNoteMarkdown(w io.Writer, note models.Note) error {
+	// JSON strings are valid YAML, and escape whatever the title has
+	title, _ := json.Marshal(note.Title.String)
+	tags, _ := json.Marshal(nonNil(note.Tags))
+
+	var b strings.Builder
+	b.WriteString("---\n")
+	fmt.Fprintf(&b, "id: %d\n", note.ID.Int.Int64())
+	fmt.Fprintf(&b, "title: %s\n", title)
+	fmt.Fprintf(&b, "type: %s\n", note.Type)
+	fmt.Fprintf(&b, "tags: %s\n", tags)
+	if note.NotebookID.Valid {
+		fmt.Fprintf(&b, "notebook_id: %d\n", note.NotebookID.Int.Int64())
+	}
+	fmt.Fprintf(&b, "created_at: %s\n", note.CreatedAt.Time.Format(time.DateOnly))
+	fmt.Fprintf(&b, "updated_at: %s\n", note.UpdatedAt.Time.Format(time.DateOnly))
+	if note.DeletedAt.Valid {
+		fmt.Fprintf(&b, "deleted_at: %s\n", note.DeletedAt.Time.Format(time.RFC3339))
+	}
+	if note.RemindAt.Valid {
+		fmt.Fprintf(&b, "remind_at: %s\n", note.RemindAt.Time.Format(time.RFC3339))
+	}
+	b.WriteString("---\n\n")
+
+	fmt.Fprintf(&b, "# %s\n\n", note.Title.String)
+	if note.Type == models.NoteTypeChecklist {
+		for _, item := range note.Items {
+			check := " "
+			if item.Checked.Bool {
+				check = "x"
+			}
+			fmt.Fprintf(&b, "- [%s] %s\n", check, item.Text.String)
+		}
+	} else if content := note.Content.String; content != "" {
+		b.WriteString(content)
+		if !strings.HasSuffix(content, "\n") {
+			b.WriteByte('\n')
+		}
+	}
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
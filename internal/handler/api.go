@@ -0,0 +1,388 @@
+// Package handler provides HTTP handlers.
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/LeandroDeJesus-S/quicknote/internal/errs"
+	"github.com/LeandroDeJesus-S/quicknote/internal/events"
+	"github.com/LeandroDeJesus-S/quicknote/internal/models"
+	"github.com/LeandroDeJesus-S/quicknote/internal/repo"
+	"github.com/LeandroDeJesus-S/quicknote/internal/validation"
+)
+
+// maxAPIBodySize is the maximum size in bytes accepted for an API request body.
+const maxAPIBodySize = 1 << 20
+
+// APIError is the body sent to API clients when a request fails.
+type APIError struct {
+	Status  int               `json:"status"`
+	Message string            `json:"message"`
+	Fields  map[string]string `json:"fields,omitempty"` // the validation error of each invalid field
+}
+
+// APIErrorHandler is the JSON counterpart of ErrorHandler.
+// Errors returned by the wrapped function are written as an [APIError] body.
+type APIErrorHandler struct {
+	f func(w http.ResponseWriter, r *http.Request) error
+}
+
+func (h *APIErrorHandler) Wrap(hf func(w http.ResponseWriter, r *http.Request) error) http.Handler {
+	h.f = hf
+	hc := *h
+	return &hc
+}
+
+// ServeHTTP implements the http.Handler interface.
+// It executes the APIErrorHandler and handles any errors returned.
+func (h APIErrorHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	err := h.f(w, r)
+	if err == nil {
+		return
+	}
+
+	switch v := err.(type) {
+	case errs.HTTPError:
+		slog.Debug("[APIErrorHandler] "+v.Error(), "sourceErr", v.Unwrap())
+		writeAPIError(w, v.Code(), v.Message(), nil)
+
+	default:
+		slog.Debug("[APIErrorHandler]", "err", v)
+		writeAPIError(w, http.StatusInternalServerError, "something went wrong, please try again later", nil)
+	}
+}
+
+// writeJSON writes data as a JSON response with the given status.
+func writeJSON(w http.ResponseWriter, status int, data any) error {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	return json.NewEncoder(w).Encode(data)
+}
+
+// writeAPIError writes an [APIError] response.
+func writeAPIError(w http.ResponseWriter, status int, message string, fields map[string]string) error {
+	return writeJSON(w, status, map[string]APIError{
+		"error": {Status: status, Message: message, Fields: fields},
+	})
+}
+
+// noteETag returns the ETag of a note, which is its quoted version.
+func noteETag(note NoteDTO) string {
+	return strconv.Quote(strconv.Itoa(note.Version))
+}
+
+// ifMatchVersion returns the note version sent in the If-Match header, or zero when
+// the header is absent or "*", meaning the update is not conditional.
+func ifMatchVersion(r *http.Request) (int, error) {
+	raw := strings.TrimSpace(r.Header.Get("If-Match"))
+	if raw == "" || raw == "*" {
+		return 0, nil
+	}
+
+	unquoted, err := strconv.Unquote(strings.TrimPrefix(raw, "W/"))
+	if err != nil {
+		return 0, errs.NewHTTPError(err, http.StatusBadRequest, "invalid If-Match header")
+	}
+	version, err := strconv.Atoi(unquoted)
+	if err != nil || version <= 0 {
+		return 0, errs.NewHTTPError(fmt.Errorf("invalid version: %q", unquoted), http.StatusBadRequest, "invalid If-Match header")
+	}
+	return version, nil
+}
+
+// apiTxError returns the error of a transaction as the [errs.HTTPError] it already is, or as a 500 with the message.
+func apiTxError(err error, message string) error {
+	var httpErr errs.HTTPError
+	if errors.As(err, &httpErr) {
+		return httpErr
+	}
+	return noteError(err, message)
+}
+
+// writeNote writes a note response with its ETag.
+func writeNote(w http.ResponseWriter, status int, note NoteDTO) error {
+	w.Header().Set("ETag", noteETag(note))
+	return writeJSON(w, status, map[string]any{"data": note})
+}
+
+// writeNoteConflict writes the response to an update made with a stale version,
+// which carries the ETag of the current note.
+func writeNoteConflict(w http.ResponseWriter, current NoteDTO) error {
+	w.Header().Set("ETag", noteETag(current))
+	return writeAPIError(w, http.StatusPreconditionFailed, "the note was changed since it was read, fetch it again", nil)
+}
+
+// apiNoteHandler handles the JSON API requests for notes.
+type apiNoteHandler struct {
+	noteRepo      repo.Noter
+	tagRepo       repo.TagRepository
+	checklistRepo repo.ChecklistRepository
+	noteShareRepo repo.NoteShareRepository
+	tx            repo.Transactor
+	hub           *events.Hub
+}
+
+// NewAPINoteHandler creates a new apiNoteHandler.
+func NewAPINoteHandler(noteRepo repo.Noter, tagRepo repo.TagRepository, checklistRepo repo.ChecklistRepository, noteShareRepo repo.NoteShareRepository, tx repo.Transactor, hub *events.Hub) *apiNoteHandler {
+	return &apiNoteHandler{noteRepo: noteRepo, tagRepo: tagRepo, checklistRepo: checklistRepo, noteShareRepo: noteShareRepo, tx: tx, hub: hub}
+}
+
+// publish publishes a change made to a note of the active workspace, see [publishNoteEvent].
+func (ah apiNoteHandler) publish(r *http.Request, typ events.NoteEventType, noteID int) {
+	publishNoteEvent(r.Context(), ah.hub, ah.noteShareRepo, typ, requestScope(r), noteID)
+}
+
+// noteID parses the note ID from the request path.
+func (ah apiNoteHandler) noteID(r *http.Request) (int, error) {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		return 0, errs.NewHTTPError(err, http.StatusBadRequest, "id is invalid")
+	}
+	return id, nil
+}
+
+// decodeNote decodes the note sent in the request body.
+func (ah apiNoteHandler) decodeNote(w http.ResponseWriter, r *http.Request) (NoteJSONRequestDTO, error) {
+	var dto NoteJSONRequestDTO
+
+	dec := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxAPIBodySize))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&dto); err != nil {
+		return dto, errs.NewHTTPError(err, http.StatusBadRequest, "invalid JSON body")
+	}
+	return dto, nil
+}
+
+// validateTags validates the tags sent in the request, if any, and returns them normalized.
+func (ah apiNoteHandler) validateTags(dto NoteJSONRequestDTO, validator validation.FormValidator) []string {
+	if dto.Tags == nil {
+		return nil
+	}
+	tags := normalizeTags(*dto.Tags)
+	if msg := tagsError(tags); msg != "" {
+		validator.AddError("tags", msg)
+	}
+	return tags
+}
+
+// validateItems validates the checklist items sent in the request, if any.
+func (ah apiNoteHandler) validateItems(dto NoteJSONRequestDTO, validator validation.FormValidator) []repo.ChecklistItemInput {
+	if dto.Items == nil {
+		return nil
+	}
+	items := dto.checklistItems()
+	if msg := checklistItemsError(items); msg != "" {
+		validator.AddError("items", msg)
+	}
+	return items
+}
+
+// List handles the request to list the user's notes.
+// They can be filtered by tags the same way as the HTML listing.
+func (ah apiNoteHandler) List(w http.ResponseWriter, r *http.Request) error {
+	notes, err := ah.noteRepo.List(r.Context(), requestScope(r), noteFilterFromQuery(r.URL.Query()))
+	if err != nil {
+		return errs.NewHTTPError(err, http.StatusInternalServerError, "error listing notes")
+	}
+	return writeJSON(w, http.StatusOK, map[string]any{"data": newNoteDTOList(notes)})
+}
+
+// Get handles the request to fetch a single note.
+func (ah apiNoteHandler) Get(w http.ResponseWriter, r *http.Request) error {
+	id, err := ah.noteID(r)
+	if err != nil {
+		return err
+	}
+
+	note, err := ah.noteRepo.ReadOne(r.Context(), requestScope(r), id)
+	if err != nil {
+		return noteError(err, "error reading note")
+	}
+	return writeNote(w, http.StatusOK, newNoteDTO(*note))
+}
+
+// Create handles the request to create a note. Every note field is required,
+// except the content of checklists, which must have their items instead.
+func (ah apiNoteHandler) Create(w http.ResponseWriter, r *http.Request) error {
+	dto, err := ah.decodeNote(w, r)
+	if err != nil {
+		return err
+	}
+
+	var rawType string
+	if dto.Type != nil {
+		rawType = *dto.Type
+	}
+	noteType, ok := parseNoteType(rawType)
+
+	validator := newNoteValidator(requiredNoteFields(noteType)...)
+	validator.ValidateForm(dto.form())
+	tags := ah.validateTags(dto, validator)
+	items := ah.validateItems(dto, validator)
+	switch {
+	case !ok:
+		validator.AddError("type", fmt.Sprintf("type must be %q or %q", models.NoteTypeText, models.NoteTypeChecklist))
+	case noteType == models.NoteTypeChecklist && dto.Items == nil:
+		validator.AddError("items", "a checklist must have at least one item")
+	case noteType == models.NoteTypeText && dto.Items != nil:
+		validator.AddError("items", "only checklists can have items")
+	}
+	if !validator.Ok() {
+		return writeAPIError(w, http.StatusUnprocessableEntity, "invalid note", validator.FieldErrors())
+	}
+
+	fields := dto.fields()
+	scope := requestScope(r)
+	var note *models.Note
+	err = ah.tx.InTx(r.Context(), func(ctx context.Context) error {
+		var err error
+		note, err = ah.noteRepo.Create(ctx, scope, noteType, fields["title"], fields["content"], fields["color"])
+		if err != nil {
+			return errs.NewHTTPError(err, http.StatusInternalServerError, "error creating note")
+		}
+		noteID := int(note.ID.Int.Int64())
+
+		note.Tags = []string{}
+		if tags != nil {
+			if err := ah.tagRepo.SetNoteTags(ctx, scope, noteID, 0, tags); err != nil {
+				return noteError(err, "error tagging note")
+			}
+			note.Tags = tags
+		}
+
+		if items != nil {
+			if err := ah.checklistRepo.SetItems(ctx, scope, noteID, 0, items); err != nil {
+				return noteError(err, "error saving checklist items")
+			}
+			if note, err = ah.noteRepo.ReadOne(ctx, scope, noteID); err != nil {
+				return noteError(err, "error reading note")
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return apiTxError(err, "error creating note")
+	}
+
+	ah.publish(r, events.NoteCreated, int(note.ID.Int.Int64()))
+	w.Header().Set("Location", fmt.Sprintf("/api/v1/notes/%d", note.ID.Int))
+	return writeNote(w, http.StatusCreated, newNoteDTO(*note))
+}
+
+// Update handles the request to partially update a note.
+// Only the fields sent in the body are validated and changed. When the If-Match header
+// carries the note ETag, the update only happens if the note is still at that version.
+func (ah apiNoteHandler) Update(w http.ResponseWriter, r *http.Request) error {
+	id, err := ah.noteID(r)
+	if err != nil {
+		return err
+	}
+
+	version, err := ifMatchVersion(r)
+	if err != nil {
+		return err
+	}
+
+	dto, err := ah.decodeNote(w, r)
+	if err != nil {
+		return err
+	}
+
+	fields := dto.fields()
+	if dto.Type != nil {
+		return writeAPIError(w, http.StatusUnprocessableEntity, "invalid note", map[string]string{"type": "the type of a note cannot be changed"})
+	}
+	if len(fields) == 0 && dto.Tags == nil && dto.Items == nil {
+		return errs.NewHTTPError(errors.New("empty patch"), http.StatusBadRequest, "no fields to update")
+	}
+
+	sent := make([]string, 0, len(fields))
+	data := make(map[string]any, len(fields))
+	for field, value := range fields {
+		sent = append(sent, field)
+		data[field] = value
+	}
+
+	validator := newNoteValidator(sent...)
+	validator.ValidateForm(dto.form())
+	tags := ah.validateTags(dto, validator)
+	items := ah.validateItems(dto, validator)
+	if !validator.Ok() {
+		return writeAPIError(w, http.StatusUnprocessableEntity, "invalid note", validator.FieldErrors())
+	}
+
+	// the API only changes the notes of the user's workspace, even if other users shared notes with them as editor
+	note, err := ah.noteRepo.ReadOne(r.Context(), requestScope(r), id)
+	if err != nil {
+		return noteError(err, "error reading note")
+	}
+	if items != nil && note.Type != models.NoteTypeChecklist {
+		return writeAPIError(w, http.StatusUnprocessableEntity, "invalid note", map[string]string{"items": "only checklists can have items"})
+	}
+
+	scope := requestScope(r)
+	err = ah.tx.InTx(r.Context(), func(ctx context.Context) error {
+		// the first change checks the expected version and locks the note, so the next ones are made whatever its version
+		var err error
+		if len(data) > 0 {
+			if _, err = ah.noteRepo.Update(ctx, scope, id, version, data); err != nil {
+				return noteError(err, "error updating note")
+			}
+			version = 0
+		}
+
+		if tags != nil {
+			if err := ah.tagRepo.SetNoteTags(ctx, scope, id, version, tags); err != nil {
+				return noteError(err, "error tagging note")
+			}
+			version = 0
+		}
+
+		if items != nil {
+			if err := ah.checklistRepo.SetItems(ctx, scope, id, version, items); err != nil {
+				return noteError(err, "error saving checklist items")
+			}
+		}
+
+		if note, err = ah.noteRepo.ReadOne(ctx, scope, id); err != nil {
+			return noteError(err, "error reading note")
+		}
+		return nil
+	})
+	var conflict *repo.NoteConflictError
+	if errors.As(err, &conflict) {
+		return writeNoteConflict(w, newNoteDTO(*conflict.Current))
+	}
+	if err != nil {
+		return apiTxError(err, "error updating note")
+	}
+
+	ah.publish(r, events.NoteUpdated, id)
+	return writeNote(w, http.StatusOK, newNoteDTO(*note))
+}
+
+// Delete handles the request to delete a note, which moves it to the trash.
+func (ah apiNoteHandler) Delete(w http.ResponseWriter, r *http.Request) error {
+	id, err := ah.noteID(r)
+	if err != nil {
+		return err
+	}
+	if err := requireRole(r, notesManagerRole); err != nil {
+		return err
+	}
+
+	if err := ah.noteRepo.Delete(r.Context(), requestScope(r), id); err != nil {
+		return noteError(err, "error deleting note")
+	}
+
+	ah.publish(r, events.NoteDeleted, id)
+	w.WriteHeader(http.StatusNoContent)
+	return nil
+}
@@ -0,0 +1,337 @@
+// Package handler provides HTTP handlers.
+package handler
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/LeandroDeJesus-S/quicknote/internal/blob"
+	"github.com/LeandroDeJesus-S/quicknote/internal/errs"
+	"github.com/LeandroDeJesus-S/quicknote/internal/models"
+	"github.com/LeandroDeJesus-S/quicknote/internal/repo"
+	"github.com/LeandroDeJesus-S/quicknote/internal/support"
+	"github.com/LeandroDeJesus-S/quicknote/internal/support/imgproc"
+)
+
+const (
+	maxUploadFiles      = 10      // the maximum number of files uploaded at once
+	maxFilenameLen      = 255     // the maximum length of an attachment filename
+	maxMultipartMemory  = 8 << 20 // how much of a multipart form is kept in memory, the rest goes to temporary files
+	attachmentFormField = "attachments"
+)
+
+// AttachmentOptions are the limits applied to the uploaded attachments.
+type AttachmentOptions struct {
+	MaxSize      int64    // the maximum size in bytes of an attachment
+	Quota        int64    // how many bytes the attachments of each user can take
+	AllowedTypes []string // the content types allowed as attachments
+}
+
+// upload is a file uploaded in a note form, checked by [noteHandler.checkUploads].
+type upload struct {
+	header      *multipart.FileHeader
+	filename    string
+	contentType string
+}
+
+// humanSize formats a size in bytes, such as 1.5 MB.
+func humanSize(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %cB", float64(n)/float64(div), "KMGT"[exp])
+}
+
+// parseNoteForm parses a note form, which is multipart when it carries attachments.
+func parseNoteForm(r *http.Request) error {
+	if strings.HasPrefix(r.Header.Get("Content-Type"), "multipart/form-data") {
+		return r.ParseMultipartForm(maxMultipartMemory)
+	}
+	return r.ParseForm()
+}
+
+// uploadedFiles returns the files uploaded in the attachments field of a note form.
+func uploadedFiles(r *http.Request) []*multipart.FileHeader {
+	if r.MultipartForm == nil {
+		return nil
+	}
+	var files []*multipart.FileHeader
+	for _, fh := range r.MultipartForm.File[attachmentFormField] {
+		// browsers send an empty part when no file is chosen
+		if fh.Filename != "" || fh.Size > 0 {
+			files = append(files, fh)
+		}
+	}
+	return files
+}
+
+// cleanFilename returns the base name of an uploaded file, without any path a browser may send.
+func cleanFilename(name string) string {
+	name = strings.TrimSpace(filepath.Base(strings.ReplaceAll(name, `\`, "/")))
+	if name == "" || name == "." || name == "/" {
+		return "arquivo"
+	}
+	if runes := []rune(name); len(runes) > maxFilenameLen {
+		name = string(runes[len(runes)-maxFilenameLen:])
+	}
+	return name
+}
+
+// sniffContentType detects the content type of an uploaded file from its first bytes,
+// as the type sent by the browser can't be trusted.
+func sniffContentType(fh *multipart.FileHeader) (string, error) {
+	f, err := fh.Open()
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	head := make([]byte, 512)
+	n, err := io.ReadFull(f, head)
+	if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) && !errors.Is(err, io.EOF) {
+		return "", err
+	}
+
+	mediaType, _, err := mime.ParseMediaType(http.DetectContentType(head[:n]))
+	if err != nil {
+		return "", err
+	}
+	return mediaType, nil
+}
+
+// checkUploads checks the files uploaded in a note form against the attachment limits and the quota of the signed-in user.
+// It returns why they can't be attached, or an empty string if they can. The variants of the images, only known once they
+// are processed, are counted in the quota as each upload is saved.
+func (nh noteHandler) checkUploads(r *http.Request, files []*multipart.FileHeader) ([]upload, string, error) {
+	if len(files) > maxUploadFiles {
+		return nil, fmt.Sprintf("at most %d files can be attached at once", maxUploadFiles), nil
+	}
+
+	var total int64
+	uploads := make([]upload, 0, len(files))
+	for _, fh := range files {
+		filename := cleanFilename(fh.Filename)
+		if fh.Size > nh.attachmentOpts.MaxSize {
+			return nil, fmt.Sprintf("%s is larger than %s", filename, humanSize(nh.attachmentOpts.MaxSize)), nil
+		}
+
+		contentType, err := sniffContentType(fh)
+		if err != nil {
+			return nil, "", errs.NewHTTPError(err, http.StatusBadRequest, "error reading uploaded file")
+		}
+		if !slices.Contains(nh.attachmentOpts.AllowedTypes, contentType) {
+			return nil, fmt.Sprintf("%s has a file type that is not allowed (%s)", filename, contentType), nil
+		}
+
+		total += fh.Size
+		uploads = append(uploads, upload{header: fh, filename: filename, contentType: contentType})
+	}
+
+	if total > 0 {
+		used, err := nh.attachmentRepo.UsedBytes(r.Context(), requestScope(r).UserID)
+		if err != nil {
+			return nil, "", errs.NewHTTPError(err, http.StatusInternalServerError, "error checking attachment quota")
+		}
+		if used+total > nh.attachmentOpts.Quota {
+			return nil, fmt.Sprintf("the files exceed your attachment quota of %s, %s are left", humanSize(nh.attachmentOpts.Quota), humanSize(max(nh.attachmentOpts.Quota-used, 0))), nil
+		}
+	}
+	return uploads, "", nil
+}
+
+// saveUploads stores the checked uploads and attaches them to the note of the scope workspace.
+// The attachments saved before an error are returned along with it, so their blobs can be deleted
+// when the transaction they were saved in is rolled back.
+func (nh noteHandler) saveUploads(r *http.Request, scope repo.Scope, noteID int, uploads []upload) ([]models.Attachment, error) {
+	var saved []models.Attachment
+	for _, up := range uploads {
+		attachment, err := nh.saveUpload(r, scope, noteID, up)
+		if err != nil {
+			return saved, err
+		}
+		saved = append(saved, *attachment)
+	}
+	return saved, nil
+}
+
+// deleteAttachmentBlobs deletes the blobs of attachments whose rows were not saved, along with their variants.
+func (nh noteHandler) deleteAttachmentBlobs(ctx context.Context, attachments []models.Attachment) {
+	for _, attachment := range attachments {
+		keys := []string{attachment.StorageKey.String}
+		for _, variant := range attachment.Variants {
+			keys = append(keys, blob.VariantKey(attachment.StorageKey.String, variant))
+		}
+		for _, key := range keys {
+			if err := nh.blobs.Delete(ctx, key); err != nil {
+				slog.Error("failed to delete the blob of an unsaved attachment", "key", key, "err", err)
+			}
+		}
+	}
+}
+
+// saveUpload stores a checked upload and attaches it to the note of the scope workspace, counting it in the quota of the scope user.
+// Images are stored without their metadata and along with their resized variants, see [imgproc.Process].
+func (nh noteHandler) saveUpload(r *http.Request, scope repo.Scope, noteID int, up upload) (attachment *models.Attachment, err error) {
+	f, err := up.header.Open()
+	if err != nil {
+		return nil, errs.NewHTTPError(err, http.StatusBadRequest, "error reading uploaded file")
+	}
+	defer f.Close()
+
+	in := repo.AttachmentInput{
+		Filename:    up.filename,
+		ContentType: up.contentType,
+		StorageKey:  blob.NewKey(strconv.FormatInt(scope.UserID, 10)),
+		Size:        up.header.Size,
+	}
+
+	// the blobs stored are deleted if the attachment can't be saved
+	var stored []string
+	defer func() {
+		if err == nil {
+			return
+		}
+		for _, key := range stored {
+			if err := nh.blobs.Delete(r.Context(), key); err != nil {
+				slog.Error("failed to delete the blob of an unsaved attachment", "key", key, "err", err)
+			}
+		}
+	}()
+
+	var content io.Reader = f
+	if imgproc.Supported(up.contentType) {
+		data, err := io.ReadAll(f)
+		if err != nil {
+			return nil, errs.NewHTTPError(err, http.StatusBadRequest, "error reading uploaded file")
+		}
+		img, err := imgproc.Process(data, up.contentType)
+		if errors.Is(err, imgproc.ErrUnsupported) {
+			return nil, errs.NewHTTPError(err, http.StatusUnprocessableEntity, fmt.Sprintf("the image %s could not be read", up.filename))
+		}
+		if err != nil {
+			return nil, errs.NewHTTPError(err, http.StatusInternalServerError, "error processing image")
+		}
+
+		for _, variant := range img.Variants {
+			key := blob.VariantKey(in.StorageKey, variant.Name)
+			if err := nh.blobs.Put(r.Context(), key, bytes.NewReader(variant.Data), int64(len(variant.Data)), variant.ContentType); err != nil {
+				return nil, errs.NewHTTPError(err, http.StatusInternalServerError, "error storing attachment")
+			}
+			stored = append(stored, key)
+			in.Variants = append(in.Variants, variant.Name)
+			in.VariantSize += int64(len(variant.Data))
+		}
+		content, in.Size = bytes.NewReader(img.Data), int64(len(img.Data))
+	}
+
+	if err := nh.blobs.Put(r.Context(), in.StorageKey, content, in.Size, in.ContentType); err != nil {
+		return nil, errs.NewHTTPError(err, http.StatusInternalServerError, "error storing attachment")
+	}
+	stored = append(stored, in.StorageKey)
+
+	attachment, err = nh.attachmentRepo.Create(r.Context(), scope, noteID, in, nh.attachmentOpts.Quota)
+	if errors.Is(err, repo.ErrAttachmentQuotaExceeded) {
+		return nil, errs.NewHTTPError(err, http.StatusRequestEntityTooLarge, "attachment quota exceeded")
+	}
+	if err != nil {
+		return nil, noteError(err, "error saving attachment")
+	}
+	return attachment, nil
+}
+
+// attachmentIDs parses the note and attachment IDs from the request path.
+func attachmentIDs(r *http.Request) (int, int, error) {
+	noteID, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		return 0, 0, errs.NewHTTPError(err, http.StatusBadRequest, "id is invalid")
+	}
+	id, err := strconv.Atoi(r.PathValue("attachment"))
+	if err != nil {
+		return 0, 0, errs.NewHTTPError(err, http.StatusBadRequest, "attachment id is invalid")
+	}
+	return noteID, id, nil
+}
+
+// attachmentError converts a repository error into an HTTP error.
+func attachmentError(err error, message string) error {
+	if errors.Is(err, repo.ErrAttachmentNotFound) || errors.Is(err, blob.ErrNotFound) {
+		return errs.NewHTTPError(err, http.StatusNotFound, "attachment not found")
+	}
+	return errs.NewHTTPError(err, http.StatusInternalServerError, message)
+}
+
+// NotesAttachment handles the request to download an attachment of a note the user can read.
+// Only images are shown inline, and the response is sandboxed so uploaded files can't run scripts.
+func (nh noteHandler) NotesAttachment(w http.ResponseWriter, r *http.Request) error {
+	noteID, id, err := attachmentIDs(r)
+	if err != nil {
+		return err
+	}
+
+	access, err := nh.noteAccess(r, noteID, models.NoteRoleViewer)
+	if err != nil {
+		return err
+	}
+
+	attachment, err := nh.attachmentRepo.ReadOne(r.Context(), noteScope(r, access), noteID, id)
+	if err != nil {
+		return attachmentError(err, "error reading attachment")
+	}
+
+	content, err := nh.blobs.Get(r.Context(), attachment.StorageKey.String)
+	if err != nil {
+		return attachmentError(err, "error reading attachment")
+	}
+	defer content.Close()
+
+	disposition := support.TernaryIf(strings.HasPrefix(attachment.ContentType.String, "image/"), "inline", "attachment")
+	w.Header().Set("Content-Type", attachment.ContentType.String)
+	w.Header().Set("Content-Length", strconv.FormatInt(attachment.Size.Int64, 10))
+	w.Header().Set("Content-Disposition", mime.FormatMediaType(disposition, map[string]string{"filename": attachment.Filename.String}))
+	w.Header().Set("Content-Security-Policy", "default-src 'none'; img-src 'self'; sandbox")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.Header().Set("Cache-Control", "private, max-age=3600")
+
+	if _, err := io.Copy(w, content); err != nil {
+		slog.Debug("failed to send attachment", "attachment_id", id, "err", err)
+	}
+	return nil
+}
+
+// NotesAttachmentDelete handles the request to delete an attachment of a note the user can edit.
+func (nh noteHandler) NotesAttachmentDelete(w http.ResponseWriter, r *http.Request) error {
+	noteID, id, err := attachmentIDs(r)
+	if err != nil {
+		return err
+	}
+
+	access, err := nh.noteAccess(r, noteID, models.NoteRoleEditor)
+	if err != nil {
+		return err
+	}
+
+	if err := nh.attachmentRepo.Delete(r.Context(), noteScope(r, access), noteID, id); err != nil {
+		return attachmentError(err, "error deleting attachment")
+	}
+
+	support.SendFlashMessage(nh.sesMng, r, support.FlashMsgSuccess, "attachment deleted")
+	http.Redirect(w, r, fmt.Sprintf("/notes/%d", noteID), http.StatusSeeOther)
+	return nil
+}
@@ -0,0 +1,144 @@
+// Package handler provides HTTP handlers.
+package handler
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/LeandroDeJesus-S/quicknote/internal/errs"
+	"github.com/LeandroDeJesus-S/quicknote/internal/ical"
+	"github.com/LeandroDeJesus-S/quicknote/internal/models"
+	"github.com/LeandroDeJesus-S/quicknote/internal/repo"
+	"github.com/LeandroDeJesus-S/quicknote/internal/support"
+	"github.com/LeandroDeJesus-S/quicknote/internal/support/authutil"
+	"github.com/alexedwards/scs/v2"
+)
+
+const (
+	// calendarURLKey is the session key of the link of a calendar feed just generated,
+	// shown once on the account page as only its token hash is stored.
+	calendarURLKey = "calendarURL"
+
+	calendarProdID = "-//quicknote//Reminders//PT"
+)
+
+// calendarHandler handles the HTTP requests for the calendar feeds of the note reminders.
+type calendarHandler struct {
+	calendarRepo repo.CalendarFeedRepository
+	reminderRepo repo.ReminderRepository
+	sesMng       *scs.SessionManager
+	appDomain    string
+}
+
+// NewCalendarHandler creates a new calendarHandler.
+func NewCalendarHandler(calendarRepo repo.CalendarFeedRepository, reminderRepo repo.ReminderRepository, sesMng *scs.SessionManager, appDomain string) *calendarHandler {
+	return &calendarHandler{calendarRepo: calendarRepo, reminderRepo: reminderRepo, sesMng: sesMng, appDomain: appDomain}
+}
+
+// recurrenceRule returns the iCalendar recurrence rule of a reminder first sent at start, matching
+// [models.Recurrence.Occurrence]: the days past the end of shorter months fall on their last day.
+func recurrenceRule(recurrence models.Recurrence, start time.Time) string {
+	switch recurrence {
+	case models.RecurrenceDaily:
+		return "FREQ=DAILY"
+	case models.RecurrenceWeekly:
+		return "FREQ=WEEKLY"
+	case models.RecurrenceMonthly:
+		if start.Day() > 28 {
+			return fmt.Sprintf("FREQ=MONTHLY;BYMONTHDAY=%d,-1;BYSETPOS=1", start.Day())
+		}
+		return "FREQ=MONTHLY"
+	case models.RecurrenceYearly:
+		if start.Month() == time.February && start.Day() == 29 {
+			return "FREQ=YEARLY;BYMONTH=2;BYMONTHDAY=29,-1;BYSETPOS=1"
+		}
+		return "FREQ=YEARLY"
+	}
+	return ""
+}
+
+// calendarEntry returns the calendar entry of the reminder of a note: a to-do for the checklists,
+// completed when all their items are checked, or an event for the others.
+func (ch calendarHandler) calendarEntry(note models.Note) ical.Entry {
+	id := note.ID.Int.Int64()
+	start := *reminderTime(note, note.RemindAt)
+	entry := ical.Entry{
+		Kind:        ical.Event,
+		UID:         fmt.Sprintf("note-%d@quicknote", id),
+		Start:       start,
+		RRule:       recurrenceRule(note.RemindRecurrence, start),
+		Summary:     note.Title.String,
+		Description: note.Content.String,
+		URL:         fmt.Sprintf("%s/notes/%d", ch.appDomain, id),
+	}
+
+	if note.Type == models.NoteTypeChecklist {
+		entry.Kind = ical.Todo
+		dto := newNoteDTO(note)
+		entry.Completed = len(dto.Items) > 0 && dto.Done() == len(dto.Items)
+	}
+	return entry
+}
+
+// CalendarFeed handles the request of a calendar app for the feed of a user, at the secret link
+// ending in "<token>.ics", with the reminders of the notes the user can read.
+func (ch calendarHandler) CalendarFeed(w http.ResponseWriter, r *http.Request) error {
+	token, ok := strings.CutSuffix(r.PathValue("file"), ".ics")
+	if !ok || token == "" {
+		return errs.NewHTTPError(errors.New("not a calendar file"), http.StatusNotFound, "calendar not found")
+	}
+
+	feed, err := ch.calendarRepo.FindByHash(r.Context(), authutil.HashToken(token))
+	if errors.Is(err, repo.ErrCalendarFeedNotFound) {
+		return errs.NewHTTPError(err, http.StatusNotFound, "calendar not found")
+	}
+	if err != nil {
+		return errs.NewHTTPError(err, http.StatusInternalServerError, "error reading calendar")
+	}
+
+	notes, err := ch.reminderRepo.ListForUser(r.Context(), feed.UserID.Int.Int64())
+	if err != nil {
+		return errs.NewHTTPError(err, http.StatusInternalServerError, "error listing reminders")
+	}
+
+	cal := ical.Calendar{ProdID: calendarProdID, Name: "Quicknote"}
+	for _, note := range notes {
+		cal.Entries = append(cal.Entries, ch.calendarEntry(note))
+	}
+
+	w.Header().Set("Content-Type", "text/calendar; charset=utf-8")
+	w.Header().Set("Cache-Control", "private, no-cache")
+	if _, err := cal.WriteTo(w); err != nil {
+		slog.Error("failed to write the calendar feed", "err", err, "user_id", feed.UserID.Int)
+	}
+	return nil
+}
+
+// CalendarRegenerate handles the request to generate the link of the user's calendar feed, replacing
+// the previous one. The link is shown once on the account page, as only its token hash is stored.
+func (ch calendarHandler) CalendarRegenerate(w http.ResponseWriter, r *http.Request) error {
+	token := authutil.GenerateToken()
+	if _, err := ch.calendarRepo.Regenerate(r.Context(), requestScope(r).UserID, authutil.HashToken(token)); err != nil {
+		return errs.NewHTTPError(err, http.StatusInternalServerError, "error generating calendar link")
+	}
+
+	ch.sesMng.Put(r.Context(), calendarURLKey, fmt.Sprintf("%s/calendar/%s.ics", ch.appDomain, token))
+	http.Redirect(w, r, "/users/me", http.StatusSeeOther)
+	return nil
+}
+
+// CalendarDelete handles the request to disable the user's calendar feed, so its link stops working.
+func (ch calendarHandler) CalendarDelete(w http.ResponseWriter, r *http.Request) error {
+	err := ch.calendarRepo.Delete(r.Context(), requestScope(r).UserID)
+	if err != nil && !errors.Is(err, repo.ErrCalendarFeedNotFound) {
+		return errs.NewHTTPError(err, http.StatusInternalServerError, "error disabling calendar")
+	}
+
+	support.SendFlashMessage(ch.sesMng, r, support.FlashMsgSuccess, "calendar link disabled")
+	http.Redirect(w, r, "/users/me", http.StatusSeeOther)
+	return nil
+}
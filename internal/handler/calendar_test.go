@@ -0,0 +1,128 @@
+package handler
+
+import (
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/LeandroDeJesus-S/quicknote/internal/models"
+)
+
+// expandRule returns the first n occurrences of the recurrence rule of an entry starting at start, as a calendar
+// app expands them. It only knows the parts [recurrenceRule] writes: FREQ, BYMONTH, BYMONTHDAY and BYSETPOS=1.
+func expandRule(t *testing.T, rule string, start time.Time, n int) []time.Time {
+	t.Helper()
+	parts := make(map[string]string)
+	for part := range strings.SplitSeq(rule, ";") {
+		name, value, _ := strings.Cut(part, "=")
+		parts[name] = value
+	}
+
+	// the days of a month the rule falls on, the day of start unless BYMONTHDAY says otherwise
+	days := func(year int, month time.Month) []int {
+		last := time.Date(year, month+1, 0, 0, 0, 0, 0, time.UTC).Day()
+		var days []int
+		if parts["BYMONTHDAY"] == "" {
+			if start.Day() <= last {
+				days = append(days, start.Day())
+			}
+			return days
+		}
+		for d := range strings.SplitSeq(parts["BYMONTHDAY"], ",") {
+			day, err := strconv.Atoi(d)
+			if err != nil {
+				t.Fatalf("BYMONTHDAY %q: %v", d, err)
+			}
+			if day < 0 {
+				day = last + 1 + day
+			}
+			if day >= 1 && day <= last {
+				days = append(days, day)
+			}
+		}
+		slices.Sort(days)
+		if parts["BYSETPOS"] == "1" && len(days) > 0 {
+			days = days[:1]
+		}
+		return days
+	}
+	at := func(year int, month time.Month, day int) time.Time {
+		return time.Date(year, month, day, start.Hour(), start.Minute(), start.Second(), 0, start.Location())
+	}
+
+	var occurrences []time.Time
+	for i := 0; len(occurrences) < n; i++ {
+		switch parts["FREQ"] {
+		case "DAILY":
+			occurrences = append(occurrences, start.AddDate(0, 0, i))
+		case "WEEKLY":
+			occurrences = append(occurrences, start.AddDate(0, 0, 7*i))
+		case "MONTHLY":
+			month := time.Date(start.Year(), start.Month()+time.Month(i), 1, 0, 0, 0, 0, time.UTC)
+			for _, day := range days(month.Year(), month.Month()) {
+				occurrences = append(occurrences, at(month.Year(), month.Month(), day))
+			}
+		case "YEARLY":
+			month := start.Month()
+			if parts["BYMONTH"] != "" {
+				m, err := strconv.Atoi(parts["BYMONTH"])
+				if err != nil {
+					t.Fatalf("BYMONTH %q: %v", parts["BYMONTH"], err)
+				}
+				month = time.Month(m)
+			}
+			for _, day := range days(start.Year()+i, month) {
+				occurrences = append(occurrences, at(start.Year()+i, month, day))
+			}
+		default:
+			t.Fatalf("unknown FREQ in %q", rule)
+		}
+	}
+	return occurrences[:n]
+}
+
+func TestRecurrenceRule(t *testing.T) {
+	newYork, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Skipf("loading the New York time zone: %v", err)
+	}
+
+	tests := []struct {
+		name       string
+		recurrence models.Recurrence
+		start      time.Time
+		want       string
+	}{
+		{"once", models.RecurrenceNone, time.Date(2024, 1, 31, 9, 0, 0, 0, time.UTC), ""},
+		{"daily", models.RecurrenceDaily, time.Date(2024, 3, 8, 9, 0, 0, 0, newYork), "FREQ=DAILY"},
+		{"weekly", models.RecurrenceWeekly, time.Date(2024, 10, 28, 9, 0, 0, 0, newYork), "FREQ=WEEKLY"},
+		{"monthly", models.RecurrenceMonthly, time.Date(2024, 1, 15, 9, 0, 0, 0, time.UTC), "FREQ=MONTHLY"},
+		{"monthly on the 28th", models.RecurrenceMonthly, time.Date(2023, 1, 28, 9, 0, 0, 0, time.UTC), "FREQ=MONTHLY"},
+		{"monthly on the 29th", models.RecurrenceMonthly, time.Date(2023, 1, 29, 9, 0, 0, 0, time.UTC), "FREQ=MONTHLY;BYMONTHDAY=29,-1;BYSETPOS=1"},
+		{"monthly on the 30th", models.RecurrenceMonthly, time.Date(2024, 1, 30, 9, 0, 0, 0, time.UTC), "FREQ=MONTHLY;BYMONTHDAY=30,-1;BYSETPOS=1"},
+		{"monthly on the 31st", models.RecurrenceMonthly, time.Date(2024, 1, 31, 9, 0, 0, 0, newYork), "FREQ=MONTHLY;BYMONTHDAY=31,-1;BYSETPOS=1"},
+		{"yearly", models.RecurrenceYearly, time.Date(2024, 3, 31, 9, 0, 0, 0, time.UTC), "FREQ=YEARLY"},
+		{"yearly on a leap day", models.RecurrenceYearly, time.Date(2024, 2, 29, 9, 0, 0, 0, time.UTC), "FREQ=YEARLY;BYMONTH=2;BYMONTHDAY=29,-1;BYSETPOS=1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rule := recurrenceRule(tt.recurrence, tt.start)
+			if rule != tt.want {
+				t.Fatalf("recurrenceRule() = %q, want %q", rule, tt.want)
+			}
+			if rule == "" {
+				return
+			}
+
+			// calendar apps show the reminder when it's mailed, over a few years of occurrences
+			const n = 30
+			for i, got := range expandRule(t, rule, tt.start, n) {
+				if want := tt.recurrence.Occurrence(tt.start, i); !got.Equal(want) {
+					t.Errorf("occurrence %d of %q = %v, want %v", i, rule, got, want)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,146 @@
+// Package handler provides HTTP handlers.
+package handler
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/LeandroDeJesus-S/quicknote/internal/errs"
+	"github.com/LeandroDeJesus-S/quicknote/internal/events"
+	"github.com/LeandroDeJesus-S/quicknote/internal/models"
+	"github.com/LeandroDeJesus-S/quicknote/internal/repo"
+)
+
+const (
+	maxChecklistItems   = 200 // the maximum number of items a checklist can have
+	maxChecklistItemLen = 500 // the maximum length of a checklist item
+)
+
+// checkedItemPrefix and uncheckedItemPrefix start the lines of the checklist items in the note forms,
+// the same way as the task lists of markdown.
+const (
+	checkedItemPrefix   = "- [x] "
+	uncheckedItemPrefix = "- [ ] "
+)
+
+// parseNoteType parses the type of a note, where empty means a text note.
+func parseNoteType(raw string) (models.NoteType, bool) {
+	switch models.NoteType(raw) {
+	case "", models.NoteTypeText:
+		return models.NoteTypeText, true
+	case models.NoteTypeChecklist:
+		return models.NoteTypeChecklist, true
+	}
+	return "", false
+}
+
+// requiredNoteFields returns the required fields of a note form of the type,
+// as the content of a checklist is optional.
+func requiredNoteFields(noteType models.NoteType) []string {
+	if noteType == models.NoteTypeChecklist {
+		return []string{"title", "color"}
+	}
+	return noteFields
+}
+
+// parseChecklistItems parses the checklist items of a note form, one per line.
+// Lines starting with "- [x] " are checked items, and the "- [ ] " prefix of the unchecked ones is optional.
+func parseChecklistItems(raw string) []repo.ChecklistItemInput {
+	var items []repo.ChecklistItemInput
+	for _, line := range strings.Split(raw, "\n") {
+		line = strings.TrimSpace(line)
+
+		var item repo.ChecklistItemInput
+		switch lower := strings.ToLower(line); {
+		case strings.HasPrefix(lower, strings.TrimSpace(checkedItemPrefix)):
+			item.Checked = true
+			line = line[len(checkedItemPrefix)-1:]
+		case strings.HasPrefix(lower, strings.TrimSpace(uncheckedItemPrefix)):
+			line = line[len(uncheckedItemPrefix)-1:]
+		}
+
+		item.Text = strings.TrimSpace(line)
+		if item.Text != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// formatChecklistItems formats the checklist items to be edited in a note form, see [parseChecklistItems].
+func formatChecklistItems(items []models.ChecklistItem) string {
+	lines := make([]string, 0, len(items))
+	for _, item := range items {
+		prefix := uncheckedItemPrefix
+		if item.Checked.Bool {
+			prefix = checkedItemPrefix
+		}
+		lines = append(lines, prefix+item.Text.String)
+	}
+	return strings.Join(lines, "\n")
+}
+
+// checklistItemsError returns why the checklist items are not valid, or an empty string if they are.
+func checklistItemsError(items []repo.ChecklistItemInput) string {
+	if len(items) == 0 {
+		return "a checklist must have at least one item"
+	}
+	if len(items) > maxChecklistItems {
+		return fmt.Sprintf("a checklist can have at most %d items", maxChecklistItems)
+	}
+	for _, item := range items {
+		if utf8.RuneCountInString(item.Text) > maxChecklistItemLen {
+			return fmt.Sprintf("checklist items must have at most %d characters", maxChecklistItemLen)
+		}
+	}
+	return ""
+}
+
+// NotesToggleItem handles the request to check or uncheck an item of a checklist note.
+// Scripts asking for JSON get the item state and the checklist progress,
+// while plain form submissions are redirected to the note.
+func (nh noteHandler) NotesToggleItem(w http.ResponseWriter, r *http.Request) error {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		return errs.NewHTTPError(err, http.StatusBadRequest, "id is invalid")
+	}
+	itemID, err := strconv.Atoi(r.PathValue("item"))
+	if err != nil {
+		return errs.NewHTTPError(err, http.StatusBadRequest, "item id is invalid")
+	}
+
+	access, err := nh.noteAccess(r, id, models.NoteRoleEditor)
+	if err != nil {
+		return err
+	}
+
+	item, err := nh.checklistRepo.ToggleItem(r.Context(), noteScope(r, access), id, 0, itemID)
+	if errors.Is(err, repo.ErrChecklistItemNotFound) {
+		return errs.NewHTTPError(err, http.StatusNotFound, "checklist item not found")
+	}
+	if err != nil {
+		return errs.NewHTTPError(err, http.StatusInternalServerError, "error toggling checklist item")
+	}
+
+	nh.publish(r, events.NoteUpdated, noteScope(r, access), id)
+
+	if !strings.Contains(r.Header.Get("Accept"), "application/json") {
+		http.Redirect(w, r, fmt.Sprintf("/notes/%d", id), http.StatusSeeOther)
+		return nil
+	}
+
+	note, err := nh.noteRepo.ReadOne(r.Context(), noteScope(r, access), id)
+	if err != nil {
+		return noteError(err, "error reading note")
+	}
+	dto := newNoteDTO(*note)
+	return writeJSON(w, http.StatusOK, map[string]any{
+		"checked": item.Checked.Bool,
+		"done":    dto.Done(),
+		"total":   len(dto.Items),
+	})
+}
@@ -0,0 +1,114 @@
+// Package handler provides HTTP handlers.
+package handler
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+
+	"github.com/LeandroDeJesus-S/quicknote/internal/collab"
+	"github.com/LeandroDeJesus-S/quicknote/internal/errs"
+	"github.com/LeandroDeJesus-S/quicknote/internal/models"
+	"github.com/LeandroDeJesus-S/quicknote/internal/repo"
+	"golang.org/x/net/websocket"
+)
+
+// maxCollabMessageSize is the maximum size in bytes of a message received from a collaborative editor.
+const maxCollabMessageSize = 1 << 20
+
+// hijacker returns the response writer wrapped by the middlewares that can take over the connection, or nil.
+func hijacker(w http.ResponseWriter) http.ResponseWriter {
+	for {
+		if _, ok := w.(http.Hijacker); ok {
+			return w
+		}
+		u, ok := w.(interface{ Unwrap() http.ResponseWriter })
+		if !ok {
+			return nil
+		}
+		w = u.Unwrap()
+	}
+}
+
+// collabHandshake accepts the WebSocket connections opened by the pages of the app only,
+// as the browsers let any site open them with the user's cookies.
+func collabHandshake(config *websocket.Config, r *http.Request) error {
+	origin, err := websocket.Origin(config, r)
+	if err != nil {
+		return err
+	}
+	if origin == nil || origin.Host != r.Host {
+		return fmt.Errorf("cross-origin websocket from %v", origin)
+	}
+	config.Origin = origin
+	return nil
+}
+
+// NotesCollab handles the request to edit the content of a note together with the other people editing it,
+// over a WebSocket exchanging [collab.Message]s. The edits are saved to the note by the collaborative editing.
+func (nh noteHandler) NotesCollab(w http.ResponseWriter, r *http.Request) error {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		return errs.NewHTTPError(err, http.StatusBadRequest, "id is invalid")
+	}
+
+	access, err := nh.noteAccess(r, id, models.NoteRoleEditor)
+	if err != nil {
+		return err
+	}
+
+	hw := hijacker(w)
+	if hw == nil {
+		return errs.NewHTTPError(errors.New("the response writer can't be hijacked"), http.StatusInternalServerError, "collaborative editing is not available")
+	}
+
+	scope, email := noteScope(r, access), requestMembership(r).Email.String
+	websocket.Server{
+		Handshake: collabHandshake,
+		Handler: func(ws *websocket.Conn) {
+			nh.collabSession(ws, scope, id, email)
+		},
+	}.ServeHTTP(hw, r)
+	return nil
+}
+
+// collabSession joins the user to the editing of the note and relays the messages between
+// the connection and the other editors, until either side leaves.
+func (nh noteHandler) collabSession(ws *websocket.Conn, scope repo.Scope, noteID int, email string) {
+	ws.MaxPayloadBytes = maxCollabMessageSize
+
+	client, err := nh.collabServer.Join(ws.Request().Context(), scope, noteID, email)
+	if err != nil {
+		slog.Error("failed to join the collaborative editing", "err", err, "note_id", noteID)
+		websocket.JSON.Send(ws, collab.Message{Type: collab.MsgError, Error: "the note could not be opened"})
+		return
+	}
+
+	sent := make(chan struct{})
+	go func() {
+		defer close(sent)
+		for msg := range client.Messages() {
+			if err := websocket.JSON.Send(ws, msg); err != nil {
+				break
+			}
+		}
+		ws.Close()
+	}()
+
+	for {
+		var msg collab.Message
+		if err := websocket.JSON.Receive(ws, &msg); err != nil {
+			break
+		}
+		if err := client.Handle(msg); err != nil {
+			slog.Debug("collaborative editor out of sync", "err", err, "note_id", noteID, "client", client.ID)
+			websocket.JSON.Send(ws, collab.Message{Type: collab.MsgError, Error: err.Error()})
+			break
+		}
+	}
+
+	client.Leave()
+	<-sent
+}
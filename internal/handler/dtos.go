@@ -3,44 +3,155 @@ package handler
 
 import (
 	"fmt"
+	"html/template"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
 
+	"github.com/LeandroDeJesus-S/quicknote/internal/events"
 	"github.com/LeandroDeJesus-S/quicknote/internal/models"
+	"github.com/LeandroDeJesus-S/quicknote/internal/repo"
+	"github.com/LeandroDeJesus-S/quicknote/internal/support/diff"
+	"github.com/LeandroDeJesus-S/quicknote/internal/support/imgproc"
 )
 
+// dateTimeLayout is the layout used to show dates and times on the pages.
+const dateTimeLayout = "02/01/2006 15:04"
+
 // NoteDTO is a data transfer object for a note.
 type NoteDTO struct {
-	ID      int
-	Title   string
-	Content string
-	Color   string
+	ID         int                `json:"id"`
+	Title      string             `json:"title"`
+	Content    string             `json:"content"`
+	Color      string             `json:"color"`
+	NotebookID *int               `json:"notebook_id"`
+	Type       string             `json:"type"`
+	Tags       []string           `json:"tags"`
+	Items      []ChecklistItemDTO `json:"items"`
+	Version    int                `json:"version"`
+
+	RemindAt         *time.Time `json:"remind_at"` // the first occurrence of the reminder in the time zone it was set in, nil when the note has no reminder
+	RemindRecurrence string     `json:"remind_recurrence"`
+	RemindNextAt     *time.Time `json:"remind_next_at"` // the occurrence of the reminder sent next, nil once a reminder that doesn't recur is sent
+}
+
+// ChecklistItemDTO is a data transfer object for an item of a checklist note.
+type ChecklistItemDTO struct {
+	ID      int    `json:"id"`
+	Text    string `json:"text"`
+	Checked bool   `json:"checked"`
 }
 
 // newNoteDTO creates a new NoteDTO from a models.Note.
 func newNoteDTO(note models.Note) NoteDTO {
-	return NoteDTO{
+	dto := NoteDTO{
 		ID:      int(note.ID.Int.Int64()),
 		Title:   note.Title.String,
 		Content: note.Content.String,
 		Color:   note.Color.String,
+		Type:    string(note.Type),
+		Tags:    note.Tags,
+		Items:   make([]ChecklistItemDTO, 0, len(note.Items)),
+		Version: int(note.Version.Int32),
+
+		RemindAt:         reminderTime(note, note.RemindAt),
+		RemindRecurrence: string(note.RemindRecurrence),
+		RemindNextAt:     reminderTime(note, note.RemindNextAt),
+	}
+	for _, item := range note.Items {
+		dto.Items = append(dto.Items, ChecklistItemDTO{
+			ID:      int(item.ID.Int.Int64()),
+			Text:    item.Text.String,
+			Checked: item.Checked.Bool,
+		})
+	}
+	if note.NotebookID.Valid {
+		notebookID := int(note.NotebookID.Int.Int64())
+		dto.NotebookID = &notebookID
+	}
+	return dto
+}
+
+// IsChecklist reports whether the note is a checklist.
+func (dto NoteDTO) IsChecklist() bool {
+	return dto.Type == string(models.NoteTypeChecklist)
+}
+
+// Done returns how many checklist items of the note are checked.
+func (dto NoteDTO) Done() int {
+	done := 0
+	for _, item := range dto.Items {
+		if item.Checked {
+			done++
+		}
+	}
+	return done
+}
+
+// notebookID returns the ID of the note notebook, or zero if it is not in any.
+func (dto NoteDTO) notebookID() int {
+	if dto.NotebookID == nil {
+		return 0
 	}
+	return *dto.NotebookID
 }
 
 // newNoteDTOList creates a new list of NoteDTOs from a list of models.Note.
 func newNoteDTOList(notes []models.Note) []NoteDTO {
-	var dtos []NoteDTO
+	dtos := make([]NoteDTO, 0, len(notes))
 	for _, note := range notes {
 		dtos = append(dtos, newNoteDTO(note))
 	}
 	return dtos
 }
 
+// NoteSearchDTO is a data transfer object for a note found by a search.
+type NoteSearchDTO struct {
+	NoteDTO
+	TitleHTML   template.HTML // the title with the matches highlighted
+	SnippetHTML template.HTML // the content fragments around the matches, highlighted
+}
+
+// newNoteSearchDTOList creates a new list of NoteSearchDTOs from a list of models.NoteSearchResult.
+func newNoteSearchDTOList(results []models.NoteSearchResult) []NoteSearchDTO {
+	dtos := make([]NoteSearchDTO, 0, len(results))
+	for _, res := range results {
+		dtos = append(dtos, NoteSearchDTO{
+			NoteDTO:     newNoteDTO(res.Note),
+			TitleHTML:   highlightHeadline(res.TitleHeadline.String),
+			SnippetHTML: highlightHeadline(res.Snippet.String),
+		})
+	}
+	return dtos
+}
+
+// highlightHeadline escapes a search headline and turns its match delimiters into <mark> tags.
+func highlightHeadline(headline string) template.HTML {
+	escaped := template.HTMLEscapeString(headline)
+	escaped = strings.ReplaceAll(escaped, repo.HeadlineStartSel, "<mark>")
+	escaped = strings.ReplaceAll(escaped, repo.HeadlineStopSel, "</mark>")
+	return template.HTML(escaped)
+}
+
 // NoteRequestDTO is a data transfer object for a note request.
 type NoteRequestDTO struct {
-	ID      int
-	Title   string
-	Content string
-	Color   string
-	Colors  []string
+	ID         int
+	Title      string
+	Content    string
+	Color      string
+	Colors     []string
+	Tags       string // comma separated tag names
+	NotebookID int    // zero when the note is not in any notebook
+	Version    int    // the version of the note being edited
+	Type       string
+	Items      string // the checklist items, one per line
+	Shared     bool   // whether the note is shared with the user by someone else, so it can't be moved to their notebooks
+
+	RemindAt         string // the reminder time as a datetime-local input value, empty when the note has no reminder
+	RemindTimezone   string // the IANA time zone of the reminder, filled in by the browser for new reminders
+	RemindRecurrence string
 }
 
 // newNoteRequestDTO creates a new NoteRequestDTO with default values.
@@ -52,5 +163,502 @@ func newNoteRequestDTO() NoteRequestDTO {
 	return NoteRequestDTO{
 		Color:  "Color3",
 		Colors: colors,
+		Type:   string(models.NoteTypeText),
+	}
+}
+
+// NoteJSONRequestDTO is a data transfer object for a note request made through the JSON API.
+// Fields left out of the request body are nil, which allows partial updates.
+type NoteJSONRequestDTO struct {
+	Title   *string             `json:"title"`
+	Content *string             `json:"content"`
+	Color   *string             `json:"color"`
+	Tags    *[]string           `json:"tags"`
+	Type    *string             `json:"type"`  // only accepted on creation
+	Items   *[]ChecklistItemDTO `json:"items"` // the checklist items, replaced as a whole
+}
+
+// fields returns the note fields present in the request mapped to their column names.
+// Tags are not included as they aren't a note column.
+func (dto NoteJSONRequestDTO) fields() map[string]string {
+	fields := make(map[string]string)
+	if dto.Title != nil {
+		fields["title"] = *dto.Title
+	}
+	if dto.Content != nil {
+		fields["content"] = *dto.Content
+	}
+	if dto.Color != nil {
+		fields["color"] = *dto.Color
+	}
+	return fields
+}
+
+// checklistItems returns the checklist items sent in the request, whose IDs are ignored.
+func (dto NoteJSONRequestDTO) checklistItems() []repo.ChecklistItemInput {
+	if dto.Items == nil {
+		return nil
+	}
+	items := make([]repo.ChecklistItemInput, 0, len(*dto.Items))
+	for _, item := range *dto.Items {
+		if text := strings.TrimSpace(item.Text); text != "" {
+			items = append(items, repo.ChecklistItemInput{Text: text, Checked: item.Checked})
+		}
+	}
+	return items
+}
+
+// form returns the fields present in the request as url.Values so they can be
+// checked by a validation.FormValidator.
+func (dto NoteJSONRequestDTO) form() url.Values {
+	form := make(url.Values)
+	for field, value := range dto.fields() {
+		form.Set(field, value)
+	}
+	return form
+}
+
+// APITokenDTO is a data transfer object for a personal API token.
+type APITokenDTO struct {
+	ID         int
+	Name       string
+	Scopes     []string
+	ExpiresAt  string
+	LastUsedAt string
+	CreatedAt  string
+	Expired    bool
+}
+
+// newAPITokenDTO creates a new APITokenDTO from a models.APIToken.
+func newAPITokenDTO(tok models.APIToken) APITokenDTO {
+	dto := APITokenDTO{
+		ID:        int(tok.ID.Int.Int64()),
+		Name:      tok.Name.String,
+		Scopes:    tok.Scopes,
+		CreatedAt: tok.CreatedAt.Time.Format(dateTimeLayout),
+	}
+	if tok.ExpiresAt.Valid {
+		dto.ExpiresAt = tok.ExpiresAt.Time.Format(dateTimeLayout)
+		dto.Expired = time.Now().After(tok.ExpiresAt.Time)
+	}
+	if tok.LastUsedAt.Valid {
+		dto.LastUsedAt = tok.LastUsedAt.Time.Format(dateTimeLayout)
+	}
+	return dto
+}
+
+// newAPITokenDTOList creates a new list of APITokenDTOs from a list of models.APIToken.
+func newAPITokenDTOList(tokens []models.APIToken) []APITokenDTO {
+	dtos := make([]APITokenDTO, 0, len(tokens))
+	for _, tok := range tokens {
+		dtos = append(dtos, newAPITokenDTO(tok))
+	}
+	return dtos
+}
+
+// ShareLinkDTO is a data transfer object for a public link to a note.
+type ShareLinkDTO struct {
+	ID          int
+	CreatedAt   string
+	ExpiresAt   string
+	Expired     bool
+	HasPassword bool
+	ViewCount   int
+}
+
+// newShareLinkDTOList creates a new list of ShareLinkDTOs from a list of models.ShareLink.
+func newShareLinkDTOList(links []models.ShareLink) []ShareLinkDTO {
+	dtos := make([]ShareLinkDTO, 0, len(links))
+	for _, link := range links {
+		dto := ShareLinkDTO{
+			ID:          int(link.ID.Int.Int64()),
+			CreatedAt:   link.CreatedAt.Time.Format(dateTimeLayout),
+			HasPassword: link.PasswordHash.Valid,
+			ViewCount:   int(link.ViewCount.Int32),
+		}
+		if link.ExpiresAt.Valid {
+			dto.ExpiresAt = link.ExpiresAt.Time.Format(dateTimeLayout)
+			dto.Expired = time.Now().After(link.ExpiresAt.Time)
+		}
+		dtos = append(dtos, dto)
 	}
+	return dtos
+}
+
+// NoteShareDTO is a data transfer object for a note shared with another user.
+type NoteShareDTO struct {
+	ID        int
+	Email     string
+	Role      string
+	CreatedAt string
+}
+
+// newNoteShareDTOList creates a new list of NoteShareDTOs from a list of models.NoteShare.
+func newNoteShareDTOList(shares []models.NoteShare) []NoteShareDTO {
+	dtos := make([]NoteShareDTO, 0, len(shares))
+	for _, share := range shares {
+		dtos = append(dtos, NoteShareDTO{
+			ID:        int(share.ID.Int.Int64()),
+			Email:     share.Email.String,
+			Role:      string(share.Role),
+			CreatedAt: share.CreatedAt.Time.Format(dateTimeLayout),
+		})
+	}
+	return dtos
+}
+
+// SharedNoteDTO is a data transfer object for a note shared with the user by someone else.
+type SharedNoteDTO struct {
+	NoteDTO
+	OwnerEmail string
+	Role       string
+}
+
+// newSharedNoteDTOList creates a new list of SharedNoteDTOs from a list of models.SharedNote.
+func newSharedNoteDTOList(notes []models.SharedNote) []SharedNoteDTO {
+	dtos := make([]SharedNoteDTO, 0, len(notes))
+	for _, note := range notes {
+		dtos = append(dtos, SharedNoteDTO{
+			NoteDTO:    newNoteDTO(note.Note),
+			OwnerEmail: note.OwnerEmail.String,
+			Role:       string(note.Role),
+		})
+	}
+	return dtos
+}
+
+// TagDTO is a data transfer object for a tag.
+type TagDTO struct {
+	ID        int
+	Name      string
+	NoteCount int
+}
+
+// newTagDTOList creates a new list of TagDTOs from a list of models.Tag.
+func newTagDTOList(tags []models.Tag) []TagDTO {
+	dtos := make([]TagDTO, 0, len(tags))
+	for _, tag := range tags {
+		dtos = append(dtos, TagDTO{
+			ID:        int(tag.ID.Int.Int64()),
+			Name:      tag.Name.String,
+			NoteCount: int(tag.NoteCount.Int64),
+		})
+	}
+	return dtos
+}
+
+// TagFilterDTO is a data transfer object for the tag filter of the note listing.
+type TagFilterDTO struct {
+	Options  []TagOptionDTO
+	Active   bool // whether the listing is filtered by any tag
+	MatchAll bool
+	AnyURL   string // the URL of the current filter matching any of the tags
+	AllURL   string // the URL of the current filter matching all of the tags
+}
+
+// TagOptionDTO is a tag that can be toggled in the note listing filter.
+type TagOptionDTO struct {
+	Name      string
+	Selected  bool
+	ToggleURL string // the URL of the current filter with this tag added or removed
+}
+
+// newTagFilterDTO creates a new TagFilterDTO for the user's tags and the current filter.
+func newTagFilterDTO(tags []models.Tag, filter repo.NoteFilter) TagFilterDTO {
+	filterURL := func(tags []string, matchAll bool) string {
+		query := url.Values{"tag": tags}
+		if matchAll {
+			query.Set("match", "all")
+		}
+		if filter.NotebookID > 0 {
+			query.Set("notebook", strconv.Itoa(filter.NotebookID))
+		}
+		return "/notes?" + query.Encode()
+	}
+
+	selected := make(map[string]bool, len(filter.Tags))
+	for _, name := range filter.Tags {
+		selected[name] = true
+	}
+
+	dto := TagFilterDTO{
+		Active:   len(filter.Tags) > 0,
+		MatchAll: filter.MatchAll,
+		AnyURL:   filterURL(filter.Tags, false),
+		AllURL:   filterURL(filter.Tags, true),
+	}
+	for _, tag := range tags {
+		name := tag.Name.String
+
+		toggled := make([]string, 0, len(filter.Tags)+1)
+		for _, t := range filter.Tags {
+			if t != name {
+				toggled = append(toggled, t)
+			}
+		}
+		if !selected[name] {
+			toggled = append(toggled, name)
+		}
+
+		dto.Options = append(dto.Options, TagOptionDTO{
+			Name:      name,
+			Selected:  selected[name],
+			ToggleURL: filterURL(toggled, filter.MatchAll),
+		})
+	}
+	return dto
+}
+
+// NotebookDTO is a data transfer object for a notebook as a node of the notebook tree.
+type NotebookDTO struct {
+	ID        int
+	ParentID  int // zero for the top level notebooks
+	Name      string
+	Label     string // the name indented by the notebook depth, used in the select options
+	NoteCount int    // the number of notes directly in the notebook
+	Children  []NotebookDTO
+}
+
+// newNotebookTree arranges a flat list of notebooks as a tree, keeping their order among siblings.
+func newNotebookTree(notebooks []models.Notebook) []NotebookDTO {
+	children := make(map[int][]models.Notebook)
+	for _, nb := range notebooks {
+		parentID := 0
+		if nb.ParentID.Valid {
+			parentID = int(nb.ParentID.Int.Int64())
+		}
+		children[parentID] = append(children[parentID], nb)
+	}
+
+	var build func(parentID, depth int) []NotebookDTO
+	build = func(parentID, depth int) []NotebookDTO {
+		nodes := make([]NotebookDTO, 0, len(children[parentID]))
+		for _, nb := range children[parentID] {
+			id := int(nb.ID.Int.Int64())
+			nodes = append(nodes, NotebookDTO{
+				ID:        id,
+				ParentID:  parentID,
+				Name:      nb.Name.String,
+				Label:     strings.Repeat("— ", depth) + nb.Name.String,
+				NoteCount: int(nb.NoteCount.Int64),
+				Children:  build(id, depth+1),
+			})
+		}
+		return nodes
+	}
+	return build(0, 0)
+}
+
+// flattenNotebookTree lists the notebooks of a tree depth-first, each one followed by its nested notebooks.
+func flattenNotebookTree(tree []NotebookDTO) []NotebookDTO {
+	var flat []NotebookDTO
+	for _, node := range tree {
+		flat = append(flat, node)
+		flat = append(flat, flattenNotebookTree(node.Children)...)
+	}
+	return flat
+}
+
+// findNotebook returns the node of the tree with the given ID.
+func findNotebook(tree []NotebookDTO, id int) (NotebookDTO, bool) {
+	for _, node := range flattenNotebookTree(tree) {
+		if node.ID == id {
+			return node, true
+		}
+	}
+	return NotebookDTO{}, false
+}
+
+// TrashedNoteDTO is a data transfer object for a note in the trash.
+type TrashedNoteDTO struct {
+	NoteDTO
+	DeletedAt string
+	PurgeAt   string // when the note is going to be deleted for good
+}
+
+// newTrashedNoteDTOList creates a new list of TrashedNoteDTOs from a list of
+// models.Note, which are purged after the retention period.
+func newTrashedNoteDTOList(notes []models.Note, retention time.Duration) []TrashedNoteDTO {
+	dtos := make([]TrashedNoteDTO, 0, len(notes))
+	for _, note := range notes {
+		dtos = append(dtos, TrashedNoteDTO{
+			NoteDTO:   newNoteDTO(note),
+			DeletedAt: note.DeletedAt.Time.Format(dateTimeLayout),
+			PurgeAt:   note.DeletedAt.Time.Add(retention).Format(dateTimeLayout),
+		})
+	}
+	return dtos
+}
+
+// RevisionDTO is a data transfer object for a note revision.
+type RevisionDTO struct {
+	ID        int
+	Author    string // the author's email, empty if the author's account was deleted
+	CreatedAt string
+	Title     string
+	Content   string
+	Color     string
+}
+
+// newRevisionDTOList creates a new list of RevisionDTOs from a list of models.NoteRevision.
+func newRevisionDTOList(revisions []models.NoteRevision) []RevisionDTO {
+	dtos := make([]RevisionDTO, 0, len(revisions))
+	for _, rev := range revisions {
+		dtos = append(dtos, RevisionDTO{
+			ID:        int(rev.ID.Int.Int64()),
+			Author:    rev.AuthorEmail.String,
+			CreatedAt: rev.CreatedAt.Time.Format(dateTimeLayout),
+			Title:     rev.Title.String,
+			Content:   rev.Content.String,
+			Color:     rev.Color.String,
+		})
+	}
+	return dtos
+}
+
+// DiffLineDTO is a data transfer object for a line of a diff.
+type DiffLineDTO struct {
+	Class   string // equal, insert or delete
+	Sign    string // the sign prefixing the line in unified diffs
+	OldLine int
+	NewLine int
+	Text    string
+}
+
+// newDiffLineDTOList creates a new list of DiffLineDTOs from the lines of a diff.
+func newDiffLineDTOList(lines []diff.Line) []DiffLineDTO {
+	dtos := make([]DiffLineDTO, 0, len(lines))
+	for _, l := range lines {
+		dto := DiffLineDTO{Class: "equal", Sign: " ", OldLine: l.OldLine, NewLine: l.NewLine, Text: l.Text}
+		switch l.Op {
+		case diff.Insert:
+			dto.Class, dto.Sign = "insert", "+"
+		case diff.Delete:
+			dto.Class, dto.Sign = "delete", "-"
+		}
+		dtos = append(dtos, dto)
+	}
+	return dtos
+}
+
+// AttachmentDTO is a data transfer object for a file attached to a note.
+type AttachmentDTO struct {
+	ID           int
+	NoteID       int
+	Filename     string
+	ContentType  string
+	Size         int64
+	ThumbnailURL string // empty if the attachment has no thumbnail
+}
+
+// URL returns the download URL of the attachment.
+func (dto AttachmentDTO) URL() string {
+	return fmt.Sprintf("/notes/%d/attachments/%d", dto.NoteID, dto.ID)
+}
+
+// HumanSize returns the size of the attachment formatted, such as 1.5 MB.
+func (dto AttachmentDTO) HumanSize() string {
+	return humanSize(dto.Size)
+}
+
+func newAttachmentDTOList(attachments []models.Attachment) []AttachmentDTO {
+	dtos := make([]AttachmentDTO, 0, len(attachments))
+	for _, a := range attachments {
+		dto := AttachmentDTO{
+			ID:          int(a.ID.Int.Int64()),
+			NoteID:      int(a.NoteID.Int.Int64()),
+			Filename:    a.Filename.String,
+			ContentType: a.ContentType.String,
+			Size:        a.Size.Int64,
+		}
+		if slices.Contains(a.Variants, imgproc.VariantThumb) {
+			dto.ThumbnailURL = imageURL(a.StorageKey.String, imgproc.VariantThumb)
+		}
+		dtos = append(dtos, dto)
+	}
+	return dtos
+}
+
+// WorkspaceDTO is a data transfer object for a workspace the user is a member of.
+type WorkspaceDTO struct {
+	ID       int
+	Name     string
+	Personal bool
+	Role     string // the user's role in the workspace
+	Active   bool   // whether it's the workspace the user is working on
+}
+
+// newWorkspaceDTO creates a new WorkspaceDTO from the user's membership, active if it's of the active workspace.
+func newWorkspaceDTO(member models.WorkspaceMember, activeID int64) WorkspaceDTO {
+	return WorkspaceDTO{
+		ID:       int(member.Workspace.ID.Int.Int64()),
+		Name:     member.Workspace.Name.String,
+		Personal: member.Workspace.IsPersonal(),
+		Role:     string(member.Role),
+		Active:   member.Workspace.ID.Int.Int64() == activeID,
+	}
+}
+
+// newWorkspaceDTOList creates a new list of WorkspaceDTOs from the user's memberships.
+func newWorkspaceDTOList(members []models.WorkspaceMember, activeID int64) []WorkspaceDTO {
+	dtos := make([]WorkspaceDTO, 0, len(members))
+	for _, member := range members {
+		dtos = append(dtos, newWorkspaceDTO(member, activeID))
+	}
+	return dtos
+}
+
+// WorkspaceMemberDTO is a data transfer object for a member of a workspace.
+type WorkspaceMemberDTO struct {
+	UserID   int
+	Email    string
+	Role     string
+	JoinedAt string
+}
+
+// newWorkspaceMemberDTOList creates a new list of WorkspaceMemberDTOs from a list of models.WorkspaceMember.
+func newWorkspaceMemberDTOList(members []models.WorkspaceMember) []WorkspaceMemberDTO {
+	dtos := make([]WorkspaceMemberDTO, 0, len(members))
+	for _, member := range members {
+		dtos = append(dtos, WorkspaceMemberDTO{
+			UserID:   int(member.UserID.Int.Int64()),
+			Email:    member.Email.String,
+			Role:     string(member.Role),
+			JoinedAt: member.CreatedAt.Time.Format(dateTimeLayout),
+		})
+	}
+	return dtos
+}
+
+// WorkspaceInviteDTO is a data transfer object for a pending invite to join a workspace.
+type WorkspaceInviteDTO struct {
+	ID        int
+	Email     string
+	Role      string
+	CreatedAt string
+}
+
+// newWorkspaceInviteDTOList creates a new list of WorkspaceInviteDTOs from a list of models.WorkspaceInvite.
+func newWorkspaceInviteDTOList(invites []models.WorkspaceInvite) []WorkspaceInviteDTO {
+	dtos := make([]WorkspaceInviteDTO, 0, len(invites))
+	for _, invite := range invites {
+		dtos = append(dtos, WorkspaceInviteDTO{
+			ID:        int(invite.ID.Int.Int64()),
+			Email:     invite.Email.String,
+			Role:      string(invite.Role),
+			CreatedAt: invite.CreatedAt.Time.Format(dateTimeLayout),
+		})
+	}
+	return dtos
+}
+
+// NoteEventDTO is a data transfer object for a change made to a note, streamed to the browser.
+type NoteEventDTO struct {
+	NoteID int    `json:"note_id"`
+	At     string `json:"at"`
+}
+
+// newNoteEventDTO creates a new NoteEventDTO from an events.NoteEvent.
+func newNoteEventDTO(e events.NoteEvent) NoteEventDTO {
+	return NoteEventDTO{NoteID: int(e.NoteID), At: e.At.Format(time.RFC3339)}
 }
@@ -0,0 +1,133 @@
+// Package handler provides HTTP handlers.
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/LeandroDeJesus-S/quicknote/internal/events"
+	"github.com/LeandroDeJesus-S/quicknote/internal/repo"
+)
+
+const (
+	// eventsHeartbeat is how often a comment is streamed to keep the idle connections open through proxies.
+	eventsHeartbeat = 30 * time.Second
+
+	// eventsRetry is how long in milliseconds the browser waits to reconnect when the stream is lost.
+	eventsRetry = 5000
+)
+
+// publishNoteEvent publishes a change made to a note to the members of its workspace and the users
+// it's shared with, scope being the one of the note's workspace. Listing the users it's shared with may
+// fail, which is logged, the event still being published to the workspace.
+func publishNoteEvent(ctx context.Context, hub *events.Hub, noteShareRepo repo.NoteShareRepository, typ events.NoteEventType, scope repo.Scope, noteID int) {
+	e := events.NoteEvent{
+		Type:        typ,
+		NoteID:      int64(noteID),
+		WorkspaceID: scope.WorkspaceID,
+		UserID:      scope.UserID,
+		At:          time.Now(),
+	}
+
+	shares, err := noteShareRepo.List(ctx, scope, noteID)
+	if err != nil {
+		slog.Error("failed to list the users a note is shared with", "err", err, "note_id", noteID)
+	}
+	for _, share := range shares {
+		e.SharedWith = append(e.SharedWith, share.UserID.Int.Int64())
+	}
+
+	hub.Publish(ctx, e)
+}
+
+// eventHandler streams the changes made to notes to the signed-in users.
+type eventHandler struct {
+	hub           *events.Hub
+	workspaceRepo repo.WorkspaceRepository
+}
+
+// NewEventHandler creates a new eventHandler.
+func NewEventHandler(hub *events.Hub, workspaceRepo repo.WorkspaceRepository) *eventHandler {
+	return &eventHandler{hub: hub, workspaceRepo: workspaceRepo}
+}
+
+// NoteEvents handles the request to stream as server-sent events the changes made to the notes of the
+// active workspace and to the notes shared with the user, until the client goes away.
+//
+// The users a note is shared with are listed as each event is published, while the membership of the user
+// is checked again before streaming an event of the workspace and on every heartbeat, ending the stream once
+// the user is no longer a member. The browser then reconnects within the workspace the user can access now.
+func (eh eventHandler) NoteEvents(w http.ResponseWriter, r *http.Request) error {
+	scope := requestScope(r)
+	sub := eh.hub.Subscribe(func(e events.NoteEvent) bool {
+		return e.VisibleTo(scope.WorkspaceID, scope.UserID)
+	})
+	defer sub.Close()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+
+	rc := http.NewResponseController(w)
+	if _, err := fmt.Fprintf(w, "retry: %d\n\n", eventsRetry); err != nil {
+		return nil
+	}
+	if err := rc.Flush(); err != nil {
+		slog.Error("failed to stream the note events", "err", err)
+		return nil
+	}
+
+	heartbeat := time.NewTicker(eventsHeartbeat)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return nil
+
+		case <-heartbeat.C:
+			if !eh.stillMember(r.Context(), scope) {
+				return nil
+			}
+			if _, err := fmt.Fprint(w, ": ping\n\n"); err != nil {
+				return nil
+			}
+
+		case e, ok := <-sub.Events():
+			if !ok {
+				return nil
+			}
+			if !slices.Contains(e.SharedWith, scope.UserID) && !eh.stillMember(r.Context(), scope) {
+				return nil
+			}
+			data, err := json.Marshal(newNoteEventDTO(e))
+			if err != nil {
+				return err
+			}
+			if _, err := fmt.Fprintf(w, "event: %s\ndata: %s\n\n", e.Type, data); err != nil {
+				return nil
+			}
+		}
+
+		if err := rc.Flush(); err != nil {
+			return nil
+		}
+	}
+}
+
+// stillMember reports whether the user of the scope is still a member of its workspace. A failure to
+// tell is logged and taken as not, as the stream is opened again by the browser.
+func (eh eventHandler) stillMember(ctx context.Context, scope repo.Scope) bool {
+	_, err := eh.workspaceRepo.Membership(ctx, scope.UserID, scope.WorkspaceID)
+	if err != nil && !errors.Is(err, repo.ErrWorkspaceNotFound) && ctx.Err() == nil {
+		slog.Error("failed to check the membership of a note events stream", "err", err, "workspace_id", scope.WorkspaceID)
+	}
+	return err == nil
+}
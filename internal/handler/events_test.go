@@ -0,0 +1,112 @@
+package handler
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/LeandroDeJesus-S/quicknote/internal/events"
+	"github.com/LeandroDeJesus-S/quicknote/internal/models"
+	"github.com/LeandroDeJesus-S/quicknote/internal/repo"
+)
+
+// leavingMembers is a [repo.WorkspaceRepository] whose user is a member until told otherwise.
+type leavingMembers struct {
+	repo.WorkspaceRepository
+	left atomic.Bool
+}
+
+func (l *leavingMembers) Membership(ctx context.Context, userID, workspaceID int64) (*models.WorkspaceMember, error) {
+	if l.left.Load() {
+		return nil, repo.ErrWorkspaceNotFound
+	}
+	return &models.WorkspaceMember{}, nil
+}
+
+// streamRecorder is a flushable [http.ResponseWriter] whose body can be read while it's being written.
+type streamRecorder struct {
+	mu     sync.Mutex
+	header http.Header
+	body   bytes.Buffer
+}
+
+func (s *streamRecorder) Header() http.Header { return s.header }
+func (s *streamRecorder) WriteHeader(int)     {}
+func (s *streamRecorder) Flush()              {}
+
+func (s *streamRecorder) Write(b []byte) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.body.Write(b)
+}
+
+func (s *streamRecorder) String() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.body.String()
+}
+
+func TestNoteEventsEndWhenTheMemberLeaves(t *testing.T) {
+	const workspaceID, userID = 1, 2
+	hub := events.NewHub()
+	members := &leavingMembers{}
+	eh := NewEventHandler(hub, members)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	r := withMember(httptest.NewRequest(http.MethodGet, "/events", nil).WithContext(ctx), workspaceID, userID)
+	w := &streamRecorder{header: make(http.Header)}
+	var streamErr error
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		streamErr = eh.NoteEvents(w, r)
+	}()
+
+	// publishes the event until the stream has it, as the handler subscribes in the background
+	streamed := func(e events.NoteEvent) bool {
+		t.Helper()
+		want := fmt.Sprintf(`"note_id":%d`, e.NoteID)
+		for deadline := time.Now().Add(2 * time.Second); time.Now().Before(deadline); {
+			hub.Publish(ctx, e)
+			select {
+			case <-done:
+				return strings.Contains(w.String(), want)
+			case <-time.After(10 * time.Millisecond):
+			}
+			if strings.Contains(w.String(), want) {
+				return true
+			}
+		}
+		t.Fatalf("the stream neither had note %d nor ended", e.NoteID)
+		return false
+	}
+
+	if !streamed(events.NoteEvent{Type: events.NoteUpdated, NoteID: 1, WorkspaceID: workspaceID}) {
+		t.Fatalf("the event of the workspace was not streamed to a member: %q", w.String())
+	}
+
+	members.left.Store(true)
+	if !streamed(events.NoteEvent{Type: events.NoteUpdated, NoteID: 2, WorkspaceID: 9, SharedWith: []int64{userID}}) {
+		t.Fatalf("the event of a note shared with the user was not streamed: %q", w.String())
+	}
+	if streamed(events.NoteEvent{Type: events.NoteUpdated, NoteID: 3, WorkspaceID: workspaceID}) {
+		t.Errorf("the event of the workspace was streamed to a user who left it: %q", w.String())
+	}
+
+	select {
+	case <-done:
+		if streamErr != nil {
+			t.Errorf("NoteEvents() error = %v", streamErr)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatalf("the stream didn't end once the user left the workspace")
+	}
+}
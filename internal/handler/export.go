@@ -0,0 +1,97 @@
+// Package handler provides HTTP handlers.
+package handler
+
+import (
+	"errors"
+	"io"
+	"log/slog"
+	"mime"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/LeandroDeJesus-S/quicknote/internal/blob"
+	"github.com/LeandroDeJesus-S/quicknote/internal/errs"
+	"github.com/LeandroDeJesus-S/quicknote/internal/models"
+	"github.com/LeandroDeJesus-S/quicknote/internal/repo"
+	"github.com/LeandroDeJesus-S/quicknote/internal/support"
+	"github.com/LeandroDeJesus-S/quicknote/internal/support/authutil"
+	"github.com/alexedwards/scs/v2"
+)
+
+// exportHandler handles the HTTP requests for the archives with the personal data of the users.
+type exportHandler struct {
+	exportRepo repo.DataExportRepository
+	blobs      blob.BlobStore
+	signer     authutil.URLSigner
+	sesMng     *scs.SessionManager
+}
+
+// NewExportHandler creates a new exportHandler.
+func NewExportHandler(exportRepo repo.DataExportRepository, blobs blob.BlobStore, signer authutil.URLSigner, sesMng *scs.SessionManager) *exportHandler {
+	return &exportHandler{exportRepo: exportRepo, blobs: blobs, signer: signer, sesMng: sesMng}
+}
+
+// DataExportRequest handles the request of the user for an archive with their personal data,
+// which is generated in background and its link mailed.
+func (eh exportHandler) DataExportRequest(w http.ResponseWriter, r *http.Request) error {
+	_, err := eh.exportRepo.Create(r.Context(), requestScope(r).UserID)
+	if errors.Is(err, repo.ErrDataExportPending) {
+		support.SendFlashMessage(eh.sesMng, r, support.FlashMsgWarn, "your data export is already being generated, we'll email you once it's ready")
+		http.Redirect(w, r, "/users/me", http.StatusSeeOther)
+		return nil
+	}
+	if err != nil {
+		return errs.NewHTTPError(err, http.StatusInternalServerError, "error requesting data export")
+	}
+
+	support.SendFlashMessage(eh.sesMng, r, support.FlashMsgSuccess, "your data export was requested, we'll email you a link to download it")
+	http.Redirect(w, r, "/users/me", http.StatusSeeOther)
+	return nil
+}
+
+// DataExportDownload handles the request to download an archive through the signed link mailed to the user.
+// The link works without a session, e.g. when opened on another device, until the archive expires.
+func (eh exportHandler) DataExportDownload(w http.ResponseWriter, r *http.Request) error {
+	notFound := errs.NewHTTPError(errors.New("invalid or expired export link"), http.StatusNotFound, "export not found or expired")
+	if !eh.signer.Verify(r.URL.Path, r.URL.Query(), time.Now()) {
+		return notFound
+	}
+
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		return notFound
+	}
+
+	export, err := eh.exportRepo.Find(r.Context(), id)
+	if errors.Is(err, repo.ErrDataExportNotFound) {
+		return notFound
+	}
+	if err != nil {
+		return errs.NewHTTPError(err, http.StatusInternalServerError, "error reading export")
+	}
+	if export.Status != models.DataExportReady || !export.BlobKey.Valid {
+		return notFound
+	}
+
+	content, err := eh.blobs.Get(r.Context(), export.BlobKey.String)
+	if errors.Is(err, blob.ErrNotFound) {
+		return notFound
+	}
+	if err != nil {
+		return errs.NewHTTPError(err, http.StatusInternalServerError, "error reading export")
+	}
+	defer content.Close()
+
+	filename := "quicknote-export-" + export.CreatedAt.Time.Format("20060102") + ".zip"
+	w.Header().Set("Content-Type", "application/zip")
+	w.Header().Set("Content-Length", strconv.FormatInt(export.Size.Int64, 10))
+	w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": filename}))
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.Header().Set("Cache-Control", "private, no-store")
+
+	if _, err := io.Copy(w, content); err != nil {
+		slog.Debug("failed to send data export", "export_id", id, "err", err)
+	}
+	return nil
+}
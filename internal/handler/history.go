@@ -0,0 +1,112 @@
+// Package handler provides HTTP handlers.
+package handler
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/LeandroDeJesus-S/quicknote/internal/errs"
+	"github.com/LeandroDeJesus-S/quicknote/internal/events"
+	"github.com/LeandroDeJesus-S/quicknote/internal/models"
+	"github.com/LeandroDeJesus-S/quicknote/internal/render"
+	"github.com/LeandroDeJesus-S/quicknote/internal/repo"
+	"github.com/LeandroDeJesus-S/quicknote/internal/support"
+	"github.com/LeandroDeJesus-S/quicknote/internal/support/diff"
+)
+
+// findRevision returns the revision with the ID given in the query parameter, or the
+// revision at the fallback index when the parameter is not set.
+func findRevision(revisions []RevisionDTO, rawID string, fallback int) (RevisionDTO, error) {
+	if rawID == "" {
+		return revisions[min(fallback, len(revisions)-1)], nil
+	}
+
+	id, err := strconv.Atoi(rawID)
+	if err != nil {
+		return RevisionDTO{}, errs.NewHTTPError(err, http.StatusBadRequest, "revision id is invalid")
+	}
+	for _, rev := range revisions {
+		if rev.ID == id {
+			return rev, nil
+		}
+	}
+	return RevisionDTO{}, errs.NewHTTPError(repo.ErrRevisionNotFound, http.StatusNotFound, "revision not found")
+}
+
+// NotesHistory handles the request to show the revision history of a note, along with
+// the line-level diff between the "from" and "to" revisions given in the query string.
+// By default the latest revision is compared with the one before it.
+func (nh noteHandler) NotesHistory(w http.ResponseWriter, r *http.Request) error {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		return errs.NewHTTPError(err, http.StatusBadRequest, "id is invalid")
+	}
+
+	access, err := nh.noteAccess(r, id, models.NoteRoleViewer)
+	if err != nil {
+		return err
+	}
+
+	revisions, err := nh.revisionRepo.List(r.Context(), noteScope(r, access), id)
+	if err != nil {
+		return noteError(err, "error listing revisions")
+	}
+	revs := newRevisionDTOList(revisions)
+
+	from, err := findRevision(revs, r.URL.Query().Get("from"), 1)
+	if err != nil {
+		return err
+	}
+	to, err := findRevision(revs, r.URL.Query().Get("to"), 0)
+	if err != nil {
+		return err
+	}
+
+	lines := diff.Lines(from.Content, to.Content)
+	return nh.render.Page(w, r, render.NewOpts().WithPage("note-history.html").WithData(map[string]any{
+		"ID":           id,
+		"Title":        revs[0].Title,
+		"Revisions":    revs,
+		"From":         from,
+		"To":           to,
+		"TitleChanged": from.Title != to.Title,
+		"ColorChanged": from.Color != to.Color,
+		"Changed":      diff.Changed(lines),
+		"Diff":         newDiffLineDTOList(lines),
+		"Access":       access,
+	}))
+}
+
+// NotesHistoryRestore handles the request to bring a note back to one of its revisions.
+// The restored state is saved as a new revision, so restoring can be undone as well.
+func (nh noteHandler) NotesHistoryRestore(w http.ResponseWriter, r *http.Request) error {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		return errs.NewHTTPError(err, http.StatusBadRequest, "id is invalid")
+	}
+	revisionID, err := strconv.Atoi(r.PathValue("revision"))
+	if err != nil {
+		return errs.NewHTTPError(err, http.StatusBadRequest, "revision id is invalid")
+	}
+
+	access, err := nh.noteAccess(r, id, models.NoteRoleEditor)
+	if err != nil {
+		return err
+	}
+
+	_, err = nh.revisionRepo.Restore(r.Context(), noteScope(r, access), id, revisionID)
+	if errors.Is(err, repo.ErrRevisionNotFound) {
+		return errs.NewHTTPError(err, http.StatusNotFound, "revision not found")
+	}
+	if err != nil {
+		return noteError(err, "error restoring revision")
+	}
+
+	nh.publish(r, events.NoteUpdated, noteScope(r, access), id)
+
+	support.SendFlashMessage(nh.sesMng, r, support.FlashMsgSuccess, "revision restored")
+	http.Redirect(w, r, fmt.Sprintf("/notes/%d/history", id), http.StatusSeeOther)
+	return nil
+}
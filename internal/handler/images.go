@@ -0,0 +1,180 @@
+// Package handler provides HTTP handlers.
+package handler
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"mime/multipart"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/LeandroDeJesus-S/quicknote/internal/blob"
+	"github.com/LeandroDeJesus-S/quicknote/internal/errs"
+	"github.com/LeandroDeJesus-S/quicknote/internal/models"
+	"github.com/LeandroDeJesus-S/quicknote/internal/repo"
+	"github.com/LeandroDeJesus-S/quicknote/internal/support/imgproc"
+	"github.com/alexedwards/scs/v2"
+)
+
+// pastedImageField is the form field the note editor pastes images in.
+const pastedImageField = "image"
+
+// imageURL returns the URL the image stored under key is served from, or one of its variants if not empty.
+func imageURL(key, variant string) string {
+	if variant == "" {
+		return "/images/" + key
+	}
+	return "/images/" + key + "/" + variant
+}
+
+// imageHandler serves the images attached to notes and their variants to whoever can read their note,
+// either the users the note belongs to or is shared with, or the sessions that opened a link to it.
+type imageHandler struct {
+	attachmentRepo repo.AttachmentRepository
+	noteShareRepo  repo.NoteShareRepository
+	shareRepo      repo.ShareLinkRepository
+	blobs          blob.BlobStore
+	sesMng         *scs.SessionManager
+}
+
+// NewImageHandler creates a new imageHandler.
+func NewImageHandler(attachmentRepo repo.AttachmentRepository, noteShareRepo repo.NoteShareRepository, shareRepo repo.ShareLinkRepository, blobs blob.BlobStore, sesMng *scs.SessionManager) *imageHandler {
+	return &imageHandler{attachmentRepo: attachmentRepo, noteShareRepo: noteShareRepo, shareRepo: shareRepo, blobs: blobs, sesMng: sesMng}
+}
+
+// canRead reports whether the request can read the images of the note, because the signed-in user
+// can read the note or the session opened a link to it that still works.
+func (ih imageHandler) canRead(r *http.Request, noteID int) (bool, error) {
+	if requestMembership(r) != nil {
+		_, err := ih.noteShareRepo.Access(r.Context(), requestScope(r), noteID)
+		if err == nil {
+			return true, nil
+		}
+		if !errors.Is(err, repo.ErrNoteNotFound) {
+			return false, err
+		}
+	}
+
+	tokenHash := ih.sesMng.GetString(r.Context(), sharedNoteKey(noteID))
+	if tokenHash == "" {
+		return false, nil
+	}
+	link, err := ih.shareRepo.FindByHash(r.Context(), tokenHash)
+	if errors.Is(err, repo.ErrShareLinkNotFound) {
+		ih.sesMng.Remove(r.Context(), sharedNoteKey(noteID))
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return int(link.NoteID.Int.Int64()) == noteID, nil
+}
+
+// Image handles the request of an image or one of its variants.
+// The medium variant of images other than photos is served as WebP to the browsers accepting it,
+// as it is lossless and smaller than PNG then.
+func (ih imageHandler) Image(w http.ResponseWriter, r *http.Request) error {
+	key := r.PathValue("owner") + "/" + r.PathValue("name")
+	variant := r.PathValue("variant")
+
+	image, err := ih.attachmentRepo.ReadImage(r.Context(), key)
+	if err != nil {
+		return attachmentError(err, "error reading image")
+	}
+
+	ok, err := ih.canRead(r, int(image.NoteID.Int.Int64()))
+	if err != nil {
+		return errs.NewHTTPError(err, http.StatusInternalServerError, "error reading image")
+	}
+	if !ok {
+		return attachmentError(repo.ErrAttachmentNotFound, "error reading image")
+	}
+
+	contentType := image.ContentType.String
+	if variant != "" {
+		if !slices.Contains(image.Variants, variant) {
+			return errs.NewHTTPError(fmt.Errorf("unknown image variant: %q", variant), http.StatusNotFound, "image not found")
+		}
+
+		w.Header().Set("Vary", "Accept")
+		if variant == imgproc.VariantMedium && contentType != "image/jpeg" && slices.Contains(image.Variants, imgproc.VariantWebP) &&
+			strings.Contains(r.Header.Get("Accept"), "image/webp") {
+			variant = imgproc.VariantWebP
+		}
+		contentType = imgproc.VariantContentType(contentType, variant)
+		key = blob.VariantKey(key, variant)
+	}
+
+	content, err := ih.blobs.Get(r.Context(), key)
+	if err != nil {
+		return attachmentError(err, "error reading image")
+	}
+	defer content.Close()
+
+	w.Header().Set("Content-Type", contentType)
+	// the key of an image is never reused for other bytes, so the browser keeps it for good
+	w.Header().Set("Cache-Control", "private, max-age=31536000, immutable")
+	w.Header().Set("Content-Security-Policy", "default-src 'none'; sandbox")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+
+	if _, err := io.Copy(w, content); err != nil {
+		slog.Debug("failed to send image", "key", key, "err", err)
+	}
+	return nil
+}
+
+// NotesImagePaste handles the request to attach an image pasted in the note editor.
+// It answers with the markdown that embeds the image in the note content.
+func (nh noteHandler) NotesImagePaste(w http.ResponseWriter, r *http.Request) error {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		return errs.NewHTTPError(err, http.StatusBadRequest, "id is invalid")
+	}
+	if err := parseNoteForm(r); err != nil {
+		return errs.NewHTTPError(err, http.StatusBadRequest, "error parsing form")
+	}
+
+	access, err := nh.noteAccess(r, id, models.NoteRoleEditor)
+	if err != nil {
+		return err
+	}
+
+	var files []*multipart.FileHeader
+	if r.MultipartForm != nil {
+		files = r.MultipartForm.File[pastedImageField]
+	}
+	if len(files) != 1 {
+		return writeAPIError(w, http.StatusBadRequest, "send one image", nil)
+	}
+
+	uploads, msg, err := nh.checkUploads(r, files)
+	if err != nil {
+		return err
+	}
+	if msg == "" && !imgproc.Supported(uploads[0].contentType) {
+		msg = "only PNG, JPEG, GIF and WebP images can be pasted"
+	}
+	if msg != "" {
+		return writeAPIError(w, http.StatusUnprocessableEntity, msg, nil)
+	}
+
+	attachment, err := nh.saveUpload(r, noteScope(r, access), id, uploads[0])
+	var httpErr errs.HTTPError
+	if errors.As(err, &httpErr) {
+		return writeAPIError(w, httpErr.Code(), httpErr.Message(), nil)
+	}
+	if err != nil {
+		return err
+	}
+
+	url := imageURL(attachment.StorageKey.String, imgproc.VariantMedium)
+	alt := strings.NewReplacer("[", "", "]", "").Replace(attachment.Filename.String)
+	return writeJSON(w, http.StatusCreated, map[string]string{
+		"url":      url,
+		"markdown": fmt.Sprintf("![%s](%s)", alt, url),
+	})
+}
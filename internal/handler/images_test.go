@@ -0,0 +1,118 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/LeandroDeJesus-S/quicknote/internal/blob"
+	"github.com/LeandroDeJesus-S/quicknote/internal/errs"
+	"github.com/LeandroDeJesus-S/quicknote/internal/models"
+	"github.com/LeandroDeJesus-S/quicknote/internal/repo"
+	"github.com/alexedwards/scs/v2"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// noteImages is a [repo.AttachmentRepository] holding images of notes by their storage keys.
+type noteImages struct {
+	repo.AttachmentRepository
+
+	images map[string]models.Attachment
+}
+
+func (n noteImages) ReadImage(ctx context.Context, storageKey string) (*models.Attachment, error) {
+	image, ok := n.images[storageKey]
+	if !ok {
+		return nil, repo.ErrAttachmentNotFound
+	}
+	return &image, nil
+}
+
+// noteLinks is a [repo.ShareLinkRepository] holding working links by their token hashes.
+type noteLinks struct {
+	repo.ShareLinkRepository
+
+	links map[string]models.ShareLink
+}
+
+func (n noteLinks) FindByHash(ctx context.Context, tokenHash string) (*models.ShareLink, error) {
+	link, ok := n.links[tokenHash]
+	if !ok {
+		return nil, repo.ErrShareLinkNotFound
+	}
+	return &link, nil
+}
+
+func TestImageAccess(t *testing.T) {
+	notes := newOwnedNotes()
+	const key = "10/a1b2c3"
+	images := noteImages{images: map[string]models.Attachment{
+		key: {NoteID: pgtype.Numeric{Int: big.NewInt(7), Valid: true}, ContentType: pgtype.Text{String: "image/png", Valid: true}},
+	}}
+	links := noteLinks{links: map[string]models.ShareLink{
+		"working": {NoteID: pgtype.Numeric{Int: big.NewInt(7), Valid: true}},
+		"other":   {NoteID: pgtype.Numeric{Int: big.NewInt(8), Valid: true}},
+	}}
+	blobs := blob.NewMemoryStore()
+	blobs.Put(context.Background(), key, strings.NewReader("png"), 3, "image/png")
+
+	sesMng := scs.New()
+	ih := NewImageHandler(images, ownedNotesAccess{notes: notes}, links, blobs, sesMng)
+
+	tests := []struct {
+		name      string
+		member    bool  // whether a user is signed in
+		workspace int64 // the active workspace of the user
+		linkHash  string
+		want      int
+	}{
+		{"owner workspace", true, 1, "", http.StatusOK},
+		{"other workspace", true, 2, "", http.StatusNotFound},
+		{"anonymous", false, 0, "", http.StatusNotFound},
+		{"anonymous through a link", false, 0, "working", http.StatusOK},
+		{"other workspace through a link", true, 2, "working", http.StatusOK},
+		{"anonymous through a revoked link", false, 0, "revoked", http.StatusNotFound},
+		{"anonymous through a link to another note", false, 0, "other", http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got error
+			mux := http.NewServeMux()
+			mux.HandleFunc("GET /images/{owner}/{name}", func(w http.ResponseWriter, r *http.Request) {
+				if tt.linkHash != "" {
+					sesMng.Put(r.Context(), sharedNoteKey(7), tt.linkHash)
+				}
+				if tt.member {
+					r = withMember(r, tt.workspace, 20)
+				}
+				got = ih.Image(w, r)
+			})
+
+			w := httptest.NewRecorder()
+			sesMng.LoadAndSave(mux).ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/images/"+key, nil))
+
+			code := http.StatusOK
+			var httpErr errs.HTTPError
+			if errors.As(got, &httpErr) {
+				code = httpErr.Code()
+			} else if got != nil {
+				t.Fatalf("error = %v, want an HTTPError", got)
+			}
+			if code != tt.want {
+				t.Fatalf("status = %d, want %d: %v", code, tt.want, got)
+			}
+			if code == http.StatusOK {
+				if cc := w.Header().Get("Cache-Control"); cc != "private, max-age=31536000, immutable" {
+					t.Errorf("Cache-Control = %q, want a private immutable one", cc)
+				}
+				if w.Body.String() != "png" {
+					t.Errorf("body = %q, want the image", w.Body)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,303 @@
+// Package handler provides HTTP handlers.
+package handler
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/LeandroDeJesus-S/quicknote/internal/errs"
+	"github.com/LeandroDeJesus-S/quicknote/internal/events"
+	"github.com/LeandroDeJesus-S/quicknote/internal/models"
+	"github.com/LeandroDeJesus-S/quicknote/internal/render"
+	"github.com/LeandroDeJesus-S/quicknote/internal/repo"
+	"github.com/LeandroDeJesus-S/quicknote/internal/support"
+	"github.com/LeandroDeJesus-S/quicknote/internal/validation"
+)
+
+// maxNotebookNameLen is the maximum length of a notebook name.
+const maxNotebookNameLen = 100
+
+// TagNotebookTree returns a template tag listing the notebooks of the signed-in user's active workspace as a tree.
+// It lists nothing for anonymous users.
+func TagNotebookTree(notebookRepo repo.NotebookRepository) render.DynamicTag {
+	return func(r *http.Request) any {
+		return func() []NotebookDTO {
+			if requestMembership(r) == nil {
+				return nil
+			}
+
+			scope := requestScope(r)
+			notebooks, err := notebookRepo.List(r.Context(), scope)
+			if err != nil {
+				slog.Error("failed to list the notebook tree", "err", err, "workspace_id", scope.WorkspaceID)
+				return nil
+			}
+			return newNotebookTree(notebooks)
+		}
+	}
+}
+
+// notebookError converts a repository error into an HTTP error, answering with
+// 404 when the notebook does not exist or belongs to another user.
+func notebookError(err error, message string) error {
+	if errors.Is(err, repo.ErrNotebookNotFound) {
+		return errs.NewHTTPError(err, http.StatusNotFound, "notebook not found")
+	}
+	return errs.NewHTTPError(err, http.StatusInternalServerError, message)
+}
+
+// moveNoteError converts the error of moving a note into an HTTP error.
+func moveNoteError(err error) error {
+	if errors.Is(err, repo.ErrNotebookNotFound) {
+		return notebookError(err, "error moving note")
+	}
+	return noteError(err, "error moving note")
+}
+
+// parseNotebookID parses an optional notebook ID sent in a form, where empty means no notebook.
+func parseNotebookID(raw string) (*int, error) {
+	if raw == "" {
+		return nil, nil
+	}
+	id, err := strconv.Atoi(raw)
+	if err != nil || id <= 0 {
+		return nil, errs.NewHTTPError(fmt.Errorf("invalid notebook id: %q", raw), http.StatusBadRequest, "notebook id is invalid")
+	}
+	return &id, nil
+}
+
+// notebookTree returns the signed-in user's notebooks as a tree.
+func (nh noteHandler) notebookTree(r *http.Request) ([]NotebookDTO, error) {
+	notebooks, err := nh.notebookRepo.List(r.Context(), requestScope(r))
+	if err != nil {
+		return nil, errs.NewHTTPError(err, http.StatusInternalServerError, "error listing notebooks")
+	}
+	return newNotebookTree(notebooks), nil
+}
+
+// notebooksDone flashes a success message and redirects back to the notebooks page.
+func (nh noteHandler) notebooksDone(w http.ResponseWriter, r *http.Request, msg string) error {
+	support.SendFlashMessage(nh.sesMng, r, support.FlashMsgSuccess, msg)
+	http.Redirect(w, r, "/notebooks", http.StatusSeeOther)
+	return nil
+}
+
+// renderNotebooks renders the notebooks page with the user's notebooks merged into data.
+func (nh noteHandler) renderNotebooks(w http.ResponseWriter, r *http.Request, data map[string]any) error {
+	tree, err := nh.notebookTree(r)
+	if err != nil {
+		return err
+	}
+
+	if data == nil {
+		data = make(map[string]any)
+	}
+	data["Notebooks"] = flattenNotebookTree(tree)
+	return nh.render.Page(w, r, render.NewOpts().WithPage("notebooks.html").WithData(data))
+}
+
+// notebookNameError returns why a notebook name is not valid, or an empty string if it is.
+func notebookNameError(name string) string {
+	validator := validation.NewFormValidator()
+	validator.AddValidator([]string{"name"}, validation.ValidateMinMaxLen(1, maxNotebookNameLen))
+	validator.ValidateForm(map[string][]string{"name": {name}})
+	return validator.FieldErrors()["name"]
+}
+
+// Notebooks handles the request to show the notebook management page.
+func (nh noteHandler) Notebooks(w http.ResponseWriter, r *http.Request) error {
+	return nh.renderNotebooks(w, r, nil)
+}
+
+// NotebooksCreate handles the request to create a notebook, optionally nested in another one.
+func (nh noteHandler) NotebooksCreate(w http.ResponseWriter, r *http.Request) error {
+	if err := r.ParseForm(); err != nil {
+		return errs.NewHTTPError(err, http.StatusBadRequest, "failed to parse form")
+	}
+
+	name := strings.TrimSpace(r.PostForm.Get("name"))
+	parentID, err := parseNotebookID(r.PostForm.Get("parent_id"))
+	if err != nil {
+		return err
+	}
+
+	fieldErrors := map[string]string{}
+	if msg := notebookNameError(name); msg != "" {
+		fieldErrors["name"] = msg
+		return nh.renderNotebooks(w, r, map[string]any{"FieldErrors": fieldErrors, "FormData": map[string]string{"name": name}})
+	}
+
+	_, err = nh.notebookRepo.Create(r.Context(), requestScope(r), name, parentID)
+	if errors.Is(err, repo.ErrDuplicatedNotebook) {
+		fieldErrors["name"] = fmt.Sprintf("there is already a notebook named %q there", name)
+		return nh.renderNotebooks(w, r, map[string]any{"FieldErrors": fieldErrors, "FormData": map[string]string{"name": name}})
+	}
+	if err != nil {
+		return notebookError(err, "error creating notebook")
+	}
+
+	return nh.notebooksDone(w, r, "notebook created")
+}
+
+// NotebooksRename handles the request to rename a notebook.
+func (nh noteHandler) NotebooksRename(w http.ResponseWriter, r *http.Request) error {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		return errs.NewHTTPError(err, http.StatusBadRequest, "id is invalid")
+	}
+	if err := r.ParseForm(); err != nil {
+		return errs.NewHTTPError(err, http.StatusBadRequest, "failed to parse form")
+	}
+
+	name := strings.TrimSpace(r.PostForm.Get("name"))
+	fieldErrors := map[string]string{}
+	if msg := notebookNameError(name); msg != "" {
+		fieldErrors["rename"] = msg
+		return nh.renderNotebooks(w, r, map[string]any{"FieldErrors": fieldErrors, "EditingID": id})
+	}
+
+	err = nh.notebookRepo.Rename(r.Context(), requestScope(r), id, name)
+	if errors.Is(err, repo.ErrDuplicatedNotebook) {
+		fieldErrors["rename"] = fmt.Sprintf("there is already a notebook named %q there", name)
+		return nh.renderNotebooks(w, r, map[string]any{"FieldErrors": fieldErrors, "EditingID": id})
+	}
+	if err != nil {
+		return notebookError(err, "error renaming notebook")
+	}
+
+	return nh.notebooksDone(w, r, "notebook renamed")
+}
+
+// NotebooksMove handles the request to move a notebook into another one or to the top level.
+func (nh noteHandler) NotebooksMove(w http.ResponseWriter, r *http.Request) error {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		return errs.NewHTTPError(err, http.StatusBadRequest, "id is invalid")
+	}
+	if err := r.ParseForm(); err != nil {
+		return errs.NewHTTPError(err, http.StatusBadRequest, "failed to parse form")
+	}
+
+	parentID, err := parseNotebookID(r.PostForm.Get("parent_id"))
+	if err != nil {
+		return err
+	}
+
+	err = nh.notebookRepo.Move(r.Context(), requestScope(r), id, parentID)
+	var msg string
+	switch {
+	case errors.Is(err, repo.ErrNotebookCycle):
+		msg = "a notebook cannot be moved into itself or into one of its nested notebooks"
+	case errors.Is(err, repo.ErrDuplicatedNotebook):
+		msg = "there is already a notebook with the same name there"
+	case err != nil:
+		return notebookError(err, "error moving notebook")
+	}
+	if msg != "" {
+		return nh.renderNotebooks(w, r, map[string]any{"FieldErrors": map[string]string{"move": msg}, "EditingID": id})
+	}
+
+	return nh.notebooksDone(w, r, "notebook moved")
+}
+
+// NotebooksDelete handles the request to show the notebook deletion page, which
+// asks whether the notebook content must be deleted too or moved to its parent.
+func (nh noteHandler) NotebooksDelete(w http.ResponseWriter, r *http.Request) error {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		return errs.NewHTTPError(err, http.StatusBadRequest, "id is invalid")
+	}
+
+	tree, err := nh.notebookTree(r)
+	if err != nil {
+		return err
+	}
+	notebook, ok := findNotebook(tree, id)
+	if !ok {
+		return notebookError(repo.ErrNotebookNotFound, "error reading notebook")
+	}
+
+	nested := flattenNotebookTree(notebook.Children)
+	noteCount := notebook.NoteCount
+	for _, nb := range nested {
+		noteCount += nb.NoteCount
+	}
+
+	parentName := ""
+	if parent, ok := findNotebook(tree, notebook.ParentID); ok {
+		parentName = parent.Name
+	}
+
+	return nh.render.Page(w, r, render.NewOpts().WithPage("notebook-delete.html").WithData(map[string]any{
+		"Notebook":    notebook,
+		"NestedCount": len(nested),
+		"NoteCount":   noteCount,
+		"ParentName":  parentName,
+	}))
+}
+
+// NotebooksDeletePost handles the request to delete a notebook. The "mode" form field tells
+// whether its notes and nested notebooks are deleted too ("cascade") or moved to its parent ("rehome").
+func (nh noteHandler) NotebooksDeletePost(w http.ResponseWriter, r *http.Request) error {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		return errs.NewHTTPError(err, http.StatusBadRequest, "id is invalid")
+	}
+	if err := r.ParseForm(); err != nil {
+		return errs.NewHTTPError(err, http.StatusBadRequest, "failed to parse form")
+	}
+
+	var mode repo.NotebookDeleteMode
+	switch r.PostForm.Get("mode") {
+	case "cascade":
+		mode = repo.NotebookDeleteCascade
+	case "rehome":
+		mode = repo.NotebookDeleteRehome
+	default:
+		return errs.NewHTTPError(errors.New("invalid delete mode"), http.StatusBadRequest, "choose what to do with the notebook content")
+	}
+
+	err = nh.notebookRepo.Delete(r.Context(), requestScope(r), id, mode)
+	if errors.Is(err, repo.ErrDuplicatedNotebook) {
+		return errs.NewHTTPError(err, http.StatusConflict, "a nested notebook has the same name as one in the parent notebook, rename it first")
+	}
+	if err != nil {
+		return notebookError(err, "error deleting notebook")
+	}
+
+	return nh.notebooksDone(w, r, "notebook deleted")
+}
+
+// NotesMove handles the request to move a note into a notebook, or out of any notebook
+// when the "notebook_id" form field is empty.
+func (nh noteHandler) NotesMove(w http.ResponseWriter, r *http.Request) error {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		return errs.NewHTTPError(err, http.StatusBadRequest, "id is invalid")
+	}
+	if err := r.ParseForm(); err != nil {
+		return errs.NewHTTPError(err, http.StatusBadRequest, "failed to parse form")
+	}
+
+	notebookID, err := parseNotebookID(r.PostForm.Get("notebook_id"))
+	if err != nil {
+		return err
+	}
+
+	if _, err := nh.noteAccess(r, id, models.NoteRoleOwner); err != nil {
+		return err
+	}
+
+	if err := nh.noteRepo.Move(r.Context(), requestScope(r), id, 0, notebookID); err != nil {
+		return moveNoteError(err)
+	}
+
+	nh.publish(r, events.NoteUpdated, requestScope(r), id)
+	support.SendFlashMessage(nh.sesMng, r, support.FlashMsgSuccess, "note moved")
+	http.Redirect(w, r, fmt.Sprintf("/notes/%d", id), http.StatusSeeOther)
+	return nil
+}
@@ -2,12 +2,22 @@
 package handler
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"log/slog"
 	"net/http"
+	"net/url"
 	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
 
+	"github.com/LeandroDeJesus-S/quicknote/internal/blob"
+	"github.com/LeandroDeJesus-S/quicknote/internal/collab"
 	"github.com/LeandroDeJesus-S/quicknote/internal/errs"
+	"github.com/LeandroDeJesus-S/quicknote/internal/events"
+	"github.com/LeandroDeJesus-S/quicknote/internal/models"
 	"github.com/LeandroDeJesus-S/quicknote/internal/render"
 	"github.com/LeandroDeJesus-S/quicknote/internal/repo"
 	"github.com/LeandroDeJesus-S/quicknote/internal/support"
@@ -18,31 +28,215 @@ import (
 
 // noteHandler handles HTTP requests for notes.
 type noteHandler struct {
-	noteRepo repo.Noter
-	render   render.TemplateRender
-	sesMng   *scs.SessionManager
+	noteRepo       repo.Noter
+	tagRepo        repo.TagRepository
+	notebookRepo   repo.NotebookRepository
+	revisionRepo   repo.RevisionRepository
+	checklistRepo  repo.ChecklistRepository
+	attachmentRepo repo.AttachmentRepository
+	noteShareRepo  repo.NoteShareRepository
+	reminderRepo   repo.ReminderRepository
+	tx             repo.Transactor
+	blobs          blob.BlobStore
+	hub            *events.Hub
+	collabServer   *collab.Server
+	render         render.TemplateRender
+	sesMng         *scs.SessionManager
+
+	trashRetention time.Duration // how long deleted notes stay in the trash
+	attachmentOpts AttachmentOptions
+}
+
+const (
+	maxNoteTags   = 20 // the maximum number of tags a note can have
+	maxTagNameLen = 50 // the maximum length of a tag name
+
+	maxPreviewBodySize = 1 << 20 // the maximum size in bytes of a markdown preview request
+)
+
+// noteFields are the required fields a note form is made of.
+var noteFields = []string{"title", "content", "color"}
+
+// newNoteValidator returns a validator with the note rules applied to the given required fields.
+// The optional comma separated "tags" field is always validated.
+func newNoteValidator(fields ...string) validation.FormValidator {
+	validator := validation.NewFormValidator()
+	validator.AddValidator(fields, validation.ValidateStringNotEmpty)
+	validator.AddValidator([]string{"tags"}, validateTags)
+	return validator
+}
+
+// normalizeTags lower-cases and trims the tag names, dropping the empty and repeated ones.
+func normalizeTags(names []string) []string {
+	tags := []string{}
+	seen := make(map[string]bool)
+	for _, name := range names {
+		name = strings.ToLower(strings.Join(strings.Fields(name), " "))
+		if name == "" || seen[name] {
+			continue
+		}
+		seen[name] = true
+		tags = append(tags, name)
+	}
+	return tags
+}
+
+// parseTags parses a comma separated list of tag names.
+func parseTags(raw string) []string {
+	return normalizeTags(strings.Split(raw, ","))
+}
+
+// tagsError returns why the normalized tags are not valid, or an empty string if they are.
+func tagsError(tags []string) string {
+	if len(tags) > maxNoteTags {
+		return fmt.Sprintf("a note can have at most %d tags", maxNoteTags)
+	}
+	for _, tag := range tags {
+		if utf8.RuneCountInString(tag) > maxTagNameLen {
+			return fmt.Sprintf("tags must have at most %d characters", maxTagNameLen)
+		}
+	}
+	return ""
+}
+
+// validateTags validates a comma separated list of tag names.
+func validateTags(v string) (bool, string) {
+	msg := tagsError(parseTags(v))
+	return msg == "", msg
+}
+
+// noteFilterFromQuery builds the filter of the note listing from the query string:
+// "tag" may be repeated, "match=all" requires every tag instead of any of them and
+// "notebook" narrows the notes down to a notebook and its nested notebooks.
+func noteFilterFromQuery(query url.Values) repo.NoteFilter {
+	notebookID, _ := strconv.Atoi(query.Get("notebook"))
+	return repo.NoteFilter{
+		Tags:       normalizeTags(query["tag"]),
+		MatchAll:   query.Get("match") == "all",
+		NotebookID: max(notebookID, 0),
+	}
 }
 
 // NewNoteHandler creates a new noteHandler.
-func NewNoteHandler(noteRepo repo.Noter, render render.TemplateRender, sesMng *scs.SessionManager) *noteHandler {
-	return &noteHandler{noteRepo: noteRepo, render: render, sesMng: sesMng}
+func NewNoteHandler(noteRepo repo.Noter, tagRepo repo.TagRepository, notebookRepo repo.NotebookRepository, revisionRepo repo.RevisionRepository, checklistRepo repo.ChecklistRepository, attachmentRepo repo.AttachmentRepository, noteShareRepo repo.NoteShareRepository, reminderRepo repo.ReminderRepository, tx repo.Transactor, blobs blob.BlobStore, hub *events.Hub, collabServer *collab.Server, render render.TemplateRender, sesMng *scs.SessionManager, trashRetention time.Duration, attachmentOpts AttachmentOptions) *noteHandler {
+	return &noteHandler{
+		noteRepo:       noteRepo,
+		tagRepo:        tagRepo,
+		notebookRepo:   notebookRepo,
+		revisionRepo:   revisionRepo,
+		checklistRepo:  checklistRepo,
+		attachmentRepo: attachmentRepo,
+		noteShareRepo:  noteShareRepo,
+		reminderRepo:   reminderRepo,
+		tx:             tx,
+		blobs:          blobs,
+		hub:            hub,
+		collabServer:   collabServer,
+		render:         render,
+		sesMng:         sesMng,
+		trashRetention: trashRetention,
+		attachmentOpts: attachmentOpts,
+	}
+}
+
+// publish publishes a change made to a note of the scope's workspace, see [publishNoteEvent].
+func (nh noteHandler) publish(r *http.Request, typ events.NoteEventType, scope repo.Scope, noteID int) {
+	publishNoteEvent(r.Context(), nh.hub, nh.noteShareRepo, typ, scope, noteID)
+}
+
+// userID returns the ID of the signed-in user.
+func (nh noteHandler) userID(r *http.Request) int64 {
+	return nh.sesMng.GetInt64(r.Context(), authutil.DefaultUserIDKey)
+}
+
+// noteError converts a repository error into an HTTP error, answering with
+// 404 when the note does not exist or belongs to another user.
+func noteError(err error, message string) error {
+	if errors.Is(err, repo.ErrNoteNotFound) {
+		return errs.NewHTTPError(err, http.StatusNotFound, "note not found")
+	}
+	return errs.NewHTTPError(err, http.StatusInternalServerError, message)
+}
+
+// noteAccess returns what the signed-in user can do with a note of their workspace or that is shared with them.
+// It answers with 404 if the note is neither, and with 403 if the user's role doesn't allow the needed one.
+func (nh noteHandler) noteAccess(r *http.Request, id int, need models.NoteRole) (*models.NoteAccess, error) {
+	access, err := nh.noteShareRepo.Access(r.Context(), requestScope(r), id)
+	if err != nil {
+		return nil, noteError(err, "error reading note")
+	}
+	if !access.Role.Allows(need) {
+		return nil, errs.NewHTTPError(
+			fmt.Errorf("user %d is a %s of note %d", requestScope(r).UserID, access.Role, id),
+			http.StatusForbidden,
+			fmt.Sprintf("only the note %ss can do this", need),
+		)
+	}
+	return access, nil
+}
+
+// noteScope returns the scope the signed-in user acts on a note within, which is the workspace
+// owning it, even for the notes shared with them.
+func noteScope(r *http.Request, access *models.NoteAccess) repo.Scope {
+	return repo.Scope{WorkspaceID: access.WorkspaceID, UserID: requestScope(r).UserID}
 }
 
 // ListNotes handles the request to list all notes.
+// When the "q" query parameter is set, the notes matching the search are listed instead, best ranked first.
 func (nh noteHandler) ListNotes(w http.ResponseWriter, r *http.Request) error {
-	notes, err := nh.noteRepo.List(
-		r.Context(),
-		nh.sesMng.GetInt64(r.Context(), authutil.DefaultUserIDKey),
-	)
+	query := strings.TrimSpace(r.URL.Query().Get("q"))
+	if query != "" {
+		results, err := nh.noteRepo.Search(r.Context(), requestScope(r), query)
+		if err != nil {
+			return errs.NewHTTPError(err, http.StatusInternalServerError, "error searching notes")
+		}
+
+		return nh.render.Page(
+			w,
+			r,
+			render.NewOpts().WithPage("list.html").WithData(map[string]any{
+				"Query":   query,
+				"Results": newNoteSearchDTOList(results),
+			}),
+		)
+	}
+
+	filter := noteFilterFromQuery(r.URL.Query())
+	notes, err := nh.noteRepo.List(r.Context(), requestScope(r), filter)
 	if err != nil {
 		return errs.NewHTTPError(err, http.StatusInternalServerError, "error listing notes")
 	}
 
-	return nh.render.Page(
-		w,
-		r,
-		render.NewOpts().WithPage("list.html").WithData(newNoteDTOList(notes)),
-	)
+	tags, err := nh.tagRepo.List(r.Context(), requestScope(r))
+	if err != nil {
+		return errs.NewHTTPError(err, http.StatusInternalServerError, "error listing tags")
+	}
+
+	data := map[string]any{
+		"Notes":     newNoteDTOList(notes),
+		"TagFilter": newTagFilterDTO(tags, filter),
+	}
+	if filter.NotebookID > 0 {
+		notebook, err := nh.notebookRepo.ReadOne(r.Context(), requestScope(r), filter.NotebookID)
+		if err != nil {
+			return notebookError(err, "error reading notebook")
+		}
+		data["Notebook"] = NotebookDTO{ID: filter.NotebookID, Name: notebook.Name.String}
+	}
+
+	return nh.render.Page(w, r, render.NewOpts().WithPage("list.html").WithData(data))
+}
+
+// SharedWithMe handles the request to list the notes other users shared with the signed-in user.
+func (nh noteHandler) SharedWithMe(w http.ResponseWriter, r *http.Request) error {
+	notes, err := nh.noteShareRepo.ListSharedWith(r.Context(), nh.userID(r))
+	if err != nil {
+		return errs.NewHTTPError(err, http.StatusInternalServerError, "error listing shared notes")
+	}
+
+	return nh.render.Page(w, r, render.NewOpts().WithPage("shared.html").WithData(map[string]any{
+		"Notes": newSharedNoteDTOList(notes),
+	}))
 }
 
 // NotesDetail handles the request to show the details of a note.
@@ -55,33 +249,90 @@ func (nh noteHandler) NotesDetail(w http.ResponseWriter, r *http.Request) error
 		return errs.NewHTTPError(err, http.StatusBadRequest, "id is invalid")
 	}
 
-	note, err := nh.noteRepo.ReadOne(r.Context(), id)
+	access, err := nh.noteAccess(r, id, models.NoteRoleViewer)
+	if err != nil {
+		return err
+	}
+
+	note, err := nh.noteRepo.ReadOne(r.Context(), noteScope(r, access), id)
 	if err != nil {
-		return errs.NewHTTPError(err, http.StatusInternalServerError, "error reading note")
+		return noteError(err, "error reading note")
 	}
 
+	tree, err := nh.notebookTree(r)
+	if err != nil {
+		return err
+	}
+
+	contentHTML, err := render.Markdown(note.Content.String)
+	if err != nil {
+		return errs.NewHTTPError(err, http.StatusInternalServerError, "error rendering note")
+	}
+
+	attachments, err := nh.attachmentRepo.List(r.Context(), noteScope(r, access), id)
+	if err != nil {
+		return errs.NewHTTPError(err, http.StatusInternalServerError, "error listing attachments")
+	}
+
+	dto := newNoteDTO(*note)
 	slog.Debug("rendering note detail", "note_id", id)
 	return nh.render.Page(
 		w,
 		r,
 		render.NewOpts().WithPage("detail.html").WithData(
-			map[string]any{"ID": id, "noteName": note.Title.String, "noteContent": note.Content.String},
+			map[string]any{
+				"ID":          id,
+				"noteName":    note.Title.String,
+				"noteContent": contentHTML,
+				"Note":        dto,
+				"Attachments": newAttachmentDTOList(attachments),
+				"Access":      access,
+				"NotebookID":  dto.notebookID(),
+				"Notebooks":   flattenNotebookTree(tree),
+			},
 		),
 	)
 }
 
+// NotesPreview handles the request to preview the markdown of a note being edited.
+// It answers with the rendered HTML fragment of the "content" form field.
+func (nh noteHandler) NotesPreview(w http.ResponseWriter, r *http.Request) error {
+	r.Body = http.MaxBytesReader(w, r.Body, maxPreviewBodySize)
+	if err := r.ParseForm(); err != nil {
+		return errs.NewHTTPError(err, http.StatusBadRequest, "error parsing form")
+	}
+
+	contentHTML, err := render.Markdown(r.PostForm.Get("content"))
+	if err != nil {
+		return errs.NewHTTPError(err, http.StatusInternalServerError, "error rendering preview")
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	_, err = w.Write([]byte(contentHTML))
+	return err
+}
+
 // NotesCreate handles the request to show the create note page.
+// The "notebook" query parameter preselects the notebook the note is created in.
 func (nh noteHandler) NotesCreate(w http.ResponseWriter, r *http.Request) error {
+	tree, err := nh.notebookTree(r)
+	if err != nil {
+		return err
+	}
+
+	noteR := newNoteRequestDTO()
+	noteR.NotebookID = noteFilterFromQuery(r.URL.Query()).NotebookID
+
 	return nh.render.Page(
 		w,
 		r,
 		render.NewOpts().WithPage("create.html").WithData(
-			map[string]any{"note": newNoteRequestDTO()},
+			map[string]any{"note": noteR, "Notebooks": flattenNotebookTree(tree)},
 		),
 	)
 }
 
-// NotesDelete handles the request to delete a note.
+// NotesDelete handles the request to delete a note, which moves it to the trash.
 func (nh noteHandler) NotesDelete(w http.ResponseWriter, r *http.Request) error {
 	rawNoteID := r.PathValue("id")
 
@@ -90,10 +341,20 @@ func (nh noteHandler) NotesDelete(w http.ResponseWriter, r *http.Request) error
 		return errs.NewHTTPError(err, http.StatusBadRequest, "invalid note id")
 	}
 
-	if err := nh.noteRepo.Delete(r.Context(), numID); err != nil {
-		slog.Error("Failed to delete note", "err", err, "note_id", numID)
+	if _, err := nh.noteAccess(r, numID, models.NoteRoleOwner); err != nil {
+		return err
+	}
+	if err := requireRole(r, notesManagerRole); err != nil {
+		return err
+	}
+
+	if err := nh.noteRepo.Delete(r.Context(), requestScope(r), numID); err != nil {
+		return noteError(err, "error deleting note")
 	}
 
+	nh.publish(r, events.NoteDeleted, requestScope(r), numID)
+	support.SendFlashMessage(nh.sesMng, r, support.FlashMsgSuccess, "note moved to the trash")
+	http.Redirect(w, r, "/notes", http.StatusSeeOther)
 	return nil
 }
 
@@ -106,28 +367,71 @@ func (nh noteHandler) NotesUpdate(w http.ResponseWriter, r *http.Request) error
 		return errs.NewHTTPError(err, http.StatusBadRequest, "id is invalid")
 	}
 
-	note, err := nh.noteRepo.ReadOne(r.Context(), id)
+	access, err := nh.noteAccess(r, id, models.NoteRoleEditor)
 	if err != nil {
-		return errs.NewHTTPError(err, http.StatusInternalServerError, "error reading note")
+		return err
+	}
+
+	note, err := nh.noteRepo.ReadOne(r.Context(), noteScope(r, access), id)
+	if err != nil {
+		return noteError(err, "error reading note")
 	}
 	noteR := newNoteRequestDTO()
 	noteR.ID = id
+	noteR.Shared = !access.IsOwner()
 	noteR.Title = note.Title.String
 	noteR.Content = note.Content.String
 	noteR.Color = note.Color.String
+	noteR.Tags = strings.Join(note.Tags, ", ")
+	noteR.NotebookID = newNoteDTO(*note).notebookID()
+	noteR.Version = int(note.Version.Int32)
+	noteR.Type = string(note.Type)
+	noteR.Items = formatChecklistItems(note.Items)
+	if at := reminderTime(*note, note.RemindAt); at != nil {
+		noteR.RemindAt = at.Format(reminderLayout)
+		noteR.RemindTimezone = at.Location().String()
+		noteR.RemindRecurrence = string(note.RemindRecurrence)
+	}
+
+	tree, err := nh.notebookTree(r)
+	if err != nil {
+		return err
+	}
 
 	return nh.render.Page(
 		w,
 		r,
 		render.NewOpts().WithPage("note-edit.html").WithData(
-			map[string]any{"note": noteR},
+			map[string]any{"note": noteR, "Notebooks": flattenNotebookTree(tree)},
 		),
 	)
 }
 
+// renderConflict renders the edit page again when the note was changed by someone else while
+// it was being edited, showing the saved note next to the submitted one. The form then carries
+// the current version, so submitting it again overwrites the saved note with the resolved one.
+func (nh noteHandler) renderConflict(w http.ResponseWriter, r *http.Request, noteR NoteRequestDTO, current NoteDTO) error {
+	tree, err := nh.notebookTree(r)
+	if err != nil {
+		return err
+	}
+
+	noteR.Version = current.Version
+	return nh.render.Page(
+		w,
+		r,
+		render.NewOpts().WithPage("note-edit.html").WithStatus(http.StatusConflict).WithData(map[string]any{
+			"note":      noteR,
+			"Conflict":  current,
+			"Notebooks": flattenNotebookTree(tree),
+		}),
+	)
+}
+
 // Save handles the request to save a note.
+// Edits carry the version of the note they started from, see [noteHandler.renderConflict].
 func (nh noteHandler) Save(w http.ResponseWriter, r *http.Request) error {
-	if err := r.ParseForm(); err != nil {
+	if err := parseNoteForm(r); err != nil {
 		return errs.NewHTTPError(err, http.StatusBadRequest, "error parsing form")
 	}
 	defer r.Body.Close()
@@ -143,12 +447,72 @@ func (nh noteHandler) Save(w http.ResponseWriter, r *http.Request) error {
 	noteR.Title = r.PostForm.Get("title")
 	noteR.Content = r.PostForm.Get("content")
 	noteR.Color = r.PostForm.Get("color")
+	noteR.Tags = r.PostForm.Get("tags")
+	noteR.Items = r.PostForm.Get("items")
+	noteR.RemindAt = r.PostForm.Get("remind_at")
+	noteR.RemindTimezone = r.PostForm.Get("remind_timezone")
+	noteR.RemindRecurrence = r.PostForm.Get("remind_recurrence")
 
-	validator := validation.NewFormValidator()
-	validator.AddValidator([]string{"title", "content", "color"}, validation.ValidateStringNotEmpty)
+	// a shared note is saved within the workspace owning it, along with its tags, checklist items and attachments
+	scope := requestScope(r)
+	if id > 0 {
+		access, err := nh.noteAccess(r, id, models.NoteRoleEditor)
+		if err != nil {
+			return err
+		}
+		scope, noteR.Shared = noteScope(r, access), !access.IsOwner()
+	}
+
+	noteType, ok := parseNoteType(r.PostForm.Get("type"))
+	if !ok {
+		return errs.NewHTTPError(fmt.Errorf("invalid note type: %q", r.PostForm.Get("type")), http.StatusBadRequest, "note type is invalid")
+	}
+	noteR.Type = string(noteType)
 
+	rawVersion := r.PostForm.Get("version")
+	noteR.Version, err = strconv.Atoi(rawVersion)
+	if err != nil && rawVersion != "" {
+		return errs.NewHTTPError(err, http.StatusBadRequest, "version is invalid")
+	}
+
+	notebookID, err := parseNotebookID(r.PostForm.Get("notebook_id"))
+	if err != nil {
+		return err
+	}
+	if notebookID != nil {
+		noteR.NotebookID = *notebookID
+	}
+
+	validator := newNoteValidator(requiredNoteFields(noteType)...)
 	validator.ValidateForm(r.PostForm)
+
+	var items []repo.ChecklistItemInput
+	if noteType == models.NoteTypeChecklist {
+		items = parseChecklistItems(noteR.Items)
+		if msg := checklistItemsError(items); msg != "" {
+			validator.AddError("items", msg)
+		}
+	}
+
+	reminder, msg := parseReminder(r.PostForm)
+	if msg != "" {
+		validator.AddError("remind_at", msg)
+	}
+
+	uploads, msg, err := nh.checkUploads(r, uploadedFiles(r))
+	if err != nil {
+		return err
+	}
+	if msg != "" {
+		validator.AddError("attachments", msg)
+	}
+
 	if !validator.Ok() {
+		tree, err := nh.notebookTree(r)
+		if err != nil {
+			return err
+		}
+
 		page := support.TernaryIf(id > 0, "note-edit.html", "create.html")
 		nh.render.Page(
 			w,
@@ -156,37 +520,87 @@ func (nh noteHandler) Save(w http.ResponseWriter, r *http.Request) error {
 			render.NewOpts().WithPage(page).WithData(map[string]any{
 				"FieldErrors": validator.FieldErrors(),
 				"note":        noteR,
+				"Notebooks":   flattenNotebookTree(tree),
 			}),
 		)
 		return nil
 	}
 
-	if id > 0 {
-		note, err := nh.noteRepo.Update(r.Context(), id, map[string]any{
-			"title":   r.PostForm.Get("title"),
-			"content": r.PostForm.Get("content"),
-			"color":   r.PostForm.Get("color"),
-		})
-		if err != nil {
-			return errs.NewHTTPError(err, http.StatusInternalServerError, "error updating note")
+	tags := parseTags(r.PostForm.Get("tags"))
+
+	// the note is saved along with its tags, items, notebook, reminder and attachments, or not at all
+	var (
+		note     *models.Note
+		attached []models.Attachment
+	)
+	err = nh.tx.InTx(r.Context(), func(ctx context.Context) error {
+		r := r.WithContext(ctx)
+
+		var err error
+		if id > 0 {
+			note, err = nh.noteRepo.Update(ctx, scope, id, noteR.Version, map[string]any{
+				"title":   r.PostForm.Get("title"),
+				"content": r.PostForm.Get("content"),
+				"color":   r.PostForm.Get("color"),
+			})
+			if err != nil {
+				return noteError(err, "error updating note")
+			}
+		} else {
+			note, err = nh.noteRepo.Create(ctx, scope, noteType, r.PostForm.Get("title"), r.PostForm.Get("content"), r.PostForm.Get("color"))
+			if err != nil {
+				return errs.NewHTTPError(err, http.StatusInternalServerError, "error creating note")
+			}
 		}
+		noteID := int(note.ID.Int.Int64())
 
-		http.Redirect(w, r, fmt.Sprintf("/notes/%d", note.ID.Int), http.StatusFound)
-		return nil
-	}
+		// the note is locked from here on, so what belongs to it is changed whatever its version
+		if err := nh.tagRepo.SetNoteTags(ctx, scope, noteID, 0, tags); err != nil {
+			return noteError(err, "error tagging note")
+		}
 
-	newNote, err := nh.noteRepo.Create(
-		r.Context(),
-		nh.sesMng.GetInt64(r.Context(), authutil.DefaultUserIDKey),
-		r.PostForm.Get("title"),
-		r.PostForm.Get("content"),
-		r.PostForm.Get("color"),
-	)
+		if noteType == models.NoteTypeChecklist {
+			if err := nh.checklistRepo.SetItems(ctx, scope, noteID, 0, items); err != nil {
+				return noteError(err, "error saving checklist items")
+			}
+		}
+
+		// notebooks belong to the workspace, so only its members file the note
+		if (id > 0 && !noteR.Shared) || (id == 0 && notebookID != nil) {
+			if err := nh.noteRepo.Move(ctx, scope, noteID, 0, notebookID); err != nil {
+				return moveNoteError(err)
+			}
+		}
+
+		if (id > 0 && reminderChanged(*note, reminder)) || (id == 0 && reminder != nil) {
+			if err := nh.reminderRepo.Set(ctx, scope, noteID, reminder); err != nil {
+				return noteError(err, "error setting reminder")
+			}
+		}
+
+		attached, err = nh.saveUploads(r, scope, noteID, uploads)
+		return err
+	})
 	if err != nil {
-		return errs.NewHTTPError(err, http.StatusInternalServerError, "error creating note")
+		nh.deleteAttachmentBlobs(r.Context(), attached)
+
+		var conflict *repo.NoteConflictError
+		if errors.As(err, &conflict) {
+			return nh.renderConflict(w, r, noteR, newNoteDTO(*conflict.Current))
+		}
+		var httpErr errs.HTTPError
+		if !errors.As(err, &httpErr) {
+			return errs.NewHTTPError(err, http.StatusInternalServerError, "error saving note")
+		}
+		return err
 	}
 
-	slog.Debug("note created successfully", "note_id", newNote.ID.Int)
-	http.Redirect(w, r, fmt.Sprintf("/notes/%d", newNote.ID.Int), http.StatusFound)
+	if id > 0 {
+		nh.publish(r, events.NoteUpdated, scope, id)
+	} else {
+		nh.publish(r, events.NoteCreated, scope, int(note.ID.Int.Int64()))
+		slog.Debug("note created successfully", "note_id", note.ID.Int)
+	}
+	http.Redirect(w, r, fmt.Sprintf("/notes/%d", note.ID.Int), http.StatusFound)
 	return nil
 }
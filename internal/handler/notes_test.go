@@ -0,0 +1,205 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/LeandroDeJesus-S/quicknote/internal/errs"
+	"github.com/LeandroDeJesus-S/quicknote/internal/models"
+	"github.com/LeandroDeJesus-S/quicknote/internal/repo"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// ownedNotes is a [repo.Noter] holding the notes of a single workspace, which answers like the
+// repository does for the other scopes. Calling the methods it doesn't implement panics, as no test
+// should reach them.
+type ownedNotes struct {
+	repo.Noter
+
+	workspaceID int64
+	notes       map[int]models.Note
+}
+
+func (o ownedNotes) ReadOne(ctx context.Context, scope repo.Scope, id int) (*models.Note, error) {
+	note, ok := o.notes[id]
+	if !ok || scope.WorkspaceID != o.workspaceID {
+		return nil, repo.ErrNoteNotFound
+	}
+	return &note, nil
+}
+
+func (o ownedNotes) Delete(ctx context.Context, scope repo.Scope, id int) error {
+	_, err := o.ReadOne(ctx, scope, id)
+	return err
+}
+
+// ownedNotesAccess is a [repo.NoteShareRepository] of [ownedNotes], which are shared with no one.
+type ownedNotesAccess struct {
+	repo.NoteShareRepository
+
+	notes ownedNotes
+}
+
+func (o ownedNotesAccess) Access(ctx context.Context, scope repo.Scope, id int) (*models.NoteAccess, error) {
+	if _, err := o.notes.ReadOne(ctx, scope, id); err != nil {
+		return nil, err
+	}
+	return &models.NoteAccess{Role: models.NoteRoleOwner}, nil
+}
+
+// withMember returns r as sent by the user signed in to the workspace they own.
+func withMember(r *http.Request, workspaceID, userID int64) *http.Request {
+	return withRole(r, workspaceID, userID, models.WorkspaceRoleOwner)
+}
+
+// withRole returns r as sent by the user signed in to the workspace with the role.
+func withRole(r *http.Request, workspaceID, userID int64, role models.WorkspaceRole) *http.Request {
+	member := &models.WorkspaceMember{
+		Workspace: models.Workspace{ID: pgtype.Numeric{Int: big.NewInt(workspaceID), Valid: true}},
+		UserID:    pgtype.Numeric{Int: big.NewInt(userID), Valid: true},
+		Role:      role,
+	}
+	return r.WithContext(context.WithValue(r.Context(), membershipKey{}, member))
+}
+
+func newOwnedNotes() ownedNotes {
+	return ownedNotes{
+		workspaceID: 1,
+		notes: map[int]models.Note{
+			7: {ID: pgtype.Numeric{Int: big.NewInt(7), Valid: true}, Title: pgtype.Text{String: "Secret", Valid: true}},
+		},
+	}
+}
+
+func TestNoteErrorNotFound(t *testing.T) {
+	err := noteError(repo.ErrNoteNotFound, "error reading note")
+
+	var httpErr errs.HTTPError
+	if !errors.As(err, &httpErr) || httpErr.Code() != http.StatusNotFound {
+		t.Fatalf("noteError(ErrNoteNotFound) = %v, want a 404 HTTPError", err)
+	}
+	if !errors.Is(err, repo.ErrNoteNotFound) {
+		t.Errorf("noteError() doesn't wrap ErrNoteNotFound")
+	}
+
+	if err := noteError(errors.New("connection reset"), "error reading note"); !errors.As(err, &httpErr) || httpErr.Code() != http.StatusInternalServerError {
+		t.Errorf("noteError(other) = %v, want a 500 HTTPError", err)
+	}
+}
+
+func TestNoteHandlersDenyOtherWorkspaces(t *testing.T) {
+	notes := newOwnedNotes()
+	nh := noteHandler{noteRepo: notes, noteShareRepo: ownedNotesAccess{notes: notes}}
+
+	handlers := []struct {
+		name    string
+		method  string
+		pattern string
+		handle  func(http.ResponseWriter, *http.Request) error
+	}{
+		{"detail", http.MethodGet, "GET /notes/{id}", nh.NotesDetail},
+		{"delete", http.MethodDelete, "DELETE /notes/{id}", nh.NotesDelete},
+	}
+	for _, tt := range handlers {
+		t.Run(tt.name, func(t *testing.T) {
+			var got error
+			mux := http.NewServeMux()
+			mux.HandleFunc(tt.pattern, func(w http.ResponseWriter, r *http.Request) {
+				got = tt.handle(w, r)
+			})
+
+			r := withMember(httptest.NewRequest(tt.method, "/notes/7", nil), 2, 20)
+			mux.ServeHTTP(httptest.NewRecorder(), r)
+
+			var httpErr errs.HTTPError
+			if !errors.As(got, &httpErr) || httpErr.Code() != http.StatusNotFound {
+				t.Fatalf("error = %v, want a 404 HTTPError", got)
+			}
+			if !errors.Is(got, repo.ErrNoteNotFound) {
+				t.Errorf("error = %v, want it to wrap ErrNoteNotFound", got)
+			}
+		})
+	}
+}
+
+func TestAPINoteGetDeniesOtherWorkspaces(t *testing.T) {
+	notes := newOwnedNotes()
+	ah := NewAPINoteHandler(notes, nil, nil, ownedNotesAccess{notes: notes}, nil, nil)
+	apiErrH := APIErrorHandler{}
+
+	mux := http.NewServeMux()
+	mux.Handle("GET /api/v1/notes/{id}", apiErrH.Wrap(ah.Get))
+
+	tests := []struct {
+		name        string
+		workspaceID int64
+		want        int
+	}{
+		{"owner workspace", 1, http.StatusOK},
+		{"other workspace", 2, http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			mux.ServeHTTP(w, withMember(httptest.NewRequest(http.MethodGet, "/api/v1/notes/7", nil), tt.workspaceID, 20))
+			if w.Code != tt.want {
+				t.Errorf("status = %d, want %d: %s", w.Code, tt.want, w.Body)
+			}
+		})
+	}
+}
+
+func TestNoteHandlersRequireAdmin(t *testing.T) {
+	notes := newOwnedNotes()
+	nh := noteHandler{noteRepo: notes, noteShareRepo: ownedNotesAccess{notes: notes}}
+	sh := shareHandler{}
+	ah := NewAPINoteHandler(notes, nil, nil, ownedNotesAccess{notes: notes}, nil, nil)
+
+	handlers := []struct {
+		name    string
+		pattern string
+		target  string
+		handle  func(http.ResponseWriter, *http.Request) error
+	}{
+		{"delete", "DELETE /notes/{id}", "/notes/7", nh.NotesDelete},
+		{"api delete", "DELETE /api/v1/notes/{id}", "/api/v1/notes/7", ah.Delete},
+		{"restore", "POST /notes/{id}/restore", "/notes/7/restore", nh.TrashRestore},
+		{"purge", "POST /notes/{id}/purge", "/notes/7/purge", nh.TrashPurge},
+		{"empty trash", "POST /notes/trash/empty", "/notes/trash/empty", nh.TrashEmpty},
+		{"shares", "GET /notes/{id}/shares", "/notes/7/shares", sh.Shares},
+		{"create link", "POST /notes/{id}/shares", "/notes/7/shares", sh.SharesCreate},
+		{"revoke link", "POST /notes/{id}/shares/{share}/revoke", "/notes/7/shares/1/revoke", sh.SharesRevoke},
+		{"share with user", "POST /notes/{id}/shares/users", "/notes/7/shares/users", sh.SharesUserAdd},
+		{"unshare with user", "POST /notes/{id}/shares/users/{share}/remove", "/notes/7/shares/users/1/remove", sh.SharesUserRemove},
+	}
+	for _, tt := range handlers {
+		t.Run(tt.name, func(t *testing.T) {
+			var got error
+			mux := http.NewServeMux()
+			mux.HandleFunc(tt.pattern, func(w http.ResponseWriter, r *http.Request) {
+				got = tt.handle(w, r)
+			})
+
+			method, _, _ := strings.Cut(tt.pattern, " ")
+			r := withRole(httptest.NewRequest(method, tt.target, nil), 1, 20, models.WorkspaceRoleMember)
+			mux.ServeHTTP(httptest.NewRecorder(), r)
+
+			var httpErr errs.HTTPError
+			if !errors.As(got, &httpErr) || httpErr.Code() != http.StatusForbidden {
+				t.Fatalf("error = %v, want a 403 HTTPError for a member", got)
+			}
+		})
+	}
+
+	for _, role := range models.WorkspaceRoles {
+		r := withRole(httptest.NewRequest(http.MethodGet, "/", nil), 1, 20, role)
+		if allowed := requireRole(r, notesManagerRole) == nil; allowed != role.Allows(models.WorkspaceRoleAdmin) {
+			t.Errorf("requireRole() allowed a %s = %v", role, allowed)
+		}
+	}
+}
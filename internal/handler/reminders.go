@@ -0,0 +1,67 @@
+// Package handler provides HTTP handlers.
+package handler
+
+import (
+	"net/url"
+	"time"
+
+	"github.com/LeandroDeJesus-S/quicknote/internal/models"
+	"github.com/LeandroDeJesus-S/quicknote/internal/repo"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// reminderLayout is the layout of the datetime-local inputs the reminders are set with.
+const reminderLayout = "2006-01-02T15:04"
+
+// reminderLocation returns the time zone of a reminder, UTC if it's unknown.
+func reminderLocation(name string) *time.Location {
+	loc, err := time.LoadLocation(name)
+	if err != nil || name == "" {
+		return time.UTC
+	}
+	return loc
+}
+
+// parseReminder parses the reminder of a note form, set at "remind_at" in the "remind_timezone" time zone,
+// which the browser fills in, and repeated as "remind_recurrence". It returns nil when "remind_at" is empty,
+// which removes the reminder, or why the reminder is invalid.
+func parseReminder(form url.Values) (*repo.ReminderInput, string) {
+	recurrence := models.Recurrence(form.Get("remind_recurrence"))
+	if !recurrence.Valid() {
+		return nil, "the reminder recurrence is invalid"
+	}
+
+	raw := form.Get("remind_at")
+	if raw == "" {
+		return nil, ""
+	}
+
+	loc := reminderLocation(form.Get("remind_timezone"))
+	at, err := time.ParseInLocation(reminderLayout, raw, loc)
+	if err != nil {
+		return nil, "the reminder date is invalid"
+	}
+	return &repo.ReminderInput{At: at, Timezone: loc.String(), Recurrence: recurrence}, ""
+}
+
+// reminderChanged reports whether the reminder set on the note is other than the one of the form.
+func reminderChanged(note models.Note, reminder *repo.ReminderInput) bool {
+	if reminder == nil {
+		return note.RemindAt.Valid
+	}
+	if !note.RemindAt.Valid {
+		return true
+	}
+	return !note.RemindAt.Time.Equal(reminder.At) ||
+		note.RemindTimezone.String != reminder.Timezone ||
+		note.RemindRecurrence != reminder.Recurrence
+}
+
+// reminderTime returns a time of the reminder of the note in the time zone it was set in, or nil if it's not valid.
+func reminderTime(note models.Note, t pgtype.Timestamptz) *time.Time {
+	if !t.Valid {
+		return nil
+	}
+	at := t.Time.In(reminderLocation(note.RemindTimezone.String))
+	return &at
+}
@@ -1,11 +1,14 @@
 package handler
 
 import (
-	"fmt"
+	"context"
 	"io/fs"
 	"net/http"
 
 	"github.com/LeandroDeJesus-S/quicknote/config"
+	"github.com/LeandroDeJesus-S/quicknote/internal/blob"
+	"github.com/LeandroDeJesus-S/quicknote/internal/collab"
+	"github.com/LeandroDeJesus-S/quicknote/internal/events"
 	"github.com/LeandroDeJesus-S/quicknote/internal/mail"
 	"github.com/LeandroDeJesus-S/quicknote/internal/render"
 	"github.com/LeandroDeJesus-S/quicknote/internal/repo"
@@ -19,16 +22,21 @@ type Mux struct {
 	*http.ServeMux
 }
 
-func NewMux(noteRepo repo.Noter, userRepo repo.UserRepository, pwHasher authutil.PasswordHasher, sessionMng *scs.SessionManager, mailer mail.Mailer, conf *config.Config) *Mux {
+func NewMux(noteRepo repo.Noter, tagRepo repo.TagRepository, notebookRepo repo.NotebookRepository, revisionRepo repo.RevisionRepository, checklistRepo repo.ChecklistRepository, attachmentRepo repo.AttachmentRepository, noteShareRepo repo.NoteShareRepository, reminderRepo repo.ReminderRepository, tx repo.Transactor, blobs blob.BlobStore, hub *events.Hub, userRepo repo.UserRepository, tokenRepo repo.APITokenRepository, shareRepo repo.ShareLinkRepository, workspaceRepo repo.WorkspaceRepository, calendarRepo repo.CalendarFeedRepository, totpRepo repo.TOTPRepository, exportRepo repo.DataExportRepository, pwHasher authutil.PasswordHasher, cipher authutil.Cipher, signer authutil.URLSigner, sessionMng *scs.SessionManager, mailer mail.Mailer, conf *config.Config) *Mux {
 	mux := &Mux{ServeMux: http.NewServeMux()}
 
 	renderer := render.NewTemplateRender(sessionMng)
 	renderer.WithEmbedFS(true)
 	renderer.WithGlobalTag("isAuthenticated", authutil.TagIsAuthenticated(sessionMng)).
 		WithGlobalTag("csrfField", authutil.TagCSRFField).
-		WithGlobalTag("flashMessage", support.TagFlashMessage(sessionMng))
+		WithGlobalTag("csrfToken", authutil.TagCSRFToken).
+		WithGlobalTag("flashMessage", support.TagFlashMessage(sessionMng)).
+		WithGlobalTag("notebookTree", TagNotebookTree(notebookRepo)).
+		WithGlobalTag("workspaces", TagWorkspaces(workspaceRepo)).
+		WithGlobalTag("canManageNotes", TagCanManageNotes())
 
 	errH := ErrorHandler{Render: renderer, Sess: sessionMng}
+	apiErrH := APIErrorHandler{}
 
 	staticFS, err := fs.Sub(view.Assets, "static")
 	if err != nil {
@@ -39,25 +47,117 @@ func NewMux(noteRepo repo.Noter, userRepo repo.UserRepository, pwHasher authutil
 	mux.Handle("GET /static/", http.StripPrefix("/static/", staticHandle))
 
 	homeHandler := NewHomeHandler(renderer)
-	noteHandler := NewNoteHandler(noteRepo, renderer, sessionMng)
+	collabServer := collab.NewServer(noteRepo, conf.CollabSaveIntervalDuration()).
+		OnSave(func(ctx context.Context, scope repo.Scope, noteID int) {
+			publishNoteEvent(ctx, hub, noteShareRepo, events.NoteUpdated, scope, noteID)
+		})
+	noteHandler := NewNoteHandler(noteRepo, tagRepo, notebookRepo, revisionRepo, checklistRepo, attachmentRepo, noteShareRepo, reminderRepo, tx, blobs, hub, collabServer, renderer, sessionMng, conf.TrashRetentionDuration(), AttachmentOptions{
+		MaxSize:      conf.AttachmentMaxSizeBytes(),
+		Quota:        conf.AttachmentQuotaBytes(),
+		AllowedTypes: conf.AttachmentAllowedTypes(),
+	})
+	tagHandler := NewTagHandler(tagRepo, renderer, sessionMng)
+	apiNoteHandler := NewAPINoteHandler(noteRepo, tagRepo, checklistRepo, noteShareRepo, tx, hub)
+	apiTokenHandler := NewAPITokenHandler(tokenRepo, renderer, sessionMng)
 	userHandler := NewUserHandler(
 		userRepo,
+		calendarRepo,
+		totpRepo,
+		tokenRepo,
 		pwHasher,
+		cipher,
 		sessionMng,
 		renderer,
 		mailer,
-		mountAppDomain(conf),
+		conf.AppDomain(),
+		conf.AccountDeletionGraceDuration(),
 	)
 
+	shareHandler := NewShareHandler(shareRepo, noteShareRepo, noteRepo, pwHasher, renderer, sessionMng, mailer, conf.AppDomain())
+
+	eventHandler := NewEventHandler(hub, workspaceRepo)
+	calendarHandler := NewCalendarHandler(calendarRepo, reminderRepo, sessionMng, conf.AppDomain())
+	exportHandler := NewExportHandler(exportRepo, blobs, signer, sessionMng)
+	workspaceHandler := NewWorkspaceHandler(workspaceRepo, renderer, sessionMng, mailer, conf.AppDomain())
+
 	authMiddleware := authutil.NewAuthMiddleware(sessionMng)
+	workspaceMiddleware := NewWorkspaceMiddleware(workspaceRepo, sessionMng)
+	requireAuth := func(next http.Handler) http.Handler {
+		return authMiddleware.RequireAuth(workspaceMiddleware.Resolve(next))
+	}
+	requireAuthAPI := func(next http.Handler) http.Handler {
+		return authMiddleware.RequireAuthAPI(workspaceMiddleware.Resolve(next))
+	}
+
+	// images are also read through the links to their notes, so they don't require a signed-in user
+	imageHandler := NewImageHandler(attachmentRepo, noteShareRepo, shareRepo, blobs, sessionMng)
+	mux.Handle("GET /images/{owner}/{name}", workspaceMiddleware.Resolve(errH.Wrap(imageHandler.Image)))
+	mux.Handle("GET /images/{owner}/{name}/{variant}", workspaceMiddleware.Resolve(errH.Wrap(imageHandler.Image)))
 
 	mux.Handle("/", errH.Wrap(homeHandler.Home))
-	mux.Handle("GET /notes", authMiddleware.RequireAuth(errH.Wrap(noteHandler.ListNotes)))
-	mux.Handle("GET /notes/{id}", authMiddleware.RequireAuth(errH.Wrap(noteHandler.NotesDetail)))
-	mux.Handle("GET /notes/create", authMiddleware.RequireAuth(errH.Wrap(noteHandler.NotesCreate)))
-	mux.Handle("DELETE /notes/{id}", authMiddleware.RequireAuth(errH.Wrap(noteHandler.NotesDelete)))
-	mux.Handle("POST /notes", authMiddleware.RequireAuth(errH.Wrap(noteHandler.Save)))
-	mux.Handle("GET /notes/{id}/edit", authMiddleware.RequireAuth(errH.Wrap(noteHandler.NotesUpdate)))
+	mux.Handle("GET /notes", requireAuth(errH.Wrap(noteHandler.ListNotes)))
+	mux.Handle("GET /notes/{id}", requireAuth(errH.Wrap(noteHandler.NotesDetail)))
+	mux.Handle("GET /notes/create", requireAuth(errH.Wrap(noteHandler.NotesCreate)))
+	mux.Handle("DELETE /notes/{id}", requireAuth(errH.Wrap(noteHandler.NotesDelete)))
+	mux.Handle("POST /notes", requireAuth(errH.Wrap(noteHandler.Save)))
+	mux.Handle("POST /notes/preview", requireAuth(errH.Wrap(noteHandler.NotesPreview)))
+	mux.Handle("GET /notes/{id}/edit", requireAuth(errH.Wrap(noteHandler.NotesUpdate)))
+	mux.Handle("POST /notes/{id}/move", requireAuth(errH.Wrap(noteHandler.NotesMove)))
+	mux.Handle("POST /notes/{id}/items/{item}/toggle", requireAuth(errH.Wrap(noteHandler.NotesToggleItem)))
+	mux.Handle("POST /notes/{id}/images", requireAuth(errH.Wrap(noteHandler.NotesImagePaste)))
+	mux.Handle("GET /notes/{id}/attachments/{attachment}", requireAuth(errH.Wrap(noteHandler.NotesAttachment)))
+	mux.Handle("POST /notes/{id}/attachments/{attachment}/delete", requireAuth(errH.Wrap(noteHandler.NotesAttachmentDelete)))
+	mux.Handle("GET /notes/{id}/shares", requireAuth(errH.Wrap(shareHandler.Shares)))
+	mux.Handle("POST /notes/{id}/shares", requireAuth(errH.Wrap(shareHandler.SharesCreate)))
+	mux.Handle("POST /notes/{id}/shares/{share}/revoke", requireAuth(errH.Wrap(shareHandler.SharesRevoke)))
+	mux.Handle("POST /notes/{id}/shares/users", requireAuth(errH.Wrap(shareHandler.SharesUserAdd)))
+	mux.Handle("POST /notes/{id}/shares/users/{share}/remove", requireAuth(errH.Wrap(shareHandler.SharesUserRemove)))
+	mux.Handle("GET /notes/{id}/collab", requireAuth(errH.Wrap(noteHandler.NotesCollab)))
+	mux.Handle("GET /notes/{id}/history", requireAuth(errH.Wrap(noteHandler.NotesHistory)))
+	mux.Handle("POST /notes/{id}/history/{revision}/restore", requireAuth(errH.Wrap(noteHandler.NotesHistoryRestore)))
+
+	mux.Handle("GET /s/{token}", errH.Wrap(shareHandler.SharedNote))
+	mux.Handle("POST /s/{token}", errH.Wrap(shareHandler.SharedNoteUnlock))
+
+	mux.Handle("GET /notes/events", requireAuth(errH.Wrap(eventHandler.NoteEvents)))
+	mux.Handle("GET /notes/shared", requireAuth(errH.Wrap(noteHandler.SharedWithMe)))
+
+	mux.Handle("GET /notes/trash", requireAuth(errH.Wrap(noteHandler.Trash)))
+	mux.Handle("POST /notes/trash/empty", requireAuth(errH.Wrap(noteHandler.TrashEmpty)))
+	mux.Handle("POST /notes/{id}/restore", requireAuth(errH.Wrap(noteHandler.TrashRestore)))
+	mux.Handle("POST /notes/{id}/purge", requireAuth(errH.Wrap(noteHandler.TrashPurge)))
+
+	mux.Handle("GET /notebooks", requireAuth(errH.Wrap(noteHandler.Notebooks)))
+	mux.Handle("POST /notebooks", requireAuth(errH.Wrap(noteHandler.NotebooksCreate)))
+	mux.Handle("POST /notebooks/{id}/rename", requireAuth(errH.Wrap(noteHandler.NotebooksRename)))
+	mux.Handle("POST /notebooks/{id}/move", requireAuth(errH.Wrap(noteHandler.NotebooksMove)))
+	mux.Handle("GET /notebooks/{id}/delete", requireAuth(errH.Wrap(noteHandler.NotebooksDelete)))
+	mux.Handle("POST /notebooks/{id}/delete", requireAuth(errH.Wrap(noteHandler.NotebooksDeletePost)))
+
+	mux.Handle("GET /tags", requireAuth(errH.Wrap(tagHandler.Tags)))
+	mux.Handle("POST /tags/merge", requireAuth(errH.Wrap(tagHandler.TagsMerge)))
+	mux.Handle("POST /tags/{id}/rename", requireAuth(errH.Wrap(tagHandler.TagsRename)))
+	mux.Handle("POST /tags/{id}/delete", requireAuth(errH.Wrap(tagHandler.TagsDelete)))
+
+	mux.Handle("GET /workspaces", requireAuth(errH.Wrap(workspaceHandler.Workspaces)))
+	mux.Handle("POST /workspaces", requireAuth(errH.Wrap(workspaceHandler.WorkspacesCreate)))
+	mux.Handle("POST /workspaces/switch", requireAuth(errH.Wrap(workspaceHandler.WorkspacesSwitch)))
+	mux.Handle("GET /workspaces/{id}", requireAuth(errH.Wrap(workspaceHandler.WorkspacesDetail)))
+	mux.Handle("POST /workspaces/{id}/rename", requireAuth(errH.Wrap(workspaceHandler.WorkspacesRename)))
+	mux.Handle("POST /workspaces/{id}/delete", requireAuth(errH.Wrap(workspaceHandler.WorkspacesDelete)))
+	mux.Handle("POST /workspaces/{id}/invites", requireAuth(errH.Wrap(workspaceHandler.WorkspacesInvite)))
+	mux.Handle("POST /workspaces/{id}/invites/{invite}/revoke", requireAuth(errH.Wrap(workspaceHandler.WorkspacesInviteRevoke)))
+	mux.Handle("POST /workspaces/{id}/members/{user}/role", requireAuth(errH.Wrap(workspaceHandler.WorkspacesMemberRole)))
+	mux.Handle("POST /workspaces/{id}/members/{user}/remove", requireAuth(errH.Wrap(workspaceHandler.WorkspacesMemberRemove)))
+	mux.Handle("GET /invites/{token}", requireAuth(errH.Wrap(workspaceHandler.WorkspacesInviteShow)))
+	mux.Handle("POST /invites/{token}", requireAuth(errH.Wrap(workspaceHandler.WorkspacesInviteAccept)))
+
+	canRead, canWrite := authutil.RequireScope(authutil.ScopeNotesRead), authutil.RequireScope(authutil.ScopeNotesWrite)
+	mux.Handle("GET /api/v1/notes", requireAuthAPI(canRead(apiErrH.Wrap(apiNoteHandler.List))))
+	mux.Handle("POST /api/v1/notes", requireAuthAPI(canWrite(apiErrH.Wrap(apiNoteHandler.Create))))
+	mux.Handle("GET /api/v1/notes/{id}", requireAuthAPI(canRead(apiErrH.Wrap(apiNoteHandler.Get))))
+	mux.Handle("PATCH /api/v1/notes/{id}", requireAuthAPI(canWrite(apiErrH.Wrap(apiNoteHandler.Update))))
+	mux.Handle("DELETE /api/v1/notes/{id}", requireAuthAPI(canWrite(apiErrH.Wrap(apiNoteHandler.Delete))))
 
 	mux.Handle("GET /users/signup", errH.Wrap(userHandler.SignUp))
 	mux.Handle("POST /users/signup", errH.Wrap(userHandler.SignUpPost))
@@ -65,10 +165,28 @@ func NewMux(noteRepo repo.Noter, userRepo repo.UserRepository, pwHasher authutil
 
 	mux.Handle("GET /users/signin", errH.Wrap(userHandler.SignIn))
 	mux.Handle("POST /users/signin", errH.Wrap(userHandler.SignInPost))
+	mux.Handle("GET /users/signin/2fa", errH.Wrap(userHandler.SignInTwoFactor))
+	mux.Handle("POST /users/signin/2fa", errH.Wrap(userHandler.SignInTwoFactorPost))
 
 	mux.Handle("GET /users/confirm/{token}", errH.Wrap(userHandler.Confirm))
-	mux.Handle("GET /users/signout", authMiddleware.RequireAuth(errH.Wrap(userHandler.SignOut)))
-	mux.Handle("GET /users/me", authMiddleware.RequireAuth(errH.Wrap(userHandler.Me)))
+	mux.Handle("GET /users/signout", requireAuth(errH.Wrap(userHandler.SignOut)))
+	mux.Handle("GET /users/me", requireAuth(errH.Wrap(userHandler.Me)))
+	mux.Handle("POST /users/me/password", requireAuth(errH.Wrap(userHandler.PasswordChange)))
+	mux.Handle("POST /users/me/email", requireAuth(errH.Wrap(userHandler.EmailChange)))
+	mux.Handle("GET /users/confirm-email/{token}", errH.Wrap(userHandler.ConfirmEmail))
+	mux.Handle("POST /users/me/delete", requireAuth(errH.Wrap(userHandler.AccountDelete)))
+	mux.Handle("POST /users/me/export", requireAuth(errH.Wrap(exportHandler.DataExportRequest)))
+	mux.Handle("GET /exports/{id}", errH.Wrap(exportHandler.DataExportDownload))
+	mux.Handle("POST /users/me/2fa", requireAuth(errH.Wrap(userHandler.TwoFactorBegin)))
+	mux.Handle("GET /users/me/2fa", requireAuth(errH.Wrap(userHandler.TwoFactorSetup)))
+	mux.Handle("POST /users/me/2fa/enable", requireAuth(errH.Wrap(userHandler.TwoFactorEnable)))
+	mux.Handle("POST /users/me/2fa/disable", requireAuth(errH.Wrap(userHandler.TwoFactorDisable)))
+	mux.Handle("POST /users/me/calendar", requireAuth(errH.Wrap(calendarHandler.CalendarRegenerate)))
+	mux.Handle("POST /users/me/calendar/delete", requireAuth(errH.Wrap(calendarHandler.CalendarDelete)))
+	mux.Handle("GET /calendar/{file}", errH.Wrap(calendarHandler.CalendarFeed))
+	mux.Handle("GET /users/tokens", requireAuth(errH.Wrap(apiTokenHandler.Tokens)))
+	mux.Handle("POST /users/tokens", requireAuth(errH.Wrap(apiTokenHandler.TokensCreate)))
+	mux.Handle("POST /users/tokens/{id}/revoke", requireAuth(errH.Wrap(apiTokenHandler.TokensRevoke)))
 	mux.Handle("GET /users/email-form", errH.Wrap(userHandler.EmailForm))
 	mux.Handle("POST /users/forgot-password", errH.Wrap(userHandler.ForgotPasswordPost))
 	mux.Handle("GET /users/reset-password/{token}", errH.Wrap(userHandler.ResetPassword))
@@ -85,17 +203,3 @@ func (m *Mux) WithMiddleware(mw ...func(http.Handler) http.Handler) http.Handler
 	}
 	return wm
 }
-
-func mountAppDomain(conf *config.Config) string {
-	scheme := "https"
-	if conf.DebugMode() {
-		scheme = "http"
-	}
-
-	host := conf.ServerHost
-	if conf.DebugMode() {
-		host = "localhost"
-	}
-
-	return fmt.Sprintf("%s://%s:%s", scheme, host, conf.ServerPort)
-}
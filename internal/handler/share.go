@@ -0,0 +1,393 @@
+// Package handler provides HTTP handlers.
+package handler
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/LeandroDeJesus-S/quicknote/internal/errs"
+	"github.com/LeandroDeJesus-S/quicknote/internal/mail"
+	"github.com/LeandroDeJesus-S/quicknote/internal/models"
+	"github.com/LeandroDeJesus-S/quicknote/internal/render"
+	"github.com/LeandroDeJesus-S/quicknote/internal/repo"
+	"github.com/LeandroDeJesus-S/quicknote/internal/support"
+	"github.com/LeandroDeJesus-S/quicknote/internal/support/authutil"
+	"github.com/LeandroDeJesus-S/quicknote/internal/validation"
+	"github.com/alexedwards/scs/v2"
+)
+
+const (
+	shareUnlockMaxAttempts    = 10 // how many wrong passwords can be typed to unlock a link within shareUnlockAttemptsWindow
+	shareUnlockAttemptsWindow = 15 * time.Minute
+	shareUnlockLockout        = 15 * time.Minute // how long a link with too many wrong passwords typed can't be unlocked
+)
+
+var shareUnlockLockedMsg = fmt.Sprintf("too many wrong passwords, please try again in %d minutes", int(shareUnlockLockout.Minutes()))
+
+// shareHandler handles the HTTP requests to share notes through public links and with other users.
+type shareHandler struct {
+	shareRepo     repo.ShareLinkRepository
+	noteShareRepo repo.NoteShareRepository
+	noteRepo      repo.Noter
+	pwHasher      authutil.PasswordHasher
+	render        render.TemplateRender
+	sesMng        *scs.SessionManager
+	mailer        mail.Mailer
+	appDomain     string
+}
+
+// NewShareHandler creates a new shareHandler.
+func NewShareHandler(shareRepo repo.ShareLinkRepository, noteShareRepo repo.NoteShareRepository, noteRepo repo.Noter, pwHasher authutil.PasswordHasher, render render.TemplateRender, sesMng *scs.SessionManager, mailer mail.Mailer, appDomain string) *shareHandler {
+	return &shareHandler{
+		shareRepo:     shareRepo,
+		noteShareRepo: noteShareRepo,
+		noteRepo:      noteRepo,
+		pwHasher:      pwHasher,
+		render:        render,
+		sesMng:        sesMng,
+		mailer:        mailer,
+		appDomain:     appDomain,
+	}
+}
+
+// unlockedKey returns the session key marking a password protected link as unlocked.
+func unlockedKey(link *models.ShareLink) string {
+	return fmt.Sprintf("share:%d", link.ID.Int.Int64())
+}
+
+// sharedNoteKey returns the session key holding the hash of the link a note was opened through,
+// which lets the session read the images of the note while the link works.
+func sharedNoteKey(noteID int) string {
+	return fmt.Sprintf("shared_note:%d", noteID)
+}
+
+// renderShares renders the sharing page of a note with its links and the users it's shared with merged into data.
+func (sh shareHandler) renderShares(w http.ResponseWriter, r *http.Request, noteID int, data map[string]any) error {
+	note, err := sh.noteRepo.ReadOne(r.Context(), requestScope(r), noteID)
+	if err != nil {
+		return noteError(err, "error reading note")
+	}
+
+	links, err := sh.shareRepo.List(r.Context(), requestScope(r), noteID)
+	if err != nil {
+		return errs.NewHTTPError(err, http.StatusInternalServerError, "error listing share links")
+	}
+
+	shares, err := sh.noteShareRepo.List(r.Context(), requestScope(r), noteID)
+	if err != nil {
+		return errs.NewHTTPError(err, http.StatusInternalServerError, "error listing note shares")
+	}
+
+	if data == nil {
+		data = make(map[string]any)
+	}
+	data["ID"] = noteID
+	data["noteName"] = note.Title.String
+	data["Links"] = newShareLinkDTOList(links)
+	data["Shares"] = newNoteShareDTOList(shares)
+	return sh.render.Page(w, r, render.NewOpts().WithPage("note-shares.html").WithData(data))
+}
+
+// Shares handles the request to show the public links of a note and the users it's shared with.
+func (sh shareHandler) Shares(w http.ResponseWriter, r *http.Request) error {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		return errs.NewHTTPError(err, http.StatusBadRequest, "id is invalid")
+	}
+	if err := requireRole(r, notesManagerRole); err != nil {
+		return err
+	}
+	return sh.renderShares(w, r, id, nil)
+}
+
+// SharesCreate handles the request to create a public link to a note, optionally expiring
+// and protected by a password. The link is only shown in the response, as just its hash is stored.
+func (sh shareHandler) SharesCreate(w http.ResponseWriter, r *http.Request) error {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		return errs.NewHTTPError(err, http.StatusBadRequest, "id is invalid")
+	}
+	if err := requireRole(r, notesManagerRole); err != nil {
+		return err
+	}
+	if err := r.ParseForm(); err != nil {
+		return errs.NewHTTPError(err, http.StatusBadRequest, "failed to parse form")
+	}
+
+	validator := validation.NewFormValidator()
+	var expiresAt *time.Time
+	if rawDays := r.PostForm.Get("expires_in"); rawDays != "" {
+		days, err := strconv.Atoi(rawDays)
+		if err != nil || days <= 0 {
+			validator.AddError("expires_in", "invalid expiration")
+		} else {
+			t := time.Now().AddDate(0, 0, days)
+			expiresAt = &t
+		}
+	}
+	if password := r.PostForm.Get("password"); password != "" {
+		validator.AddValidator([]string{"password"}, validation.ValidateMinMaxLen(4, 72))
+		validator.ValidateForm(r.PostForm)
+	}
+	if !validator.Ok() {
+		return sh.renderShares(w, r, id, map[string]any{"FieldErrors": validator.FieldErrors()})
+	}
+
+	var passwordHash *string
+	if password := r.PostForm.Get("password"); password != "" {
+		hash, err := sh.pwHasher.HashPassword(password)
+		if err != nil {
+			return errs.NewHTTPError(err, http.StatusInternalServerError, "error creating share link")
+		}
+		passwordHash = &hash
+	}
+
+	plainTok := authutil.GenerateToken()
+	_, err = sh.shareRepo.Create(r.Context(), requestScope(r), id, authutil.HashToken(plainTok), passwordHash, expiresAt)
+	if err != nil {
+		return noteError(err, "error creating share link")
+	}
+
+	return sh.renderShares(w, r, id, map[string]any{"NewLink": sh.appDomain + "/s/" + plainTok})
+}
+
+// SharesRevoke handles the request to revoke a public link, which stops working at once.
+func (sh shareHandler) SharesRevoke(w http.ResponseWriter, r *http.Request) error {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		return errs.NewHTTPError(err, http.StatusBadRequest, "id is invalid")
+	}
+	if err := requireRole(r, notesManagerRole); err != nil {
+		return err
+	}
+	shareID, err := strconv.Atoi(r.PathValue("share"))
+	if err != nil {
+		return errs.NewHTTPError(err, http.StatusBadRequest, "share id is invalid")
+	}
+
+	err = sh.shareRepo.Delete(r.Context(), requestScope(r), id, shareID)
+	if errors.Is(err, repo.ErrShareLinkNotFound) {
+		return errs.NewHTTPError(err, http.StatusNotFound, "share link not found")
+	}
+	if err != nil {
+		return errs.NewHTTPError(err, http.StatusInternalServerError, "error revoking share link")
+	}
+
+	support.SendFlashMessage(sh.sesMng, r, support.FlashMsgSuccess, "share link revoked")
+	http.Redirect(w, r, fmt.Sprintf("/notes/%d/shares", id), http.StatusSeeOther)
+	return nil
+}
+
+// SharesUserAdd handles the request to share a note with another user by their email, as a viewer or
+// an editor. Sharing it again with the same user changes their role. The user is told about it by email,
+// which is sent the same when no one has an account with it yet, so as not to tell who has one.
+func (sh shareHandler) SharesUserAdd(w http.ResponseWriter, r *http.Request) error {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		return errs.NewHTTPError(err, http.StatusBadRequest, "id is invalid")
+	}
+	if err := requireRole(r, notesManagerRole); err != nil {
+		return err
+	}
+	if err := r.ParseForm(); err != nil {
+		return errs.NewHTTPError(err, http.StatusBadRequest, "failed to parse form")
+	}
+
+	email := strings.TrimSpace(r.PostForm.Get("email"))
+	role := models.NoteRole(r.PostForm.Get("role"))
+
+	fieldErrors := map[string]string{}
+	if ok, msg := validation.ValidateEmailPattern(email); !ok {
+		fieldErrors["email"] = msg
+	}
+	if role != models.NoteRoleViewer && role != models.NoteRoleEditor {
+		fieldErrors["role"] = "invalid role"
+	}
+	if len(fieldErrors) > 0 {
+		return sh.renderShares(w, r, id, map[string]any{"FieldErrors": fieldErrors, "FormData": map[string]string{"email": email}})
+	}
+
+	share, err := sh.noteShareRepo.Share(r.Context(), requestScope(r), id, email, role)
+	switch {
+	case errors.Is(err, repo.ErrShareWithOwner):
+		fieldErrors["email"] = "the user is already a member of this workspace"
+	case err != nil:
+		return noteError(err, "error sharing note")
+	}
+	if len(fieldErrors) > 0 {
+		return sh.renderShares(w, r, id, map[string]any{"FieldErrors": fieldErrors, "FormData": map[string]string{"email": email}})
+	}
+
+	msg, typ := fmt.Sprintf("note shared with %s", share.Email.String), support.FlashMsgSuccess
+	if err := sh.sendShareMail(r, id, share); err != nil {
+		slog.Error("failed to send the note shared email", "note_id", id, "error", err)
+		msg, typ = fmt.Sprintf("note shared with %s, but the email telling them could not be sent", share.Email.String), support.FlashMsgWarn
+	}
+
+	support.SendFlashMessage(sh.sesMng, r, typ, msg)
+	http.Redirect(w, r, fmt.Sprintf("/notes/%d/shares", id), http.StatusSeeOther)
+	return nil
+}
+
+// sendShareMail tells the user a note was shared with them by the signed-in user.
+func (sh shareHandler) sendShareMail(r *http.Request, noteID int, share *models.NoteShare) error {
+	note, err := sh.noteRepo.ReadOne(r.Context(), requestScope(r), noteID)
+	if err != nil {
+		return err
+	}
+
+	sharer := requestMembership(r).Email.String
+	body, err := sh.render.Mail("note-shared.html", map[string]string{
+		"Owner": sharer,
+		"Title": note.Title.String,
+		"Role":  string(share.Role),
+		"URL":   fmt.Sprintf("%s/notes/%d", sh.appDomain, noteID),
+	})
+	if err != nil {
+		return err
+	}
+
+	return sh.mailer.Send(mail.Message{
+		To:      []string{share.Email.String},
+		Subject: fmt.Sprintf("%s shared a note with you", sharer),
+		Body:    body,
+		IsHTML:  true,
+	})
+}
+
+// SharesUserRemove handles the request to stop sharing a note with a user.
+func (sh shareHandler) SharesUserRemove(w http.ResponseWriter, r *http.Request) error {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		return errs.NewHTTPError(err, http.StatusBadRequest, "id is invalid")
+	}
+	if err := requireRole(r, notesManagerRole); err != nil {
+		return err
+	}
+	shareID, err := strconv.Atoi(r.PathValue("share"))
+	if err != nil {
+		return errs.NewHTTPError(err, http.StatusBadRequest, "share id is invalid")
+	}
+
+	err = sh.noteShareRepo.Unshare(r.Context(), requestScope(r), id, shareID)
+	if errors.Is(err, repo.ErrNoteShareNotFound) {
+		return errs.NewHTTPError(err, http.StatusNotFound, "note share not found")
+	}
+	if err != nil {
+		return errs.NewHTTPError(err, http.StatusInternalServerError, "error removing note share")
+	}
+
+	support.SendFlashMessage(sh.sesMng, r, support.FlashMsgSuccess, "note no longer shared with the user")
+	http.Redirect(w, r, fmt.Sprintf("/notes/%d/shares", id), http.StatusSeeOther)
+	return nil
+}
+
+// findLink returns the link of the token in the request path.
+func (sh shareHandler) findLink(r *http.Request) (*models.ShareLink, error) {
+	link, err := sh.shareRepo.FindByHash(r.Context(), authutil.HashToken(r.PathValue("token")))
+	if errors.Is(err, repo.ErrShareLinkNotFound) {
+		return nil, errs.NewHTTPError(err, http.StatusNotFound, "this link does not exist or has expired")
+	}
+	if err != nil {
+		return nil, errs.NewHTTPError(err, http.StatusInternalServerError, "error reading shared note")
+	}
+	return link, nil
+}
+
+// setSharedHeaders keeps the shared pages, and the token in their URL, out of caches,
+// search engines and the Referer header of the links in the note.
+func setSharedHeaders(w http.ResponseWriter) {
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("Referrer-Policy", "no-referrer")
+	w.Header().Set("X-Robots-Tag", "noindex, nofollow")
+}
+
+// SharedNote handles the request to read a note through its public link.
+// Only the title and the content of the note are shown, nothing else of the note or its owner.
+func (sh shareHandler) SharedNote(w http.ResponseWriter, r *http.Request) error {
+	setSharedHeaders(w)
+	link, err := sh.findLink(r)
+	if err != nil {
+		return err
+	}
+
+	if link.PasswordHash.Valid && !sh.sesMng.GetBool(r.Context(), unlockedKey(link)) {
+		return sh.render.Page(w, r, render.NewOpts().WithPage("shared-password.html").WithData(map[string]any{"Token": r.PathValue("token")}))
+	}
+
+	scope := repo.Scope{WorkspaceID: link.WorkspaceID.Int.Int64(), UserID: link.UserID.Int.Int64()}
+	note, err := sh.noteRepo.ReadOne(r.Context(), scope, int(link.NoteID.Int.Int64()))
+	if err != nil {
+		return errs.NewHTTPError(err, http.StatusNotFound, "this link does not exist or has expired")
+	}
+
+	contentHTML, err := render.Markdown(note.Content.String)
+	if err != nil {
+		return errs.NewHTTPError(err, http.StatusInternalServerError, "error rendering note")
+	}
+
+	sh.sesMng.Put(r.Context(), sharedNoteKey(int(link.NoteID.Int.Int64())), authutil.HashToken(r.PathValue("token")))
+	if err := sh.shareRepo.CountView(r.Context(), link.ID.Int.Int64()); err != nil {
+		slog.Error("failed to count share link view", "error", err)
+	}
+
+	dto := newNoteDTO(*note)
+	return sh.render.Page(w, r, render.NewOpts().WithPage("shared-note.html").WithData(map[string]any{
+		"Title":     dto.Title,
+		"Content":   contentHTML,
+		"Checklist": dto.IsChecklist(),
+		"Items":     dto.Items,
+	}))
+}
+
+// SharedNoteUnlock handles the request to unlock a password protected link for the session.
+// The wrong passwords are counted for the link, which is locked for a while once too many are typed.
+func (sh shareHandler) SharedNoteUnlock(w http.ResponseWriter, r *http.Request) error {
+	setSharedHeaders(w)
+	link, err := sh.findLink(r)
+	if err != nil {
+		return err
+	}
+	if err := r.ParseForm(); err != nil {
+		return errs.NewHTTPError(err, http.StatusBadRequest, "failed to parse form")
+	}
+
+	if link.PasswordHash.Valid {
+		if link.Locked {
+			return sh.renderUnlockError(w, r, http.StatusTooManyRequests, shareUnlockLockedMsg)
+		}
+
+		ok, err := sh.pwHasher.CheckPassword(r.PostForm.Get("password"), link.PasswordHash.String)
+		if err != nil || !ok {
+			locked, err := sh.shareRepo.RecordFailure(r.Context(), link.ID.Int.Int64(), shareUnlockAttemptsWindow, shareUnlockMaxAttempts, shareUnlockLockout)
+			if err != nil {
+				return errs.NewHTTPError(err, http.StatusInternalServerError, "failed to check password")
+			}
+			if locked {
+				return sh.renderUnlockError(w, r, http.StatusTooManyRequests, shareUnlockLockedMsg)
+			}
+			return sh.renderUnlockError(w, r, http.StatusUnauthorized, "wrong password")
+		}
+
+		if err := sh.shareRepo.ResetFailures(r.Context(), link.ID.Int.Int64()); err != nil {
+			slog.Error("failed to reset the wrong passwords of a share link", "link_id", link.ID.Int, "error", err)
+		}
+		sh.sesMng.Put(r.Context(), unlockedKey(link), true)
+	}
+
+	http.Redirect(w, r, "/s/"+r.PathValue("token"), http.StatusSeeOther)
+	return nil
+}
+
+// renderUnlockError renders the password form of a link again with the error.
+func (sh shareHandler) renderUnlockError(w http.ResponseWriter, r *http.Request, status int, message string) error {
+	return sh.render.Page(w, r, render.NewOpts().WithPage("shared-password.html").WithStatus(status).WithData(map[string]any{
+		"Token":       r.PathValue("token"),
+		"FieldErrors": map[string]string{"password": message},
+	}))
+}
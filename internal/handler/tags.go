@@ -0,0 +1,141 @@
+// Package handler provides HTTP handlers.
+package handler
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/LeandroDeJesus-S/quicknote/internal/errs"
+	"github.com/LeandroDeJesus-S/quicknote/internal/render"
+	"github.com/LeandroDeJesus-S/quicknote/internal/repo"
+	"github.com/LeandroDeJesus-S/quicknote/internal/support"
+	"github.com/alexedwards/scs/v2"
+)
+
+// tagHandler handles the HTTP requests to manage the tags of the active workspace.
+type tagHandler struct {
+	tagRepo repo.TagRepository
+	render  render.TemplateRender
+	sesMng  *scs.SessionManager
+}
+
+// NewTagHandler creates a new tagHandler.
+func NewTagHandler(tagRepo repo.TagRepository, render render.TemplateRender, sesMng *scs.SessionManager) *tagHandler {
+	return &tagHandler{tagRepo: tagRepo, render: render, sesMng: sesMng}
+}
+
+// tagError converts a repository error into an HTTP error.
+func tagError(err error, message string) error {
+	if errors.Is(err, repo.ErrTagNotFound) {
+		return errs.NewHTTPError(err, http.StatusNotFound, "tag not found")
+	}
+	return errs.NewHTTPError(err, http.StatusInternalServerError, message)
+}
+
+// done flashes a success message and redirects back to the tags page.
+func (th tagHandler) done(w http.ResponseWriter, r *http.Request, msg string) error {
+	support.SendFlashMessage(th.sesMng, r, support.FlashMsgSuccess, msg)
+	http.Redirect(w, r, "/tags", http.StatusSeeOther)
+	return nil
+}
+
+// renderTags renders the tags page with the workspace tags merged into data.
+func (th tagHandler) renderTags(w http.ResponseWriter, r *http.Request, data map[string]any) error {
+	tags, err := th.tagRepo.List(r.Context(), requestScope(r))
+	if err != nil {
+		return errs.NewHTTPError(err, http.StatusInternalServerError, "error listing tags")
+	}
+
+	if data == nil {
+		data = make(map[string]any)
+	}
+	data["Tags"] = newTagDTOList(tags)
+	return th.render.Page(w, r, render.NewOpts().WithPage("tags.html").WithData(data))
+}
+
+// Tags handles the request to show the tag management page.
+func (th tagHandler) Tags(w http.ResponseWriter, r *http.Request) error {
+	return th.renderTags(w, r, nil)
+}
+
+// TagsRename handles the request to rename a tag, which changes it on every note tagged with it.
+func (th tagHandler) TagsRename(w http.ResponseWriter, r *http.Request) error {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		return errs.NewHTTPError(err, http.StatusBadRequest, "id is invalid")
+	}
+	if err := r.ParseForm(); err != nil {
+		return errs.NewHTTPError(err, http.StatusBadRequest, "failed to parse form")
+	}
+
+	names := normalizeTags([]string{r.PostForm.Get("name")})
+	fieldErrors := map[string]string{}
+	if len(names) == 0 {
+		fieldErrors["name"] = "field cannot be empty"
+	} else if msg := tagsError(names); msg != "" {
+		fieldErrors["name"] = msg
+	}
+	if len(fieldErrors) > 0 {
+		return th.renderTags(w, r, map[string]any{"FieldErrors": fieldErrors, "EditingID": id})
+	}
+
+	err = th.tagRepo.Rename(r.Context(), requestScope(r), id, names[0])
+	if errors.Is(err, repo.ErrDuplicatedTag) {
+		fieldErrors["name"] = fmt.Sprintf("the tag %q already exists, merge them instead", names[0])
+		return th.renderTags(w, r, map[string]any{"FieldErrors": fieldErrors, "EditingID": id})
+	}
+	if err != nil {
+		return tagError(err, "error renaming tag")
+	}
+
+	return th.done(w, r, "tag renamed")
+}
+
+// TagsMerge handles the request to merge the selected tags into a target tag.
+func (th tagHandler) TagsMerge(w http.ResponseWriter, r *http.Request) error {
+	if err := r.ParseForm(); err != nil {
+		return errs.NewHTTPError(err, http.StatusBadRequest, "failed to parse form")
+	}
+
+	targetID, err := strconv.Atoi(r.PostForm.Get("target"))
+	if err != nil {
+		return th.renderTags(w, r, map[string]any{"FieldErrors": map[string]string{"merge": "select the tag to merge into"}})
+	}
+
+	var sourceIDs []int
+	for _, raw := range r.PostForm["sources"] {
+		id, err := strconv.Atoi(raw)
+		if err != nil {
+			return errs.NewHTTPError(err, http.StatusBadRequest, "id is invalid")
+		}
+		if id != targetID {
+			sourceIDs = append(sourceIDs, id)
+		}
+	}
+	if len(sourceIDs) == 0 {
+		return th.renderTags(w, r, map[string]any{"FieldErrors": map[string]string{"merge": "select the tags to be merged"}})
+	}
+
+	tagged, err := th.tagRepo.Merge(r.Context(), requestScope(r), sourceIDs, targetID)
+	if err != nil {
+		return tagError(err, "error merging tags")
+	}
+
+	return th.done(w, r, fmt.Sprintf("tags merged, %d note(s) updated", tagged))
+}
+
+// TagsDelete handles the request to delete a tag, removing it from every note.
+func (th tagHandler) TagsDelete(w http.ResponseWriter, r *http.Request) error {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		return errs.NewHTTPError(err, http.StatusBadRequest, "id is invalid")
+	}
+
+	if err := th.tagRepo.Delete(r.Context(), requestScope(r), id); err != nil {
+		return tagError(err, "error deleting tag")
+	}
+
+	return th.done(w, r, "tag deleted")
+}
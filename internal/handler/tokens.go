@@ -0,0 +1,125 @@
+// Package handler provides HTTP handlers.
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/LeandroDeJesus-S/quicknote/internal/errs"
+	"github.com/LeandroDeJesus-S/quicknote/internal/render"
+	"github.com/LeandroDeJesus-S/quicknote/internal/repo"
+	"github.com/LeandroDeJesus-S/quicknote/internal/support"
+	"github.com/LeandroDeJesus-S/quicknote/internal/support/authutil"
+	"github.com/LeandroDeJesus-S/quicknote/internal/validation"
+	"github.com/alexedwards/scs/v2"
+)
+
+// apiTokenHandler handles the HTTP requests to manage personal API tokens.
+type apiTokenHandler struct {
+	tokenRepo repo.APITokenRepository
+	render    render.TemplateRender
+	sesMng    *scs.SessionManager
+}
+
+// NewAPITokenHandler creates a new apiTokenHandler.
+func NewAPITokenHandler(tokenRepo repo.APITokenRepository, render render.TemplateRender, sesMng *scs.SessionManager) *apiTokenHandler {
+	return &apiTokenHandler{tokenRepo: tokenRepo, render: render, sesMng: sesMng}
+}
+
+// renderTokens renders the tokens page with the user's tokens merged into data.
+func (th apiTokenHandler) renderTokens(w http.ResponseWriter, r *http.Request, data map[string]any) error {
+	tokens, err := th.tokenRepo.List(r.Context(), th.sesMng.GetInt64(r.Context(), authutil.DefaultUserIDKey))
+	if err != nil {
+		return errs.NewHTTPError(err, http.StatusInternalServerError, "error listing API tokens")
+	}
+
+	if data == nil {
+		data = make(map[string]any)
+	}
+	data["Tokens"] = newAPITokenDTOList(tokens)
+	data["Scopes"] = authutil.Scopes
+	return th.render.Page(w, r, render.NewOpts().WithPage("user-tokens.html").WithData(data))
+}
+
+// Tokens handles the request to show the user's API tokens.
+func (th apiTokenHandler) Tokens(w http.ResponseWriter, r *http.Request) error {
+	return th.renderTokens(w, r, nil)
+}
+
+// TokensCreate handles the request to create an API token.
+// The plain token is only shown in the response, as just its hash is stored.
+func (th apiTokenHandler) TokensCreate(w http.ResponseWriter, r *http.Request) error {
+	if err := r.ParseForm(); err != nil {
+		return errs.NewHTTPError(err, http.StatusBadRequest, "failed to parse form")
+	}
+
+	validator := validation.NewFormValidator()
+	validator.AddValidator([]string{"name"}, validation.ValidateMinMaxLen(3, 100))
+	validator.ValidateForm(r.PostForm)
+
+	scopes := r.PostForm["scopes"]
+	if len(scopes) == 0 {
+		validator.AddError("scopes", "select at least one scope")
+	}
+	for _, scope := range scopes {
+		if !slices.Contains(authutil.Scopes, scope) {
+			validator.AddError("scopes", "invalid scope")
+		}
+	}
+
+	var expiresAt *time.Time
+	if rawDays := r.PostForm.Get("expires_in"); rawDays != "" {
+		days, err := strconv.Atoi(rawDays)
+		if err != nil || days <= 0 {
+			validator.AddError("expires_in", "invalid expiration")
+		} else {
+			t := time.Now().AddDate(0, 0, days)
+			expiresAt = &t
+		}
+	}
+
+	if !validator.Ok() {
+		return th.renderTokens(w, r, map[string]any{
+			"FieldErrors": validator.FieldErrors(),
+			"FormData":    map[string]string{"name": r.PostForm.Get("name")},
+		})
+	}
+
+	plainTok := authutil.APITokenPrefix + authutil.GenerateToken()
+	_, err := th.tokenRepo.Create(
+		r.Context(),
+		th.sesMng.GetInt64(r.Context(), authutil.DefaultUserIDKey),
+		r.PostForm.Get("name"),
+		authutil.HashToken(plainTok),
+		scopes,
+		expiresAt,
+	)
+	if err != nil {
+		return errs.NewHTTPError(err, http.StatusInternalServerError, "error creating API token")
+	}
+
+	return th.renderTokens(w, r, map[string]any{"NewToken": plainTok})
+}
+
+// TokensRevoke handles the request to revoke an API token.
+func (th apiTokenHandler) TokensRevoke(w http.ResponseWriter, r *http.Request) error {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		return errs.NewHTTPError(err, http.StatusBadRequest, "id is invalid")
+	}
+
+	err = th.tokenRepo.Delete(r.Context(), th.sesMng.GetInt64(r.Context(), authutil.DefaultUserIDKey), id)
+	if errors.Is(err, repo.ErrAPITokenNotFound) {
+		return errs.NewHTTPError(err, http.StatusNotFound, "API token not found")
+	}
+	if err != nil {
+		return errs.NewHTTPError(err, http.StatusInternalServerError, "error revoking API token")
+	}
+
+	support.SendFlashMessage(th.sesMng, r, support.FlashMsgSuccess, "token revoked")
+	http.Redirect(w, r, "/users/tokens", http.StatusSeeOther)
+	return nil
+}
@@ -0,0 +1,82 @@
+// Package handler provides HTTP handlers.
+package handler
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/LeandroDeJesus-S/quicknote/internal/errs"
+	"github.com/LeandroDeJesus-S/quicknote/internal/events"
+	"github.com/LeandroDeJesus-S/quicknote/internal/render"
+	"github.com/LeandroDeJesus-S/quicknote/internal/support"
+)
+
+// trashDone flashes a success message and redirects to the given path.
+func (nh noteHandler) trashDone(w http.ResponseWriter, r *http.Request, msg, path string) error {
+	support.SendFlashMessage(nh.sesMng, r, support.FlashMsgSuccess, msg)
+	http.Redirect(w, r, path, http.StatusSeeOther)
+	return nil
+}
+
+// Trash handles the request to list the notes in the trash.
+func (nh noteHandler) Trash(w http.ResponseWriter, r *http.Request) error {
+	notes, err := nh.noteRepo.ListTrash(r.Context(), requestScope(r))
+	if err != nil {
+		return errs.NewHTTPError(err, http.StatusInternalServerError, "error listing trash")
+	}
+
+	return nh.render.Page(w, r, render.NewOpts().WithPage("trash.html").WithData(map[string]any{
+		"Notes":         newTrashedNoteDTOList(notes, nh.trashRetention),
+		"RetentionDays": int(nh.trashRetention.Hours() / 24),
+	}))
+}
+
+// TrashRestore handles the request to take a note out of the trash.
+func (nh noteHandler) TrashRestore(w http.ResponseWriter, r *http.Request) error {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		return errs.NewHTTPError(err, http.StatusBadRequest, "id is invalid")
+	}
+	if err := requireRole(r, notesManagerRole); err != nil {
+		return err
+	}
+
+	if err := nh.noteRepo.Restore(r.Context(), requestScope(r), id); err != nil {
+		return noteError(err, "error restoring note")
+	}
+
+	nh.publish(r, events.NoteCreated, requestScope(r), id)
+	return nh.trashDone(w, r, "note restored", fmt.Sprintf("/notes/%d", id))
+}
+
+// TrashPurge handles the request to delete a note in the trash for good.
+func (nh noteHandler) TrashPurge(w http.ResponseWriter, r *http.Request) error {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		return errs.NewHTTPError(err, http.StatusBadRequest, "id is invalid")
+	}
+	if err := requireRole(r, notesManagerRole); err != nil {
+		return err
+	}
+
+	if err := nh.noteRepo.Purge(r.Context(), requestScope(r), id); err != nil {
+		return noteError(err, "error deleting note")
+	}
+
+	return nh.trashDone(w, r, "note deleted for good", "/notes/trash")
+}
+
+// TrashEmpty handles the request to delete every note in the trash for good.
+func (nh noteHandler) TrashEmpty(w http.ResponseWriter, r *http.Request) error {
+	if err := requireRole(r, notesManagerRole); err != nil {
+		return err
+	}
+
+	purged, err := nh.noteRepo.EmptyTrash(r.Context(), requestScope(r))
+	if err != nil {
+		return errs.NewHTTPError(err, http.StatusInternalServerError, "error emptying trash")
+	}
+
+	return nh.trashDone(w, r, fmt.Sprintf("%d note(s) deleted for good", purged), "/notes/trash")
+}
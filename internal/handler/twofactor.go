@@ -0,0 +1,330 @@
+// Package handler provides HTTP handlers.
+package handler
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"html/template"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/LeandroDeJesus-S/quicknote/internal/errs"
+	"github.com/LeandroDeJesus-S/quicknote/internal/models"
+	"github.com/LeandroDeJesus-S/quicknote/internal/render"
+	"github.com/LeandroDeJesus-S/quicknote/internal/repo"
+	"github.com/LeandroDeJesus-S/quicknote/internal/support"
+	"github.com/LeandroDeJesus-S/quicknote/internal/support/authutil"
+	"github.com/LeandroDeJesus-S/quicknote/internal/validation"
+	"github.com/skip2/go-qrcode"
+)
+
+const (
+	// twoFactorUserKey is the session key of the user who checked the password and still has to type a code.
+	twoFactorUserKey = "twoFactorUserID"
+	// twoFactorExpiresKey is the session key of when the pending sign-in expires.
+	twoFactorExpiresKey = "twoFactorExpires"
+
+	twoFactorTTL            = 5 * time.Minute
+	twoFactorMaxAttempts    = 5 // how many wrong codes a user can type within twoFactorAttemptsWindow
+	twoFactorAttemptsWindow = 15 * time.Minute
+	twoFactorLockout        = 15 * time.Minute // how long a user typing too many wrong codes can't sign in
+	recoveryCodesCount      = 10
+
+	totpIssuer = "Quicknote"
+)
+
+// twoFactorLockedMsg is shown to the users who typed too many wrong codes.
+var twoFactorLockedMsg = fmt.Sprintf("too many invalid codes, please try again in %d minutes", int(twoFactorLockout.Minutes()))
+
+// beginTwoFactor starts a pending sign-in of a user who checked the password, which only
+// becomes a session after the user types a code of the authenticator app or a recovery code.
+func (h *userHandler) beginTwoFactor(w http.ResponseWriter, r *http.Request, userID int64) error {
+	if err := h.sesMng.RenewToken(r.Context()); err != nil {
+		return errs.NewHTTPError(err, http.StatusInternalServerError, "failed to renew session token")
+	}
+
+	// a user signed in before on the same browser is signed out
+	h.sesMng.Remove(r.Context(), authutil.DefaultUserIDKey)
+	h.sesMng.Put(r.Context(), twoFactorUserKey, userID)
+	h.sesMng.Put(r.Context(), twoFactorExpiresKey, time.Now().Add(twoFactorTTL))
+
+	http.Redirect(w, r, "/users/signin/2fa", http.StatusSeeOther)
+	return nil
+}
+
+// pendingTwoFactor returns the user of the pending sign-in, or 0 if there is none or it expired.
+func (h *userHandler) pendingTwoFactor(r *http.Request) int64 {
+	userID := h.sesMng.GetInt64(r.Context(), twoFactorUserKey)
+	if userID <= 0 || time.Now().After(h.sesMng.GetTime(r.Context(), twoFactorExpiresKey)) {
+		return 0
+	}
+	return userID
+}
+
+// cancelTwoFactor discards the pending sign-in and sends the user back to the sign-in page.
+func (h *userHandler) cancelTwoFactor(w http.ResponseWriter, r *http.Request, msg string) error {
+	h.sesMng.Remove(r.Context(), twoFactorUserKey)
+	h.sesMng.Remove(r.Context(), twoFactorExpiresKey)
+
+	support.SendFlashMessage(h.sesMng, r, support.FlashMsgError, msg)
+	http.Redirect(w, r, "/users/signin", http.StatusSeeOther)
+	return nil
+}
+
+// SignInTwoFactor handles the request to show the page asking the code of a pending sign-in.
+func (h *userHandler) SignInTwoFactor(w http.ResponseWriter, r *http.Request) error {
+	if h.pendingTwoFactor(r) == 0 {
+		return h.cancelTwoFactor(w, r, "your sign-in expired, please try again")
+	}
+	return h.render.Page(w, r, render.NewOpts().WithPage("user-signin-2fa.html"))
+}
+
+// SignInTwoFactorPost handles the request to finish a pending sign-in with a code of the
+// authenticator app or one of the recovery codes, which are told apart by their length.
+func (h *userHandler) SignInTwoFactorPost(w http.ResponseWriter, r *http.Request) error {
+	if err := r.ParseForm(); err != nil {
+		return errs.NewHTTPError(err, http.StatusBadRequest, "failed to parse form")
+	}
+
+	userID := h.pendingTwoFactor(r)
+	if userID == 0 {
+		return h.cancelTwoFactor(w, r, "your sign-in expired, please try again")
+	}
+
+	validator := validation.NewFormValidator()
+	validator.AddValidator([]string{"code"}, validation.ValidateStringNotEmpty)
+	validator.ValidateForm(r.PostForm)
+	if !validator.Ok() {
+		return h.render.Page(w, r, render.NewOpts().WithPage("user-signin-2fa.html").WithData(map[string]any{
+			"FieldErrors": validator.FieldErrors(),
+		}))
+	}
+
+	totp, err := h.totpRepo.Find(r.Context(), userID)
+	if errors.Is(err, repo.ErrTOTPNotFound) {
+		// disabled meanwhile, the password was already checked
+		return h.finishTwoFactor(w, r, userID)
+	}
+	if err != nil {
+		return errs.NewHTTPError(err, http.StatusInternalServerError, "failed to verify code")
+	}
+	if totp.Locked {
+		return h.cancelTwoFactor(w, r, twoFactorLockedMsg)
+	}
+
+	code := r.PostForm.Get("code")
+	usedRecovery := len(code) > authutil.TOTPDigits+2
+	var ok bool
+	if usedRecovery {
+		ok, err = h.useRecoveryCode(r, userID, code)
+	} else {
+		ok, err = h.useTOTPCode(r, totp, code)
+	}
+	if err != nil {
+		return errs.NewHTTPError(err, http.StatusInternalServerError, "failed to verify code")
+	}
+
+	if !ok {
+		// the failures are counted for the user, so signing in again doesn't start them over
+		locked, err := h.totpRepo.RecordFailure(r.Context(), userID, twoFactorAttemptsWindow, twoFactorMaxAttempts, twoFactorLockout)
+		if err != nil {
+			return errs.NewHTTPError(err, http.StatusInternalServerError, "failed to verify code")
+		}
+		if locked {
+			return h.cancelTwoFactor(w, r, twoFactorLockedMsg)
+		}
+
+		validator.AddError("code", "invalid code")
+		return h.render.Page(w, r, render.NewOpts().WithPage("user-signin-2fa.html").WithData(map[string]any{
+			"FieldErrors": validator.FieldErrors(),
+		}))
+	}
+
+	if err := h.totpRepo.ResetFailures(r.Context(), userID); err != nil {
+		slog.Error("failed to reset the wrong codes of a user", "user_id", userID, "error", err)
+	}
+	if usedRecovery {
+		support.SendFlashMessage(h.sesMng, r, support.FlashMsgWarn, "you signed in with a recovery code, which can't be used again")
+	}
+	return h.finishTwoFactor(w, r, userID)
+}
+
+// useTOTPCode checks a code of the authenticator app, recording its time step so it can't be used again.
+func (h *userHandler) useTOTPCode(r *http.Request, totp *models.UserTOTP, code string) (bool, error) {
+	secret, err := h.cipher.Decrypt(totp.Secret.String)
+	if err != nil {
+		return false, err
+	}
+
+	step, ok := authutil.ValidateTOTP(string(secret), code, time.Now(), totp.LastUsedStep.Int64)
+	if !ok {
+		return false, nil
+	}
+
+	err = h.totpRepo.UseStep(r.Context(), totp.UserID.Int.Int64(), step)
+	if errors.Is(err, repo.ErrTOTPStepUsed) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// useRecoveryCode checks a recovery code, marking it as used.
+func (h *userHandler) useRecoveryCode(r *http.Request, userID int64, code string) (bool, error) {
+	hash := authutil.HashToken(authutil.NormalizeRecoveryCode(code))
+	err := h.totpRepo.UseRecoveryCode(r.Context(), userID, hash)
+	if errors.Is(err, repo.ErrRecoveryCodeNotFound) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// finishTwoFactor turns the pending sign-in into the session of the user.
+func (h *userHandler) finishTwoFactor(w http.ResponseWriter, r *http.Request, userID int64) error {
+	h.sesMng.Remove(r.Context(), twoFactorUserKey)
+	h.sesMng.Remove(r.Context(), twoFactorExpiresKey)
+
+	if err := h.startSession(r, userID); err != nil {
+		return err
+	}
+	http.Redirect(w, r, "/notes", http.StatusSeeOther)
+	return nil
+}
+
+// TwoFactorBegin handles the request to set up the two-factor authentication, storing a new
+// secret which is only enabled after the user confirms a code of the authenticator app.
+func (h *userHandler) TwoFactorBegin(w http.ResponseWriter, r *http.Request) error {
+	secret, err := h.cipher.Encrypt([]byte(authutil.GenerateTOTPSecret()))
+	if err != nil {
+		return errs.NewHTTPError(err, http.StatusInternalServerError, "failed to encrypt secret")
+	}
+
+	err = h.totpRepo.Begin(r.Context(), h.sesMng.GetInt64(r.Context(), authutil.DefaultUserIDKey), secret)
+	if errors.Is(err, repo.ErrTOTPEnabled) {
+		support.SendFlashMessage(h.sesMng, r, support.FlashMsgError, "two-factor authentication is already enabled")
+		http.Redirect(w, r, "/users/me", http.StatusSeeOther)
+		return nil
+	}
+	if err != nil {
+		return errs.NewHTTPError(err, http.StatusInternalServerError, "failed to set up two-factor authentication")
+	}
+
+	http.Redirect(w, r, "/users/me/2fa", http.StatusSeeOther)
+	return nil
+}
+
+// TwoFactorSetup handles the request to show the QR code of the secret being set up.
+func (h *userHandler) TwoFactorSetup(w http.ResponseWriter, r *http.Request) error {
+	return h.renderTwoFactorSetup(w, r, nil)
+}
+
+// renderTwoFactorSetup renders the page with the QR code of the secret being set up and the form
+// to confirm it, or redirects to the account page if there is none.
+func (h *userHandler) renderTwoFactorSetup(w http.ResponseWriter, r *http.Request, fieldErrors map[string]string) error {
+	userID := h.sesMng.GetInt64(r.Context(), authutil.DefaultUserIDKey)
+	totp, err := h.totpRepo.Find(r.Context(), userID)
+	if err != nil && !errors.Is(err, repo.ErrTOTPNotFound) {
+		return errs.NewHTTPError(err, http.StatusInternalServerError, "failed to read two-factor authentication")
+	}
+	if totp == nil || totp.Enabled() {
+		http.Redirect(w, r, "/users/me", http.StatusSeeOther)
+		return nil
+	}
+
+	usr, err := h.repo.FindByID(r.Context(), userID)
+	if err != nil {
+		return errs.NewHTTPError(err, http.StatusInternalServerError, "failed to find user")
+	}
+	secret, err := h.cipher.Decrypt(totp.Secret.String)
+	if err != nil {
+		return errs.NewHTTPError(err, http.StatusInternalServerError, "failed to decrypt secret")
+	}
+
+	png, err := qrcode.Encode(authutil.TOTPURI(totpIssuer, usr.Email.String, string(secret)), qrcode.Medium, 240)
+	if err != nil {
+		return errs.NewHTTPError(err, http.StatusInternalServerError, "failed to render qr code")
+	}
+
+	// the secret is shown in groups of four characters for the users who type it in the app
+	var groups []string
+	for s := string(secret); len(s) > 0; s = s[min(4, len(s)):] {
+		groups = append(groups, s[:min(4, len(s))])
+	}
+
+	return h.render.Page(w, r, render.NewOpts().WithPage("user-2fa-setup.html").WithData(map[string]any{
+		"QRCode":      template.URL("data:image/png;base64," + base64.StdEncoding.EncodeToString(png)),
+		"Secret":      strings.Join(groups, " "),
+		"FieldErrors": fieldErrors,
+	}))
+}
+
+// TwoFactorEnable handles the request to enable the secret being set up with a code of the
+// authenticator app, showing the recovery codes once.
+func (h *userHandler) TwoFactorEnable(w http.ResponseWriter, r *http.Request) error {
+	if err := r.ParseForm(); err != nil {
+		return errs.NewHTTPError(err, http.StatusBadRequest, "failed to parse form")
+	}
+
+	userID := h.sesMng.GetInt64(r.Context(), authutil.DefaultUserIDKey)
+	totp, err := h.totpRepo.Find(r.Context(), userID)
+	if err != nil && !errors.Is(err, repo.ErrTOTPNotFound) {
+		return errs.NewHTTPError(err, http.StatusInternalServerError, "failed to read two-factor authentication")
+	}
+	if totp == nil || totp.Enabled() {
+		http.Redirect(w, r, "/users/me", http.StatusSeeOther)
+		return nil
+	}
+
+	secret, err := h.cipher.Decrypt(totp.Secret.String)
+	if err != nil {
+		return errs.NewHTTPError(err, http.StatusInternalServerError, "failed to decrypt secret")
+	}
+	step, ok := authutil.ValidateTOTP(string(secret), r.PostForm.Get("code"), time.Now(), 0)
+	if !ok {
+		return h.renderTwoFactorSetup(w, r, map[string]string{"code": "invalid code, check the time of your phone and try again"})
+	}
+
+	codes := make([]string, recoveryCodesCount)
+	hashes := make([]string, recoveryCodesCount)
+	for i := range codes {
+		codes[i] = authutil.GenerateRecoveryCode()
+		hashes[i] = authutil.HashToken(codes[i])
+	}
+
+	if err := h.totpRepo.Enable(r.Context(), userID, step, hashes); err != nil {
+		return errs.NewHTTPError(err, http.StatusInternalServerError, "failed to enable two-factor authentication")
+	}
+
+	support.SendFlashMessage(h.sesMng, r, support.FlashMsgSuccess, "two-factor authentication enabled")
+	return h.renderAccount(w, r, map[string]any{"RecoveryCodes": codes})
+}
+
+// TwoFactorDisable handles the request to disable the two-factor authentication, which asks the password again.
+func (h *userHandler) TwoFactorDisable(w http.ResponseWriter, r *http.Request) error {
+	if err := r.ParseForm(); err != nil {
+		return errs.NewHTTPError(err, http.StatusBadRequest, "failed to parse form")
+	}
+
+	userID := h.sesMng.GetInt64(r.Context(), authutil.DefaultUserIDKey)
+	usr, err := h.repo.FindByID(r.Context(), userID)
+	if err != nil {
+		return errs.NewHTTPError(err, http.StatusInternalServerError, "failed to find user")
+	}
+
+	if ok, _ := h.pwHasher.CheckPassword(r.PostForm.Get("password"), usr.Password.String); !ok {
+		return h.renderAccount(w, r, map[string]any{
+			"FieldErrors": map[string]string{"disable_2fa_password": "invalid password"},
+		})
+	}
+
+	err = h.totpRepo.Disable(r.Context(), userID)
+	if err != nil && !errors.Is(err, repo.ErrTOTPNotFound) {
+		return errs.NewHTTPError(err, http.StatusInternalServerError, "failed to disable two-factor authentication")
+	}
+
+	support.SendFlashMessage(h.sesMng, r, support.FlashMsgSuccess, "two-factor authentication disabled")
+	http.Redirect(w, r, "/users/me", http.StatusSeeOther)
+	return nil
+}
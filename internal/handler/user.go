@@ -7,9 +7,11 @@ import (
 	"log/slog"
 	"net/http"
 	"strings"
+	"time"
 
 	"github.com/LeandroDeJesus-S/quicknote/internal/errs"
 	"github.com/LeandroDeJesus-S/quicknote/internal/mail"
+	"github.com/LeandroDeJesus-S/quicknote/internal/models"
 	"github.com/LeandroDeJesus-S/quicknote/internal/render"
 	"github.com/LeandroDeJesus-S/quicknote/internal/repo"
 	"github.com/LeandroDeJesus-S/quicknote/internal/support"
@@ -21,19 +23,25 @@ import (
 
 // userHandler handles HTTP requests for users.
 type userHandler struct {
-	sesMng   *scs.SessionManager
-	repo     repo.UserRepository
-	pwHasher authutil.PasswordHasher
+	sesMng       *scs.SessionManager
+	sessions     *authutil.SessionLister
+	repo         repo.UserRepository
+	calendarRepo repo.CalendarFeedRepository
+	totpRepo     repo.TOTPRepository
+	tokenRepo    repo.APITokenRepository
+	pwHasher     authutil.PasswordHasher
+	cipher       authutil.Cipher
 
 	render render.TemplateRender
 	mailer mail.Mailer
 
-	appDomain string
+	appDomain     string
+	deletionGrace time.Duration // how long after asking an account is deleted for good
 }
 
 // NewUserHandler creates a new userHandler.
-func NewUserHandler(repo repo.UserRepository, pwHasher authutil.PasswordHasher, sesMng *scs.SessionManager, render render.TemplateRender, mailer mail.Mailer, appDomain string) *userHandler {
-	uh := &userHandler{repo: repo, pwHasher: pwHasher, sesMng: sesMng, render: render, mailer: mailer, appDomain: appDomain}
+func NewUserHandler(repo repo.UserRepository, calendarRepo repo.CalendarFeedRepository, totpRepo repo.TOTPRepository, tokenRepo repo.APITokenRepository, pwHasher authutil.PasswordHasher, cipher authutil.Cipher, sesMng *scs.SessionManager, render render.TemplateRender, mailer mail.Mailer, appDomain string, deletionGrace time.Duration) *userHandler {
+	uh := &userHandler{repo: repo, calendarRepo: calendarRepo, totpRepo: totpRepo, tokenRepo: tokenRepo, pwHasher: pwHasher, cipher: cipher, sesMng: sesMng, sessions: authutil.NewSessionLister(sesMng), render: render, mailer: mailer, appDomain: appDomain, deletionGrace: deletionGrace}
 	return uh
 }
 
@@ -87,10 +95,6 @@ func (h *userHandler) SignInPost(w http.ResponseWriter, r *http.Request) error {
 	// testar se a funcionalidade de reenvio de token está ativada
 	if !usr.Active.Bool {
 		validator.AddError("email", "your account is not active")
-		tok, err := h.repo.UserPendingToken(r.Context(), usr.ID.Int.Int64())
-		if err != nil {
-			return errs.NewHTTPError(err, http.StatusInternalServerError, "failed to get user pending token")
-		}
 		return h.render.Page(
 			w,
 			r,
@@ -98,7 +102,6 @@ func (h *userHandler) SignInPost(w http.ResponseWriter, r *http.Request) error {
 				"FieldErrors":    validator.FieldErrors(),
 				"FormData":       map[string]string{"email": r.PostForm.Get("email")},
 				"askResendToken": true,
-				"userToken":      tok.Token.String,
 			}),
 		)
 	}
@@ -116,14 +119,16 @@ func (h *userHandler) SignInPost(w http.ResponseWriter, r *http.Request) error {
 		)
 	}
 
-	if err := h.sesMng.RenewToken(r.Context()); err != nil {
-		return errs.NewHTTPError(err, http.StatusInternalServerError, "failed to renew session token")
+	totp, err := h.totpRepo.Find(r.Context(), usr.ID.Int.Int64())
+	if err != nil && !errors.Is(err, repo.ErrTOTPNotFound) {
+		return errs.NewHTTPError(err, http.StatusInternalServerError, "failed to verify credentials")
+	}
+	if totp != nil && totp.Enabled() {
+		return h.beginTwoFactor(w, r, usr.ID.Int.Int64())
 	}
 
-	h.sesMng.Put(r.Context(), authutil.DefaultUserIDKey, usr.ID.Int.Int64())
-
-	if _, _, err := h.sesMng.Commit(r.Context()); err != nil {
-		return errs.NewHTTPError(err, http.StatusInternalServerError, "failed to commit session")
+	if err := h.startSession(r, usr.ID.Int.Int64()); err != nil {
+		return err
 	}
 
 	slog.Debug("session after commit",
@@ -134,6 +139,28 @@ func (h *userHandler) SignInPost(w http.ResponseWriter, r *http.Request) error {
 	return nil
 }
 
+// startSession signs the user in, renewing the session token. Signing in cancels the deletion of the account.
+func (h *userHandler) startSession(r *http.Request, userID int64) error {
+	if err := h.sesMng.RenewToken(r.Context()); err != nil {
+		return errs.NewHTTPError(err, http.StatusInternalServerError, "failed to renew session token")
+	}
+
+	h.sesMng.Put(r.Context(), authutil.DefaultUserIDKey, userID)
+
+	canceled, err := h.repo.CancelDeletion(r.Context(), userID)
+	if err != nil {
+		return errs.NewHTTPError(err, http.StatusInternalServerError, "failed to cancel account deletion")
+	}
+	if canceled {
+		support.SendFlashMessage(h.sesMng, r, support.FlashMsgInfo, "welcome back, the deletion of your account was canceled")
+	}
+
+	if _, _, err := h.sesMng.Commit(r.Context()); err != nil {
+		return errs.NewHTTPError(err, http.StatusInternalServerError, "failed to commit session")
+	}
+	return nil
+}
+
 // SignUp handles the request to show the sign-up page.
 func (h *userHandler) SignUp(w http.ResponseWriter, r *http.Request) error {
 	return h.render.Page(w, r, render.NewOpts().WithPage("user-signup.html"))
@@ -171,7 +198,7 @@ func (h *userHandler) SignUpPost(w http.ResponseWriter, r *http.Request) error {
 	}
 
 	tok := authutil.GenerateToken()
-	usr, token, err := h.repo.CreateUserAndToken(r.Context(), r.PostForm.Get("email"), hashedPw, tok)
+	usr, _, err := h.repo.CreateUserAndToken(r.Context(), r.PostForm.Get("email"), hashedPw, authutil.HashToken(tok))
 
 	if errors.Is(err, repo.ErrDuplicatedEmail) {
 		validator.AddError("email", "email not available")
@@ -189,7 +216,7 @@ func (h *userHandler) SignUpPost(w http.ResponseWriter, r *http.Request) error {
 		return errs.NewHTTPError(err, http.StatusInternalServerError, "failed to create user")
 	}
 
-	tokURL := fmt.Sprintf("%s/users/confirm/%s", h.appDomain, token.Token.String)
+	tokURL := fmt.Sprintf("%s/users/confirm/%s", h.appDomain, tok)
 	body, err := h.render.Mail("confirmation.html", tokURL)
 	if err != nil {
 		return errs.NewHTTPError(err, http.StatusInternalServerError, "failed to render confirmation email")
@@ -216,7 +243,15 @@ func (h *userHandler) SignUpSuccess(w http.ResponseWriter, r *http.Request) erro
 func (h *userHandler) Confirm(w http.ResponseWriter, r *http.Request) error {
 	token := r.PathValue("token")
 
-	if err := h.repo.ConfirmUserWithToken(r.Context(), token); err != nil {
+	err := h.repo.ConfirmUserWithToken(r.Context(), authutil.HashToken(token))
+	switch {
+	case errors.Is(err, repo.ErrConfirmationTokenNotFound), errors.Is(err, repo.ErrTokenWrongPurpose), errors.Is(err, repo.ErrTokenAlreadyConfirmed):
+		return errs.NewHTTPError(err, http.StatusNotFound, "invalid or already used link")
+	case errors.Is(err, repo.ErrTokenExpired):
+		support.SendFlashMessage(h.sesMng, r, support.FlashMsgError, "your token has expired, please ask for a new one")
+		http.Redirect(w, r, "/users/email-form?sub=resend-token", http.StatusSeeOther)
+		return nil
+	case err != nil:
 		return errs.NewHTTPError(err, http.StatusInternalServerError, "failed to confirm user")
 	}
 
@@ -233,7 +268,260 @@ func (h *userHandler) SignOut(w http.ResponseWriter, r *http.Request) error {
 	return nil
 }
 
+// Me handles the request to show the account page of the signed-in user.
 func (h *userHandler) Me(w http.ResponseWriter, r *http.Request) error {
+	return h.renderAccount(w, r, nil)
+}
+
+// renderAccount renders the account page with the user's settings merged into data.
+func (h *userHandler) renderAccount(w http.ResponseWriter, r *http.Request, data map[string]any) error {
+	if data == nil {
+		data = make(map[string]any)
+	}
+
+	userID := h.sesMng.GetInt64(r.Context(), authutil.DefaultUserIDKey)
+
+	usr, err := h.repo.FindByID(r.Context(), userID)
+	if err != nil {
+		return errs.NewHTTPError(err, http.StatusInternalServerError, "failed to find user")
+	}
+	data["Email"] = usr.Email.String
+	data["JoinedAt"] = usr.CreatedAt.Time.Format("02/01/2006")
+	data["DeletionGraceDays"] = int(h.deletionGrace.Hours() / 24)
+	data["DeletionGraceHours"] = int(h.deletionGrace.Hours())
+
+	totp, err := h.totpRepo.Find(r.Context(), userID)
+	if err != nil && !errors.Is(err, repo.ErrTOTPNotFound) {
+		return errs.NewHTTPError(err, http.StatusInternalServerError, "failed to read two-factor authentication")
+	}
+	if totp != nil && totp.Enabled() {
+		data["TwoFactorEnabledAt"] = totp.EnabledAt.Time.Format(dateTimeLayout)
+		data["RecoveryCodesLeft"] = totp.RecoveryCodesLeft
+	}
+
+	feed, err := h.calendarRepo.Find(r.Context(), userID)
+	if err != nil && !errors.Is(err, repo.ErrCalendarFeedNotFound) {
+		return errs.NewHTTPError(err, http.StatusInternalServerError, "error reading calendar")
+	}
+	if feed != nil {
+		data["CalendarCreatedAt"] = feed.CreatedAt.Time.Format(dateTimeLayout)
+	}
+	data["CalendarURL"] = h.sesMng.PopString(r.Context(), calendarURLKey)
+
+	return h.render.Page(w, r, render.NewOpts().WithPage("user-account.html").WithData(data))
+}
+
+// PasswordChange handles the request of the signed-in user to change the password, which asks the current one.
+func (h *userHandler) PasswordChange(w http.ResponseWriter, r *http.Request) error {
+	if err := r.ParseForm(); err != nil {
+		return errs.NewHTTPError(err, http.StatusBadRequest, "failed to parse form")
+	}
+
+	validator := validation.NewFormValidator()
+	validator.AddValidator([]string{"current_password"}, validation.ValidateStringNotEmpty)
+	validator.AddValidator([]string{"new_password"}, validation.ValidateMinMaxLen(6, 20))
+
+	if r.PostForm.Get("new_password") != r.PostForm.Get("password_confirm") {
+		validator.AddError("password_confirm", "passwords do not match")
+	}
+
+	validator.ValidateForm(r.PostForm)
+	if !validator.Ok() {
+		return h.renderAccount(w, r, map[string]any{"FieldErrors": validator.FieldErrors()})
+	}
+
+	usr, err := h.repo.FindByID(r.Context(), h.sesMng.GetInt64(r.Context(), authutil.DefaultUserIDKey))
+	if err != nil {
+		return errs.NewHTTPError(err, http.StatusInternalServerError, "failed to find user")
+	}
+	if ok, _ := h.pwHasher.CheckPassword(r.PostForm.Get("current_password"), usr.Password.String); !ok {
+		validator.AddError("current_password", "invalid password")
+		return h.renderAccount(w, r, map[string]any{"FieldErrors": validator.FieldErrors()})
+	}
+
+	newPW, err := h.pwHasher.HashPassword(r.PostForm.Get("new_password"))
+	if err != nil {
+		return errs.NewHTTPError(err, http.StatusInternalServerError, "failed to hash password")
+	}
+	if err := h.repo.UpdatePassword(r.Context(), usr.ID.Int.Int64(), newPW); err != nil {
+		return errs.NewHTTPError(err, http.StatusInternalServerError, "failed to update password")
+	}
+
+	// whoever knew the old password may be signed in somewhere else or hold an API token
+	if err := h.sesMng.RenewToken(r.Context()); err != nil {
+		return errs.NewHTTPError(err, http.StatusInternalServerError, "failed to renew session token")
+	}
+	if err := h.revokeAccess(r, usr.ID.Int.Int64()); err != nil {
+		return err
+	}
+
+	if err := h.mailer.Send(mail.Message{
+		To:      []string{usr.Email.String},
+		Subject: "Password changed",
+		Body:    []byte("Your password was successfully changed, your other sessions were signed out and your API tokens revoked. If it wasn't you, reset your password right away."),
+	}); err != nil {
+		slog.Error("failed to send password changed email", "error", err)
+	}
+
+	support.SendFlashMessage(h.sesMng, r, support.FlashMsgSuccess, "your password was successfully changed, your other sessions were signed out and your API tokens revoked")
+	http.Redirect(w, r, "/users/me", http.StatusSeeOther)
+	return nil
+}
+
+// revokeAccess signs out the sessions of the user but the one of the request and revokes the API tokens.
+func (h *userHandler) revokeAccess(r *http.Request, userID int64) error {
+	if err := h.sessions.RevokeUserSessions(r.Context(), userID, h.sesMng.Token(r.Context())); err != nil {
+		return errs.NewHTTPError(err, http.StatusInternalServerError, "failed to sign out the other sessions")
+	}
+	if err := h.tokenRepo.DeleteAll(r.Context(), userID); err != nil {
+		return errs.NewHTTPError(err, http.StatusInternalServerError, "failed to revoke the API tokens")
+	}
+	return nil
+}
+
+// EmailChange handles the request of the signed-in user to change the email, which asks the current
+// password. The switch only takes effect once the user opens the link mailed to the new email.
+func (h *userHandler) EmailChange(w http.ResponseWriter, r *http.Request) error {
+	if err := r.ParseForm(); err != nil {
+		return errs.NewHTTPError(err, http.StatusBadRequest, "failed to parse form")
+	}
+
+	newEmail := strings.TrimSpace(r.PostForm.Get("new_email"))
+	r.PostForm.Set("new_email", newEmail)
+
+	validator := validation.NewFormValidator()
+	validator.AddValidator([]string{"email_password"}, validation.ValidateStringNotEmpty)
+	validator.AddValidator([]string{"new_email"}, validation.ValidateEmailPattern)
+
+	validator.ValidateForm(r.PostForm)
+	if !validator.Ok() {
+		return h.renderAccount(w, r, map[string]any{
+			"FieldErrors": validator.FieldErrors(),
+			"FormData":    map[string]string{"new_email": newEmail},
+		})
+	}
+
+	usr, err := h.repo.FindByID(r.Context(), h.sesMng.GetInt64(r.Context(), authutil.DefaultUserIDKey))
+	if err != nil {
+		return errs.NewHTTPError(err, http.StatusInternalServerError, "failed to find user")
+	}
+	if ok, _ := h.pwHasher.CheckPassword(r.PostForm.Get("email_password"), usr.Password.String); !ok {
+		validator.AddError("email_password", "invalid password")
+	}
+	if strings.EqualFold(newEmail, usr.Email.String) {
+		validator.AddError("new_email", "this is already your email")
+	}
+
+	tok := authutil.GenerateToken()
+	if validator.Ok() {
+		_, err = h.repo.CreateEmailChangeToken(r.Context(), usr.ID.Int.Int64(), newEmail, authutil.HashToken(tok))
+		if errors.Is(err, repo.ErrDuplicatedEmail) {
+			validator.AddError("new_email", "email not available")
+		} else if err != nil {
+			return errs.NewHTTPError(err, http.StatusInternalServerError, "failed to create email change token")
+		}
+	}
+	if !validator.Ok() {
+		return h.renderAccount(w, r, map[string]any{
+			"FieldErrors": validator.FieldErrors(),
+			"FormData":    map[string]string{"new_email": newEmail},
+		})
+	}
+
+	tokURL := fmt.Sprintf("%s/users/confirm-email/%s", h.appDomain, tok)
+	body, err := h.render.Mail("email-change.html", map[string]string{"URL": tokURL, "Email": newEmail})
+	if err != nil {
+		return errs.NewHTTPError(err, http.StatusInternalServerError, "failed to render email change email")
+	}
+	if err := h.mailer.Send(mail.Message{
+		To:      []string{newEmail},
+		Subject: "Confirm your new email",
+		Body:    body,
+		IsHTML:  true,
+	}); err != nil {
+		return errs.NewHTTPError(err, http.StatusInternalServerError, "failed to send email change email")
+	}
+
+	if err := h.mailer.Send(mail.Message{
+		To:      []string{usr.Email.String},
+		Subject: "Email change requested",
+		Body:    []byte(fmt.Sprintf("A change of your email to %s was requested. If it wasn't you, change your password right away.", newEmail)),
+	}); err != nil {
+		slog.Error("failed to send email change notice", "error", err)
+	}
+
+	support.SendFlashMessage(h.sesMng, r, support.FlashMsgSuccess, "almost there, check your new email to confirm the change")
+	http.Redirect(w, r, "/users/me", http.StatusSeeOther)
+	return nil
+}
+
+// ConfirmEmail handles the request of the link mailed to a new email, switching the user's email to it.
+func (h *userHandler) ConfirmEmail(w http.ResponseWriter, r *http.Request) error {
+	_, err := h.repo.ConfirmEmailChange(r.Context(), authutil.HashToken(r.PathValue("token")))
+	switch {
+	case errors.Is(err, repo.ErrConfirmationTokenNotFound), errors.Is(err, repo.ErrTokenWrongPurpose), errors.Is(err, repo.ErrTokenAlreadyConfirmed):
+		return errs.NewHTTPError(err, http.StatusNotFound, "invalid or already used link")
+	case errors.Is(err, repo.ErrTokenExpired):
+		return errs.NewHTTPError(err, http.StatusBadRequest, "the link has expired, please ask the change again")
+	case errors.Is(err, repo.ErrDuplicatedEmail):
+		return errs.NewHTTPError(err, http.StatusConflict, "email not available")
+	case err != nil:
+		return errs.NewHTTPError(err, http.StatusInternalServerError, "failed to confirm email")
+	}
+
+	support.SendFlashMessage(h.sesMng, r, support.FlashMsgSuccess, "your email was successfully changed")
+	return h.render.Page(w, r, render.NewOpts().WithPage("generic-message.html"))
+}
+
+// AccountDelete handles the request of the signed-in user to delete the account, which asks the password.
+// The account is deleted for good after the grace period, unless the user signs in before.
+func (h *userHandler) AccountDelete(w http.ResponseWriter, r *http.Request) error {
+	if err := r.ParseForm(); err != nil {
+		return errs.NewHTTPError(err, http.StatusBadRequest, "failed to parse form")
+	}
+
+	usr, err := h.repo.FindByID(r.Context(), h.sesMng.GetInt64(r.Context(), authutil.DefaultUserIDKey))
+	if err != nil {
+		return errs.NewHTTPError(err, http.StatusInternalServerError, "failed to find user")
+	}
+	if ok, _ := h.pwHasher.CheckPassword(r.PostForm.Get("delete_password"), usr.Password.String); !ok {
+		return h.renderAccount(w, r, map[string]any{
+			"FieldErrors": map[string]string{"delete_password": "invalid password"},
+		})
+	}
+
+	deleteAt := time.Now().Add(h.deletionGrace)
+	if err := h.repo.ScheduleDeletion(r.Context(), usr.ID.Int.Int64(), deleteAt); err != nil {
+		return errs.NewHTTPError(err, http.StatusInternalServerError, "failed to schedule account deletion")
+	}
+
+	body, err := h.render.Mail("account-deletion.html", map[string]string{
+		"When": deleteAt.UTC().Format("2006-01-02 15:04 MST"),
+		"URL":  fmt.Sprintf("%s/users/signin", h.appDomain),
+	})
+	if err != nil {
+		return errs.NewHTTPError(err, http.StatusInternalServerError, "failed to render account deletion email")
+	}
+	if err := h.mailer.Send(mail.Message{
+		To:      []string{usr.Email.String},
+		Subject: "Your account will be deleted",
+		Body:    body,
+		IsHTML:  true,
+	}); err != nil {
+		slog.Error("failed to send account deletion email", "error", err)
+	}
+
+	// signs out everywhere, keeping a session without the user to show the flash message
+	if err := h.sesMng.RenewToken(r.Context()); err != nil {
+		return errs.NewHTTPError(err, http.StatusInternalServerError, "failed to renew session token")
+	}
+	h.sesMng.Remove(r.Context(), authutil.DefaultUserIDKey)
+	if err := h.revokeAccess(r, usr.ID.Int.Int64()); err != nil {
+		return err
+	}
+
+	support.SendFlashMessage(h.sesMng, r, support.FlashMsgInfo, "your account will be deleted, sign in before "+deleteAt.Format(dateTimeLayout)+" to cancel it")
+	http.Redirect(w, r, "/users/signin", http.StatusSeeOther)
 	return nil
 }
 
@@ -292,12 +580,12 @@ func (h *userHandler) ForgotPasswordPost(w http.ResponseWriter, r *http.Request)
 		)
 	}
 
-	tok, err := h.repo.CreateUserToken(r.Context(), usr.ID.Int.Int64(), authutil.GenerateToken())
-	if err != nil {
+	tok := authutil.GenerateToken()
+	if _, err := h.repo.CreateUserToken(r.Context(), usr.ID.Int.Int64(), authutil.HashToken(tok), models.TokenPurposePasswordReset); err != nil {
 		return errs.NewHTTPError(err, http.StatusInternalServerError, "failed to create user token")
 	}
 
-	tokURL := fmt.Sprintf("%s/users/reset-password/%s", h.appDomain, tok.Token.String)
+	tokURL := fmt.Sprintf("%s/users/reset-password/%s", h.appDomain, tok)
 	body, err := h.render.Mail("forgot-password.html", tokURL)
 	if err != nil {
 		return errs.NewHTTPError(err, http.StatusInternalServerError, "failed to render confirmation email")
@@ -323,11 +611,12 @@ func (h *userHandler) ResetPassword(w http.ResponseWriter, r *http.Request) erro
 		return errs.NewHTTPError(errors.New("pw token not sent"), http.StatusBadRequest, "invalid token")
 	}
 
-	if err := h.repo.CheckResetToken(r.Context(), token); err != nil {
+	if err := h.repo.CheckToken(r.Context(), authutil.HashToken(token), models.TokenPurposePasswordReset); err != nil {
 		slog.Error("failed to check pw token", "error", err)
 		if errors.Is(err, repo.ErrTokenExpired) {
 			support.SendFlashMessage(h.sesMng, r, support.FlashMsgError, "your token has expired, please try again")
-			http.Redirect(w, r, "/users/forgot-password", http.StatusSeeOther)
+			http.Redirect(w, r, "/users/email-form?sub=forgot-password", http.StatusSeeOther)
+			return nil
 		}
 		return errs.NewHTTPError(err, http.StatusBadRequest, err.Error())
 	}
@@ -369,8 +658,8 @@ func (h *userHandler) ResetPasswordPost(w http.ResponseWriter, r *http.Request)
 		)
 	}
 
-	token := r.PostForm.Get("token")
-	if err := h.repo.CheckResetToken(r.Context(), token); err != nil {
+	tokenHash := authutil.HashToken(r.PostForm.Get("token"))
+	if err := h.repo.CheckToken(r.Context(), tokenHash, models.TokenPurposePasswordReset); err != nil {
 		slog.Error("failed to check pw token", "error", err)
 		return errs.NewHTTPError(err, http.StatusBadRequest, err.Error())
 	}
@@ -380,21 +669,33 @@ func (h *userHandler) ResetPasswordPost(w http.ResponseWriter, r *http.Request)
 		return errs.NewHTTPError(err, http.StatusInternalServerError, "failed to hash password")
 	}
 
-	usrMail, err := h.repo.UpdatePasswordByToken(r.Context(), token, newPW)
+	// the token is checked again as it's used, so the same link can't reset the password twice at once
+	usr, err := h.repo.UpdatePasswordByToken(r.Context(), tokenHash, newPW)
+	if errors.Is(err, repo.ErrConfirmationTokenNotFound) || errors.Is(err, repo.ErrTokenWrongPurpose) || errors.Is(err, repo.ErrTokenAlreadyConfirmed) || errors.Is(err, repo.ErrTokenExpired) {
+		return errs.NewHTTPError(err, http.StatusBadRequest, err.Error())
+	}
 	if err != nil {
 		slog.Error("failed to update password", "error", err)
 		return errs.NewHTTPError(err, http.StatusInternalServerError, "failed to update password")
 	}
 
+	// whoever knew the old password is signed out, as the reset may be the way the owner takes the account back
+	if err := h.sessions.RevokeUserSessions(r.Context(), usr.ID.Int.Int64(), ""); err != nil {
+		return errs.NewHTTPError(err, http.StatusInternalServerError, "failed to sign out the sessions")
+	}
+	if err := h.tokenRepo.DeleteAll(r.Context(), usr.ID.Int.Int64()); err != nil {
+		return errs.NewHTTPError(err, http.StatusInternalServerError, "failed to revoke the API tokens")
+	}
+
 	if err := h.mailer.Send(mail.Message{
-		To:      []string{usrMail},
+		To:      []string{usr.Email.String},
 		Subject: "Password changed",
-		Body:    []byte("Your password was successfully changed"),
+		Body:    []byte("Your password was successfully changed, your sessions were signed out and your API tokens revoked. If it wasn't you, reset your password right away."),
 	}); err != nil {
 		slog.Error("failed to send confirmation email", "error", err)
 	}
 
-	support.SendFlashMessage(h.sesMng, r, support.FlashMsgSuccess, "your password was successfully changed, you can now sign in")
+	support.SendFlashMessage(h.sesMng, r, support.FlashMsgSuccess, "your password was successfully changed and your sessions signed out, you can now sign in")
 	http.Redirect(w, r, "/users/signin", http.StatusSeeOther)
 	return nil
 }
@@ -439,7 +740,7 @@ func (h *userHandler) ResendToken(w http.ResponseWriter, r *http.Request) error
 	}
 
 	// checks for pending token
-	pendingTok, err := h.repo.UserPendingToken(r.Context(), usr.ID.Int.Int64())
+	pendingTok, err := h.repo.UserPendingToken(r.Context(), usr.ID.Int.Int64(), models.TokenPurposeConfirmation)
 	if err != nil && !errors.Is(err, repo.ErrConfirmationTokenNotFound) {
 		return errs.NewHTTPError(err, http.StatusInternalServerError, "failed to get user pending token")
 	}
@@ -454,21 +755,8 @@ func (h *userHandler) ResendToken(w http.ResponseWriter, r *http.Request) error
 			}),
 		)
 	}
-	err = h.repo.CheckResetToken(r.Context(), pendingTok.Token.String)
-	if err != nil && !errors.Is(err, repo.ErrTokenExpired) {
-		validator.AddError("email", "invalid email")
-		return h.render.Page(
-			w,
-			r,
-			render.NewOpts().WithPage("user-email-form.html").WithData(map[string]any{
-				"FieldErrors": validator.FieldErrors(),
-				"FormData":    map[string]string{"email": email},
-			}),
-		)
-	}
-
 	newTok := authutil.GenerateToken()
-	if err := h.repo.UpdateUserToken(r.Context(), pendingTok.ID.Int.Int64(), newTok); err != nil {
+	if err := h.repo.UpdateUserToken(r.Context(), pendingTok.ID.Int.Int64(), authutil.HashToken(newTok)); err != nil {
 		return errs.NewHTTPError(err, http.StatusInternalServerError, "failed to update token")
 	}
 
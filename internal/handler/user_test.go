@@ -0,0 +1,140 @@
+package handler
+
+import (
+	"context"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/LeandroDeJesus-S/quicknote/internal/mail"
+	"github.com/LeandroDeJesus-S/quicknote/internal/models"
+	"github.com/LeandroDeJesus-S/quicknote/internal/render"
+	"github.com/LeandroDeJesus-S/quicknote/internal/repo"
+	"github.com/LeandroDeJesus-S/quicknote/internal/support"
+	"github.com/LeandroDeJesus-S/quicknote/internal/support/authutil"
+	"github.com/alexedwards/scs/v2"
+	"github.com/alexedwards/scs/v2/memstore"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// deletingUsers is a [repo.UserRepository] of a single user, recording when its deletion is scheduled.
+type deletingUsers struct {
+	repo.UserRepository
+
+	user     models.User
+	deleteAt time.Time
+}
+
+func (d *deletingUsers) FindByID(ctx context.Context, id int64) (*models.User, error) {
+	return &d.user, nil
+}
+
+func (d *deletingUsers) ScheduleDeletion(ctx context.Context, id int64, deleteAt time.Time) error {
+	d.deleteAt = deleteAt
+	return nil
+}
+
+// revokedTokens is a [repo.APITokenRepository] recording whose tokens were revoked.
+type revokedTokens struct {
+	repo.APITokenRepository
+
+	users []int64
+}
+
+func (t *revokedTokens) DeleteAll(ctx context.Context, userID int64) error {
+	t.users = append(t.users, userID)
+	return nil
+}
+
+// plainHasher is a [authutil.PasswordHasher] keeping the passwords as they are.
+type plainHasher struct{}
+
+func (plainHasher) HashPassword(password string) (string, error) { return password, nil }
+func (plainHasher) CheckPassword(pw, hash string) (bool, error)  { return pw == hash, nil }
+
+// mailRender is a [render.TemplateRender] only rendering the mails, as their template names.
+type mailRender struct {
+	render.TemplateRender
+}
+
+func (mailRender) Mail(tplName string, data any) ([]byte, error) { return []byte(tplName), nil }
+
+type discardMailer struct{}
+
+func (discardMailer) Send(msg mail.Message) error { return nil }
+
+func TestAccountDeleteSignsOutEverywhere(t *testing.T) {
+	sesMng := scs.New()
+	sesMng.Store = memstore.New()
+
+	// signIn stores a new session of the user, returning its token.
+	signIn := func(userID int64) string {
+		t.Helper()
+		ctx, err := sesMng.Load(context.Background(), "")
+		if err != nil {
+			t.Fatalf("Load() error = %v", err)
+		}
+		sesMng.Put(ctx, authutil.DefaultUserIDKey, userID)
+		token, _, err := sesMng.Commit(ctx)
+		if err != nil {
+			t.Fatalf("Commit() error = %v", err)
+		}
+		return token
+	}
+	current, other, stranger := signIn(1), signIn(1), signIn(2)
+
+	users := &deletingUsers{user: models.User{
+		ID:       pgtype.Numeric{Int: big.NewInt(1), Valid: true},
+		Email:    pgtype.Text{String: "user@example.com", Valid: true},
+		Password: pgtype.Text{String: "secret", Valid: true},
+	}}
+	tokens := &revokedTokens{}
+	uh := NewUserHandler(users, nil, nil, tokens, plainHasher{}, nil, sesMng, mailRender{}, discardMailer{}, "http://localhost", time.Hour)
+
+	handler := sesMng.LoadAndSave(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := uh.AccountDelete(w, r); err != nil {
+			t.Errorf("AccountDelete() error = %v", err)
+		}
+	}))
+	req := httptest.NewRequest(http.MethodPost, "/users/me/delete", strings.NewReader(url.Values{"delete_password": {"secret"}}.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.AddCookie(&http.Cookie{Name: sesMng.Cookie.Name, Value: current})
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+
+	if users.deleteAt.IsZero() {
+		t.Fatalf("AccountDelete() didn't schedule the deletion")
+	}
+	if len(tokens.users) != 1 || tokens.users[0] != 1 {
+		t.Errorf("revoked the API tokens of %v, want of user 1", tokens.users)
+	}
+	for token, want := range map[string]bool{current: false, other: false, stranger: true} {
+		if _, found, _ := sesMng.Store.Find(token); found != want {
+			t.Errorf("session %s found = %v, want %v", token, found, want)
+		}
+	}
+
+	var renewed string
+	for _, c := range w.Result().Cookies() {
+		if c.Name == sesMng.Cookie.Name {
+			renewed = c.Value
+		}
+	}
+	if renewed == "" || renewed == current {
+		t.Fatalf("session cookie = %q, want a renewed session", renewed)
+	}
+	ctx, err := sesMng.Load(context.Background(), renewed)
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if sesMng.Exists(ctx, authutil.DefaultUserIDKey) {
+		t.Errorf("renewed session still signed in")
+	}
+	if msg := sesMng.GetString(ctx, support.FlashMsgKey); !strings.Contains(msg, "will be deleted") {
+		t.Errorf("flash message = %q, want the deletion notice", msg)
+	}
+}
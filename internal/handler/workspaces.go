@@ -0,0 +1,585 @@
+// Package handler provides HTTP handlers.
+package handler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/LeandroDeJesus-S/quicknote/internal/errs"
+	"github.com/LeandroDeJesus-S/quicknote/internal/mail"
+	"github.com/LeandroDeJesus-S/quicknote/internal/models"
+	"github.com/LeandroDeJesus-S/quicknote/internal/render"
+	"github.com/LeandroDeJesus-S/quicknote/internal/repo"
+	"github.com/LeandroDeJesus-S/quicknote/internal/support"
+	"github.com/LeandroDeJesus-S/quicknote/internal/support/authutil"
+	"github.com/LeandroDeJesus-S/quicknote/internal/validation"
+	"github.com/alexedwards/scs/v2"
+)
+
+const (
+	// activeWorkspaceKey is the session key of the workspace the user chose to work on.
+	activeWorkspaceKey = "workspaceId"
+
+	// maxWorkspaceNameLen is the maximum length of a workspace name.
+	maxWorkspaceNameLen = 100
+)
+
+type membershipKey struct{}
+
+// requestMembership returns the membership of the signed-in user in their active workspace,
+// resolved by [workspaceMiddleware.Resolve], or nil for anonymous requests.
+func requestMembership(r *http.Request) *models.WorkspaceMember {
+	member, _ := r.Context().Value(membershipKey{}).(*models.WorkspaceMember)
+	return member
+}
+
+// requestScope returns the scope of the repository operations of the signed-in user, which is their active workspace.
+func requestScope(r *http.Request) repo.Scope {
+	member := requestMembership(r)
+	if member == nil {
+		return repo.Scope{}
+	}
+	return repo.Scope{WorkspaceID: member.Workspace.ID.Int.Int64(), UserID: member.UserID.Int.Int64()}
+}
+
+type workspaceMiddleware struct {
+	workspaceRepo repo.WorkspaceRepository
+	sesMng        *scs.SessionManager
+}
+
+// NewWorkspaceMiddleware creates a middleware resolving the active workspace of the signed-in user.
+func NewWorkspaceMiddleware(workspaceRepo repo.WorkspaceRepository, sesMng *scs.SessionManager) *workspaceMiddleware {
+	return &workspaceMiddleware{workspaceRepo: workspaceRepo, sesMng: sesMng}
+}
+
+// Resolve stores in the request context the membership of the signed-in user in their active workspace,
+// the one chosen with the switcher and kept in the session. The personal workspace is used instead when no
+// workspace was chosen, as for the requests authenticated by an API token, or when the user is no longer
+// a member of the chosen one. Anonymous requests are passed through untouched.
+//
+// It must run after the authentication middlewares.
+func (wm *workspaceMiddleware) Resolve(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		userID := authutil.RequestUserID(wm.sesMng, r)
+		if userID <= 0 {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		member, err := wm.membership(r, userID)
+		if err != nil {
+			slog.Error("failed to resolve the active workspace", "err", err, "user_id", userID)
+			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			return
+		}
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), membershipKey{}, member)))
+	})
+}
+
+// membership returns the membership of the user in the workspace of the session, falling back to the personal one.
+func (wm *workspaceMiddleware) membership(r *http.Request, userID int64) (*models.WorkspaceMember, error) {
+	if id := wm.sesMng.GetInt64(r.Context(), activeWorkspaceKey); id > 0 {
+		member, err := wm.workspaceRepo.Membership(r.Context(), userID, id)
+		if err == nil {
+			return member, nil
+		}
+		if !errors.Is(err, repo.ErrWorkspaceNotFound) {
+			return nil, err
+		}
+		// the user left the workspace or was removed from it
+		wm.sesMng.Remove(r.Context(), activeWorkspaceKey)
+	}
+	return wm.workspaceRepo.Personal(r.Context(), userID)
+}
+
+// TagWorkspaces returns a template tag listing the workspaces of the signed-in user for the workspace switcher.
+// It lists nothing for anonymous users.
+func TagWorkspaces(workspaceRepo repo.WorkspaceRepository) render.DynamicTag {
+	return func(r *http.Request) any {
+		return func() []WorkspaceDTO {
+			active := requestMembership(r)
+			if active == nil {
+				return nil
+			}
+
+			members, err := workspaceRepo.ListForUser(r.Context(), active.UserID.Int.Int64())
+			if err != nil {
+				slog.Error("failed to list the user's workspaces", "err", err, "user_id", active.UserID.Int)
+				return nil
+			}
+			return newWorkspaceDTOList(members, active.Workspace.ID.Int.Int64())
+		}
+	}
+}
+
+// workspaceHandler handles the HTTP requests to manage workspaces, their members and invites.
+type workspaceHandler struct {
+	workspaceRepo repo.WorkspaceRepository
+	render        render.TemplateRender
+	sesMng        *scs.SessionManager
+	mailer        mail.Mailer
+	appDomain     string
+}
+
+// NewWorkspaceHandler creates a new workspaceHandler.
+func NewWorkspaceHandler(workspaceRepo repo.WorkspaceRepository, render render.TemplateRender, sesMng *scs.SessionManager, mailer mail.Mailer, appDomain string) *workspaceHandler {
+	return &workspaceHandler{workspaceRepo: workspaceRepo, render: render, sesMng: sesMng, mailer: mailer, appDomain: appDomain}
+}
+
+// workspaceError converts a repository error into an HTTP error, answering with
+// 404 when the workspace does not exist or the user is not a member of it.
+func workspaceError(err error, message string) error {
+	switch {
+	case errors.Is(err, repo.ErrWorkspaceNotFound):
+		return errs.NewHTTPError(err, http.StatusNotFound, "workspace not found")
+	case errors.Is(err, repo.ErrWorkspaceMemberNotFound):
+		return errs.NewHTTPError(err, http.StatusNotFound, "workspace member not found")
+	case errors.Is(err, repo.ErrLastWorkspaceOwner):
+		return errs.NewHTTPError(err, http.StatusConflict, "the workspace must be left with an owner, make someone else an owner first")
+	}
+	return errs.NewHTTPError(err, http.StatusInternalServerError, message)
+}
+
+// member returns the membership of the signed-in user in the workspace of the request path,
+// answering with 403 if their role doesn't allow the needed one.
+func (wh workspaceHandler) member(r *http.Request, need models.WorkspaceRole) (*models.WorkspaceMember, error) {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		return nil, errs.NewHTTPError(err, http.StatusBadRequest, "id is invalid")
+	}
+
+	member, err := wh.workspaceRepo.Membership(r.Context(), requestScope(r).UserID, id)
+	if err != nil {
+		return nil, workspaceError(err, "error reading workspace")
+	}
+	if err := roleError(member, need); err != nil {
+		return nil, err
+	}
+	return member, nil
+}
+
+// roleError answers with 403 if the member's role doesn't allow the needed one.
+func roleError(member *models.WorkspaceMember, need models.WorkspaceRole) error {
+	if member.Role.Allows(need) {
+		return nil
+	}
+	return errs.NewHTTPError(
+		fmt.Errorf("user %d is a %s of workspace %d", member.UserID.Int, member.Role, member.Workspace.ID.Int),
+		http.StatusForbidden,
+		fmt.Sprintf("only the workspace %ss can do this", need),
+	)
+}
+
+// notesManagerRole is the role needed to do what can't be undone with the notes of a workspace, deleting or
+// restoring them and emptying the trash, and to share them out of it.
+const notesManagerRole = models.WorkspaceRoleAdmin
+
+// requireRole answers with 403 if the signed-in user's role in their active workspace doesn't allow the needed one.
+func requireRole(r *http.Request, need models.WorkspaceRole) error {
+	member := requestMembership(r)
+	if member == nil {
+		return errs.NewHTTPError(errors.New("no active workspace"), http.StatusForbidden, "no active workspace")
+	}
+	return roleError(member, need)
+}
+
+// TagCanManageNotes returns a dynamic tag reporting whether the signed-in user has the [notesManagerRole]
+// in their active workspace.
+func TagCanManageNotes() render.DynamicTag {
+	return func(r *http.Request) any {
+		return func() bool { return requireRole(r, notesManagerRole) == nil }
+	}
+}
+
+// done flashes a success message and redirects to the given page.
+func (wh workspaceHandler) done(w http.ResponseWriter, r *http.Request, url, msg string) error {
+	support.SendFlashMessage(wh.sesMng, r, support.FlashMsgSuccess, msg)
+	http.Redirect(w, r, url, http.StatusSeeOther)
+	return nil
+}
+
+// workspaceURL returns the URL of the page of a workspace.
+func workspaceURL(member *models.WorkspaceMember) string {
+	return fmt.Sprintf("/workspaces/%d", member.Workspace.ID.Int.Int64())
+}
+
+// validateWorkspaceName validates the name of a workspace sent in a form and returns it trimmed.
+func validateWorkspaceName(r *http.Request) (string, map[string]string) {
+	name := strings.TrimSpace(r.PostForm.Get("name"))
+	fieldErrors := map[string]string{}
+	if ok, msg := validation.ValidateStringNotEmpty(name); !ok {
+		fieldErrors["name"] = msg
+	} else if ok, msg := validation.ValidateMinMaxLen(1, maxWorkspaceNameLen)(name); !ok {
+		fieldErrors["name"] = msg
+	}
+	return name, fieldErrors
+}
+
+// renderWorkspaces renders the page listing the user's workspaces with data merged into it.
+func (wh workspaceHandler) renderWorkspaces(w http.ResponseWriter, r *http.Request, status int, data map[string]any) error {
+	scope := requestScope(r)
+	members, err := wh.workspaceRepo.ListForUser(r.Context(), scope.UserID)
+	if err != nil {
+		return errs.NewHTTPError(err, http.StatusInternalServerError, "error listing workspaces")
+	}
+
+	if data == nil {
+		data = make(map[string]any)
+	}
+	data["Workspaces"] = newWorkspaceDTOList(members, scope.WorkspaceID)
+	return wh.render.Page(w, r, render.NewOpts().WithPage("workspaces.html").WithData(data).WithStatus(status))
+}
+
+// Workspaces handles the request to list the user's workspaces.
+func (wh workspaceHandler) Workspaces(w http.ResponseWriter, r *http.Request) error {
+	return wh.renderWorkspaces(w, r, http.StatusOK, nil)
+}
+
+// WorkspacesCreate handles the request to create a team workspace, owned by the user, who switches to it.
+func (wh workspaceHandler) WorkspacesCreate(w http.ResponseWriter, r *http.Request) error {
+	if err := r.ParseForm(); err != nil {
+		return errs.NewHTTPError(err, http.StatusBadRequest, "failed to parse form")
+	}
+
+	name, fieldErrors := validateWorkspaceName(r)
+	if len(fieldErrors) > 0 {
+		return wh.renderWorkspaces(w, r, http.StatusUnprocessableEntity, map[string]any{"FieldErrors": fieldErrors, "FormData": map[string]string{"name": name}})
+	}
+
+	member, err := wh.workspaceRepo.Create(r.Context(), requestScope(r).UserID, name)
+	if err != nil {
+		return errs.NewHTTPError(err, http.StatusInternalServerError, "error creating workspace")
+	}
+
+	wh.sesMng.Put(r.Context(), activeWorkspaceKey, member.Workspace.ID.Int.Int64())
+	return wh.done(w, r, workspaceURL(member), "workspace created, invite the people you work with")
+}
+
+// WorkspacesSwitch handles the request to change the workspace the user is working on.
+func (wh workspaceHandler) WorkspacesSwitch(w http.ResponseWriter, r *http.Request) error {
+	if err := r.ParseForm(); err != nil {
+		return errs.NewHTTPError(err, http.StatusBadRequest, "failed to parse form")
+	}
+	id, err := strconv.ParseInt(r.PostForm.Get("workspace"), 10, 64)
+	if err != nil {
+		return errs.NewHTTPError(err, http.StatusBadRequest, "workspace id is invalid")
+	}
+
+	member, err := wh.workspaceRepo.Membership(r.Context(), requestScope(r).UserID, id)
+	if err != nil {
+		return workspaceError(err, "error switching workspace")
+	}
+
+	wh.sesMng.Put(r.Context(), activeWorkspaceKey, id)
+	return wh.done(w, r, "/notes", fmt.Sprintf("now working on %s", member.Workspace.Name.String))
+}
+
+// renderWorkspace renders the page of a workspace, with its members and pending invites, merging data into it.
+func (wh workspaceHandler) renderWorkspace(w http.ResponseWriter, r *http.Request, member *models.WorkspaceMember, status int, data map[string]any) error {
+	id := member.Workspace.ID.Int.Int64()
+	members, err := wh.workspaceRepo.ListMembers(r.Context(), id)
+	if err != nil {
+		return errs.NewHTTPError(err, http.StatusInternalServerError, "error listing workspace members")
+	}
+
+	var invites []models.WorkspaceInvite
+	if member.Role.Allows(models.WorkspaceRoleAdmin) {
+		if invites, err = wh.workspaceRepo.ListInvites(r.Context(), id); err != nil {
+			return errs.NewHTTPError(err, http.StatusInternalServerError, "error listing workspace invites")
+		}
+	}
+
+	if data == nil {
+		data = make(map[string]any)
+	}
+	data["Workspace"] = newWorkspaceDTO(*member, requestScope(r).WorkspaceID)
+	data["UserID"] = int(member.UserID.Int.Int64())
+	data["CanManage"] = member.Role.Allows(models.WorkspaceRoleAdmin)
+	data["IsOwner"] = member.Role.Allows(models.WorkspaceRoleOwner)
+	data["Members"] = newWorkspaceMemberDTOList(members)
+	data["Invites"] = newWorkspaceInviteDTOList(invites)
+	return wh.render.Page(w, r, render.NewOpts().WithPage("workspace.html").WithData(data).WithStatus(status))
+}
+
+// WorkspacesDetail handles the request to show a workspace of the user.
+func (wh workspaceHandler) WorkspacesDetail(w http.ResponseWriter, r *http.Request) error {
+	member, err := wh.member(r, models.WorkspaceRoleMember)
+	if err != nil {
+		return err
+	}
+	return wh.renderWorkspace(w, r, member, http.StatusOK, nil)
+}
+
+// WorkspacesRename handles the request to rename a workspace.
+func (wh workspaceHandler) WorkspacesRename(w http.ResponseWriter, r *http.Request) error {
+	member, err := wh.member(r, models.WorkspaceRoleOwner)
+	if err != nil {
+		return err
+	}
+	if err := r.ParseForm(); err != nil {
+		return errs.NewHTTPError(err, http.StatusBadRequest, "failed to parse form")
+	}
+
+	name, fieldErrors := validateWorkspaceName(r)
+	if len(fieldErrors) > 0 {
+		return wh.renderWorkspace(w, r, member, http.StatusUnprocessableEntity, map[string]any{"FieldErrors": fieldErrors})
+	}
+
+	if err := wh.workspaceRepo.Rename(r.Context(), member.Workspace.ID.Int.Int64(), name); err != nil {
+		return workspaceError(err, "error renaming workspace")
+	}
+	return wh.done(w, r, workspaceURL(member), "workspace renamed")
+}
+
+// WorkspacesDelete handles the request to delete a team workspace along with all its notes.
+func (wh workspaceHandler) WorkspacesDelete(w http.ResponseWriter, r *http.Request) error {
+	member, err := wh.member(r, models.WorkspaceRoleOwner)
+	if err != nil {
+		return err
+	}
+	if member.Workspace.IsPersonal() {
+		return errs.NewHTTPError(repo.ErrPersonalWorkspace, http.StatusBadRequest, "your personal workspace can't be deleted")
+	}
+
+	id := member.Workspace.ID.Int.Int64()
+	if err := wh.workspaceRepo.Delete(r.Context(), id); err != nil {
+		return workspaceError(err, "error deleting workspace")
+	}
+	if wh.sesMng.GetInt64(r.Context(), activeWorkspaceKey) == id {
+		wh.sesMng.Remove(r.Context(), activeWorkspaceKey)
+	}
+	return wh.done(w, r, "/workspaces", fmt.Sprintf("workspace %s deleted", member.Workspace.Name.String))
+}
+
+// WorkspacesInvite handles the request to invite someone by email to join a team workspace.
+// Inviting the same email again sends a new invite, the previous one no longer working.
+func (wh workspaceHandler) WorkspacesInvite(w http.ResponseWriter, r *http.Request) error {
+	member, err := wh.member(r, models.WorkspaceRoleAdmin)
+	if err != nil {
+		return err
+	}
+	if err := r.ParseForm(); err != nil {
+		return errs.NewHTTPError(err, http.StatusBadRequest, "failed to parse form")
+	}
+
+	email := strings.TrimSpace(r.PostForm.Get("email"))
+	role := models.WorkspaceRole(r.PostForm.Get("role"))
+
+	fieldErrors := map[string]string{}
+	if ok, msg := validation.ValidateEmailPattern(email); !ok {
+		fieldErrors["email"] = msg
+	}
+	if role != models.WorkspaceRoleMember && role != models.WorkspaceRoleAdmin {
+		fieldErrors["role"] = "invalid role"
+	}
+
+	var invite *models.WorkspaceInvite
+	plainTok := authutil.GenerateToken()
+	if len(fieldErrors) == 0 {
+		invite, err = wh.workspaceRepo.CreateInvite(r.Context(), member.UserID.Int.Int64(), member.Workspace.ID.Int.Int64(), email, role, authutil.HashToken(plainTok))
+		switch {
+		case errors.Is(err, repo.ErrPersonalWorkspace):
+			return errs.NewHTTPError(err, http.StatusBadRequest, "your personal workspace can't be shared, create a team workspace instead")
+		case errors.Is(err, repo.ErrAlreadyWorkspaceMember):
+			fieldErrors["email"] = "this user is already a member of the workspace"
+		case err != nil:
+			return workspaceError(err, "error inviting to workspace")
+		}
+	}
+	if len(fieldErrors) > 0 {
+		return wh.renderWorkspace(w, r, member, http.StatusUnprocessableEntity, map[string]any{"FieldErrors": fieldErrors, "FormData": map[string]string{"email": email}})
+	}
+
+	if err := wh.sendInviteMail(member, invite, plainTok); err != nil {
+		slog.Error("failed to send the workspace invite email", "workspace_id", member.Workspace.ID.Int, "error", err)
+		support.SendFlashMessage(wh.sesMng, r, support.FlashMsgWarn, fmt.Sprintf("the invite email to %s could not be sent, try inviting them again later", email))
+		http.Redirect(w, r, workspaceURL(member), http.StatusSeeOther)
+		return nil
+	}
+	return wh.done(w, r, workspaceURL(member), fmt.Sprintf("invite sent to %s", email))
+}
+
+// sendInviteMail sends the invite to join a workspace to its email, with a link to accept it by the plain token.
+func (wh workspaceHandler) sendInviteMail(member *models.WorkspaceMember, invite *models.WorkspaceInvite, plainTok string) error {
+	body, err := wh.render.Mail("workspace-invite.html", map[string]string{
+		"Inviter":   member.Email.String,
+		"Workspace": member.Workspace.Name.String,
+		"Role":      string(invite.Role),
+		"URL":       fmt.Sprintf("%s/invites/%s", wh.appDomain, plainTok),
+	})
+	if err != nil {
+		return err
+	}
+
+	return wh.mailer.Send(mail.Message{
+		To:      []string{invite.Email.String},
+		Subject: fmt.Sprintf("%s invited you to join %s on quicknote", member.Email.String, member.Workspace.Name.String),
+		Body:    body,
+		IsHTML:  true,
+	})
+}
+
+// WorkspacesInviteRevoke handles the request to cancel a pending invite.
+func (wh workspaceHandler) WorkspacesInviteRevoke(w http.ResponseWriter, r *http.Request) error {
+	member, err := wh.member(r, models.WorkspaceRoleAdmin)
+	if err != nil {
+		return err
+	}
+	inviteID, err := strconv.ParseInt(r.PathValue("invite"), 10, 64)
+	if err != nil {
+		return errs.NewHTTPError(err, http.StatusBadRequest, "invite id is invalid")
+	}
+
+	err = wh.workspaceRepo.RevokeInvite(r.Context(), member.Workspace.ID.Int.Int64(), inviteID)
+	if errors.Is(err, repo.ErrWorkspaceInviteNotFound) {
+		return errs.NewHTTPError(err, http.StatusNotFound, "invite not found")
+	}
+	if err != nil {
+		return workspaceError(err, "error revoking invite")
+	}
+	return wh.done(w, r, workspaceURL(member), "invite revoked")
+}
+
+// targetMember returns the member of the request path, checking the signed-in member can manage them:
+// admins manage the members and the other admins, while only owners manage the owners.
+func (wh workspaceHandler) targetMember(r *http.Request, member *models.WorkspaceMember) (*models.WorkspaceMember, error) {
+	userID, err := strconv.ParseInt(r.PathValue("user"), 10, 64)
+	if err != nil {
+		return nil, errs.NewHTTPError(err, http.StatusBadRequest, "user id is invalid")
+	}
+
+	target, err := wh.workspaceRepo.Membership(r.Context(), userID, member.Workspace.ID.Int.Int64())
+	if errors.Is(err, repo.ErrWorkspaceNotFound) {
+		return nil, errs.NewHTTPError(err, http.StatusNotFound, "workspace member not found")
+	}
+	if err != nil {
+		return nil, workspaceError(err, "error reading workspace member")
+	}
+	if !member.Role.Allows(target.Role) {
+		return nil, errs.NewHTTPError(
+			fmt.Errorf("user %d is a %s, not allowed to manage a %s", member.UserID.Int, member.Role, target.Role),
+			http.StatusForbidden,
+			"only the workspace owners can do this",
+		)
+	}
+	return target, nil
+}
+
+// WorkspacesMemberRole handles the request to change the role of a member.
+// Only owners can make someone an owner.
+func (wh workspaceHandler) WorkspacesMemberRole(w http.ResponseWriter, r *http.Request) error {
+	member, err := wh.member(r, models.WorkspaceRoleAdmin)
+	if err != nil {
+		return err
+	}
+	target, err := wh.targetMember(r, member)
+	if err != nil {
+		return err
+	}
+	if err := r.ParseForm(); err != nil {
+		return errs.NewHTTPError(err, http.StatusBadRequest, "failed to parse form")
+	}
+
+	role := models.WorkspaceRole(r.PostForm.Get("role"))
+	if !role.Allows(models.WorkspaceRoleMember) {
+		return errs.NewHTTPError(fmt.Errorf("invalid workspace role: %q", role), http.StatusBadRequest, "role is invalid")
+	}
+	if !member.Role.Allows(role) {
+		return errs.NewHTTPError(
+			fmt.Errorf("user %d is a %s, not allowed to grant %s", member.UserID.Int, member.Role, role),
+			http.StatusForbidden,
+			"only the workspace owners can do this",
+		)
+	}
+
+	if err := wh.workspaceRepo.SetMemberRole(r.Context(), member.Workspace.ID.Int.Int64(), target.UserID.Int.Int64(), role); err != nil {
+		return workspaceError(err, "error changing member role")
+	}
+	return wh.done(w, r, workspaceURL(member), fmt.Sprintf("%s is now a %s", target.Email.String, role))
+}
+
+// WorkspacesMemberRemove handles the request to remove a member from a workspace.
+// Any member can remove themselves to leave it, as long as the workspace is left with an owner.
+func (wh workspaceHandler) WorkspacesMemberRemove(w http.ResponseWriter, r *http.Request) error {
+	member, err := wh.member(r, models.WorkspaceRoleMember)
+	if err != nil {
+		return err
+	}
+
+	leaving := r.PathValue("user") == strconv.FormatInt(member.UserID.Int.Int64(), 10)
+	if leaving && member.Workspace.IsPersonal() {
+		return errs.NewHTTPError(repo.ErrPersonalWorkspace, http.StatusBadRequest, "you can't leave your personal workspace")
+	}
+
+	target := member
+	if !leaving {
+		if !member.Role.Allows(models.WorkspaceRoleAdmin) {
+			return errs.NewHTTPError(
+				fmt.Errorf("user %d is a %s of workspace %d", member.UserID.Int, member.Role, member.Workspace.ID.Int),
+				http.StatusForbidden,
+				"only the workspace admins can do this",
+			)
+		}
+		if target, err = wh.targetMember(r, member); err != nil {
+			return err
+		}
+	}
+
+	if err := wh.workspaceRepo.RemoveMember(r.Context(), member.Workspace.ID.Int.Int64(), target.UserID.Int.Int64()); err != nil {
+		return workspaceError(err, "error removing member")
+	}
+
+	if leaving {
+		return wh.done(w, r, "/workspaces", fmt.Sprintf("you left %s", member.Workspace.Name.String))
+	}
+	return wh.done(w, r, workspaceURL(member), fmt.Sprintf("%s removed from the workspace", target.Email.String))
+}
+
+// inviteError converts the error of finding or accepting an invite into an HTTP error.
+func inviteError(err error) error {
+	switch {
+	case errors.Is(err, repo.ErrConfirmationTokenNotFound):
+		return errs.NewHTTPError(err, http.StatusNotFound, "invite not found")
+	case errors.Is(err, repo.ErrTokenAlreadyConfirmed):
+		return errs.NewHTTPError(err, http.StatusGone, "this invite was already accepted")
+	case errors.Is(err, repo.ErrTokenExpired):
+		return errs.NewHTTPError(err, http.StatusGone, "this invite has expired, ask for a new one")
+	case errors.Is(err, repo.ErrInviteEmailMismatch):
+		return errs.NewHTTPError(err, http.StatusForbidden, "this invite was sent to another email, sign in with it to accept it")
+	}
+	return errs.NewHTTPError(err, http.StatusInternalServerError, "error reading invite")
+}
+
+// WorkspacesInviteShow handles the request to show an invite to join a workspace, so the user can accept it.
+func (wh workspaceHandler) WorkspacesInviteShow(w http.ResponseWriter, r *http.Request) error {
+	invite, workspace, err := wh.workspaceRepo.FindInvite(r.Context(), authutil.HashToken(r.PathValue("token")))
+	if err != nil {
+		return inviteError(err)
+	}
+
+	return wh.render.Page(w, r, render.NewOpts().WithPage("workspace-invite.html").WithData(map[string]any{
+		"Token":     r.PathValue("token"),
+		"Workspace": workspace.Name.String,
+		"Email":     invite.Email.String,
+		"Role":      string(invite.Role),
+		"Mismatch":  !strings.EqualFold(invite.Email.String, requestMembership(r).Email.String),
+	}))
+}
+
+// WorkspacesInviteAccept handles the request to accept an invite, which must have been sent to the
+// signed-in user's email. The user switches to the workspace they joined.
+func (wh workspaceHandler) WorkspacesInviteAccept(w http.ResponseWriter, r *http.Request) error {
+	active := requestMembership(r)
+	member, err := wh.workspaceRepo.AcceptInvite(r.Context(), authutil.HashToken(r.PathValue("token")), active.UserID.Int.Int64(), active.Email.String)
+	if err != nil {
+		return inviteError(err)
+	}
+
+	wh.sesMng.Put(r.Context(), activeWorkspaceKey, member.Workspace.ID.Int.Int64())
+	return wh.done(w, r, "/notes", fmt.Sprintf("welcome to %s", member.Workspace.Name.String))
+}
@@ -0,0 +1,246 @@
+// Package ical writes iCalendar (RFC 5545) calendars that calendar apps can subscribe to.
+package ical
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+const (
+	// maxLineLen is the length in octets the content lines are folded at.
+	maxLineLen = 75
+
+	dateTimeLayout = "20060102T150405"
+)
+
+// ComponentKind is the kind of calendar component an [Entry] is written as.
+type ComponentKind string
+
+const (
+	Event ComponentKind = "VEVENT" // something happening at a time
+	Todo  ComponentKind = "VTODO"  // something to be done by a time
+)
+
+// Entry is an event or to-do of a calendar.
+type Entry struct {
+	Kind        ComponentKind
+	UID         string    // identifies the entry across the versions of the calendar
+	Start       time.Time // when the entry happens or is due, written in its location
+	RRule       string    // the recurrence rule, such as "FREQ=WEEKLY", empty when the entry doesn't recur
+	Summary     string
+	Description string
+	URL         string
+	Completed   bool // whether a to-do is done
+}
+
+// Calendar is a list of entries.
+type Calendar struct {
+	ProdID  string // identifies the product that created the calendar
+	Name    string // the name calendar apps show for the calendar
+	Entries []Entry
+}
+
+// WriteTo writes the calendar in the iCalendar format. The entries starting out of UTC are written
+// with the time zone of their location, which is described from the Go time zone database.
+func (c Calendar) WriteTo(w io.Writer) (int64, error) {
+	cw := &contentWriter{w: bufio.NewWriter(w)}
+	now := time.Now()
+
+	cw.line("BEGIN", "VCALENDAR")
+	cw.line("VERSION", "2.0")
+	cw.line("PRODID", c.ProdID)
+	cw.line("CALSCALE", "GREGORIAN")
+	if c.Name != "" {
+		cw.line("X-WR-CALNAME", escapeText(c.Name))
+	}
+
+	for _, tz := range c.timezones(now) {
+		tz.write(cw)
+	}
+
+	stamp := now.UTC().Format(dateTimeLayout) + "Z"
+	for _, e := range c.Entries {
+		cw.line("BEGIN", string(e.Kind))
+		cw.line("UID", escapeText(e.UID))
+		cw.line("DTSTAMP", stamp)
+		cw.dateTime("DTSTART", e.Start)
+		if e.Kind == Todo {
+			cw.dateTime("DUE", e.Start)
+			status := "NEEDS-ACTION"
+			if e.Completed {
+				status = "COMPLETED"
+			}
+			cw.line("STATUS", status)
+		}
+		if e.RRule != "" {
+			cw.line("RRULE", e.RRule)
+		}
+		cw.line("SUMMARY", escapeText(e.Summary))
+		if e.Description != "" {
+			cw.line("DESCRIPTION", escapeText(e.Description))
+		}
+		if e.URL != "" {
+			cw.line("URL", e.URL)
+		}
+		cw.line("END", string(e.Kind))
+	}
+
+	cw.line("END", "VCALENDAR")
+	if cw.err == nil {
+		cw.err = cw.w.Flush()
+	}
+	return cw.n, cw.err
+}
+
+// timezones returns the time zones of the entries, described from the year of the earliest
+// entry in each of them until some years from now, which covers the recurrences calendar apps show.
+func (c Calendar) timezones(now time.Time) []timezone {
+	zones := make(map[string]*timezone)
+	for _, e := range c.Entries {
+		loc := e.Start.Location()
+		if loc == time.UTC {
+			continue
+		}
+		tz, ok := zones[loc.String()]
+		if !ok {
+			tz = &timezone{loc: loc, from: e.Start}
+			zones[loc.String()] = tz
+		}
+		if e.Start.Before(tz.from) {
+			tz.from = e.Start
+		}
+	}
+
+	tzs := make([]timezone, 0, len(zones))
+	for _, tz := range zones {
+		tz.from = time.Date(tz.from.In(tz.loc).Year(), time.January, 1, 0, 0, 0, 0, tz.loc)
+		tz.to = time.Date(now.Year()+5, time.January, 1, 0, 0, 0, 0, time.UTC)
+		tzs = append(tzs, *tz)
+	}
+	slices.SortFunc(tzs, func(a, b timezone) int {
+		return strings.Compare(a.loc.String(), b.loc.String())
+	})
+	return tzs
+}
+
+// timezone describes the offsets of a location from UTC over a period.
+type timezone struct {
+	loc      *time.Location
+	from, to time.Time
+}
+
+// write writes the VTIMEZONE component of the time zone, with an observance for the offset at
+// the start of the period and one for every change of offset within it.
+func (tz timezone) write(cw *contentWriter) {
+	cw.line("BEGIN", "VTIMEZONE")
+	cw.line("TZID", tz.loc.String())
+
+	start := tz.from.In(tz.loc)
+	_, offset := start.Zone()
+	tz.observance(cw, start, offset)
+
+	for day := tz.from; day.Before(tz.to); day = day.Add(24 * time.Hour) {
+		next := day.Add(24 * time.Hour)
+		_, before := day.In(tz.loc).Zone()
+		_, after := next.In(tz.loc).Zone()
+		if before == after {
+			continue
+		}
+
+		// the offsets change at most once a day, at the first second of the new offset
+		lo, hi := day, next
+		for hi.Sub(lo) > time.Second {
+			mid := lo.Add(hi.Sub(lo) / 2)
+			if _, o := mid.In(tz.loc).Zone(); o == before {
+				lo = mid
+			} else {
+				hi = mid
+			}
+		}
+		tz.observance(cw, hi.In(tz.loc), before)
+	}
+
+	cw.line("END", "VTIMEZONE")
+}
+
+// observance writes the observance of the offset starting at t, which follows the offset from.
+func (tz timezone) observance(cw *contentWriter, t time.Time, from int) {
+	name, offset := t.Zone()
+	kind := "STANDARD"
+	if t.IsDST() {
+		kind = "DAYLIGHT"
+	}
+
+	cw.line("BEGIN", kind)
+	// the start of an observance is the local time of the offset it follows
+	cw.line("DTSTART", t.UTC().Add(time.Duration(from)*time.Second).Format(dateTimeLayout))
+	cw.line("TZOFFSETFROM", formatOffset(from))
+	cw.line("TZOFFSETTO", formatOffset(offset))
+	cw.line("TZNAME", escapeText(name))
+	cw.line("END", kind)
+}
+
+// formatOffset formats an offset from UTC in seconds as +HHMM, or +HHMMSS if it has seconds.
+func formatOffset(offset int) string {
+	sign := '+'
+	if offset < 0 {
+		sign, offset = '-', -offset
+	}
+	s := fmt.Sprintf("%c%02d%02d", sign, offset/3600, offset/60%60)
+	if offset%60 != 0 {
+		s += fmt.Sprintf("%02d", offset%60)
+	}
+	return s
+}
+
+// escapeText escapes a value of the TEXT type.
+func escapeText(s string) string {
+	return strings.NewReplacer(`\`, `\\`, ";", `\;`, ",", `\,`, "\r\n", `\n`, "\n", `\n`, "\r", `\n`).Replace(s)
+}
+
+// contentWriter writes content lines, folding the long ones. Its first error is kept and stops any further writing.
+type contentWriter struct {
+	w   *bufio.Writer
+	n   int64
+	err error
+}
+
+// dateTime writes a date-time property, in UTC or with the time zone of t.
+func (cw *contentWriter) dateTime(name string, t time.Time) {
+	if t.Location() == time.UTC {
+		cw.line(name, t.Format(dateTimeLayout)+"Z")
+		return
+	}
+	cw.line(name+";TZID="+t.Location().String(), t.Format(dateTimeLayout))
+}
+
+// line writes the content line of a property, its value already escaped.
+func (cw *contentWriter) line(name, value string) {
+	if cw.err != nil {
+		return
+	}
+
+	line := name + ":" + value
+	var b strings.Builder
+	width := 0
+	for _, r := range line {
+		size := utf8.RuneLen(r)
+		if width+size > maxLineLen {
+			// the folded lines start with a space, which counts towards their length
+			b.WriteString("\r\n ")
+			width = 1
+		}
+		b.WriteRune(r)
+		width += size
+	}
+	b.WriteString("\r\n")
+
+	n, err := cw.w.WriteString(b.String())
+	cw.n += int64(n)
+	cw.err = err
+}
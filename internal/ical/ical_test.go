@@ -0,0 +1,108 @@
+package ical
+
+import (
+	"bufio"
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+)
+
+func TestEscapeText(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want string
+	}{
+		{"plain", "Buy milk", "Buy milk"},
+		{"empty", "", ""},
+		{"backslash", `C:\notes`, `C:\\notes`},
+		{"semicolon and comma", "eggs; milk, bread", `eggs\; milk\, bread`},
+		{"newlines", "a\nb\r\nc\rd", `a\nb\nc\nd`},
+		{"escaped already", `a\,b`, `a\\\,b`},
+		{"multi-byte", "Reunião às 9h, café", `Reunião às 9h\, café`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := escapeText(tt.in); got != tt.want {
+				t.Errorf("escapeText(%q) = %q, want %q", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLineFolding(t *testing.T) {
+	tests := []struct {
+		name  string
+		value string
+	}{
+		{"short", "Buy milk"},
+		{"exactly the limit", strings.Repeat("a", maxLineLen-len("SUMMARY:"))},
+		{"one past the limit", strings.Repeat("a", maxLineLen-len("SUMMARY:")+1)},
+		{"long ascii", strings.Repeat("abcdefghij", 30)},
+		{"two-byte runes", strings.Repeat("ção", 60)},
+		{"three-byte runes", strings.Repeat("日本語", 50)},
+		{"four-byte runes", strings.Repeat("😀", 70)},
+		{"runes across the limit", "a" + strings.Repeat("é", 80)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			cw := &contentWriter{w: bufio.NewWriter(&buf)}
+			cw.line("SUMMARY", tt.value)
+			if cw.err == nil {
+				cw.err = cw.w.Flush()
+			}
+			if cw.err != nil {
+				t.Fatalf("line() error = %v", cw.err)
+			}
+			if cw.n != int64(buf.Len()) {
+				t.Errorf("line() counted %d octets, wrote %d", cw.n, buf.Len())
+			}
+
+			out := buf.String()
+			if !strings.HasSuffix(out, "\r\n") {
+				t.Fatalf("line() = %q, want it ended by CRLF", out)
+			}
+			lines := strings.Split(strings.TrimSuffix(out, "\r\n"), "\r\n")
+			for i, line := range lines {
+				if len(line) > maxLineLen {
+					t.Errorf("line %d has %d octets, want at most %d", i, len(line), maxLineLen)
+				}
+				if !utf8.ValidString(line) {
+					t.Errorf("line %d splits a rune: %q", i, line)
+				}
+				if i > 0 && !strings.HasPrefix(line, " ") {
+					t.Errorf("folded line %d = %q, want it started by a space", i, line)
+				}
+			}
+
+			unfolded := strings.ReplaceAll(strings.TrimSuffix(out, "\r\n"), "\r\n ", "")
+			if unfolded != "SUMMARY:"+tt.value {
+				t.Errorf("unfolded line = %q, want %q", unfolded, "SUMMARY:"+tt.value)
+			}
+		})
+	}
+}
+
+func TestWriteToEscapesAndFolds(t *testing.T) {
+	cal := Calendar{ProdID: "-//test//EN", Entries: []Entry{{
+		Kind:    Event,
+		UID:     "note-1@test",
+		Start:   time.Date(2024, 1, 31, 9, 0, 0, 0, time.UTC),
+		Summary: "Reunião; " + strings.Repeat("ação, ", 20),
+	}}}
+
+	var buf bytes.Buffer
+	if _, err := cal.WriteTo(&buf); err != nil {
+		t.Fatalf("WriteTo() error = %v", err)
+	}
+	unfolded := strings.ReplaceAll(buf.String(), "\r\n ", "")
+	if want := "SUMMARY:" + escapeText(cal.Entries[0].Summary) + "\r\n"; !strings.Contains(unfolded, want) {
+		t.Errorf("WriteTo() = %q, want the summary %q", unfolded, want)
+	}
+	if !strings.Contains(unfolded, "DTSTART:20240131T090000Z\r\n") {
+		t.Errorf("WriteTo() = %q, want the start in UTC", unfolded)
+	}
+}
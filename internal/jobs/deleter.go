@@ -0,0 +1,53 @@
+package jobs
+
+import (
+	"context"
+	"log/slog"
+	"time"
+)
+
+// AccountDeleterRepo is what the [AccountDeleter] needs from the users repository.
+type AccountDeleterRepo interface {
+	DeleteScheduledBefore(ctx context.Context, before time.Time) (int64, error)
+}
+
+// AccountDeleter periodically deletes for good the accounts whose deletion grace period is over.
+// Their notes, tokens and the rest of their data go with them through the foreign keys in cascade.
+type AccountDeleter struct {
+	users    AccountDeleterRepo
+	interval time.Duration
+}
+
+// NewAccountDeleter creates a new AccountDeleter that looks for the accounts to delete on every interval.
+func NewAccountDeleter(users AccountDeleterRepo, interval time.Duration) *AccountDeleter {
+	return &AccountDeleter{users: users, interval: interval}
+}
+
+// DeleteOnce deletes the accounts due to be deleted and returns how many were deleted.
+func (d *AccountDeleter) DeleteOnce(ctx context.Context) (int64, error) {
+	return d.users.DeleteScheduledBefore(ctx, time.Now())
+}
+
+// Run deletes the due accounts right away and then on every interval, until ctx is done.
+func (d *AccountDeleter) Run(ctx context.Context) {
+	slog.Info("account deleter started", "interval", d.interval)
+
+	ticker := time.NewTicker(d.interval)
+	defer ticker.Stop()
+
+	for {
+		deleted, err := d.DeleteOnce(ctx)
+		if err != nil {
+			slog.Error("failed to delete accounts", "err", err)
+		} else if deleted > 0 {
+			slog.Info("accounts deleted", "users", deleted)
+		}
+
+		select {
+		case <-ctx.Done():
+			slog.Info("account deleter stopped")
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,196 @@
+package jobs
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"time"
+
+	"github.com/LeandroDeJesus-S/quicknote/internal/blob"
+	"github.com/LeandroDeJesus-S/quicknote/internal/export"
+	"github.com/LeandroDeJesus-S/quicknote/internal/mail"
+	"github.com/LeandroDeJesus-S/quicknote/internal/models"
+	"github.com/LeandroDeJesus-S/quicknote/internal/render"
+	"github.com/LeandroDeJesus-S/quicknote/internal/support/authutil"
+)
+
+// exportBatchSize is how many archives are generated at once, each one held in a temporary file.
+const exportBatchSize = 5
+
+// DataExporterRepo is what the [DataExporter] needs from the data exports repository.
+type DataExporterRepo interface {
+	GeneratePending(ctx context.Context, limit int, expiresAt time.Time, generate func(models.DataExport) (string, int64, error)) ([]models.DataExport, error)
+	DeleteExpired(ctx context.Context, now time.Time) ([]string, error)
+	Collect(ctx context.Context, userID int64) (*models.PersonalData, error)
+}
+
+// SessionLister lists the sessions signed in as a user.
+type SessionLister interface {
+	UserSessions(ctx context.Context, userID int64) ([]models.Session, error)
+}
+
+// DataExporter periodically generates the archives with the personal data the users asked for, mailing
+// them a signed link to download it, and removes the archives whose link expired.
+type DataExporter struct {
+	exports   DataExporterRepo
+	sessions  SessionLister
+	blobs     blob.BlobStore
+	mailer    mail.Mailer
+	render    render.TemplateRender
+	signer    authutil.URLSigner
+	appDomain string
+	ttl       time.Duration // how long the archives can be downloaded
+	interval  time.Duration
+}
+
+// NewDataExporter creates a new DataExporter that looks for the archives to generate on every interval,
+// which can be downloaded for ttl from the app at appDomain.
+func NewDataExporter(exports DataExporterRepo, sessions SessionLister, blobs blob.BlobStore, mailer mail.Mailer, render render.TemplateRender, signer authutil.URLSigner, appDomain string, ttl, interval time.Duration) *DataExporter {
+	return &DataExporter{
+		exports:   exports,
+		sessions:  sessions,
+		blobs:     blobs,
+		mailer:    mailer,
+		render:    render,
+		signer:    signer,
+		appDomain: appDomain,
+		ttl:       ttl,
+		interval:  interval,
+	}
+}
+
+// ExportOnce generates the pending archives and mails their links, returning how many were handled.
+func (e *DataExporter) ExportOnce(ctx context.Context) (int, error) {
+	handled := 0
+	for {
+		expiresAt := time.Now().Add(e.ttl)
+		exports, err := e.exports.GeneratePending(ctx, exportBatchSize, expiresAt, func(exp models.DataExport) (string, int64, error) {
+			return e.generate(ctx, exp)
+		})
+		if err != nil {
+			return handled, err
+		}
+
+		for _, exp := range exports {
+			if err := e.notify(exp); err != nil {
+				slog.Error("failed to mail data export", "err", err, "export_id", exp.ID.Int)
+			}
+		}
+
+		handled += len(exports)
+		if len(exports) < exportBatchSize {
+			return handled, nil
+		}
+	}
+}
+
+// generate writes the archive of an export to a temporary file and stores it, returning the key and size of its blob.
+func (e *DataExporter) generate(ctx context.Context, exp models.DataExport) (string, int64, error) {
+	userID := exp.UserID.Int.Int64()
+	data, err := e.exports.Collect(ctx, userID)
+	if err != nil {
+		return "", 0, err
+	}
+	data.Sessions, err = e.sessions.UserSessions(ctx, userID)
+	if err != nil {
+		return "", 0, fmt.Errorf("failed to list sessions: %w", err)
+	}
+
+	f, err := os.CreateTemp("", "quicknote-export-*.zip")
+	if err != nil {
+		return "", 0, err
+	}
+	defer os.Remove(f.Name())
+	defer f.Close()
+
+	if err := export.WriteArchive(f, data, time.Now()); err != nil {
+		return "", 0, err
+	}
+	size, err := f.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return "", 0, err
+	}
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return "", 0, err
+	}
+
+	key := blob.NewKey("exports")
+	if err := e.blobs.Put(ctx, key, f, size, "application/zip"); err != nil {
+		return "", 0, err
+	}
+	return key, size, nil
+}
+
+// notify mails the user the signed link of a generated archive, or tells that it failed.
+func (e *DataExporter) notify(exp models.DataExport) error {
+	if exp.Status != models.DataExportReady {
+		return e.mailer.Send(mail.Message{
+			To:      []string{exp.Email.String},
+			Subject: "Your data export failed",
+			Body:    []byte("We couldn't generate the archive with your data. Please ask for it again from your account page."),
+		})
+	}
+
+	link := e.signer.Sign(fmt.Sprintf("/exports/%d", exp.ID.Int.Int64()), exp.ExpiresAt.Time)
+	body, err := e.render.Mail("data-export.html", map[string]string{
+		"URL":     e.appDomain + link,
+		"Expires": exp.ExpiresAt.Time.UTC().Format("2006-01-02 15:04 MST"),
+	})
+	if err != nil {
+		return err
+	}
+	return e.mailer.Send(mail.Message{
+		To:      []string{exp.Email.String},
+		Subject: "Your data export is ready",
+		Body:    body,
+		IsHTML:  true,
+	})
+}
+
+// cleanup removes the archives expired by now and their blobs.
+func (e *DataExporter) cleanup(ctx context.Context) (int, error) {
+	keys, err := e.exports.DeleteExpired(ctx, time.Now())
+	if err != nil {
+		return 0, err
+	}
+	for _, key := range keys {
+		if err := e.blobs.Delete(ctx, key); err != nil {
+			slog.Error("failed to delete data export blob", "err", err, "key", key)
+		}
+	}
+	return len(keys), nil
+}
+
+// Run generates the pending archives and removes the expired ones right away and then on every
+// interval, until ctx is done.
+func (e *DataExporter) Run(ctx context.Context) {
+	slog.Info("data exporter started", "ttl", e.ttl, "interval", e.interval)
+
+	ticker := time.NewTicker(e.interval)
+	defer ticker.Stop()
+
+	for {
+		exported, err := e.ExportOnce(ctx)
+		if err != nil {
+			slog.Error("failed to generate data exports", "err", err)
+		} else if exported > 0 {
+			slog.Info("data exports generated", "exports", exported)
+		}
+
+		removed, err := e.cleanup(ctx)
+		if err != nil {
+			slog.Error("failed to remove expired data exports", "err", err)
+		} else if removed > 0 {
+			slog.Info("expired data exports removed", "exports", removed)
+		}
+
+		select {
+		case <-ctx.Done():
+			slog.Info("data exporter stopped")
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,54 @@
+// Package jobs provides the background jobs run alongside the HTTP server.
+package jobs
+
+import (
+	"context"
+	"log/slog"
+	"time"
+)
+
+// TrashPurgerRepo is what the [TrashPurger] needs from the notes repository.
+type TrashPurgerRepo interface {
+	PurgeDeletedBefore(ctx context.Context, before time.Time) (int64, error)
+}
+
+// TrashPurger periodically deletes for good the notes kept in the trash for longer than the retention period.
+type TrashPurger struct {
+	notes     TrashPurgerRepo
+	retention time.Duration
+	interval  time.Duration
+}
+
+// NewTrashPurger creates a new TrashPurger that checks the trash on every interval.
+func NewTrashPurger(notes TrashPurgerRepo, retention, interval time.Duration) *TrashPurger {
+	return &TrashPurger{notes: notes, retention: retention, interval: interval}
+}
+
+// PurgeOnce deletes the notes deleted before the retention period and returns how many were purged.
+func (p *TrashPurger) PurgeOnce(ctx context.Context) (int64, error) {
+	return p.notes.PurgeDeletedBefore(ctx, time.Now().Add(-p.retention))
+}
+
+// Run purges the trash right away and then on every interval, until ctx is done.
+func (p *TrashPurger) Run(ctx context.Context) {
+	slog.Info("trash purger started", "retention", p.retention, "interval", p.interval)
+
+	ticker := time.NewTicker(p.interval)
+	defer ticker.Stop()
+
+	for {
+		purged, err := p.PurgeOnce(ctx)
+		if err != nil {
+			slog.Error("failed to purge the trash", "err", err)
+		} else if purged > 0 {
+			slog.Info("trash purged", "notes", purged)
+		}
+
+		select {
+		case <-ctx.Done():
+			slog.Info("trash purger stopped")
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,107 @@
+package jobs
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/LeandroDeJesus-S/quicknote/internal/mail"
+	"github.com/LeandroDeJesus-S/quicknote/internal/models"
+	"github.com/LeandroDeJesus-S/quicknote/internal/render"
+)
+
+// reminderBatchSize is how many due reminders are sent at once.
+const reminderBatchSize = 100
+
+// ReminderSenderRepo is what the [ReminderSender] needs from the reminders repository.
+type ReminderSenderRepo interface {
+	DeliverDue(ctx context.Context, now time.Time, limit int, deliver func(models.DueReminder) error) (int, error)
+}
+
+// ReminderSender periodically mails the reminders of the notes that are due. Each reminder is
+// locked while it's sent, so every instance of the server can run its own sender.
+type ReminderSender struct {
+	reminders ReminderSenderRepo
+	mailer    mail.Mailer
+	render    render.TemplateRender
+	appDomain string
+	interval  time.Duration
+}
+
+// NewReminderSender creates a new ReminderSender that looks for due reminders on every interval,
+// linking the mails to the notes in the app at appDomain.
+func NewReminderSender(reminders ReminderSenderRepo, mailer mail.Mailer, render render.TemplateRender, appDomain string, interval time.Duration) *ReminderSender {
+	return &ReminderSender{reminders: reminders, mailer: mailer, render: render, appDomain: appDomain, interval: interval}
+}
+
+// SendOnce sends the reminders due by now, returning how many were handled.
+// Reminders failing to be sent are kept to be sent again on the next interval.
+func (s *ReminderSender) SendOnce(ctx context.Context) (int, error) {
+	sent := 0
+	for {
+		failed := false
+		handled, err := s.reminders.DeliverDue(ctx, time.Now(), reminderBatchSize, func(reminder models.DueReminder) error {
+			err := s.send(reminder)
+			failed = failed || err != nil
+			return err
+		})
+		if err != nil {
+			return sent, err
+		}
+		sent += handled
+
+		// a short or failing batch means there is nothing else to be sent for now
+		if handled < reminderBatchSize || failed {
+			return sent, nil
+		}
+	}
+}
+
+// send mails a reminder to the user who set it, telling its time in the time zone it was set in.
+func (s *ReminderSender) send(reminder models.DueReminder) error {
+	loc, err := time.LoadLocation(reminder.Timezone.String)
+	if err != nil {
+		loc = time.UTC
+	}
+
+	body, err := s.render.Mail("reminder.html", map[string]string{
+		"Title": reminder.Title.String,
+		"When":  reminder.DueAt.Time.In(loc).Format("2006-01-02 15:04 MST"),
+		"URL":   fmt.Sprintf("%s/notes/%d", s.appDomain, reminder.NoteID.Int),
+	})
+	if err != nil {
+		return err
+	}
+
+	return s.mailer.Send(mail.Message{
+		To:      []string{reminder.Email.String},
+		Subject: fmt.Sprintf("Reminder: %s", reminder.Title.String),
+		Body:    body,
+		IsHTML:  true,
+	})
+}
+
+// Run sends the due reminders right away and then on every interval, until ctx is done.
+func (s *ReminderSender) Run(ctx context.Context) {
+	slog.Info("reminder sender started", "interval", s.interval)
+
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+
+	for {
+		sent, err := s.SendOnce(ctx)
+		if err != nil {
+			slog.Error("failed to send the due reminders", "err", err)
+		} else if sent > 0 {
+			slog.Info("due reminders sent", "reminders", sent)
+		}
+
+		select {
+		case <-ctx.Done():
+			slog.Info("reminder sender stopped")
+			return
+		case <-ticker.C:
+		}
+	}
+}
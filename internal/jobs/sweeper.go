@@ -0,0 +1,100 @@
+package jobs
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/LeandroDeJesus-S/quicknote/internal/blob"
+	"github.com/LeandroDeJesus-S/quicknote/internal/models"
+)
+
+// sweepBatchSize is how many orphan attachments are swept at once.
+const sweepBatchSize = 100
+
+// AttachmentSweeperRepo is what the [AttachmentSweeper] needs from the attachments repository.
+type AttachmentSweeperRepo interface {
+	ListOrphans(ctx context.Context, limit int) ([]models.Attachment, error)
+	DeleteOrphans(ctx context.Context, storageKeys []string) error
+}
+
+// AttachmentSweeper periodically deletes the blobs of the attachments detached from their notes,
+// which happens when they are deleted or their notes are purged.
+type AttachmentSweeper struct {
+	attachments AttachmentSweeperRepo
+	blobs       blob.BlobStore
+	interval    time.Duration
+}
+
+// NewAttachmentSweeper creates a new AttachmentSweeper that looks for orphan attachments on every interval.
+func NewAttachmentSweeper(attachments AttachmentSweeperRepo, blobs blob.BlobStore, interval time.Duration) *AttachmentSweeper {
+	return &AttachmentSweeper{attachments: attachments, blobs: blobs, interval: interval}
+}
+
+// SweepOnce deletes the orphan attachments and their blobs, returning how many were deleted.
+// Attachments whose blobs can't be deleted are kept to be swept again later.
+func (s *AttachmentSweeper) SweepOnce(ctx context.Context) (int, error) {
+	swept := 0
+	for {
+		orphans, err := s.attachments.ListOrphans(ctx, sweepBatchSize)
+		if err != nil {
+			return swept, err
+		}
+
+		deleted := make([]string, 0, len(orphans))
+		for _, orphan := range orphans {
+			if err := s.deleteBlobs(ctx, orphan); err != nil {
+				slog.Error("failed to delete attachment blob", "key", orphan.StorageKey.String, "err", err)
+				continue
+			}
+			deleted = append(deleted, orphan.StorageKey.String)
+		}
+
+		if len(deleted) > 0 {
+			if err := s.attachments.DeleteOrphans(ctx, deleted); err != nil {
+				return swept, err
+			}
+			swept += len(deleted)
+		}
+
+		// a short or failing batch means there is nothing else to be swept for now
+		if len(orphans) < sweepBatchSize || len(deleted) < len(orphans) {
+			return swept, nil
+		}
+	}
+}
+
+// deleteBlobs deletes the blob of an attachment and the blobs of its variants.
+func (s *AttachmentSweeper) deleteBlobs(ctx context.Context, attachment models.Attachment) error {
+	key := attachment.StorageKey.String
+	for _, variant := range attachment.Variants {
+		if err := s.blobs.Delete(ctx, blob.VariantKey(key, variant)); err != nil {
+			return err
+		}
+	}
+	return s.blobs.Delete(ctx, key)
+}
+
+// Run sweeps the orphan attachments right away and then on every interval, until ctx is done.
+func (s *AttachmentSweeper) Run(ctx context.Context) {
+	slog.Info("attachment sweeper started", "interval", s.interval)
+
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+
+	for {
+		swept, err := s.SweepOnce(ctx)
+		if err != nil {
+			slog.Error("failed to sweep orphan attachments", "err", err)
+		} else if swept > 0 {
+			slog.Info("orphan attachments swept", "attachments", swept)
+		}
+
+		select {
+		case <-ctx.Done():
+			slog.Info("attachment sweeper stopped")
+			return
+		case <-ticker.C:
+		}
+	}
+}
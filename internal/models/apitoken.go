@@ -0,0 +1,16 @@
+package models
+
+import (
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+type APIToken struct {
+	ID         pgtype.Numeric   `json:"id"`
+	UserID     pgtype.Numeric   `json:"user_id"`
+	Name       pgtype.Text      `json:"name"`
+	TokenHash  pgtype.Text      `json:"-"`
+	Scopes     []string         `json:"scopes"`
+	ExpiresAt  pgtype.Timestamp `json:"expires_at"`
+	LastUsedAt pgtype.Timestamp `json:"last_used_at"`
+	CreatedAt  pgtype.Timestamp `json:"created_at"`
+}
@@ -0,0 +1,18 @@
+package models
+
+import (
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// Attachment is a file attached to a note, whose content is kept in a blob store.
+type Attachment struct {
+	ID          pgtype.Numeric   `json:"id"`
+	NoteID      pgtype.Numeric   `json:"note_id"`
+	UserID      pgtype.Numeric   `json:"user_id"`
+	Filename    pgtype.Text      `json:"filename"`
+	ContentType pgtype.Text      `json:"content_type"`
+	Size        pgtype.Int8      `json:"size"`        // the size in bytes
+	StorageKey  pgtype.Text      `json:"storage_key"` // the key of the content in the blob store
+	Variants    []string         `json:"variants"`    // the names of the resized copies of images, stored next to the content
+	CreatedAt   pgtype.Timestamp `json:"created_at"`
+}
@@ -0,0 +1,13 @@
+package models
+
+import (
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// CalendarFeed is the iCalendar feed of the reminders of the notes a user can read,
+// which calendar apps subscribe to through a secret link.
+type CalendarFeed struct {
+	UserID    pgtype.Numeric   `json:"user_id"`
+	TokenHash pgtype.Text      `json:"-"`
+	CreatedAt pgtype.Timestamp `json:"created_at"` // when the current link was generated
+}
@@ -0,0 +1,22 @@
+package models
+
+import (
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// NoteType tells how the content of a note is organized.
+type NoteType string
+
+const (
+	NoteTypeText      NoteType = "text"      // the note is its markdown content
+	NoteTypeChecklist NoteType = "checklist" // the note is a list of checklist items, with an optional content
+)
+
+// ChecklistItem is an item of a checklist note.
+type ChecklistItem struct {
+	ID       pgtype.Numeric `json:"id"`
+	NoteID   pgtype.Numeric `json:"note_id"`
+	Text     pgtype.Text    `json:"text"`
+	Checked  pgtype.Bool    `json:"checked"`
+	Position pgtype.Int4    `json:"position"`
+}
@@ -0,0 +1,67 @@
+package models
+
+import (
+	"time"
+
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// DataExportStatus tells whether the archive of a [DataExport] was generated.
+type DataExportStatus string
+
+const (
+	DataExportPending DataExportStatus = "pending" // waiting to be generated in background
+	DataExportReady   DataExportStatus = "ready"   // generated, can be downloaded until it expires
+	DataExportFailed  DataExportStatus = "failed"  // the generation failed, the user has to ask again
+)
+
+// DataExport is an archive with the personal data of a user, which the user asks for and downloads
+// through a signed link mailed once it's generated.
+type DataExport struct {
+	ID         pgtype.Numeric   `json:"id"`
+	UserID     pgtype.Numeric   `json:"user_id"` // not valid once the user is deleted, until the archive is removed
+	Status     DataExportStatus `json:"status"`
+	BlobKey    pgtype.Text      `json:"-"`
+	Size       pgtype.Int8      `json:"size"`
+	Email      pgtype.Text      `json:"-"` // of the user, where the link is mailed
+	CreatedAt  pgtype.Timestamp `json:"created_at"`
+	FinishedAt pgtype.Timestamp `json:"finished_at"`
+	ExpiresAt  pgtype.Timestamp `json:"expires_at"` // when the archive is removed and its link stops working
+}
+
+// PersonalData is everything stored about a user, as it's exported.
+type PersonalData struct {
+	Profile       UserProfile    `json:"profile"`
+	Notes         []Note         `json:"notes"` // written by the user, in any workspace and in the trash
+	APITokens     []APIToken     `json:"api_tokens"`
+	AccountTokens []AccountToken `json:"account_tokens"`
+	CalendarFeed  *CalendarFeed  `json:"calendar_feed"`
+	TwoFactor     *UserTOTP      `json:"two_factor"`
+	Sessions      []Session      `json:"sessions"`
+}
+
+// UserProfile is the account of a user without the password hash.
+type UserProfile struct {
+	ID        pgtype.Numeric   `json:"id"`
+	Email     pgtype.Text      `json:"email"`
+	Active    pgtype.Bool      `json:"active"`
+	CreatedAt pgtype.Timestamp `json:"created_at"`
+	UpdatedAt pgtype.Timestamp `json:"updated_at"`
+	DeleteAt  pgtype.Timestamp `json:"delete_at"`
+}
+
+// AccountToken is the metadata of a token mailed to confirm the account, reset the password or change the email.
+type AccountToken struct {
+	ID        pgtype.Numeric   `json:"id"`
+	Purpose   TokenPurpose     `json:"purpose"`
+	Confirmed pgtype.Bool      `json:"confirmed"`
+	NewEmail  pgtype.Text      `json:"new_email"`
+	CreatedAt pgtype.Timestamp `json:"created_at"`
+	UpdatedAt pgtype.Timestamp `json:"updated_at"`
+}
+
+// Session is a browser session signed in as a user.
+type Session struct {
+	ID        string    `json:"id"` // a hash of the session token, which can't be used to take over the session
+	ExpiresAt time.Time `json:"expires_at"`
+}
@@ -5,11 +5,31 @@ import (
 )
 
 type Note struct {
-	ID        pgtype.Numeric `json:"id"`
-	Title     pgtype.Text    `json:"title"`
-	Content   pgtype.Text    `json:"content"`
-	Color     pgtype.Text    `json:"color"`
-	CreatedAt pgtype.Date    `json:"created_at"`
-	UpdatedAt pgtype.Date    `json:"updated_at"`
-	UserID    pgtype.Numeric `json:"user_id"`
+	ID         pgtype.Numeric   `json:"id"`
+	Title      pgtype.Text      `json:"title"`
+	Content    pgtype.Text      `json:"content"`
+	Color      pgtype.Text      `json:"color"`
+	CreatedAt  pgtype.Date      `json:"created_at"`
+	UpdatedAt  pgtype.Date      `json:"updated_at"`
+	UserID     pgtype.Numeric   `json:"user_id"`
+	NotebookID pgtype.Numeric   `json:"notebook_id"` // not valid for the notes outside of any notebook
+	DeletedAt  pgtype.Timestamp `json:"deleted_at"`  // when the note was moved to the trash, not valid for the notes out of it
+	Version    pgtype.Int4      `json:"version"`     // incremented on every update, used to detect concurrent edits
+	Type       NoteType         `json:"type"`
+	Tags       []string         `json:"tags"`
+	Items      []ChecklistItem  `json:"items"` // the checklist items ordered by position, empty for text notes
+
+	RemindAt         pgtype.Timestamptz `json:"remind_at"`       // when the reminder of the note is first mailed, not valid for notes without a reminder
+	RemindTimezone   pgtype.Text        `json:"remind_timezone"` // the IANA time zone the reminder was set in, which its recurrences follow
+	RemindRecurrence Recurrence         `json:"remind_recurrence"`
+	RemindNextAt     pgtype.Timestamptz `json:"remind_next_at"` // the occurrence of the reminder mailed next, not valid once a reminder that doesn't recur is sent
+	RemindedAt       pgtype.Timestamptz `json:"reminded_at"`    // when the reminder was last mailed
+}
+
+// NoteSearchResult is a note matched by a full-text search.
+type NoteSearchResult struct {
+	Note
+	Rank          pgtype.Float4 `json:"rank"`
+	TitleHeadline pgtype.Text   `json:"title_headline"` // the title with the matches highlighted
+	Snippet       pgtype.Text   `json:"snippet"`        // the content fragments around the matches, highlighted
 }
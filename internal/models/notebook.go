@@ -0,0 +1,14 @@
+package models
+
+import (
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+type Notebook struct {
+	ID        pgtype.Numeric `json:"id"`
+	UserID    pgtype.Numeric `json:"user_id"`
+	ParentID  pgtype.Numeric `json:"parent_id"` // not valid for the top level notebooks
+	Name      pgtype.Text    `json:"name"`
+	CreatedAt pgtype.Date    `json:"created_at"`
+	NoteCount pgtype.Int8    `json:"note_count"` // the number of notes directly in it, filled when listing
+}
@@ -0,0 +1,80 @@
+package models
+
+import (
+	"time"
+
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// Recurrence tells how often the reminder of a note repeats.
+type Recurrence string
+
+const (
+	RecurrenceNone    Recurrence = ""        // the reminder is sent once
+	RecurrenceDaily   Recurrence = "daily"   // the reminder is sent every day at the same time
+	RecurrenceWeekly  Recurrence = "weekly"  // the reminder is sent every week on the same weekday
+	RecurrenceMonthly Recurrence = "monthly" // the reminder is sent every month on the same day, or the last one of shorter months
+	RecurrenceYearly  Recurrence = "yearly"  // the reminder is sent every year on the same date
+)
+
+// Recurrences are the valid recurrences, in the order they are offered.
+var Recurrences = []Recurrence{RecurrenceNone, RecurrenceDaily, RecurrenceWeekly, RecurrenceMonthly, RecurrenceYearly}
+
+// Valid reports whether the recurrence is one of [Recurrences].
+func (rc Recurrence) Valid() bool {
+	for _, valid := range Recurrences {
+		if rc == valid {
+			return true
+		}
+	}
+	return false
+}
+
+// Occurrence returns the n-th occurrence of a reminder first sent at start, the first one being start.
+// The occurrences keep the wall clock time of start in its location, even across daylight saving changes.
+func (rc Recurrence) Occurrence(start time.Time, n int) time.Time {
+	switch rc {
+	case RecurrenceDaily:
+		return start.AddDate(0, 0, n)
+	case RecurrenceWeekly:
+		return start.AddDate(0, 0, 7*n)
+	case RecurrenceMonthly:
+		return addMonths(start, n)
+	case RecurrenceYearly:
+		return addMonths(start, 12*n)
+	}
+	return start
+}
+
+// Next returns the first occurrence of a reminder first sent at start that is after the given time,
+// or false if the reminder doesn't recur.
+func (rc Recurrence) Next(start, after time.Time) (time.Time, bool) {
+	if rc == RecurrenceNone || !rc.Valid() {
+		return time.Time{}, false
+	}
+	for n := 1; ; n++ {
+		if next := rc.Occurrence(start, n); next.After(after) {
+			return next, true
+		}
+	}
+}
+
+// addMonths adds months to t, clamping the day to the last one of the resulting month,
+// so the 31st of January plus a month is the 28th or 29th of February.
+func addMonths(t time.Time, months int) time.Time {
+	year, month, day := t.Date()
+	first := time.Date(year, month+time.Month(months), 1, t.Hour(), t.Minute(), t.Second(), t.Nanosecond(), t.Location())
+	last := first.AddDate(0, 1, -1).Day()
+	return first.AddDate(0, 0, min(day, last)-1)
+}
+
+// DueReminder is the reminder of a note that is due to be sent.
+type DueReminder struct {
+	NoteID     pgtype.Numeric     `json:"note_id"`
+	Title      pgtype.Text        `json:"title"`
+	RemindAt   pgtype.Timestamptz `json:"remind_at"` // the first occurrence of the reminder, which the recurrences are counted from
+	DueAt      pgtype.Timestamptz `json:"due_at"`    // the occurrence due to be sent
+	Timezone   pgtype.Text        `json:"timezone"`  // the IANA time zone the reminder was set in
+	Recurrence Recurrence         `json:"recurrence"`
+	Email      pgtype.Text        `json:"email"` // of the user who set the reminder
+}
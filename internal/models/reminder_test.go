@@ -0,0 +1,73 @@
+package models
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRecurrenceNext(t *testing.T) {
+	newYork, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Skipf("loading the New York time zone: %v", err)
+	}
+	at := func(year int, month time.Month, day, hour, min int, loc *time.Location) time.Time {
+		return time.Date(year, month, day, hour, min, 0, 0, loc)
+	}
+
+	tests := []struct {
+		name       string
+		recurrence Recurrence
+		start      time.Time
+		after      time.Time
+		want       time.Time
+		wantOk     bool
+	}{
+		{"once", RecurrenceNone, at(2024, 1, 1, 9, 0, time.UTC), at(2024, 1, 1, 9, 0, time.UTC), time.Time{}, false},
+		{"unknown", Recurrence("hourly"), at(2024, 1, 1, 9, 0, time.UTC), at(2024, 1, 1, 9, 0, time.UTC), time.Time{}, false},
+		{"daily right after the start", RecurrenceDaily, at(2024, 1, 1, 9, 0, time.UTC), at(2024, 1, 1, 9, 0, time.UTC), at(2024, 1, 2, 9, 0, time.UTC), true},
+		{"daily skipping the missed ones", RecurrenceDaily, at(2024, 1, 1, 9, 0, time.UTC), at(2024, 1, 5, 12, 0, time.UTC), at(2024, 1, 6, 9, 0, time.UTC), true},
+		{"weekly on the same weekday", RecurrenceWeekly, at(2024, 1, 1, 9, 0, time.UTC), at(2024, 1, 3, 9, 0, time.UTC), at(2024, 1, 8, 9, 0, time.UTC), true},
+		{"daily into daylight saving time", RecurrenceDaily, at(2024, 3, 9, 9, 0, newYork), at(2024, 3, 9, 9, 0, newYork), at(2024, 3, 10, 9, 0, newYork), true},
+		{"daily out of daylight saving time", RecurrenceDaily, at(2024, 11, 2, 9, 0, newYork), at(2024, 11, 2, 9, 0, newYork), at(2024, 11, 3, 9, 0, newYork), true},
+		{"monthly out of daylight saving time", RecurrenceMonthly, at(2024, 10, 15, 9, 0, newYork), at(2024, 10, 15, 9, 0, newYork), at(2024, 11, 15, 9, 0, newYork), true},
+		{"monthly clamped to a leap February", RecurrenceMonthly, at(2024, 1, 31, 9, 0, time.UTC), at(2024, 1, 31, 9, 0, time.UTC), at(2024, 2, 29, 9, 0, time.UTC), true},
+		{"monthly clamped to February", RecurrenceMonthly, at(2023, 1, 31, 9, 0, time.UTC), at(2023, 1, 31, 9, 0, time.UTC), at(2023, 2, 28, 9, 0, time.UTC), true},
+		{"monthly back to the 31st after February", RecurrenceMonthly, at(2024, 1, 31, 9, 0, time.UTC), at(2024, 2, 29, 9, 0, time.UTC), at(2024, 3, 31, 9, 0, time.UTC), true},
+		{"monthly clamped to a 30-day month", RecurrenceMonthly, at(2024, 3, 31, 9, 0, time.UTC), at(2024, 3, 31, 9, 0, time.UTC), at(2024, 4, 30, 9, 0, time.UTC), true},
+		{"monthly across the year", RecurrenceMonthly, at(2024, 12, 31, 9, 0, time.UTC), at(2024, 12, 31, 9, 0, time.UTC), at(2025, 1, 31, 9, 0, time.UTC), true},
+		{"yearly from a leap day", RecurrenceYearly, at(2024, 2, 29, 9, 0, time.UTC), at(2024, 2, 29, 9, 0, time.UTC), at(2025, 2, 28, 9, 0, time.UTC), true},
+		{"yearly back to the leap day", RecurrenceYearly, at(2024, 2, 29, 9, 0, time.UTC), at(2027, 3, 1, 9, 0, time.UTC), at(2028, 2, 29, 9, 0, time.UTC), true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := tt.recurrence.Next(tt.start, tt.after)
+			if ok != tt.wantOk || !got.Equal(tt.want) {
+				t.Errorf("Next() = %v, %v, want %v, %v", got, ok, tt.want, tt.wantOk)
+			}
+		})
+	}
+}
+
+func TestAddMonths(t *testing.T) {
+	tests := []struct {
+		name   string
+		t      time.Time
+		months int
+		want   time.Time
+	}{
+		{"same day", time.Date(2024, 1, 15, 9, 30, 0, 0, time.UTC), 1, time.Date(2024, 2, 15, 9, 30, 0, 0, time.UTC)},
+		{"last day of a shorter month", time.Date(2024, 5, 31, 9, 30, 0, 0, time.UTC), 1, time.Date(2024, 6, 30, 9, 30, 0, 0, time.UTC)},
+		{"last day of February", time.Date(2023, 1, 29, 9, 30, 0, 0, time.UTC), 1, time.Date(2023, 2, 28, 9, 30, 0, 0, time.UTC)},
+		{"over several months", time.Date(2024, 1, 31, 9, 30, 0, 0, time.UTC), 3, time.Date(2024, 4, 30, 9, 30, 0, 0, time.UTC)},
+		{"into the next year", time.Date(2024, 11, 30, 9, 30, 0, 0, time.UTC), 3, time.Date(2025, 2, 28, 9, 30, 0, 0, time.UTC)},
+		{"backwards", time.Date(2024, 3, 31, 9, 30, 0, 0, time.UTC), -1, time.Date(2024, 2, 29, 9, 30, 0, 0, time.UTC)},
+		{"none", time.Date(2024, 1, 31, 9, 30, 0, 0, time.UTC), 0, time.Date(2024, 1, 31, 9, 30, 0, 0, time.UTC)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := addMonths(tt.t, tt.months); !got.Equal(tt.want) {
+				t.Errorf("addMonths(%v, %d) = %v, want %v", tt.t, tt.months, got, tt.want)
+			}
+		})
+	}
+}
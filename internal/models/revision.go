@@ -0,0 +1,17 @@
+package models
+
+import (
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// NoteRevision is the state of a note saved at some point of its history.
+type NoteRevision struct {
+	ID          pgtype.Numeric   `json:"id"`
+	NoteID      pgtype.Numeric   `json:"note_id"`
+	UserID      pgtype.Numeric   `json:"user_id"`      // the author of the revision, not valid if the author's account was deleted
+	AuthorEmail pgtype.Text      `json:"author_email"` // filled when reading
+	Title       pgtype.Text      `json:"title"`
+	Content     pgtype.Text      `json:"content"`
+	Color       pgtype.Text      `json:"color"`
+	CreatedAt   pgtype.Timestamp `json:"created_at"`
+}
@@ -0,0 +1,70 @@
+package models
+
+import (
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// ShareLink is a public link to read a note without an account.
+type ShareLink struct {
+	ID           pgtype.Numeric   `json:"id"`
+	NoteID       pgtype.Numeric   `json:"note_id"`
+	UserID       pgtype.Numeric   `json:"user_id"`      // the user who created the link
+	WorkspaceID  pgtype.Numeric   `json:"workspace_id"` // the workspace of the note, only filled when found by hash
+	TokenHash    pgtype.Text      `json:"-"`
+	PasswordHash pgtype.Text      `json:"-"` // not valid if the link has no password
+	ExpiresAt    pgtype.Timestamp `json:"expires_at"`
+	ViewCount    pgtype.Int4      `json:"view_count"`
+	CreatedAt    pgtype.Timestamp `json:"created_at"`
+	Locked       bool             `json:"-"` // whether too many wrong passwords were typed lately, so none is checked for now; only filled when found by hash
+}
+
+// NoteRole is what a user can do with a note.
+type NoteRole string
+
+const (
+	NoteRoleViewer NoteRole = "viewer" // can read the note
+	NoteRoleEditor NoteRole = "editor" // can read and edit the note
+	NoteRoleOwner  NoteRole = "owner"  // can also delete, move and share the note
+)
+
+// noteRoleRanks orders the roles, each one allowing everything the lower ones do.
+var noteRoleRanks = map[NoteRole]int{NoteRoleViewer: 1, NoteRoleEditor: 2, NoteRoleOwner: 3}
+
+// Allows reports whether the role allows what the needed role does.
+func (r NoteRole) Allows(need NoteRole) bool {
+	return noteRoleRanks[r] >= noteRoleRanks[need] && noteRoleRanks[r] > 0
+}
+
+// NoteShare is a note shared with another user.
+type NoteShare struct {
+	ID        pgtype.Numeric   `json:"id"`
+	NoteID    pgtype.Numeric   `json:"note_id"`
+	UserID    pgtype.Numeric   `json:"user_id"`
+	Email     pgtype.Text      `json:"email"` // the email of the user the note is shared with
+	Role      NoteRole         `json:"role"`
+	CreatedAt pgtype.Timestamp `json:"created_at"`
+}
+
+// NoteAccess is what a user can do with a note of their workspace or that is shared with them.
+type NoteAccess struct {
+	WorkspaceID int64  // the workspace owning the note
+	OwnerEmail  string // the email of the user who created the note
+	Role        NoteRole
+}
+
+// CanEdit reports whether the user can edit the note.
+func (a NoteAccess) CanEdit() bool {
+	return a.Role.Allows(NoteRoleEditor)
+}
+
+// IsOwner reports whether the note is in the user's workspace.
+func (a NoteAccess) IsOwner() bool {
+	return a.Role == NoteRoleOwner
+}
+
+// SharedNote is a note shared with a user by its owner.
+type SharedNote struct {
+	Note       Note
+	OwnerEmail pgtype.Text
+	Role       NoteRole
+}
@@ -0,0 +1,13 @@
+package models
+
+import (
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+type Tag struct {
+	ID        pgtype.Numeric `json:"id"`
+	UserID    pgtype.Numeric `json:"user_id"`
+	Name      pgtype.Text    `json:"name"`
+	CreatedAt pgtype.Date    `json:"created_at"`
+	NoteCount pgtype.Int8    `json:"note_count"` // the number of notes tagged with it, filled when listing
+}
@@ -0,0 +1,22 @@
+package models
+
+import (
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// UserTOTP is the time-based one-time password secret of a user, asked after the password on sign-in
+// once enabled. The secret is stored encrypted.
+type UserTOTP struct {
+	UserID            pgtype.Numeric   `json:"user_id"`
+	Secret            pgtype.Text      `json:"-"`
+	LastUsedStep      pgtype.Int8      `json:"-"`          // the time step of the last code used, which can't be used again
+	EnabledAt         pgtype.Timestamp `json:"enabled_at"` // null while the user hasn't confirmed a first code
+	CreatedAt         pgtype.Timestamp `json:"created_at"`
+	RecoveryCodesLeft int              `json:"recovery_codes_left"`
+	Locked            bool             `json:"-"` // whether too many wrong codes were typed to sign in lately, so none is checked for now
+}
+
+// Enabled reports whether the user confirmed the secret, so the codes are asked on sign-in.
+func (t UserTOTP) Enabled() bool {
+	return t.EnabledAt.Valid
+}
@@ -5,19 +5,31 @@ import (
 )
 
 type User struct {
-	ID        pgtype.Numeric `json:"id"`
-	Email     pgtype.Text    `json:"email"`
-	Password  pgtype.Text    `json:"password"`
-	Active    pgtype.Bool    `json:"active"`
-	CreatedAt pgtype.Date    `json:"created_at"`
-	UpdatedAt pgtype.Date    `json:"updated_at"`
+	ID        pgtype.Numeric   `json:"id"`
+	Email     pgtype.Text      `json:"email"`
+	Password  pgtype.Text      `json:"password"`
+	Active    pgtype.Bool      `json:"active"`
+	CreatedAt pgtype.Date      `json:"created_at"`
+	UpdatedAt pgtype.Date      `json:"updated_at"`
+	DeleteAt  pgtype.Timestamp `json:"delete_at"` // when the account is deleted for good, null unless the user asked it
 }
 
+// TokenPurpose is what a [UserConfirmationToken] was mailed for, the only thing it can be used for.
+type TokenPurpose string
+
+const (
+	TokenPurposeConfirmation  TokenPurpose = "confirmation"   // confirms the signup, activating the account
+	TokenPurposePasswordReset TokenPurpose = "password_reset" // sets a new password for a user who forgot it
+	TokenPurposeEmailChange   TokenPurpose = "email_change"   // confirms the switch to a new email
+)
+
 type UserConfirmationToken struct {
 	ID        pgtype.Numeric `json:"id"`
 	UserID    pgtype.Numeric `json:"user_id"`
-	Token     pgtype.Text    `json:"token"`
+	TokenHash pgtype.Text    `json:"-"` // the SHA-256 hash of the token, which is only known by the link mailed
+	Purpose   TokenPurpose   `json:"purpose"`
 	Confirmed pgtype.Bool    `json:"confirmed"`
+	NewEmail  pgtype.Text    `json:"new_email"` // the email the user is switching to, set only on the tokens of an email change
 	CreatedAt pgtype.Date    `json:"created_at"`
 	UpdatedAt pgtype.Date    `json:"updated_at"`
 }
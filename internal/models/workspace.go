@@ -0,0 +1,61 @@
+package models
+
+import (
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// Workspace owns notes, notebooks and tags shared by its members.
+// Every user has a personal workspace no one else can join.
+type Workspace struct {
+	ID             pgtype.Numeric   `json:"id"`
+	Name           pgtype.Text      `json:"name"`
+	PersonalUserID pgtype.Numeric   `json:"personal_user_id"` // the user of a personal workspace, not valid for the team ones
+	CreatedAt      pgtype.Timestamp `json:"created_at"`
+}
+
+// IsPersonal reports whether the workspace is the personal workspace of a user.
+func (w Workspace) IsPersonal() bool {
+	return w.PersonalUserID.Valid
+}
+
+// WorkspaceRole is what a member can do in a workspace.
+type WorkspaceRole string
+
+const (
+	WorkspaceRoleMember WorkspaceRole = "member" // can work on the notes of the workspace
+	WorkspaceRoleAdmin  WorkspaceRole = "admin"  // can also delete, restore and share the notes, and invite, remove and change the role of the members
+	WorkspaceRoleOwner  WorkspaceRole = "owner"  // can also rename and delete the workspace
+)
+
+// WorkspaceRoles are the roles a member can be given, from the lowest to the highest.
+var WorkspaceRoles = []WorkspaceRole{WorkspaceRoleMember, WorkspaceRoleAdmin, WorkspaceRoleOwner}
+
+// workspaceRoleRanks orders the roles, each one allowing everything the lower ones do.
+var workspaceRoleRanks = map[WorkspaceRole]int{WorkspaceRoleMember: 1, WorkspaceRoleAdmin: 2, WorkspaceRoleOwner: 3}
+
+// Allows reports whether the role allows what the needed role does.
+func (r WorkspaceRole) Allows(need WorkspaceRole) bool {
+	return workspaceRoleRanks[r] >= workspaceRoleRanks[need] && workspaceRoleRanks[r] > 0
+}
+
+// WorkspaceMember is a user taking part in a workspace.
+type WorkspaceMember struct {
+	Workspace Workspace
+	UserID    pgtype.Numeric   `json:"user_id"`
+	Email     pgtype.Text      `json:"email"`
+	Role      WorkspaceRole    `json:"role"`
+	CreatedAt pgtype.Timestamp `json:"created_at"` // when the user joined the workspace
+}
+
+// WorkspaceInvite is an invitation sent by email to join a workspace.
+type WorkspaceInvite struct {
+	ID          pgtype.Numeric   `json:"id"`
+	WorkspaceID pgtype.Numeric   `json:"workspace_id"`
+	Email       pgtype.Text      `json:"email"`
+	Role        WorkspaceRole    `json:"role"`
+	TokenHash   pgtype.Text      `json:"-"`
+	InvitedBy   pgtype.Numeric   `json:"invited_by"`
+	Confirmed   pgtype.Bool      `json:"confirmed"` // whether it was accepted
+	CreatedAt   pgtype.Timestamp `json:"created_at"`
+	UpdatedAt   pgtype.Timestamp `json:"updated_at"`
+}
@@ -0,0 +1,59 @@
+package render
+
+import (
+	"bytes"
+	"html/template"
+	"regexp"
+	"sync"
+
+	chromahtml "github.com/alecthomas/chroma/v2/formatters/html"
+	"github.com/microcosm-cc/bluemonday"
+	"github.com/yuin/goldmark"
+	highlighting "github.com/yuin/goldmark-highlighting/v2"
+	"github.com/yuin/goldmark/extension"
+)
+
+var (
+	markdownOnce      sync.Once
+	markdownConverter goldmark.Markdown
+	markdownPolicy    *bluemonday.Policy
+)
+
+// newMarkdownConverter returns the converter of the note markdown: CommonMark with the GFM
+// extensions (tables, task lists, strikethrough and autolinks) and highlighted fenced code.
+// The code is highlighted with CSS classes, styled by static/css/highlight.css.
+func newMarkdownConverter() goldmark.Markdown {
+	return goldmark.New(
+		goldmark.WithExtensions(
+			extension.GFM,
+			highlighting.NewHighlighting(
+				highlighting.WithFormatOptions(chromahtml.WithClasses(true)),
+			),
+		),
+	)
+}
+
+// newMarkdownPolicy returns the allow-list the rendered markdown is sanitised with.
+// On top of the user generated content policy, it keeps the highlighting classes
+// and the disabled checkboxes of the task lists.
+func newMarkdownPolicy() *bluemonday.Policy {
+	p := bluemonday.UGCPolicy()
+	p.AllowAttrs("class").Matching(regexp.MustCompile(`^[a-zA-Z0-9 _-]+$`)).OnElements("pre", "code", "span")
+	p.AllowAttrs("type").Matching(regexp.MustCompile(`^checkbox$`)).OnElements("input")
+	p.AllowAttrs("checked", "disabled").OnElements("input")
+	return p
+}
+
+// Markdown renders markdown source as sanitised HTML, safe to be put in a template as is.
+func Markdown(source string) (template.HTML, error) {
+	markdownOnce.Do(func() {
+		markdownConverter = newMarkdownConverter()
+		markdownPolicy = newMarkdownPolicy()
+	})
+
+	var buf bytes.Buffer
+	if err := markdownConverter.Convert([]byte(source), &buf); err != nil {
+		return "", err
+	}
+	return template.HTML(markdownPolicy.SanitizeBytes(buf.Bytes())), nil
+}
@@ -0,0 +1,105 @@
+package render
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestMarkdown(t *testing.T) {
+	tests := []struct {
+		name    string
+		source  string
+		want    []string // substrings the HTML must have
+		notWant []string // substrings the HTML must not have
+	}{
+		{
+			name:    "script tags",
+			source:  "hello <script>alert(1)</script> world\n\n<script src=\"/x.js\"></script>",
+			want:    []string{"hello", "world"},
+			notWant: []string{"<script", "x.js"},
+		},
+		{
+			name:    "event handler attributes",
+			source:  `<img src="/x.png" onerror="alert(1)"> ![x](/x.png "a\" onerror=\"alert(1)")`,
+			want:    []string{`<img src="/x.png"`},
+			notWant: []string{"onerror=", "<img src=\"/x.png\" onerror"},
+		},
+		{
+			name:    "javascript markdown link",
+			source:  "[click](javascript:alert(1))",
+			want:    []string{"click"},
+			notWant: []string{"javascript:"},
+		},
+		{
+			name:    "javascript html link",
+			source:  `<a href="JavaScript:alert(1)">click</a>`,
+			want:    []string{"click"},
+			notWant: []string{"avascript:"},
+		},
+		{
+			name:    "safe links",
+			source:  "[site](https://example.com)",
+			want:    []string{`href="https://example.com"`},
+			notWant: nil,
+		},
+		{
+			name:    "task list",
+			source:  "- [x] done\n- [ ] todo",
+			want:    []string{`<input checked="" disabled="" type="checkbox"`, `<input disabled="" type="checkbox"`, "done", "todo"},
+			notWant: nil,
+		},
+		{
+			name:    "highlighted code",
+			source:  "```go\nfunc main() {}\n```",
+			want:    []string{`<pre class="chroma">`, `<code>`, `<span class="kd">func</span>`},
+			notWant: []string{"style="},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Markdown(tt.source)
+			if err != nil {
+				t.Fatalf("Markdown() error = %v", err)
+			}
+			for _, want := range tt.want {
+				if !strings.Contains(string(got), want) {
+					t.Errorf("Markdown() = %q, want it to contain %q", got, want)
+				}
+			}
+			for _, notWant := range tt.notWant {
+				if strings.Contains(string(got), notWant) {
+					t.Errorf("Markdown() = %q, want it not to contain %q", got, notWant)
+				}
+			}
+		})
+	}
+}
+
+// TestMarkdownPolicy checks the allow-list on its own, as the converter already leaves out the raw HTML
+// of the notes, and the policy is what stops it if the converter ever lets some through.
+func TestMarkdownPolicy(t *testing.T) {
+	tests := []struct {
+		name string
+		html string
+		want string
+	}{
+		{"script tags", `<p>a<script>alert(1)</script>b</p>`, `<p>ab</p>`},
+		{"event handler attributes", `<img src="/x.png" onerror="alert(1)"><a href="/notes" onclick="alert(2)">notes</a>`, `<img src="/x.png"><a href="/notes" rel="nofollow">notes</a>`},
+		{"javascript links", `<a href="javascript:alert(1)">click</a>`, `click`},
+		{"disabled checkboxes", `<input checked="" disabled="" type="checkbox">`, `<input checked="" disabled="" type="checkbox">`},
+		{"other inputs", `<input type="text" value="x" onchange="alert(1)">`, ``},
+		{"highlighting classes", `<pre class="chroma"><code><span class="kd">func</span></code></pre>`, `<pre class="chroma"><code><span class="kd">func</span></code></pre>`},
+		{"classes on other elements", `<p class="evil">text</p><div class="chroma">block</div>`, `<p>text</p><div>block</div>`},
+		{"class values", `<span class="a&#34; onclick=&#34;x">text</span>`, `<span>text</span>`},
+	}
+
+	policy := newMarkdownPolicy()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := policy.Sanitize(tt.html); got != tt.want {
+				t.Errorf("Sanitize() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"html/template"
 	"net/http"
+	"path"
 
 	"github.com/LeandroDeJesus-S/quicknote/internal/errs"
 	"github.com/LeandroDeJesus-S/quicknote/view"
@@ -101,7 +102,8 @@ func (tr *templateRender) Mail(tplName string, data any) ([]byte, error) {
 		return nil, err
 	}
 
-	if err := t.Execute(buf, data); err != nil {
+	// the parsed template is named after the file, the root one is left empty
+	if err := t.ExecuteTemplate(buf, path.Base(tplName), data); err != nil {
 		return nil, err
 	}
 
@@ -0,0 +1,110 @@
+package repo
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/LeandroDeJesus-S/quicknote/internal/errs"
+	"github.com/LeandroDeJesus-S/quicknote/internal/models"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+var ErrAPITokenNotFound = errs.NewRepoError(errors.New("api token not found"))
+
+// APITokenRepository stores the personal API tokens. Only the token hash is ever persisted.
+type APITokenRepository interface {
+	Create(ctx context.Context, userID int64, name, tokenHash string, scopes []string, expiresAt *time.Time) (*models.APIToken, error) // creates a token, expiresAt nil means it never expires
+	List(ctx context.Context, userID int64) ([]models.APIToken, error)                                                                 // lists the user's tokens, newest first
+	FindByHash(ctx context.Context, tokenHash string) (*models.APIToken, error)                                                        // returns [ErrAPITokenNotFound] if there is no token with the hash
+	TouchLastUsed(ctx context.Context, id int64) error                                                                                 // sets the token last used time to now
+	Delete(ctx context.Context, userID int64, id int) error                                                                            // revokes a token, returns [ErrAPITokenNotFound] if it's not owned by the user
+	DeleteAll(ctx context.Context, userID int64) error                                                                                 // revokes every token of the user
+}
+
+type apiTokenRepo struct {
+	db *pgxpool.Pool
+}
+
+func NewAPITokenRepo(db *pgxpool.Pool) APITokenRepository {
+	return &apiTokenRepo{db: db}
+}
+
+func (r *apiTokenRepo) Create(ctx context.Context, userID int64, name, tokenHash string, scopes []string, expiresAt *time.Time) (*models.APIToken, error) {
+	var t models.APIToken
+	t.Name = pgtype.Text{String: name, Valid: true}
+	t.TokenHash = pgtype.Text{String: tokenHash, Valid: true}
+	t.Scopes = scopes
+	if expiresAt != nil {
+		t.ExpiresAt = pgtype.Timestamp{Time: *expiresAt, Valid: true}
+	}
+
+	query := "INSERT INTO api_tokens (user_id, name, token_hash, scopes, expires_at) VALUES ($1, $2, $3, $4, $5) RETURNING id, user_id, created_at"
+	err := r.db.QueryRow(ctx, query, userID, t.Name, t.TokenHash, t.Scopes, t.ExpiresAt).Scan(&t.ID, &t.UserID, &t.CreatedAt)
+	if err != nil {
+		return nil, errs.NewRepoError(err)
+	}
+	return &t, nil
+}
+
+func (r *apiTokenRepo) List(ctx context.Context, userID int64) ([]models.APIToken, error) {
+	query := "SELECT id, user_id, name, scopes, expires_at, last_used_at, created_at FROM api_tokens WHERE user_id = $1 ORDER BY created_at DESC"
+	rows, err := r.db.Query(ctx, query, userID)
+	if err != nil {
+		return nil, errs.NewRepoError(err)
+	}
+	defer rows.Close()
+
+	var tokens []models.APIToken
+	for rows.Next() {
+		var t models.APIToken
+		if err := rows.Scan(&t.ID, &t.UserID, &t.Name, &t.Scopes, &t.ExpiresAt, &t.LastUsedAt, &t.CreatedAt); err != nil {
+			return nil, errs.NewRepoError(err)
+		}
+		tokens = append(tokens, t)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, errs.NewRepoError(err)
+	}
+	return tokens, nil
+}
+
+func (r *apiTokenRepo) FindByHash(ctx context.Context, tokenHash string) (*models.APIToken, error) {
+	var t models.APIToken
+	query := "SELECT id, user_id, name, token_hash, scopes, expires_at, last_used_at, created_at FROM api_tokens WHERE token_hash = $1"
+	err := r.db.QueryRow(ctx, query, tokenHash).Scan(&t.ID, &t.UserID, &t.Name, &t.TokenHash, &t.Scopes, &t.ExpiresAt, &t.LastUsedAt, &t.CreatedAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrAPITokenNotFound
+	}
+	if err != nil {
+		return nil, errs.NewRepoError(err)
+	}
+	return &t, nil
+}
+
+func (r *apiTokenRepo) TouchLastUsed(ctx context.Context, id int64) error {
+	if _, err := r.db.Exec(ctx, "UPDATE api_tokens SET last_used_at = now() WHERE id = $1", id); err != nil {
+		return errs.NewRepoError(err)
+	}
+	return nil
+}
+
+func (r *apiTokenRepo) Delete(ctx context.Context, userID int64, id int) error {
+	tag, err := r.db.Exec(ctx, "DELETE FROM api_tokens WHERE id = $1 AND user_id = $2", id, userID)
+	if err != nil {
+		return errs.NewRepoError(err)
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrAPITokenNotFound
+	}
+	return nil
+}
+
+func (r *apiTokenRepo) DeleteAll(ctx context.Context, userID int64) error {
+	if _, err := r.db.Exec(ctx, "DELETE FROM api_tokens WHERE user_id = $1", userID); err != nil {
+		return errs.NewRepoError(err)
+	}
+	return nil
+}
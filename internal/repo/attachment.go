@@ -0,0 +1,230 @@
+package repo
+
+import (
+	"context"
+	"errors"
+
+	"github.com/LeandroDeJesus-S/quicknote/internal/errs"
+	"github.com/LeandroDeJesus-S/quicknote/internal/models"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+var (
+	ErrAttachmentNotFound      = errs.NewRepoError(errors.New("attachment not found"))
+	ErrAttachmentQuotaExceeded = errs.NewRepoError(errors.New("attachment quota exceeded"))
+)
+
+// attachmentColumns are the columns scanned by [attachmentScanFields], where a is the attachments table.
+const attachmentColumns = "a.id, a.note_id, a.user_id, a.filename, a.content_type, a.size, a.storage_key, a.variants, a.created_at"
+
+func attachmentScanFields(a *models.Attachment) []any {
+	return []any{&a.ID, &a.NoteID, &a.UserID, &a.Filename, &a.ContentType, &a.Size, &a.StorageKey, &a.Variants, &a.CreatedAt}
+}
+
+// AttachmentInput is a file to be attached to a note, whose content is already stored under StorageKey.
+type AttachmentInput struct {
+	Filename    string
+	ContentType string
+	StorageKey  string
+	Size        int64
+	Variants    []string // the names of the resized copies of images, see [blob.VariantKey]
+	VariantSize int64    // how many bytes the variants take altogether, counted in the quota along with Size
+}
+
+// AttachmentRepository manages the files attached to notes. The contents of the files are kept
+// in a blob store, so deleted attachments are only detached from their notes, becoming orphans
+// until their blobs are deleted, see ListOrphans.
+//
+// The attachments are reached through the notes of the workspace of the given [Scope],
+// while the quota counts the attachments uploaded by its user, wherever they are, with the variants of the images.
+type AttachmentRepository interface {
+	List(ctx context.Context, scope Scope, noteID int) ([]models.Attachment, error)                                   // lists the attachments of a note out of the trash of the workspace, in upload order
+	ReadOne(ctx context.Context, scope Scope, noteID, id int) (*models.Attachment, error)                             // returns [ErrAttachmentNotFound] if the attachment is not of a note out of the trash of the workspace
+	ReadImage(ctx context.Context, storageKey string) (*models.Attachment, error)                                     // returns the image attachment stored under the key, whoever owns it, for the caller to check the access to its note. Returns [ErrAttachmentNotFound] if there is no such image, it was deleted or its note is in the trash
+	Create(ctx context.Context, scope Scope, noteID int, in AttachmentInput, quota int64) (*models.Attachment, error) // uploads an attachment by the user. Returns [ErrAttachmentQuotaExceeded] if the user's attachments would take more than quota bytes, or [ErrNoteNotFound] if the note is not in the workspace
+	UsedBytes(ctx context.Context, userID int64) (int64, error)                                                       // returns how many bytes the attachments uploaded by the user take, with their variants
+	Delete(ctx context.Context, scope Scope, noteID, id int) error                                                    // detaches an attachment from its note. Returns [ErrAttachmentNotFound] like ReadOne
+	ListOrphans(ctx context.Context, limit int) ([]models.Attachment, error)                                          // lists the attachments detached from their notes, either deleted or of purged notes
+	DeleteOrphans(ctx context.Context, storageKeys []string) error                                                    // deletes the orphan attachments for good, once their blobs were deleted
+}
+
+type attachmentRepo struct {
+	db *pgxpool.Pool
+}
+
+func NewAttachmentRepo(db *pgxpool.Pool) AttachmentRepository {
+	return &attachmentRepo{db: db}
+}
+
+func (r *attachmentRepo) List(ctx context.Context, scope Scope, noteID int) ([]models.Attachment, error) {
+	rows, err := conn(ctx, r.db).Query(
+		ctx,
+		`SELECT `+attachmentColumns+` FROM attachments a JOIN notes n ON n.id = a.note_id
+			WHERE a.note_id = $1 AND n.workspace_id = $2 AND n.deleted_at IS NULL
+			ORDER BY a.id`,
+		noteID, scope.WorkspaceID,
+	)
+	if err != nil {
+		return nil, errs.NewRepoError(err)
+	}
+	defer rows.Close()
+
+	var attachments []models.Attachment
+	for rows.Next() {
+		var a models.Attachment
+		if err := rows.Scan(attachmentScanFields(&a)...); err != nil {
+			return nil, errs.NewRepoError(err)
+		}
+		attachments = append(attachments, a)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, errs.NewRepoError(err)
+	}
+	return attachments, nil
+}
+
+func (r *attachmentRepo) ReadOne(ctx context.Context, scope Scope, noteID, id int) (*models.Attachment, error) {
+	var a models.Attachment
+	err := conn(ctx, r.db).QueryRow(
+		ctx,
+		`SELECT `+attachmentColumns+` FROM attachments a JOIN notes n ON n.id = a.note_id
+			WHERE a.id = $1 AND a.note_id = $2 AND n.workspace_id = $3 AND n.deleted_at IS NULL`,
+		id, noteID, scope.WorkspaceID,
+	).Scan(attachmentScanFields(&a)...)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrAttachmentNotFound
+	}
+	if err != nil {
+		return nil, errs.NewRepoError(err)
+	}
+	return &a, nil
+}
+
+func (r *attachmentRepo) ReadImage(ctx context.Context, storageKey string) (*models.Attachment, error) {
+	var a models.Attachment
+	err := conn(ctx, r.db).QueryRow(
+		ctx,
+		`SELECT `+attachmentColumns+` FROM attachments a JOIN notes n ON n.id = a.note_id
+			WHERE a.storage_key = $1 AND n.deleted_at IS NULL AND a.content_type LIKE 'image/%'`,
+		storageKey,
+	).Scan(attachmentScanFields(&a)...)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrAttachmentNotFound
+	}
+	if err != nil {
+		return nil, errs.NewRepoError(err)
+	}
+	return &a, nil
+}
+
+// usedBytesQuery sums the bytes the attachments of a user take in the quota, their variants included.
+const usedBytesQuery = "SELECT COALESCE(sum(size + variants_size), 0) FROM attachments WHERE user_id = $1 AND note_id IS NOT NULL"
+
+func (r *attachmentRepo) Create(ctx context.Context, scope Scope, noteID int, in AttachmentInput, quota int64) (*models.Attachment, error) {
+	tx, err := conn(ctx, r.db).Begin(ctx)
+	if err != nil {
+		return nil, errs.NewRepoError(err)
+	}
+	defer tx.Rollback(ctx)
+
+	// locking the user serializes the uploads of the user, so they can't exceed the quota together
+	if _, err := tx.Exec(ctx, "SELECT id FROM users WHERE id = $1 FOR UPDATE", scope.UserID); err != nil {
+		return nil, errs.NewRepoError(err)
+	}
+
+	var id int64
+	err = tx.QueryRow(ctx, "SELECT id FROM notes WHERE id = $1 AND workspace_id = $2 AND deleted_at IS NULL", noteID, scope.WorkspaceID).Scan(&id)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrNoteNotFound
+	}
+	if err != nil {
+		return nil, errs.NewRepoError(err)
+	}
+
+	var used int64
+	err = tx.QueryRow(ctx, usedBytesQuery, scope.UserID).Scan(&used)
+	if err != nil {
+		return nil, errs.NewRepoError(err)
+	}
+	if used+in.Size+in.VariantSize > quota {
+		return nil, ErrAttachmentQuotaExceeded
+	}
+
+	variants := in.Variants
+	if variants == nil {
+		variants = []string{}
+	}
+
+	var a models.Attachment
+	err = tx.QueryRow(
+		ctx,
+		`INSERT INTO attachments AS a (note_id, user_id, filename, content_type, size, storage_key, variants, variants_size)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+			RETURNING `+attachmentColumns,
+		noteID, scope.UserID, in.Filename, in.ContentType, in.Size, in.StorageKey, variants, in.VariantSize,
+	).Scan(attachmentScanFields(&a)...)
+	if err != nil {
+		return nil, errs.NewRepoError(err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, errs.NewRepoError(err)
+	}
+	return &a, nil
+}
+
+func (r *attachmentRepo) UsedBytes(ctx context.Context, userID int64) (int64, error) {
+	var used int64
+	err := conn(ctx, r.db).QueryRow(ctx, usedBytesQuery, userID).Scan(&used)
+	if err != nil {
+		return 0, errs.NewRepoError(err)
+	}
+	return used, nil
+}
+
+func (r *attachmentRepo) Delete(ctx context.Context, scope Scope, noteID, id int) error {
+	tag, err := conn(ctx, r.db).Exec(
+		ctx,
+		`UPDATE attachments a SET note_id = NULL
+			FROM notes n
+			WHERE a.id = $1 AND a.note_id = $2 AND n.id = a.note_id AND n.workspace_id = $3 AND n.deleted_at IS NULL`,
+		id, noteID, scope.WorkspaceID,
+	)
+	if err != nil {
+		return errs.NewRepoError(err)
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrAttachmentNotFound
+	}
+	return nil
+}
+
+func (r *attachmentRepo) ListOrphans(ctx context.Context, limit int) ([]models.Attachment, error) {
+	rows, err := conn(ctx, r.db).Query(ctx, "SELECT "+attachmentColumns+" FROM attachments a WHERE a.note_id IS NULL ORDER BY a.id LIMIT $1", limit)
+	if err != nil {
+		return nil, errs.NewRepoError(err)
+	}
+	defer rows.Close()
+
+	var attachments []models.Attachment
+	for rows.Next() {
+		var a models.Attachment
+		if err := rows.Scan(attachmentScanFields(&a)...); err != nil {
+			return nil, errs.NewRepoError(err)
+		}
+		attachments = append(attachments, a)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, errs.NewRepoError(err)
+	}
+	return attachments, nil
+}
+
+func (r *attachmentRepo) DeleteOrphans(ctx context.Context, storageKeys []string) error {
+	_, err := conn(ctx, r.db).Exec(ctx, "DELETE FROM attachments WHERE note_id IS NULL AND storage_key = ANY($1)", storageKeys)
+	if err != nil {
+		return errs.NewRepoError(err)
+	}
+	return nil
+}
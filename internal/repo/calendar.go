@@ -0,0 +1,80 @@
+package repo
+
+import (
+	"context"
+	"errors"
+
+	"github.com/LeandroDeJesus-S/quicknote/internal/errs"
+	"github.com/LeandroDeJesus-S/quicknote/internal/models"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+var ErrCalendarFeedNotFound = errs.NewRepoError(errors.New("calendar feed not found"))
+
+// CalendarFeedRepository stores the calendar feeds of the users, one per user. Only the token hash is ever persisted.
+type CalendarFeedRepository interface {
+	Find(ctx context.Context, userID int64) (*models.CalendarFeed, error)                         // returns [ErrCalendarFeedNotFound] if the user has no feed
+	FindByHash(ctx context.Context, tokenHash string) (*models.CalendarFeed, error)               // returns [ErrCalendarFeedNotFound] if there is no feed with the hash or its user is not active
+	Regenerate(ctx context.Context, userID int64, tokenHash string) (*models.CalendarFeed, error) // creates the feed of the user, or replaces its token so the previous link stops working
+	Delete(ctx context.Context, userID int64) error                                               // deletes the feed of the user, returns [ErrCalendarFeedNotFound] if there is none
+}
+
+type calendarFeedRepo struct {
+	db *pgxpool.Pool
+}
+
+func NewCalendarFeedRepo(db *pgxpool.Pool) CalendarFeedRepository {
+	return &calendarFeedRepo{db: db}
+}
+
+// scanCalendarFeed scans a row of the calendar_feeds table, returning [ErrCalendarFeedNotFound] if there is none.
+func scanCalendarFeed(row pgx.Row) (*models.CalendarFeed, error) {
+	var f models.CalendarFeed
+	err := row.Scan(&f.UserID, &f.TokenHash, &f.CreatedAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrCalendarFeedNotFound
+	}
+	if err != nil {
+		return nil, errs.NewRepoError(err)
+	}
+	return &f, nil
+}
+
+func (r *calendarFeedRepo) Find(ctx context.Context, userID int64) (*models.CalendarFeed, error) {
+	return scanCalendarFeed(r.db.QueryRow(
+		ctx,
+		"SELECT user_id, token_hash, created_at FROM calendar_feeds WHERE user_id = $1",
+		userID,
+	))
+}
+
+func (r *calendarFeedRepo) FindByHash(ctx context.Context, tokenHash string) (*models.CalendarFeed, error) {
+	return scanCalendarFeed(r.db.QueryRow(
+		ctx,
+		`SELECT f.user_id, f.token_hash, f.created_at FROM calendar_feeds f JOIN users u ON u.id = f.user_id
+			WHERE f.token_hash = $1 AND u.active`,
+		tokenHash,
+	))
+}
+
+func (r *calendarFeedRepo) Regenerate(ctx context.Context, userID int64, tokenHash string) (*models.CalendarFeed, error) {
+	return scanCalendarFeed(r.db.QueryRow(
+		ctx,
+		`INSERT INTO calendar_feeds (user_id, token_hash) VALUES ($1, $2)
+			ON CONFLICT (user_id) DO UPDATE SET token_hash = EXCLUDED.token_hash, created_at = CURRENT_TIMESTAMP
+			RETURNING user_id, token_hash, created_at`,
+		userID, tokenHash,
+	))
+}
+
+func (r *calendarFeedRepo) Delete(ctx context.Context, userID int64) error {
+	tag, err := r.db.Exec(ctx, "DELETE FROM calendar_feeds WHERE user_id = $1", userID)
+	if err != nil {
+		return errs.NewRepoError(err)
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrCalendarFeedNotFound
+	}
+	return nil
+}
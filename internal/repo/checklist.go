@@ -0,0 +1,109 @@
+package repo
+
+import (
+	"context"
+	"errors"
+
+	"github.com/LeandroDeJesus-S/quicknote/internal/errs"
+	"github.com/LeandroDeJesus-S/quicknote/internal/models"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+var ErrChecklistItemNotFound = errs.NewRepoError(errors.New("checklist item not found"))
+
+// ChecklistItemInput is an item to be saved in a checklist.
+type ChecklistItemInput struct {
+	Text    string
+	Checked bool
+}
+
+// ChecklistRepository manages the items of the checklist notes.
+// Every operation is scoped to the checklist notes out of the trash of the workspace of the given [Scope],
+// and moves the note on to a new version, returning a [NoteConflictError] if version is not zero and the note is at another version.
+type ChecklistRepository interface {
+	SetItems(ctx context.Context, scope Scope, noteID, version int, items []ChecklistItemInput) error        // replaces the items of a checklist, keeping their order. Returns [ErrNoteNotFound] if the note is not a checklist of the workspace
+	ToggleItem(ctx context.Context, scope Scope, noteID, version, itemID int) (*models.ChecklistItem, error) // checks an unchecked item or unchecks a checked one. Returns [ErrChecklistItemNotFound] if the item is not of a checklist of the workspace
+}
+
+type checklistRepo struct {
+	db *pgxpool.Pool
+}
+
+func NewChecklistRepo(db *pgxpool.Pool) ChecklistRepository {
+	return &checklistRepo{db: db}
+}
+
+func (r *checklistRepo) SetItems(ctx context.Context, scope Scope, noteID, version int, items []ChecklistItemInput) error {
+	tx, err := conn(ctx, r.db).Begin(ctx)
+	if err != nil {
+		return errs.NewRepoError(err)
+	}
+	defer tx.Rollback(ctx)
+
+	note, err := bumpNoteVersion(ctx, tx, scope, noteID, version)
+	if err != nil {
+		return err
+	}
+	if note.Type != models.NoteTypeChecklist {
+		return ErrNoteNotFound
+	}
+
+	if _, err := tx.Exec(ctx, "DELETE FROM checklist_items WHERE note_id = $1", noteID); err != nil {
+		return errs.NewRepoError(err)
+	}
+
+	rows := make([][]any, 0, len(items))
+	for i, item := range items {
+		rows = append(rows, []any{noteID, item.Text, item.Checked, i + 1})
+	}
+	_, err = tx.CopyFrom(
+		ctx,
+		pgx.Identifier{"checklist_items"},
+		[]string{"note_id", "text", "checked", "position"},
+		pgx.CopyFromRows(rows),
+	)
+	if err != nil {
+		return errs.NewRepoError(err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return errs.NewRepoError(err)
+	}
+	return nil
+}
+
+func (r *checklistRepo) ToggleItem(ctx context.Context, scope Scope, noteID, version, itemID int) (*models.ChecklistItem, error) {
+	tx, err := conn(ctx, r.db).Begin(ctx)
+	if err != nil {
+		return nil, errs.NewRepoError(err)
+	}
+	defer tx.Rollback(ctx)
+
+	_, err = bumpNoteVersion(ctx, tx, scope, noteID, version)
+	if errors.Is(err, ErrNoteNotFound) {
+		return nil, ErrChecklistItemNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var item models.ChecklistItem
+	err = tx.QueryRow(
+		ctx,
+		`UPDATE checklist_items SET checked = NOT checked WHERE id = $1 AND note_id = $2
+			RETURNING id, note_id, text, checked, position`,
+		itemID, noteID,
+	).Scan(&item.ID, &item.NoteID, &item.Text, &item.Checked, &item.Position)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrChecklistItemNotFound
+	}
+	if err != nil {
+		return nil, errs.NewRepoError(err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, errs.NewRepoError(err)
+	}
+	return &item, nil
+}
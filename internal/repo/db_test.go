@@ -0,0 +1,102 @@
+package repo
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// testDatabaseURLEnv is the environment variable with the URL of the database the repository tests run on.
+// The tests using the database are skipped if it's not set.
+const testDatabaseURLEnv = "TEST_DATABASE_URL"
+
+// testDB returns a pool on a new schema of the test database with every migration applied.
+// The schema is dropped when the test ends, so the tests don't see each other's rows.
+func testDB(t *testing.T) *pgxpool.Pool {
+	t.Helper()
+
+	url := os.Getenv(testDatabaseURLEnv)
+	if url == "" {
+		t.Skipf("%s not set", testDatabaseURLEnv)
+	}
+	ctx := context.Background()
+
+	b := make([]byte, 6)
+	rand.Read(b)
+	schema := "test_" + hex.EncodeToString(b)
+
+	admin, err := pgx.Connect(ctx, url)
+	if err != nil {
+		t.Fatalf("connecting to the test database: %v", err)
+	}
+	if _, err := admin.Exec(ctx, "CREATE SCHEMA "+schema); err != nil {
+		admin.Close(ctx)
+		t.Fatalf("creating schema: %v", err)
+	}
+	t.Cleanup(func() {
+		admin.Exec(context.Background(), "DROP SCHEMA "+schema+" CASCADE")
+		admin.Close(context.Background())
+	})
+
+	conf, err := pgxpool.ParseConfig(url)
+	if err != nil {
+		t.Fatalf("parsing %s: %v", testDatabaseURLEnv, err)
+	}
+	conf.ConnConfig.RuntimeParams["search_path"] = schema
+	pool, err := pgxpool.NewWithConfig(ctx, conf)
+	if err != nil {
+		t.Fatalf("creating pool: %v", err)
+	}
+	t.Cleanup(pool.Close)
+
+	files, err := filepath.Glob(filepath.Join("..", "..", "migrations", "*.up.sql"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	sort.Strings(files)
+
+	conn, err := pool.Acquire(ctx)
+	if err != nil {
+		t.Fatalf("acquiring connection: %v", err)
+	}
+	defer conn.Release()
+	for _, file := range files {
+		sql, err := os.ReadFile(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		// the simple protocol runs every statement of the file at once
+		if _, err := conn.Conn().PgConn().Exec(ctx, string(sql)).ReadAll(); err != nil {
+			t.Fatalf("applying %s: %v", filepath.Base(file), err)
+		}
+	}
+	return pool
+}
+
+// testUser creates an active user with the email and returns the scope of its personal workspace.
+func testUser(t *testing.T, db *pgxpool.Pool, email string) Scope {
+	t.Helper()
+	ctx := context.Background()
+
+	usr, err := NewUserRepo(db, nil).Create(ctx, email, "not a real hash")
+	if err != nil {
+		t.Fatalf("creating user %s: %v", email, err)
+	}
+	userID := usr.ID.Int.Int64()
+	if _, err := db.Exec(ctx, "UPDATE users SET active = TRUE WHERE id = $1", userID); err != nil {
+		t.Fatalf("activating user %s: %v", email, err)
+	}
+
+	member, err := NewWorkspaceRepo(db).Personal(ctx, userID)
+	if err != nil {
+		t.Fatalf("creating the personal workspace of %s: %v", email, err)
+	}
+	return Scope{WorkspaceID: member.Workspace.ID.Int.Int64(), UserID: userID}
+}
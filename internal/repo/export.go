@@ -0,0 +1,223 @@
+package repo
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/LeandroDeJesus-S/quicknote/internal/errs"
+	"github.com/LeandroDeJesus-S/quicknote/internal/models"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+var (
+	ErrDataExportNotFound = errs.NewRepoError(errors.New("data export not found"))
+	ErrDataExportPending  = errs.NewRepoError(errors.New("a data export is already being generated"))
+)
+
+// DataExportRepository stores the archives with the personal data the users ask for, and collects that data.
+type DataExportRepository interface {
+	Create(ctx context.Context, userID int64) (*models.DataExport, error)                                                                                      // asks for an archive, returns [ErrDataExportPending] if the user is already waiting for one
+	Find(ctx context.Context, id int64) (*models.DataExport, error)                                                                                            // returns [ErrDataExportNotFound] if there is no archive with the id or its user was deleted
+	GeneratePending(ctx context.Context, limit int, expiresAt time.Time, generate func(models.DataExport) (string, int64, error)) ([]models.DataExport, error) // generates up to limit pending archives and returns them, see [dataExportRepo.GeneratePending]
+	DeleteExpired(ctx context.Context, now time.Time) ([]string, error)                                                                                        // deletes the archives expired by now, failed or of deleted users, and returns the keys of their blobs
+	Collect(ctx context.Context, userID int64) (*models.PersonalData, error)                                                                                   // returns the data stored about the user, but the sessions
+}
+
+type dataExportRepo struct {
+	db *pgxpool.Pool
+}
+
+func NewDataExportRepo(db *pgxpool.Pool) DataExportRepository {
+	return &dataExportRepo{db: db}
+}
+
+func (r *dataExportRepo) Create(ctx context.Context, userID int64) (*models.DataExport, error) {
+	var e models.DataExport
+	query := "INSERT INTO data_exports (user_id) VALUES ($1) RETURNING id, user_id, status, created_at"
+	if err := r.db.QueryRow(ctx, query, userID).Scan(&e.ID, &e.UserID, &e.Status, &e.CreatedAt); err != nil {
+		if strings.Contains(err.Error(), "violates unique constraint") {
+			return nil, ErrDataExportPending
+		}
+		return nil, errs.NewRepoError(err)
+	}
+	return &e, nil
+}
+
+func (r *dataExportRepo) Find(ctx context.Context, id int64) (*models.DataExport, error) {
+	var e models.DataExport
+	query := `SELECT e.id, e.user_id, e.status, e.blob_key, e.size, u.email, e.created_at, e.finished_at, e.expires_at
+		FROM data_exports e JOIN users u ON u.id = e.user_id WHERE e.id = $1`
+	err := r.db.QueryRow(ctx, query, id).Scan(&e.ID, &e.UserID, &e.Status, &e.BlobKey, &e.Size, &e.Email, &e.CreatedAt, &e.FinishedAt, &e.ExpiresAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrDataExportNotFound
+	}
+	if err != nil {
+		return nil, errs.NewRepoError(err)
+	}
+	return &e, nil
+}
+
+// GeneratePending locks up to limit pending archives, oldest first, and calls generate for each of them,
+// which stores the archive and returns the key and size of its blob. The archives generated are ready until
+// expiresAt, the others failed. The archives are locked while generated, so every instance of the server
+// can generate them, and are returned once their status is committed, so their links can be mailed.
+func (r *dataExportRepo) GeneratePending(ctx context.Context, limit int, expiresAt time.Time, generate func(models.DataExport) (string, int64, error)) ([]models.DataExport, error) {
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return nil, errs.NewRepoError(err)
+	}
+	defer tx.Rollback(ctx)
+
+	rows, err := tx.Query(
+		ctx,
+		`SELECT e.id, e.user_id, e.status, u.email, e.created_at FROM data_exports e JOIN users u ON u.id = e.user_id
+			WHERE e.status = 'pending'
+			ORDER BY e.created_at
+			LIMIT $1
+			FOR UPDATE OF e SKIP LOCKED`,
+		limit,
+	)
+	if err != nil {
+		return nil, errs.NewRepoError(err)
+	}
+
+	var pending []models.DataExport
+	for rows.Next() {
+		var e models.DataExport
+		if err := rows.Scan(&e.ID, &e.UserID, &e.Status, &e.Email, &e.CreatedAt); err != nil {
+			rows.Close()
+			return nil, errs.NewRepoError(err)
+		}
+		pending = append(pending, e)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, errs.NewRepoError(err)
+	}
+
+	for i, e := range pending {
+		key, size, err := generate(e)
+		if err != nil {
+			slog.Error("failed to generate data export", "err", err, "export_id", e.ID.Int)
+			err = tx.QueryRow(
+				ctx,
+				"UPDATE data_exports SET status = 'failed', finished_at = CURRENT_TIMESTAMP WHERE id = $1 RETURNING status, finished_at",
+				e.ID,
+			).Scan(&pending[i].Status, &pending[i].FinishedAt)
+		} else {
+			err = tx.QueryRow(
+				ctx,
+				`UPDATE data_exports SET status = 'ready', blob_key = $2, size = $3, finished_at = CURRENT_TIMESTAMP, expires_at = $4
+					WHERE id = $1 RETURNING status, blob_key, size, finished_at, expires_at`,
+				e.ID, key, size, expiresAt,
+			).Scan(&pending[i].Status, &pending[i].BlobKey, &pending[i].Size, &pending[i].FinishedAt, &pending[i].ExpiresAt)
+		}
+		if err != nil {
+			return nil, errs.NewRepoError(err)
+		}
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, errs.NewRepoError(err)
+	}
+	return pending, nil
+}
+
+func (r *dataExportRepo) DeleteExpired(ctx context.Context, now time.Time) ([]string, error) {
+	rows, err := r.db.Query(
+		ctx,
+		`DELETE FROM data_exports WHERE expires_at <= $1 OR status = 'failed' OR (user_id IS NULL AND status <> 'pending')
+			RETURNING blob_key`,
+		now,
+	)
+	if err != nil {
+		return nil, errs.NewRepoError(err)
+	}
+	defer rows.Close()
+
+	var keys []string
+	for rows.Next() {
+		var key *string
+		if err := rows.Scan(&key); err != nil {
+			return nil, errs.NewRepoError(err)
+		}
+		if key != nil {
+			keys = append(keys, *key)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, errs.NewRepoError(err)
+	}
+	return keys, nil
+}
+
+func (r *dataExportRepo) Collect(ctx context.Context, userID int64) (*models.PersonalData, error) {
+	var data models.PersonalData
+
+	p := &data.Profile
+	err := r.db.QueryRow(
+		ctx,
+		"SELECT id, email, active, created_at, updated_at, delete_at FROM users WHERE id = $1",
+		userID,
+	).Scan(&p.ID, &p.Email, &p.Active, &p.CreatedAt, &p.UpdatedAt, &p.DeleteAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrUserNotFound
+	}
+	if err != nil {
+		return nil, errs.NewRepoError(err)
+	}
+
+	rows, err := r.db.Query(ctx, "SELECT "+noteColumns+" FROM notes n WHERE n.user_id = $1 ORDER BY n.id", userID)
+	if err != nil {
+		return nil, errs.NewRepoError(err)
+	}
+	for rows.Next() {
+		var note models.Note
+		if err := rows.Scan(noteScanFields(&note)...); err != nil {
+			rows.Close()
+			return nil, errs.NewRepoError(err)
+		}
+		data.Notes = append(data.Notes, note)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, errs.NewRepoError(err)
+	}
+
+	data.APITokens, err = NewAPITokenRepo(r.db).List(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err = r.db.Query(ctx, "SELECT id, purpose, confirmed, new_email, created_at, updated_at FROM user_tokens WHERE user_id = $1 ORDER BY id", userID)
+	if err != nil {
+		return nil, errs.NewRepoError(err)
+	}
+	for rows.Next() {
+		var t models.AccountToken
+		if err := rows.Scan(&t.ID, &t.Purpose, &t.Confirmed, &t.NewEmail, &t.CreatedAt, &t.UpdatedAt); err != nil {
+			rows.Close()
+			return nil, errs.NewRepoError(err)
+		}
+		data.AccountTokens = append(data.AccountTokens, t)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, errs.NewRepoError(err)
+	}
+
+	data.CalendarFeed, err = NewCalendarFeedRepo(r.db).Find(ctx, userID)
+	if err != nil && !errors.Is(err, ErrCalendarFeedNotFound) {
+		return nil, err
+	}
+	data.TwoFactor, err = NewTOTPRepo(r.db).Find(ctx, userID)
+	if err != nil && !errors.Is(err, ErrTOTPNotFound) {
+		return nil, err
+	}
+
+	return &data, nil
+}
@@ -2,6 +2,7 @@ package repo
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log/slog"
 	"math/big"
@@ -11,18 +12,136 @@ import (
 
 	"github.com/LeandroDeJesus-S/quicknote/internal/errs"
 	"github.com/LeandroDeJesus-S/quicknote/internal/models"
+	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgtype"
 	"github.com/jackc/pgx/v5/pgxpool"
 )
 
-var fieldNameRegex = regexp.MustCompile(`^[A-Za-z_]+$`)
+var (
+	ErrNoteNotFound = errs.NewRepoError(errors.New("note not found"))
+	ErrNoteConflict = errs.NewRepoError(errors.New("note was changed since it was read"))
 
+	fieldNameRegex = regexp.MustCompile(`^[A-Za-z_]+$`)
+
+	// protectedNoteFields are the columns that can never be changed through Update.
+	// The notebook is changed through Move, which checks the notebook ownership, and the reminder through [ReminderRepository].
+	protectedNoteFields = map[string]bool{
+		"id": true, "user_id": true, "workspace_id": true, "notebook_id": true, "deleted_at": true, "version": true, "note_type": true,
+		"remind_at": true, "remind_timezone": true, "remind_recurrence": true, "remind_user_id": true, "remind_next_at": true, "reminded_at": true, "remind_claimed_until": true,
+	}
+)
+
+// noteColumns are the columns selected for a note, which must be aliased as "n".
+// They are scanned by [scanNote].
+const noteColumns = `n.id, n.title, n.content, n.color, n.created_at, n.updated_at, n.user_id, n.notebook_id, n.deleted_at, n.version,
+	n.note_type, n.remind_at, n.remind_timezone, n.remind_recurrence, n.remind_next_at, n.reminded_at,
+	COALESCE((SELECT array_agg(t.name ORDER BY t.name) FROM note_tags nt JOIN tags t ON t.id = nt.tag_id WHERE nt.note_id = n.id), '{}'),
+	COALESCE((SELECT json_agg(json_build_object('id', ci.id, 'note_id', ci.note_id, 'text', ci.text, 'checked', ci.checked, 'position', ci.position) ORDER BY ci.position)
+		FROM checklist_items ci WHERE ci.note_id = n.id), '[]')`
+
+// noteScanFields returns the scan destinations of [noteColumns] followed by extra.
+func noteScanFields(note *models.Note, extra ...any) []any {
+	return append([]any{
+		&note.ID, &note.Title, &note.Content, &note.Color, &note.CreatedAt, &note.UpdatedAt, &note.UserID,
+		&note.NotebookID, &note.DeletedAt, &note.Version, &note.Type, &note.RemindAt, &note.RemindTimezone,
+		&note.RemindRecurrence, &note.RemindNextAt, &note.RemindedAt, &note.Tags, &note.Items,
+	}, extra...)
+}
+
+// scanNote scans a row selected with [noteColumns].
+func scanNote(row pgx.Row) (*models.Note, error) {
+	var note models.Note
+	err := row.Scan(noteScanFields(&note)...)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrNoteNotFound
+	}
+	if err != nil {
+		return nil, errs.NewRepoError(err)
+	}
+	return &note, nil
+}
+
+// NoteConflictError is returned by [Noter.Update], and by the other changes to a note or what belongs to it,
+// when the note was updated by someone else since the expected version was read. It matches [ErrNoteConflict] with errors.Is.
+type NoteConflictError struct {
+	Current *models.Note // the note as it is now
+}
+
+func (e *NoteConflictError) Error() string {
+	return fmt.Sprintf("%s: now at version %d", ErrNoteConflict, e.Current.Version.Int32)
+}
+
+func (e *NoteConflictError) Unwrap() error {
+	return ErrNoteConflict
+}
+
+// noteConflict tells a stale version apart from a missing note once a change expecting a version matched
+// no note, returning a [NoteConflictError] with the note as it is now, or [ErrNoteNotFound].
+func noteConflict(ctx context.Context, tx pgx.Tx, scope Scope, id int) error {
+	current, err := scanNote(tx.QueryRow(
+		ctx,
+		"SELECT "+noteColumns+" FROM notes n WHERE n.id = $1 AND n.workspace_id = $2 AND n.deleted_at IS NULL",
+		id, scope.WorkspaceID,
+	))
+	if err != nil {
+		return err
+	}
+	return &NoteConflictError{Current: current}
+}
+
+// bumpNoteVersion moves a note out of the trash on to its next version within tx, locking it until tx ends,
+// for the changes made to what belongs to the note, like its tags or items, to be seen as a new version of it.
+// It returns the note as it was before the change, and a [NoteConflictError] if version is not zero and the
+// note is at another version, or [ErrNoteNotFound] if the note is not in the workspace.
+func bumpNoteVersion(ctx context.Context, tx pgx.Tx, scope Scope, id, version int) (*models.Note, error) {
+	note, err := scanNote(tx.QueryRow(
+		ctx,
+		`UPDATE notes n SET version = n.version + 1
+			WHERE n.id = $1 AND n.workspace_id = $2 AND n.deleted_at IS NULL AND ($3::int = 0 OR n.version = $3)
+			RETURNING `+noteColumns,
+		id, scope.WorkspaceID, version,
+	))
+	if errors.Is(err, ErrNoteNotFound) && version > 0 {
+		return nil, noteConflict(ctx, tx, scope, id)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return note, nil
+}
+
+// NoteFilter narrows down the notes returned by [Noter.List].
+// The zero value matches every note.
+type NoteFilter struct {
+	Tags     []string // the tag names the notes must be tagged with
+	MatchAll bool     // whether the notes must have all the Tags, instead of at least one of them
+
+	// NotebookID, when set, narrows the notes down to the ones in the notebook or in its nested notebooks.
+	NotebookID int
+}
+
+// Noter is the notes repository. Every operation is scoped to the workspace of the given [Scope],
+// so notes of other workspaces behave as if they did not exist. The editors of a note shared with them
+// act on it within the scope of its workspace, see [NoteShareRepository].
+//
+// Deleted notes are moved to the trash, where only ListTrash, Restore and Purge see them,
+// until they are purged for good.
+//
+// Every change to a note, to its notebook, tags or checklist items included, moves it on to a new version,
+// so a save changing several of them within a transaction can move it on by more than one version.
 type Noter interface {
-	List(ctx context.Context, userID int64) ([]models.Note, error)
-	ReadOne(ctx context.Context, tid int) (*models.Note, error)
-	Create(ctx context.Context, userID int64, title, content, color string) (*models.Note, error)
-	Update(ctx context.Context, id int, data map[string]any) (*models.Note, error)
-	Delete(ctx context.Context, id int) error
+	List(ctx context.Context, scope Scope, filter NoteFilter) ([]models.Note, error)                                       // lists the workspace notes matching the filter
+	ReadOne(ctx context.Context, scope Scope, id int) (*models.Note, error)                                                // returns [ErrNoteNotFound] if the note does not exist or is not in the workspace
+	Create(ctx context.Context, scope Scope, noteType models.NoteType, title, content, color string) (*models.Note, error) // creates a note of the type in the workspace, recording its first revision by the user
+	Update(ctx context.Context, scope Scope, id, version int, data map[string]any) (*models.Note, error)                   // records the updated note as a new revision by the user. Returns a [NoteConflictError] if version is not zero and the note is at another version, or [ErrNoteNotFound] like ReadOne
+	Delete(ctx context.Context, scope Scope, id int) error                                                                 // moves a note to the trash. Returns [ErrNoteNotFound] like ReadOne
+	Search(ctx context.Context, scope Scope, query string) ([]models.NoteSearchResult, error)                              // full-text searches the workspace notes, see [ParseSearchQuery] for the query syntax
+	Move(ctx context.Context, scope Scope, id, version int, notebookID *int) error                                         // moves a note into a notebook, or out of any notebook if it is nil. Returns [ErrNotebookNotFound] if the notebook is not in the workspace, or a [NoteConflictError] like Update
+	ListTrash(ctx context.Context, scope Scope) ([]models.Note, error)                                                     // lists the workspace notes in the trash, the most recently deleted first
+	Restore(ctx context.Context, scope Scope, id int) error                                                                // takes a note out of the trash. Returns [ErrNoteNotFound] if the note is not in the workspace trash
+	Purge(ctx context.Context, scope Scope, id int) error                                                                  // deletes a note in the trash for good. Returns [ErrNoteNotFound] if the note is not in the workspace trash
+	EmptyTrash(ctx context.Context, scope Scope) (int64, error)                                                            // deletes every note in the workspace trash for good and returns how many were deleted
+	PurgeDeletedBefore(ctx context.Context, before time.Time) (int64, error)                                               // deletes for good the notes of every workspace moved to the trash before the given time
 }
 
 type noteRepo struct {
@@ -33,12 +152,33 @@ func NewNoteRepo(db *pgxpool.Pool) Noter {
 	return &noteRepo{db: db}
 }
 
-func (nr noteRepo) List(ctx context.Context, userID int64) ([]models.Note, error) {
-	rows, err := nr.db.Query(
-		context.Background(),
-		"SELECT id, title, content, color, created_at, updated_at FROM notes WHERE user_id = $1",
-		userID,
-	)
+func (nr noteRepo) List(ctx context.Context, scope Scope, filter NoteFilter) ([]models.Note, error) {
+	var query strings.Builder
+	query.WriteString("SELECT " + noteColumns + " FROM notes n WHERE n.workspace_id = $1 AND n.deleted_at IS NULL")
+	args := []any{scope.WorkspaceID}
+
+	if len(filter.Tags) > 0 {
+		args = append(args, filter.Tags)
+		tagged := fmt.Sprintf("SELECT count(DISTINCT t.name) FROM note_tags nt JOIN tags t ON t.id = nt.tag_id WHERE nt.note_id = n.id AND t.name = ANY($%d)", len(args))
+		if filter.MatchAll {
+			args = append(args, len(uniqueStrings(filter.Tags)))
+			query.WriteString(fmt.Sprintf(" AND (%s) = $%d", tagged, len(args)))
+		} else {
+			query.WriteString(" AND (" + tagged + ") > 0")
+		}
+	}
+	if filter.NotebookID > 0 {
+		args = append(args, filter.NotebookID)
+		query.WriteString(fmt.Sprintf(" AND n.notebook_id IN (%s)", fmt.Sprintf(notebookSubtreeQuery, len(args))))
+	}
+	query.WriteString(" ORDER BY n.updated_at DESC, n.id DESC")
+
+	return nr.queryNotes(ctx, query.String(), args...)
+}
+
+// queryNotes runs a query selecting [noteColumns] and scans all the returned notes.
+func (nr noteRepo) queryNotes(ctx context.Context, query string, args ...any) ([]models.Note, error) {
+	rows, err := conn(ctx, nr.db).Query(ctx, query, args...)
 	if err != nil {
 		return nil, errs.NewRepoError(err)
 	}
@@ -46,69 +186,79 @@ func (nr noteRepo) List(ctx context.Context, userID int64) ([]models.Note, error
 
 	var notes []models.Note
 	for rows.Next() {
-		var note models.Note
-		err = rows.Scan(&note.ID, &note.Title, &note.Content, &note.Color, &note.CreatedAt, &note.UpdatedAt)
+		note, err := scanNote(rows)
 		if err != nil {
-			return nil, errs.NewRepoError(err)
+			return nil, err
 		}
-		notes = append(notes, note)
+		notes = append(notes, *note)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, errs.NewRepoError(err)
 	}
 	return notes, nil
 }
 
-func (nr noteRepo) ReadOne(ctx context.Context, id int) (*models.Note, error) {
-	row := nr.db.QueryRow(
-		context.Background(),
-		"SELECT id, title, content, color, created_at, updated_at FROM notes WHERE id = $1",
-		id,
+func (nr noteRepo) ReadOne(ctx context.Context, scope Scope, id int) (*models.Note, error) {
+	row := conn(ctx, nr.db).QueryRow(
+		ctx,
+		"SELECT "+noteColumns+" FROM notes n WHERE n.id = $1 AND n.workspace_id = $2 AND n.deleted_at IS NULL",
+		id, scope.WorkspaceID,
 	)
-	var note models.Note
-	err := row.Scan(&note.ID, &note.Title, &note.Content, &note.Color, &note.CreatedAt, &note.UpdatedAt)
-	if err != nil {
-		return nil, errs.NewRepoError(err)
-	}
-	return &note, nil
+	return scanNote(row)
 }
 
-func (nr noteRepo) Create(ctx context.Context, userID int64, title, content, color string) (*models.Note, error) {
+func (nr noteRepo) Create(ctx context.Context, scope Scope, noteType models.NoteType, title, content, color string) (*models.Note, error) {
 	var note models.Note
 
-	note.UserID = pgtype.Numeric{Int: big.NewInt(userID), Valid: true}
+	note.UserID = pgtype.Numeric{Int: big.NewInt(scope.UserID), Valid: true}
 	note.Title = pgtype.Text{String: title, Valid: title != ""}
 	note.Content = pgtype.Text{String: content, Valid: content != ""}
 	note.Color = pgtype.Text{String: color, Valid: color != ""}
 	note.CreatedAt = pgtype.Date{Time: time.Now(), Valid: true}
 	note.UpdatedAt = pgtype.Date{Time: time.Now(), Valid: true}
+	note.Version = pgtype.Int4{Int32: 1, Valid: true}
+	note.Type = noteType
 
-	row := nr.db.QueryRow(
-		context.Background(),
-		"INSERT INTO notes (title, content, color, user_id, created_at, updated_at) VALUES ($1, $2, $3, $4, $5, $6) RETURNING id",
-		title, content, color, userID, note.CreatedAt, note.UpdatedAt,
+	tx, err := conn(ctx, nr.db).Begin(ctx)
+	if err != nil {
+		return nil, errs.NewRepoError(err)
+	}
+	defer tx.Rollback(ctx)
+
+	row := tx.QueryRow(
+		ctx,
+		"INSERT INTO notes (title, content, color, user_id, workspace_id, note_type, created_at, updated_at) VALUES ($1, $2, $3, $4, $5, $6, $7, $8) RETURNING id",
+		title, content, color, scope.UserID, scope.WorkspaceID, noteType, note.CreatedAt, note.UpdatedAt,
 	)
 
 	if err := row.Scan(&note.ID); err != nil {
 		return nil, errs.NewRepoError(err)
 	}
 
+	if err := recordRevision(ctx, tx, note.ID.Int.Int64(), scope.UserID); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, errs.NewRepoError(err)
+	}
 	return &note, nil
 }
 
-func (nr noteRepo) Update(ctx context.Context, id int, data map[string]any) (*models.Note, error) {
+func (nr noteRepo) Update(ctx context.Context, scope Scope, id, version int, data map[string]any) (*models.Note, error) {
 	if data == nil {
 		return nil, errs.NewRepoError(fmt.Errorf("no data to update"))
 	}
 
-	var note models.Note
-
 	var query strings.Builder
-	query.WriteString("UPDATE notes SET ")
-	args := make([]any, 0, len(data)+1)
+	query.WriteString("UPDATE notes n SET version = n.version + 1, ")
+	args := make([]any, 0, len(data)+3)
 
 	i := 0
 	nmap := len(data)
 
 	for field, value := range data {
-		if !fieldNameRegex.MatchString(field) {
+		if !fieldNameRegex.MatchString(field) || protectedNoteFields[field] {
 			return nil, errs.NewRepoError(fmt.Errorf("invalid field name: %s", field))
 		}
 
@@ -123,31 +273,205 @@ func (nr noteRepo) Update(ctx context.Context, id int, data map[string]any) (*mo
 		i++
 	}
 
-	query.WriteString(fmt.Sprintf("WHERE id=$%d ", i+1))
-	args = append(args, id)
+	query.WriteString(fmt.Sprintf("WHERE n.id=$%d AND n.workspace_id=$%d AND n.deleted_at IS NULL ", i+1, i+2))
+	args = append(args, id, scope.WorkspaceID)
+
+	if version > 0 {
+		query.WriteString(fmt.Sprintf("AND n.version=$%d ", i+3))
+		args = append(args, version)
+	}
+
+	query.WriteString("RETURNING " + noteColumns)
 
-	query.WriteString("RETURNING id, title, content, color, created_at, updated_at")
+	tx, err := conn(ctx, nr.db).Begin(ctx)
+	if err != nil {
+		return nil, errs.NewRepoError(err)
+	}
+	defer tx.Rollback(ctx)
 
 	slog.Debug("updating note", "query", query.String(), "args", args)
-	row := nr.db.QueryRow(
-		context.Background(),
+	row := tx.QueryRow(
+		ctx,
 		query.String(),
 		args...,
 	)
 
-	err := row.Scan(&note.ID, &note.Title, &note.Content, &note.Color, &note.CreatedAt, &note.UpdatedAt)
+	note, err := scanNote(row)
+	if errors.Is(err, ErrNoteNotFound) && version > 0 {
+		return nil, noteConflict(ctx, tx, scope, id)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if err := recordRevision(ctx, tx, note.ID.Int.Int64(), scope.UserID); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, errs.NewRepoError(err)
+	}
+	return note, nil
+}
+
+func (nr noteRepo) Delete(ctx context.Context, scope Scope, id int) error {
+	tag, err := conn(ctx, nr.db).Exec(
+		ctx,
+		"UPDATE notes SET deleted_at = CURRENT_TIMESTAMP WHERE id = $1 AND workspace_id = $2 AND deleted_at IS NULL",
+		id, scope.WorkspaceID,
+	)
+	if err != nil {
+		return errs.NewRepoError(err)
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrNoteNotFound
+	}
+	return nil
+}
+
+func (nr noteRepo) Move(ctx context.Context, scope Scope, id, version int, notebookID *int) error {
+	tx, err := conn(ctx, nr.db).Begin(ctx)
+	if err != nil {
+		return errs.NewRepoError(err)
+	}
+	defer tx.Rollback(ctx)
+
+	if notebookID != nil {
+		var exists bool
+		err := tx.QueryRow(
+			ctx,
+			"SELECT EXISTS (SELECT 1 FROM notebooks WHERE id = $1 AND workspace_id = $2)",
+			*notebookID, scope.WorkspaceID,
+		).Scan(&exists)
+		if err != nil {
+			return errs.NewRepoError(err)
+		}
+		if !exists {
+			return ErrNotebookNotFound
+		}
+	}
+
+	tag, err := tx.Exec(
+		ctx,
+		`UPDATE notes SET notebook_id = $1, updated_at = CURRENT_TIMESTAMP, version = version + 1
+			WHERE id = $2 AND workspace_id = $3 AND deleted_at IS NULL AND ($4::int = 0 OR version = $4)`,
+		notebookID, id, scope.WorkspaceID, version,
+	)
+	if err != nil {
+		return errs.NewRepoError(err)
+	}
+	if tag.RowsAffected() == 0 {
+		if version > 0 {
+			return noteConflict(ctx, tx, scope, id)
+		}
+		return ErrNoteNotFound
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return errs.NewRepoError(err)
+	}
+	return nil
+}
+
+func (nr noteRepo) Search(ctx context.Context, scope Scope, query string) ([]models.NoteSearchResult, error) {
+	tsquery := ParseSearchQuery(query)
+	if tsquery == "" {
+		return nil, nil
+	}
+
+	q := `SELECT ` + noteColumns + `,
+			ts_rank(n.search_vector, q) AS rank,
+			ts_headline('` + searchConfig + `', n.title, q, $4),
+			ts_headline('` + searchConfig + `', coalesce(n.content, ''), q, $3)
+		FROM notes n, to_tsquery('` + searchConfig + `', $2) q
+		WHERE n.workspace_id = $1 AND n.deleted_at IS NULL AND n.search_vector @@ q
+		ORDER BY rank DESC, n.updated_at DESC
+		LIMIT $5`
+
+	rows, err := conn(ctx, nr.db).Query(ctx, q, scope.WorkspaceID, tsquery, headlineOptions, titleHeadlineOptions, searchResultsLimit)
 	if err != nil {
 		return nil, errs.NewRepoError(err)
 	}
+	defer rows.Close()
 
-	return &note, nil
+	var results []models.NoteSearchResult
+	for rows.Next() {
+		var res models.NoteSearchResult
+		err := rows.Scan(noteScanFields(&res.Note, &res.Rank, &res.TitleHeadline, &res.Snippet)...)
+		if err != nil {
+			return nil, errs.NewRepoError(err)
+		}
+		results = append(results, res)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, errs.NewRepoError(err)
+	}
+	return results, nil
+}
+
+func (nr noteRepo) ListTrash(ctx context.Context, scope Scope) ([]models.Note, error) {
+	return nr.queryNotes(
+		ctx,
+		"SELECT "+noteColumns+" FROM notes n WHERE n.workspace_id = $1 AND n.deleted_at IS NOT NULL ORDER BY n.deleted_at DESC, n.id DESC",
+		scope.WorkspaceID,
+	)
+}
+
+func (nr noteRepo) Restore(ctx context.Context, scope Scope, id int) error {
+	tag, err := conn(ctx, nr.db).Exec(
+		ctx,
+		"UPDATE notes SET deleted_at = NULL WHERE id = $1 AND workspace_id = $2 AND deleted_at IS NOT NULL",
+		id, scope.WorkspaceID,
+	)
+	if err != nil {
+		return errs.NewRepoError(err)
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrNoteNotFound
+	}
+	return nil
 }
 
-func (nr noteRepo) Delete(ctx context.Context, id int) error {
-	_, err := nr.db.Exec(
-		context.Background(),
-		"DELETE FROM notes WHERE id = $1",
-		id,
+func (nr noteRepo) Purge(ctx context.Context, scope Scope, id int) error {
+	tag, err := conn(ctx, nr.db).Exec(
+		ctx,
+		"DELETE FROM notes WHERE id = $1 AND workspace_id = $2 AND deleted_at IS NOT NULL",
+		id, scope.WorkspaceID,
 	)
-	return err
+	if err != nil {
+		return errs.NewRepoError(err)
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrNoteNotFound
+	}
+	return nil
+}
+
+func (nr noteRepo) EmptyTrash(ctx context.Context, scope Scope) (int64, error) {
+	tag, err := conn(ctx, nr.db).Exec(ctx, "DELETE FROM notes WHERE workspace_id = $1 AND deleted_at IS NOT NULL", scope.WorkspaceID)
+	if err != nil {
+		return 0, errs.NewRepoError(err)
+	}
+	return tag.RowsAffected(), nil
+}
+
+func (nr noteRepo) PurgeDeletedBefore(ctx context.Context, before time.Time) (int64, error) {
+	tag, err := conn(ctx, nr.db).Exec(ctx, "DELETE FROM notes WHERE deleted_at < $1", before)
+	if err != nil {
+		return 0, errs.NewRepoError(err)
+	}
+	return tag.RowsAffected(), nil
+}
+
+// uniqueStrings returns the distinct values of s, keeping their order.
+func uniqueStrings(s []string) []string {
+	seen := make(map[string]bool, len(s))
+	var unique []string
+	for _, v := range s {
+		if !seen[v] {
+			seen[v] = true
+			unique = append(unique, v)
+		}
+	}
+	return unique
 }
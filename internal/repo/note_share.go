@@ -0,0 +1,220 @@
+package repo
+
+import (
+	"context"
+	"errors"
+
+	"github.com/LeandroDeJesus-S/quicknote/internal/errs"
+	"github.com/LeandroDeJesus-S/quicknote/internal/models"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+var (
+	ErrNoteShareNotFound = errs.NewRepoError(errors.New("note share not found"))
+	ErrShareWithOwner    = errs.NewRepoError(errors.New("the user is a member of the note's workspace"))
+)
+
+// noteShareColumns are the columns scanned by [noteShareScanFields], where s is the note_shares
+// table and u the user the note is shared with, left joined as the share may be waiting for them.
+const noteShareColumns = "s.id, s.note_id, s.user_id, COALESCE(u.email, s.email), s.role, s.created_at"
+
+func noteShareScanFields(s *models.NoteShare) []any {
+	return []any{&s.ID, &s.NoteID, &s.UserID, &s.Email, &s.Role, &s.CreatedAt}
+}
+
+// NoteShareRepository manages the notes shared with users out of their workspace and what they can do with them.
+// The notes are owned by their workspace, whose members are the only ones who can share them,
+// and notes in the trash are not shared with anyone. A note shared with an email of no active user
+// waits for them, shown like any other share, until an account with the email is activated.
+type NoteShareRepository interface {
+	Share(ctx context.Context, scope Scope, noteID int, email string, role models.NoteRole) (*models.NoteShare, error) // shares a note of the workspace with the user of the email, or changes their role if it's already shared with them. Returns [ErrNoteNotFound] or [ErrShareWithOwner]
+	List(ctx context.Context, scope Scope, noteID int) ([]models.NoteShare, error)                                     // lists the users a note of the workspace is shared with, by email
+	Unshare(ctx context.Context, scope Scope, noteID, id int) error                                                    // stops sharing a note, returns [ErrNoteShareNotFound] if it's not a share of a note of the workspace
+	Access(ctx context.Context, scope Scope, noteID int) (*models.NoteAccess, error)                                   // returns what the user can do with a note, owning the notes of the workspace. Returns [ErrNoteNotFound] if the note is not in the workspace and it's not shared with the user
+	ListSharedWith(ctx context.Context, userID int64) ([]models.SharedNote, error)                                     // lists the notes shared with the user, the most recently updated first
+}
+
+type noteShareRepo struct {
+	db *pgxpool.Pool
+}
+
+func NewNoteShareRepo(db *pgxpool.Pool) NoteShareRepository {
+	return &noteShareRepo{db: db}
+}
+
+func (r *noteShareRepo) Share(ctx context.Context, scope Scope, noteID int, email string, role models.NoteRole) (*models.NoteShare, error) {
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return nil, errs.NewRepoError(err)
+	}
+	defer tx.Rollback(ctx)
+
+	var id int64
+	err = tx.QueryRow(ctx, "SELECT id FROM notes WHERE id = $1 AND workspace_id = $2 AND deleted_at IS NULL FOR UPDATE", noteID, scope.WorkspaceID).Scan(&id)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrNoteNotFound
+	}
+	if err != nil {
+		return nil, errs.NewRepoError(err)
+	}
+
+	var (
+		userID int64
+		member bool
+	)
+	err = tx.QueryRow(
+		ctx,
+		`SELECT u.id, EXISTS (SELECT 1 FROM workspace_members m WHERE m.workspace_id = $2 AND m.user_id = u.id)
+			FROM users u WHERE u.email = $1 AND u.active`,
+		email, scope.WorkspaceID,
+	).Scan(&userID, &member)
+
+	insert := `INSERT INTO note_shares (note_id, user_id, role) VALUES ($1, $2, $3)
+		ON CONFLICT (note_id, user_id) DO UPDATE SET role = EXCLUDED.role`
+	args := []any{noteID, userID, role}
+	switch {
+	case errors.Is(err, pgx.ErrNoRows):
+		// kept by the email for whoever activates an account with it
+		insert = `INSERT INTO note_shares (note_id, email, role) VALUES ($1, $2, $3)
+			ON CONFLICT (note_id, email) DO UPDATE SET role = EXCLUDED.role`
+		args = []any{noteID, email, role}
+	case err != nil:
+		return nil, errs.NewRepoError(err)
+	case member:
+		return nil, ErrShareWithOwner
+	}
+
+	var s models.NoteShare
+	err = tx.QueryRow(
+		ctx,
+		`WITH s AS (`+insert+` RETURNING *)
+		SELECT `+noteShareColumns+` FROM s LEFT JOIN users u ON u.id = s.user_id`,
+		args...,
+	).Scan(noteShareScanFields(&s)...)
+	if err != nil {
+		return nil, errs.NewRepoError(err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, errs.NewRepoError(err)
+	}
+	return &s, nil
+}
+
+func (r *noteShareRepo) List(ctx context.Context, scope Scope, noteID int) ([]models.NoteShare, error) {
+	rows, err := r.db.Query(
+		ctx,
+		`SELECT `+noteShareColumns+` FROM note_shares s
+			LEFT JOIN users u ON u.id = s.user_id
+			JOIN notes n ON n.id = s.note_id
+			WHERE s.note_id = $1 AND n.workspace_id = $2
+			ORDER BY COALESCE(u.email, s.email)`,
+		noteID, scope.WorkspaceID,
+	)
+	if err != nil {
+		return nil, errs.NewRepoError(err)
+	}
+	defer rows.Close()
+
+	var shares []models.NoteShare
+	for rows.Next() {
+		var s models.NoteShare
+		if err := rows.Scan(noteShareScanFields(&s)...); err != nil {
+			return nil, errs.NewRepoError(err)
+		}
+		shares = append(shares, s)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, errs.NewRepoError(err)
+	}
+	return shares, nil
+}
+
+func (r *noteShareRepo) Unshare(ctx context.Context, scope Scope, noteID, id int) error {
+	tag, err := r.db.Exec(
+		ctx,
+		"DELETE FROM note_shares s USING notes n WHERE s.id = $1 AND s.note_id = $2 AND n.id = s.note_id AND n.workspace_id = $3",
+		id, noteID, scope.WorkspaceID,
+	)
+	if err != nil {
+		return errs.NewRepoError(err)
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrNoteShareNotFound
+	}
+	return nil
+}
+
+func (r *noteShareRepo) Access(ctx context.Context, scope Scope, noteID int) (*models.NoteAccess, error) {
+	var access models.NoteAccess
+	err := r.db.QueryRow(
+		ctx,
+		`SELECT n.workspace_id, u.email, CASE WHEN n.workspace_id = $3 THEN $4::text ELSE s.role END
+			FROM notes n
+			JOIN users u ON u.id = n.user_id
+			LEFT JOIN note_shares s ON s.note_id = n.id AND s.user_id = $2
+			WHERE n.id = $1 AND n.deleted_at IS NULL AND (n.workspace_id = $3 OR s.id IS NOT NULL)`,
+		noteID, scope.UserID, scope.WorkspaceID, string(models.NoteRoleOwner),
+	).Scan(&access.WorkspaceID, &access.OwnerEmail, &access.Role)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrNoteNotFound
+	}
+	if err != nil {
+		return nil, errs.NewRepoError(err)
+	}
+	return &access, nil
+}
+
+func (r *noteShareRepo) ListSharedWith(ctx context.Context, userID int64) ([]models.SharedNote, error) {
+	rows, err := r.db.Query(
+		ctx,
+		`SELECT `+noteColumns+`, u.email, s.role
+			FROM note_shares s
+			JOIN notes n ON n.id = s.note_id
+			JOIN users u ON u.id = n.user_id
+			WHERE s.user_id = $1 AND n.deleted_at IS NULL
+			ORDER BY n.updated_at DESC, n.id DESC`,
+		userID,
+	)
+	if err != nil {
+		return nil, errs.NewRepoError(err)
+	}
+	defer rows.Close()
+
+	var notes []models.SharedNote
+	for rows.Next() {
+		var sn models.SharedNote
+		if err := rows.Scan(noteScanFields(&sn.Note, &sn.OwnerEmail, &sn.Role)...); err != nil {
+			return nil, errs.NewRepoError(err)
+		}
+		notes = append(notes, sn)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, errs.NewRepoError(err)
+	}
+	return notes, nil
+}
+
+// claimNoteShares passes the notes shared with the email while it had no active account to the user who
+// has it now, but the ones already shared with them or in their workspaces.
+func claimNoteShares(ctx context.Context, tx pgx.Tx, userID int64, email string) error {
+	_, err := tx.Exec(
+		ctx,
+		`DELETE FROM note_shares p WHERE p.email = $2 AND (
+			EXISTS (SELECT 1 FROM note_shares s WHERE s.note_id = p.note_id AND s.user_id = $1)
+			OR EXISTS (
+				SELECT 1 FROM notes n JOIN workspace_members m ON m.workspace_id = n.workspace_id
+				WHERE n.id = p.note_id AND m.user_id = $1
+			)
+		)`,
+		userID, email,
+	)
+	if err != nil {
+		return errs.NewRepoError(err)
+	}
+	if _, err := tx.Exec(ctx, "UPDATE note_shares SET user_id = $1, email = NULL WHERE email = $2", userID, email); err != nil {
+		return errs.NewRepoError(err)
+	}
+	return nil
+}
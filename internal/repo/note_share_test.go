@@ -0,0 +1,63 @@
+package repo
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/LeandroDeJesus-S/quicknote/internal/models"
+	"github.com/LeandroDeJesus-S/quicknote/internal/support/authutil"
+)
+
+func TestShareWithEmailWithoutAccount(t *testing.T) {
+	db := testDB(t)
+	ctx := context.Background()
+	shares := NewNoteShareRepo(db)
+	users := NewUserRepo(db, TokenTTLs{models.TokenPurposeConfirmation: time.Hour})
+
+	owner := testUser(t, db, "owner@example.com")
+	note, err := NewNoteRepo(db).Create(ctx, owner, models.NoteTypeText, "Shared", "", "#ffffff")
+	if err != nil {
+		t.Fatalf("creating note: %v", err)
+	}
+	noteID := int(note.ID.Int.Int64())
+
+	share, err := shares.Share(ctx, owner, noteID, "newcomer@example.com", models.NoteRoleEditor)
+	if err != nil {
+		t.Fatalf("Share() with an email without account error = %v", err)
+	}
+	if share.Email.String != "newcomer@example.com" || share.Role != models.NoteRoleEditor {
+		t.Errorf("Share() = %s as %s, want newcomer@example.com as editor", share.Email.String, share.Role)
+	}
+	list, err := shares.List(ctx, owner, noteID)
+	if err != nil {
+		t.Fatalf("List() error = %v", err)
+	}
+	if len(list) != 1 || list[0].Email.String != "newcomer@example.com" {
+		t.Fatalf("List() = %+v, want the share waiting for newcomer@example.com", list)
+	}
+
+	usr, err := users.Create(ctx, "newcomer@example.com", "not a real hash")
+	if err != nil {
+		t.Fatalf("creating user: %v", err)
+	}
+	newcomer := Scope{UserID: usr.ID.Int.Int64()}
+	if _, err := shares.Access(ctx, newcomer, noteID); err == nil {
+		t.Errorf("Access() before activating the account succeeded")
+	}
+
+	tokenHash := authutil.HashToken(authutil.GenerateToken())
+	if _, err := users.CreateUserToken(ctx, newcomer.UserID, tokenHash, models.TokenPurposeConfirmation); err != nil {
+		t.Fatalf("CreateUserToken() error = %v", err)
+	}
+	if err := users.ConfirmUserWithToken(ctx, tokenHash); err != nil {
+		t.Fatalf("ConfirmUserWithToken() error = %v", err)
+	}
+	access, err := shares.Access(ctx, newcomer, noteID)
+	if err != nil {
+		t.Fatalf("Access() after activating the account error = %v", err)
+	}
+	if access.Role != models.NoteRoleEditor {
+		t.Errorf("Access() role = %s, want editor", access.Role)
+	}
+}
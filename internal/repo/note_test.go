@@ -0,0 +1,126 @@
+package repo
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/LeandroDeJesus-S/quicknote/internal/models"
+)
+
+func TestNoteScopeDeniesOtherUsersAndWorkspaces(t *testing.T) {
+	db := testDB(t)
+	ctx := context.Background()
+	notes := NewNoteRepo(db)
+
+	owner := testUser(t, db, "owner@example.com")
+	other := testUser(t, db, "other@example.com")
+	team, err := NewWorkspaceRepo(db).Create(ctx, owner.UserID, "Team")
+	if err != nil {
+		t.Fatalf("creating team workspace: %v", err)
+	}
+
+	note, err := notes.Create(ctx, owner, models.NoteTypeText, "Secret", "only for the owner", "#ffffff")
+	if err != nil {
+		t.Fatalf("creating note: %v", err)
+	}
+	id := int(note.ID.Int.Int64())
+
+	scopes := []struct {
+		name  string
+		scope Scope
+	}{
+		{"another user's workspace", other},
+		{"another user in the owner's workspace id", Scope{WorkspaceID: other.WorkspaceID, UserID: owner.UserID}},
+		{"another workspace of the owner", Scope{WorkspaceID: team.Workspace.ID.Int.Int64(), UserID: owner.UserID}},
+	}
+	for _, tt := range scopes {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := notes.ReadOne(ctx, tt.scope, id); !errors.Is(err, ErrNoteNotFound) {
+				t.Errorf("ReadOne() error = %v, want ErrNoteNotFound", err)
+			}
+			if _, err := notes.Update(ctx, tt.scope, id, 0, map[string]any{"title": "Stolen"}); !errors.Is(err, ErrNoteNotFound) {
+				t.Errorf("Update() error = %v, want ErrNoteNotFound", err)
+			}
+			if _, err := notes.Update(ctx, tt.scope, id, int(note.Version.Int32), map[string]any{"title": "Stolen"}); !errors.Is(err, ErrNoteNotFound) {
+				t.Errorf("Update() with version error = %v, want ErrNoteNotFound", err)
+			}
+			if err := notes.Delete(ctx, tt.scope, id); !errors.Is(err, ErrNoteNotFound) {
+				t.Errorf("Delete() error = %v, want ErrNoteNotFound", err)
+			}
+		})
+	}
+
+	got, err := notes.ReadOne(ctx, owner, id)
+	if err != nil {
+		t.Fatalf("ReadOne() by the owner error = %v", err)
+	}
+	if got.Title.String != "Secret" || got.DeletedAt.Valid || got.Version.Int32 != note.Version.Int32 {
+		t.Errorf("note changed by other scopes: title %q, deleted %v, version %d", got.Title.String, got.DeletedAt.Valid, got.Version.Int32)
+	}
+}
+
+func TestNoteVersionCoversTagsItemsAndNotebook(t *testing.T) {
+	db := testDB(t)
+	ctx := context.Background()
+	notes := NewNoteRepo(db)
+	tags := NewTagRepo(db)
+	checklists := NewChecklistRepo(db)
+
+	owner := testUser(t, db, "owner@example.com")
+	note, err := notes.Create(ctx, owner, models.NoteTypeChecklist, "Groceries", "", "#ffffff")
+	if err != nil {
+		t.Fatalf("creating note: %v", err)
+	}
+	id := int(note.ID.Int.Int64())
+	stale := int(note.Version.Int32)
+
+	changes := []struct {
+		name   string
+		change func(version int) error
+	}{
+		{"tags", func(version int) error { return tags.SetNoteTags(ctx, owner, id, version, []string{"home"}) }},
+		{"items", func(version int) error {
+			return checklists.SetItems(ctx, owner, id, version, []ChecklistItemInput{{Text: "milk"}})
+		}},
+		{"notebook", func(version int) error { return notes.Move(ctx, owner, id, version, nil) }},
+	}
+	for _, tt := range changes {
+		t.Run(tt.name, func(t *testing.T) {
+			before, err := notes.ReadOne(ctx, owner, id)
+			if err != nil {
+				t.Fatalf("ReadOne() error = %v", err)
+			}
+			if err := tt.change(int(before.Version.Int32)); err != nil {
+				t.Fatalf("change at the current version error = %v", err)
+			}
+
+			after, err := notes.ReadOne(ctx, owner, id)
+			if err != nil {
+				t.Fatalf("ReadOne() error = %v", err)
+			}
+			if after.Version.Int32 != before.Version.Int32+1 {
+				t.Errorf("version = %d, want %d", after.Version.Int32, before.Version.Int32+1)
+			}
+
+			var conflict *NoteConflictError
+			if err := tt.change(stale); !errors.As(err, &conflict) {
+				t.Fatalf("change at a stale version error = %v, want a NoteConflictError", err)
+			}
+			if conflict.Current.Version.Int32 != after.Version.Int32 {
+				t.Errorf("conflict at version %d, want %d", conflict.Current.Version.Int32, after.Version.Int32)
+			}
+		})
+	}
+
+	items, err := notes.ReadOne(ctx, owner, id)
+	if err != nil {
+		t.Fatalf("ReadOne() error = %v", err)
+	}
+	if _, err := checklists.ToggleItem(ctx, owner, id, stale, int(items.Items[0].ID.Int.Int64())); !errors.Is(err, ErrNoteConflict) {
+		t.Errorf("ToggleItem() at a stale version error = %v, want ErrNoteConflict", err)
+	}
+	if _, err := checklists.ToggleItem(ctx, owner, id, int(items.Version.Int32), int(items.Items[0].ID.Int.Int64())); err != nil {
+		t.Errorf("ToggleItem() at the current version error = %v", err)
+	}
+}
@@ -0,0 +1,245 @@
+package repo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/LeandroDeJesus-S/quicknote/internal/errs"
+	"github.com/LeandroDeJesus-S/quicknote/internal/models"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+var (
+	ErrNotebookNotFound   = errs.NewRepoError(errors.New("notebook not found"))
+	ErrDuplicatedNotebook = errs.NewRepoError(errors.New("notebook name already in use"))
+	ErrNotebookCycle      = errs.NewRepoError(errors.New("notebook cannot be moved into itself or into a nested notebook"))
+)
+
+// notebookSubtreeQuery selects the ID of a notebook and of all its nested notebooks.
+// It must be formatted with the number of the placeholder holding the notebook ID.
+const notebookSubtreeQuery = `WITH RECURSIVE subtree AS (
+		SELECT id FROM notebooks WHERE id = $%d
+		UNION ALL
+		SELECT nb.id FROM notebooks nb JOIN subtree s ON nb.parent_id = s.id
+	) SELECT id FROM subtree`
+
+// NotebookDeleteMode tells what happens to the content of a deleted notebook.
+type NotebookDeleteMode int
+
+const (
+	// NotebookDeleteCascade deletes the notebook along with its nested notebooks, moving all their notes to the trash.
+	NotebookDeleteCascade NotebookDeleteMode = iota
+	// NotebookDeleteRehome moves the notes and the nested notebooks of the notebook to its parent,
+	// or to the top level if it has none, before deleting it.
+	NotebookDeleteRehome
+)
+
+// NotebookRepository manages the workspace notebooks, which group notes hierarchically.
+// Every operation is scoped to the workspace of the given [Scope].
+type NotebookRepository interface {
+	List(ctx context.Context, scope Scope) ([]models.Notebook, error)                              // lists every notebook of the workspace by name, with how many notes out of the trash each one has
+	ReadOne(ctx context.Context, scope Scope, id int) (*models.Notebook, error)                    // returns [ErrNotebookNotFound] if the notebook does not exist or is not in the workspace
+	Create(ctx context.Context, scope Scope, name string, parentID *int) (*models.Notebook, error) // creates a notebook at the top level or nested in the parent. Returns [ErrDuplicatedNotebook] if the parent already has a notebook with the name
+	Rename(ctx context.Context, scope Scope, id int, name string) error                            // returns [ErrDuplicatedNotebook] if the parent already has a notebook with the name
+	Move(ctx context.Context, scope Scope, id int, parentID *int) error                            // moves a notebook into another one, or to the top level if parentID is nil. Returns [ErrNotebookCycle] if the parent is the notebook itself or one nested in it
+	Delete(ctx context.Context, scope Scope, id int, mode NotebookDeleteMode) error                // deletes a notebook, handling its content according to the mode
+}
+
+type notebookRepo struct {
+	db *pgxpool.Pool
+}
+
+func NewNotebookRepo(db *pgxpool.Pool) NotebookRepository {
+	return &notebookRepo{db: db}
+}
+
+// notebookError converts the errors of the statements changing a notebook name or parent.
+func notebookError(err error) error {
+	if strings.Contains(err.Error(), "violates unique constraint") {
+		return ErrDuplicatedNotebook
+	}
+	return errs.NewRepoError(err)
+}
+
+func (r *notebookRepo) List(ctx context.Context, scope Scope) ([]models.Notebook, error) {
+	query := `SELECT nb.id, nb.user_id, nb.parent_id, nb.name, nb.created_at, count(n.id)
+		FROM notebooks nb LEFT JOIN notes n ON n.notebook_id = nb.id AND n.deleted_at IS NULL
+		WHERE nb.workspace_id = $1
+		GROUP BY nb.id
+		ORDER BY nb.name`
+	rows, err := conn(ctx, r.db).Query(ctx, query, scope.WorkspaceID)
+	if err != nil {
+		return nil, errs.NewRepoError(err)
+	}
+	defer rows.Close()
+
+	var notebooks []models.Notebook
+	for rows.Next() {
+		var nb models.Notebook
+		if err := rows.Scan(&nb.ID, &nb.UserID, &nb.ParentID, &nb.Name, &nb.CreatedAt, &nb.NoteCount); err != nil {
+			return nil, errs.NewRepoError(err)
+		}
+		notebooks = append(notebooks, nb)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, errs.NewRepoError(err)
+	}
+	return notebooks, nil
+}
+
+func (r *notebookRepo) ReadOne(ctx context.Context, scope Scope, id int) (*models.Notebook, error) {
+	var nb models.Notebook
+	err := conn(ctx, r.db).QueryRow(
+		ctx,
+		"SELECT id, user_id, parent_id, name, created_at FROM notebooks WHERE id = $1 AND workspace_id = $2",
+		id, scope.WorkspaceID,
+	).Scan(&nb.ID, &nb.UserID, &nb.ParentID, &nb.Name, &nb.CreatedAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrNotebookNotFound
+	}
+	if err != nil {
+		return nil, errs.NewRepoError(err)
+	}
+	return &nb, nil
+}
+
+func (r *notebookRepo) Create(ctx context.Context, scope Scope, name string, parentID *int) (*models.Notebook, error) {
+	if parentID != nil {
+		if _, err := r.ReadOne(ctx, scope, *parentID); err != nil {
+			return nil, err
+		}
+	}
+
+	var nb models.Notebook
+	err := conn(ctx, r.db).QueryRow(
+		ctx,
+		"INSERT INTO notebooks (user_id, workspace_id, parent_id, name) VALUES ($1, $2, $3, $4) RETURNING id, user_id, parent_id, name, created_at",
+		scope.UserID, scope.WorkspaceID, parentID, name,
+	).Scan(&nb.ID, &nb.UserID, &nb.ParentID, &nb.Name, &nb.CreatedAt)
+	if err != nil {
+		return nil, notebookError(err)
+	}
+	return &nb, nil
+}
+
+func (r *notebookRepo) Rename(ctx context.Context, scope Scope, id int, name string) error {
+	tag, err := conn(ctx, r.db).Exec(ctx, "UPDATE notebooks SET name = $1 WHERE id = $2 AND workspace_id = $3", name, id, scope.WorkspaceID)
+	if err != nil {
+		return notebookError(err)
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrNotebookNotFound
+	}
+	return nil
+}
+
+func (r *notebookRepo) Move(ctx context.Context, scope Scope, id int, parentID *int) error {
+	tx, err := conn(ctx, r.db).Begin(ctx)
+	if err != nil {
+		return errs.NewRepoError(err)
+	}
+	defer tx.Rollback(ctx)
+
+	// locks the notebooks so concurrent moves cannot create a cycle
+	var count int64
+	err = tx.QueryRow(
+		ctx,
+		"SELECT count(*) FROM (SELECT id FROM notebooks WHERE workspace_id = $1 AND (id = $2 OR id = $3) FOR UPDATE) nb",
+		scope.WorkspaceID, id, parentID,
+	).Scan(&count)
+	if err != nil {
+		return errs.NewRepoError(err)
+	}
+	expected := int64(1)
+	if parentID != nil && *parentID != id {
+		expected = 2
+	}
+	if count != expected {
+		return ErrNotebookNotFound
+	}
+
+	if parentID != nil {
+		var cycle bool
+		err = tx.QueryRow(
+			ctx,
+			fmt.Sprintf("SELECT $2::bigint IN (%s)", fmt.Sprintf(notebookSubtreeQuery, 1)),
+			id, *parentID,
+		).Scan(&cycle)
+		if err != nil {
+			return errs.NewRepoError(err)
+		}
+		if cycle {
+			return ErrNotebookCycle
+		}
+	}
+
+	if _, err := tx.Exec(ctx, "UPDATE notebooks SET parent_id = $1 WHERE id = $2", parentID, id); err != nil {
+		return notebookError(err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return errs.NewRepoError(err)
+	}
+	return nil
+}
+
+func (r *notebookRepo) Delete(ctx context.Context, scope Scope, id int, mode NotebookDeleteMode) error {
+	tx, err := conn(ctx, r.db).Begin(ctx)
+	if err != nil {
+		return errs.NewRepoError(err)
+	}
+	defer tx.Rollback(ctx)
+
+	var nb models.Notebook
+	err = tx.QueryRow(
+		ctx,
+		"SELECT id, parent_id FROM notebooks WHERE id = $1 AND workspace_id = $2 FOR UPDATE",
+		id, scope.WorkspaceID,
+	).Scan(&nb.ID, &nb.ParentID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return ErrNotebookNotFound
+	}
+	if err != nil {
+		return errs.NewRepoError(err)
+	}
+
+	switch mode {
+	case NotebookDeleteCascade:
+		// the nested notebooks are deleted by the foreign key, but their notes would be left
+		// behind, so they go to the trash from where they can still be restored
+		_, err = tx.Exec(
+			ctx,
+			fmt.Sprintf(
+				"UPDATE notes SET deleted_at = CURRENT_TIMESTAMP WHERE deleted_at IS NULL AND notebook_id IN (%s)",
+				fmt.Sprintf(notebookSubtreeQuery, 1),
+			),
+			id,
+		)
+		if err != nil {
+			return errs.NewRepoError(err)
+		}
+
+	case NotebookDeleteRehome:
+		if _, err := tx.Exec(ctx, "UPDATE notes SET notebook_id = $1 WHERE notebook_id = $2", nb.ParentID, id); err != nil {
+			return errs.NewRepoError(err)
+		}
+		if _, err := tx.Exec(ctx, "UPDATE notebooks SET parent_id = $1 WHERE parent_id = $2", nb.ParentID, id); err != nil {
+			return notebookError(err)
+		}
+
+	default:
+		return errs.NewRepoError(fmt.Errorf("invalid notebook delete mode: %d", mode))
+	}
+
+	if _, err := tx.Exec(ctx, "DELETE FROM notebooks WHERE id = $1", id); err != nil {
+		return errs.NewRepoError(err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return errs.NewRepoError(err)
+	}
+	return nil
+}
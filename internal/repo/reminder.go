@@ -0,0 +1,199 @@
+package repo
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/LeandroDeJesus-S/quicknote/internal/errs"
+	"github.com/LeandroDeJesus-S/quicknote/internal/models"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// ReminderInput is the reminder set on a note.
+type ReminderInput struct {
+	At         time.Time // the first time the reminder is sent
+	Timezone   string    // the IANA time zone the reminder was set in, which its recurrences follow
+	Recurrence models.Recurrence
+}
+
+// ReminderRepository manages the reminders of the notes, which are mailed to the user who set them.
+type ReminderRepository interface {
+	Set(ctx context.Context, scope Scope, noteID int, reminder *ReminderInput) error                               // sets the reminder of a note of the workspace for the user, or removes it if nil. Returns [ErrNoteNotFound] if the note is not in the workspace
+	DeliverDue(ctx context.Context, now time.Time, limit int, deliver func(models.DueReminder) error) (int, error) // delivers up to limit reminders due by now and returns how many were handled, see [reminderRepo.DeliverDue]
+	ListForUser(ctx context.Context, userID int64) ([]models.Note, error)                                          // lists the notes with a reminder the user can read, in their workspaces or shared with them, the first reminded first
+}
+
+type reminderRepo struct {
+	db *pgxpool.Pool
+}
+
+func NewReminderRepo(db *pgxpool.Pool) ReminderRepository {
+	return &reminderRepo{db: db}
+}
+
+func (r *reminderRepo) Set(ctx context.Context, scope Scope, noteID int, reminder *ReminderInput) error {
+	query := `UPDATE notes SET remind_at = NULL, remind_timezone = DEFAULT, remind_recurrence = DEFAULT, remind_user_id = NULL,
+			remind_next_at = NULL, reminded_at = NULL, remind_claimed_until = NULL
+		WHERE id = $1 AND workspace_id = $2 AND deleted_at IS NULL`
+	args := []any{noteID, scope.WorkspaceID}
+	if reminder != nil {
+		query = `UPDATE notes SET remind_at = $3, remind_timezone = $4, remind_recurrence = $5, remind_user_id = $6,
+				remind_next_at = $3, reminded_at = NULL, remind_claimed_until = NULL
+			WHERE id = $1 AND workspace_id = $2 AND deleted_at IS NULL`
+		args = append(args, reminder.At, reminder.Timezone, reminder.Recurrence, scope.UserID)
+	}
+
+	tag, err := conn(ctx, r.db).Exec(ctx, query, args...)
+	if err != nil {
+		return errs.NewRepoError(err)
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrNoteNotFound
+	}
+	return nil
+}
+
+func (r *reminderRepo) ListForUser(ctx context.Context, userID int64) ([]models.Note, error) {
+	rows, err := conn(ctx, r.db).Query(
+		ctx,
+		`SELECT `+noteColumns+` FROM notes n
+			WHERE n.remind_at IS NOT NULL AND n.deleted_at IS NULL AND (
+				n.workspace_id IN (SELECT m.workspace_id FROM workspace_members m WHERE m.user_id = $1)
+				OR n.id IN (SELECT s.note_id FROM note_shares s WHERE s.user_id = $1)
+			)
+			ORDER BY n.remind_at, n.id`,
+		userID,
+	)
+	if err != nil {
+		return nil, errs.NewRepoError(err)
+	}
+	defer rows.Close()
+
+	var notes []models.Note
+	for rows.Next() {
+		note, err := scanNote(rows)
+		if err != nil {
+			return nil, err
+		}
+		notes = append(notes, *note)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, errs.NewRepoError(err)
+	}
+	return notes, nil
+}
+
+// reminderClaim is how long a reminder stays claimed by the instance delivering it, see [reminderRepo.DeliverDue].
+const reminderClaim = 10 * time.Minute
+
+// DeliverDue calls deliver with the reminders due by now, the most overdue first, and marks the ones delivered
+// without an error as sent: a recurring reminder is moved on to its next occurrence after now, skipping the ones
+// missed, and the others are done. The reminders failing to be delivered are left due, to be delivered again later.
+//
+// The reminders are claimed for [reminderClaim] before being delivered, outside of any transaction, and the ones
+// claimed elsewhere are skipped, so several instances never deliver the same reminder at once. A reminder still
+// claimed once delivered is marked as sent, unless it was changed meanwhile, and a claim running out without that,
+// as when the instance stopped, has the reminder delivered again. The reminders of users who can no longer read
+// the note are marked as sent without being delivered.
+func (r *reminderRepo) DeliverDue(ctx context.Context, now time.Time, limit int, deliver func(models.DueReminder) error) (int, error) {
+	claimedUntil := now.Add(reminderClaim).Truncate(time.Microsecond)
+	rows, err := conn(ctx, r.db).Query(
+		ctx,
+		`WITH claimed AS (
+				UPDATE notes SET remind_claimed_until = $3
+				WHERE id IN (
+					SELECT id FROM notes
+					WHERE remind_next_at <= $1 AND deleted_at IS NULL AND (remind_claimed_until IS NULL OR remind_claimed_until <= $1)
+					ORDER BY remind_next_at
+					LIMIT $2
+					FOR UPDATE SKIP LOCKED
+				)
+				RETURNING id, title, remind_at, remind_next_at, remind_timezone, remind_recurrence, remind_user_id, workspace_id
+			)
+			SELECT n.id, n.title, n.remind_at, n.remind_next_at, n.remind_timezone, n.remind_recurrence, u.email,
+				COALESCE(u.active, FALSE) AND (
+					EXISTS (SELECT 1 FROM workspace_members m WHERE m.workspace_id = n.workspace_id AND m.user_id = u.id)
+					OR EXISTS (SELECT 1 FROM note_shares s WHERE s.note_id = n.id AND s.user_id = u.id)
+				)
+			FROM claimed n LEFT JOIN users u ON u.id = n.remind_user_id
+			ORDER BY n.remind_next_at`,
+		now, limit, claimedUntil,
+	)
+	if err != nil {
+		return 0, errs.NewRepoError(err)
+	}
+
+	type dueReminder struct {
+		models.DueReminder
+		allowed bool
+	}
+	var due []dueReminder
+	for rows.Next() {
+		var d dueReminder
+		if err := rows.Scan(&d.NoteID, &d.Title, &d.RemindAt, &d.DueAt, &d.Timezone, &d.Recurrence, &d.Email, &d.allowed); err != nil {
+			rows.Close()
+			return 0, errs.NewRepoError(err)
+		}
+		due = append(due, d)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, errs.NewRepoError(err)
+	}
+
+	for _, d := range due {
+		if d.allowed {
+			if err := deliver(d.DueReminder); err != nil {
+				slog.Error("failed to deliver reminder", "err", err, "note_id", d.NoteID.Int)
+				if err := r.release(ctx, d.DueReminder, claimedUntil); err != nil {
+					return 0, err
+				}
+				continue
+			}
+		}
+		if err := r.markSent(ctx, d.DueReminder, claimedUntil, now); err != nil {
+			return 0, err
+		}
+	}
+	return len(due), nil
+}
+
+// release gives up the claim on a reminder failing to be delivered, which is left due to be delivered again.
+func (r *reminderRepo) release(ctx context.Context, reminder models.DueReminder, claimedUntil time.Time) error {
+	_, err := conn(ctx, r.db).Exec(
+		ctx,
+		"UPDATE notes SET remind_claimed_until = NULL WHERE id = $1 AND remind_claimed_until = $2",
+		reminder.NoteID, claimedUntil,
+	)
+	if err != nil {
+		return errs.NewRepoError(err)
+	}
+	return nil
+}
+
+// markSent moves a recurring reminder on to its next occurrence after now, counted from its first one
+// in the time zone it was set in, or marks a reminder that doesn't recur as done. A reminder which is no
+// longer claimed until claimedUntil or due at the same time, as when it was changed meanwhile, is left as is.
+func (r *reminderRepo) markSent(ctx context.Context, reminder models.DueReminder, claimedUntil, now time.Time) error {
+	loc, err := time.LoadLocation(reminder.Timezone.String)
+	if err != nil {
+		loc = time.UTC
+	}
+
+	var nextAt *time.Time
+	if next, ok := reminder.Recurrence.Next(reminder.RemindAt.Time.In(loc), now); ok {
+		nextAt = &next
+	}
+
+	_, err = conn(ctx, r.db).Exec(
+		ctx,
+		`UPDATE notes SET remind_next_at = $2, reminded_at = $3, remind_claimed_until = NULL
+			WHERE id = $1 AND remind_claimed_until = $4 AND remind_next_at = $5`,
+		reminder.NoteID, nextAt, now, claimedUntil, reminder.DueAt,
+	)
+	if err != nil {
+		return errs.NewRepoError(err)
+	}
+	return nil
+}
@@ -0,0 +1,51 @@
+package repo
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/LeandroDeJesus-S/quicknote/internal/models"
+)
+
+func TestDeliverDueClaimsReminders(t *testing.T) {
+	db := testDB(t)
+	ctx := context.Background()
+	reminders := NewReminderRepo(db)
+
+	owner := testUser(t, db, "owner@example.com")
+	note, err := NewNoteRepo(db).Create(ctx, owner, models.NoteTypeText, "Call the bank", "", "#ffffff")
+	if err != nil {
+		t.Fatalf("creating note: %v", err)
+	}
+	id := int(note.ID.Int.Int64())
+	now := time.Now()
+	if err := reminders.Set(ctx, owner, id, &ReminderInput{At: now.Add(-time.Minute), Timezone: "UTC"}); err != nil {
+		t.Fatalf("setting reminder: %v", err)
+	}
+
+	failed, err := reminders.DeliverDue(ctx, now, 10, func(models.DueReminder) error { return errors.New("smtp down") })
+	if err != nil || failed != 1 {
+		t.Fatalf("DeliverDue() failing = %d, %v, want 1", failed, err)
+	}
+
+	var delivered, concurrent int
+	n, err := reminders.DeliverDue(ctx, now, 10, func(models.DueReminder) error {
+		delivered++
+		// another instance doesn't see the reminder while it's being delivered
+		var err error
+		concurrent, err = reminders.DeliverDue(ctx, now, 10, func(models.DueReminder) error { return nil })
+		return err
+	})
+	if err != nil || n != 1 || delivered != 1 {
+		t.Fatalf("DeliverDue() after a failure = %d, %v with %d delivered, want the reminder delivered again", n, err, delivered)
+	}
+	if concurrent != 0 {
+		t.Errorf("DeliverDue() while delivering handled %d reminders, want 0", concurrent)
+	}
+
+	if n, err := reminders.DeliverDue(ctx, now.Add(time.Hour), 10, func(models.DueReminder) error { return nil }); err != nil || n != 0 {
+		t.Errorf("DeliverDue() once sent = %d, %v, want 0", n, err)
+	}
+}
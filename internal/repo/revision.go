@@ -0,0 +1,116 @@
+package repo
+
+import (
+	"context"
+	"errors"
+
+	"github.com/LeandroDeJesus-S/quicknote/internal/errs"
+	"github.com/LeandroDeJesus-S/quicknote/internal/models"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+var ErrRevisionNotFound = errs.NewRepoError(errors.New("revision not found"))
+
+// revisionColumns are the columns selected for a revision, which must be aliased as "r"
+// and joined with its author as "u".
+const revisionColumns = "r.id, r.note_id, r.user_id, u.email, r.title, r.content, r.color, r.created_at"
+
+// revisionScanFields returns the scan destinations of [revisionColumns].
+func revisionScanFields(rev *models.NoteRevision) []any {
+	return []any{&rev.ID, &rev.NoteID, &rev.UserID, &rev.AuthorEmail, &rev.Title, &rev.Content, &rev.Color, &rev.CreatedAt}
+}
+
+// recordRevision saves the current state of a note as a new revision by the author.
+// It must run in the same transaction that changed the note.
+func recordRevision(ctx context.Context, tx pgx.Tx, noteID, authorID int64) error {
+	_, err := tx.Exec(
+		ctx,
+		`INSERT INTO note_revisions (note_id, user_id, title, content, color)
+			SELECT id, $2::bigint, title, content, color FROM notes WHERE id = $1`,
+		noteID, authorID,
+	)
+	if err != nil {
+		return errs.NewRepoError(err)
+	}
+	return nil
+}
+
+// RevisionRepository reads the revision history of the notes, which [Noter] records on every save.
+// Every operation is scoped to the notes of the workspace of the given [Scope].
+type RevisionRepository interface {
+	List(ctx context.Context, scope Scope, noteID int) ([]models.NoteRevision, error) // lists the revisions of a note, newest first. Returns [ErrNoteNotFound] if the note is not in the workspace
+	Restore(ctx context.Context, scope Scope, noteID, id int) (*models.Note, error)   // brings a note back to a revision, which is recorded as a new revision by the user. Returns [ErrRevisionNotFound] if the revision is not of a note in the workspace
+}
+
+type revisionRepo struct {
+	db *pgxpool.Pool
+}
+
+func NewRevisionRepo(db *pgxpool.Pool) RevisionRepository {
+	return &revisionRepo{db: db}
+}
+
+func (r *revisionRepo) List(ctx context.Context, scope Scope, noteID int) ([]models.NoteRevision, error) {
+	query := `SELECT ` + revisionColumns + `
+		FROM note_revisions r LEFT JOIN users u ON u.id = r.user_id
+		WHERE r.note_id = $1
+			AND EXISTS (SELECT 1 FROM notes n WHERE n.id = r.note_id AND n.workspace_id = $2 AND n.deleted_at IS NULL)
+		ORDER BY r.id DESC`
+	rows, err := conn(ctx, r.db).Query(ctx, query, noteID, scope.WorkspaceID)
+	if err != nil {
+		return nil, errs.NewRepoError(err)
+	}
+	defer rows.Close()
+
+	var revisions []models.NoteRevision
+	for rows.Next() {
+		var rev models.NoteRevision
+		if err := rows.Scan(revisionScanFields(&rev)...); err != nil {
+			return nil, errs.NewRepoError(err)
+		}
+		revisions = append(revisions, rev)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, errs.NewRepoError(err)
+	}
+
+	// every note has at least the revision recorded when it was created
+	if len(revisions) == 0 {
+		return nil, ErrNoteNotFound
+	}
+	return revisions, nil
+}
+
+func (r *revisionRepo) Restore(ctx context.Context, scope Scope, noteID, id int) (*models.Note, error) {
+	tx, err := conn(ctx, r.db).Begin(ctx)
+	if err != nil {
+		return nil, errs.NewRepoError(err)
+	}
+	defer tx.Rollback(ctx)
+
+	row := tx.QueryRow(
+		ctx,
+		`UPDATE notes n SET title = r.title, content = r.content, color = r.color, version = n.version + 1, updated_at = CURRENT_TIMESTAMP
+			FROM note_revisions r
+			WHERE r.id = $1 AND r.note_id = n.id AND n.id = $2 AND n.workspace_id = $3 AND n.deleted_at IS NULL
+			RETURNING `+noteColumns,
+		id, noteID, scope.WorkspaceID,
+	)
+	note, err := scanNote(row)
+	if errors.Is(err, ErrNoteNotFound) {
+		return nil, ErrRevisionNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if err := recordRevision(ctx, tx, note.ID.Int.Int64(), scope.UserID); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, errs.NewRepoError(err)
+	}
+	return note, nil
+}
@@ -0,0 +1,117 @@
+package repo
+
+import (
+	"strings"
+	"unicode"
+)
+
+const (
+	// HeadlineStartSel and HeadlineStopSel delimit the highlighted matches in the search
+	// headlines. They are plain text so the headlines can be escaped before being turned into HTML.
+	HeadlineStartSel = "[[mark]]"
+	HeadlineStopSel  = "[[/mark]]"
+
+	// searchConfig is the text search configuration used to index and query notes.
+	searchConfig = "simple"
+	// searchResultsLimit is the maximum number of notes a search returns.
+	searchResultsLimit = 100
+)
+
+var (
+	// headlineOptions are the ts_headline options used for the content snippets.
+	headlineOptions = `StartSel="` + HeadlineStartSel + `", StopSel="` + HeadlineStopSel + `", MaxFragments=2, MaxWords=25, MinWords=8, FragmentDelimiter=" … "`
+	// titleHeadlineOptions are the ts_headline options used for the titles, which are kept whole.
+	titleHeadlineOptions = `StartSel="` + HeadlineStartSel + `", StopSel="` + HeadlineStopSel + `", HighlightAll=true`
+)
+
+// searchTerm is a single term of a search: a word or a "quoted phrase", possibly negated.
+type searchTerm struct {
+	lexemes []string
+	phrase  bool
+	negated bool
+}
+
+// tsquery returns the term as a to_tsquery expression.
+func (t searchTerm) tsquery() string {
+	var expr string
+	switch {
+	case len(t.lexemes) == 1 && !t.phrase && !t.negated:
+		// single words match as prefixes so partially typed words find results
+		expr = t.lexemes[0] + ":*"
+	case len(t.lexemes) == 1:
+		expr = t.lexemes[0]
+	default:
+		expr = "(" + strings.Join(t.lexemes, " <-> ") + ")"
+	}
+
+	if t.negated {
+		return "!" + expr
+	}
+	return expr
+}
+
+// ParseSearchQuery converts a search typed by the user into a to_tsquery expression.
+//
+// Words are combined with AND, "quoted phrases" must match their words in sequence and a
+// leading minus negates a word or a phrase, as in: meeting "action items" -draft.
+// Every character other than letters and digits is dropped, so the output is always a valid
+// tsquery. It returns an empty string if the search has no usable term.
+func ParseSearchQuery(q string) string {
+	var terms []string
+	for _, term := range tokenizeSearch(q) {
+		if len(term.lexemes) > 0 {
+			terms = append(terms, term.tsquery())
+		}
+	}
+	return strings.Join(terms, " & ")
+}
+
+// tokenizeSearch splits a search into its terms.
+func tokenizeSearch(q string) []searchTerm {
+	var terms []searchTerm
+	runes := []rune(q)
+
+	for i := 0; i < len(runes); {
+		if unicode.IsSpace(runes[i]) {
+			i++
+			continue
+		}
+
+		var term searchTerm
+		if runes[i] == '-' {
+			term.negated = true
+			i++
+		}
+
+		var raw string
+		if i < len(runes) && runes[i] == '"' {
+			term.phrase = true
+			end := i + 1
+			for end < len(runes) && runes[end] != '"' {
+				end++
+			}
+			raw = string(runes[i+1 : end])
+			i = end + 1 // skips the closing quote, if any
+		} else {
+			end := i
+			for end < len(runes) && !unicode.IsSpace(runes[end]) {
+				end++
+			}
+			raw = string(runes[i:end])
+			i = end
+		}
+
+		term.lexemes = searchLexemes(raw)
+		// words joined by punctuation, like e-mail, are searched as a phrase
+		term.phrase = term.phrase || len(term.lexemes) > 1
+		terms = append(terms, term)
+	}
+	return terms
+}
+
+// searchLexemes returns the lower-cased runs of letters and digits in s.
+func searchLexemes(s string) []string {
+	return strings.FieldsFunc(strings.ToLower(s), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
@@ -0,0 +1,36 @@
+package repo
+
+import "testing"
+
+func TestParseSearchQuery(t *testing.T) {
+	tests := []struct {
+		name  string
+		query string
+		want  string
+	}{
+		{"empty", "", ""},
+		{"only spaces", "  \t\n ", ""},
+		{"only punctuation", `!@# "" - ;:`, ""},
+		{"word as prefix", "meet", "meet:*"},
+		{"words combined with and", "meeting notes", "meeting:* & notes:*"},
+		{"lower-cased", "Meeting", "meeting:*"},
+		{"accented letters kept", "reunião", "reunião:*"},
+		{"phrase", `"action items"`, "(action <-> items)"},
+		{"single word phrase is exact", `"draft"`, "draft"},
+		{"unterminated phrase", `"action items`, "(action <-> items)"},
+		{"empty phrase dropped", `meeting ""`, "meeting:*"},
+		{"negated word is exact", "-draft", "!draft"},
+		{"negated phrase", `-"action items"`, "!(action <-> items)"},
+		{"lone minus dropped", "meeting -", "meeting:*"},
+		{"minus inside a word", "e-mail", "(e <-> mail)"},
+		{"tsquery operators dropped", "a&b | !c:*", "(a <-> b) & c:*"},
+		{"mixed", `meeting "action items" -draft`, "meeting:* & (action <-> items) & !draft"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ParseSearchQuery(tt.query); got != tt.want {
+				t.Errorf("ParseSearchQuery(%q) = %q, want %q", tt.query, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,159 @@
+package repo
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/LeandroDeJesus-S/quicknote/internal/errs"
+	"github.com/LeandroDeJesus-S/quicknote/internal/models"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+var ErrShareLinkNotFound = errs.NewRepoError(errors.New("share link not found"))
+
+// shareLinkColumns are the columns scanned by [shareLinkScanFields], where s is the share_links table.
+const shareLinkColumns = "s.id, s.note_id, s.user_id, s.token_hash, s.password_hash, s.expires_at, s.view_count, s.created_at"
+
+func shareLinkScanFields(s *models.ShareLink) []any {
+	return []any{&s.ID, &s.NoteID, &s.UserID, &s.TokenHash, &s.PasswordHash, &s.ExpiresAt, &s.ViewCount, &s.CreatedAt}
+}
+
+// ShareLinkRepository stores the public links to the notes. Only the token hash is ever persisted.
+// The links are managed within the workspace of the given [Scope], and the user creating one is recorded along with it.
+type ShareLinkRepository interface {
+	Create(ctx context.Context, scope Scope, noteID int, tokenHash string, passwordHash *string, expiresAt *time.Time) (*models.ShareLink, error) // creates a link to a note, nil password hash and expiresAt mean it has no password and never expires. Returns [ErrNoteNotFound] if the note is not in the workspace
+	List(ctx context.Context, scope Scope, noteID int) ([]models.ShareLink, error)                                                                // lists the links to a note of the workspace, newest first
+	FindByHash(ctx context.Context, tokenHash string) (*models.ShareLink, error)                                                                  // returns [ErrShareLinkNotFound] if there is no link with the hash, it expired or its note is in the trash
+	CountView(ctx context.Context, id int64) error                                                                                                // adds one to the views of a link
+	RecordFailure(ctx context.Context, id int64, window time.Duration, maxFailures int, lockout time.Duration) (locked bool, err error)           // counts a wrong password typed to unlock a link, starting over when the first one counted is older than window; reaching maxFailures locks the link for lockout, reporting so. Returns [ErrShareLinkNotFound] if there is no link with the id
+	ResetFailures(ctx context.Context, id int64) error                                                                                            // forgets the wrong passwords counted for a link, once the right one is typed
+	Delete(ctx context.Context, scope Scope, noteID, id int) error                                                                                // revokes a link, returns [ErrShareLinkNotFound] if it's not of a note of the workspace
+}
+
+type shareLinkRepo struct {
+	db *pgxpool.Pool
+}
+
+func NewShareLinkRepo(db *pgxpool.Pool) ShareLinkRepository {
+	return &shareLinkRepo{db: db}
+}
+
+func (r *shareLinkRepo) Create(ctx context.Context, scope Scope, noteID int, tokenHash string, passwordHash *string, expiresAt *time.Time) (*models.ShareLink, error) {
+	var s models.ShareLink
+	err := r.db.QueryRow(
+		ctx,
+		`INSERT INTO share_links AS s (note_id, user_id, token_hash, password_hash, expires_at)
+			SELECT n.id, $6::bigint, $3::text, $4::text, $5::timestamp FROM notes n WHERE n.id = $1 AND n.workspace_id = $2 AND n.deleted_at IS NULL
+			RETURNING `+shareLinkColumns,
+		noteID, scope.WorkspaceID, tokenHash, passwordHash, expiresAt, scope.UserID,
+	).Scan(shareLinkScanFields(&s)...)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrNoteNotFound
+	}
+	if err != nil {
+		return nil, errs.NewRepoError(err)
+	}
+	return &s, nil
+}
+
+func (r *shareLinkRepo) List(ctx context.Context, scope Scope, noteID int) ([]models.ShareLink, error) {
+	rows, err := r.db.Query(
+		ctx,
+		`SELECT `+shareLinkColumns+` FROM share_links s JOIN notes n ON n.id = s.note_id
+			WHERE s.note_id = $1 AND n.workspace_id = $2
+			ORDER BY s.created_at DESC`,
+		noteID, scope.WorkspaceID,
+	)
+	if err != nil {
+		return nil, errs.NewRepoError(err)
+	}
+	defer rows.Close()
+
+	var links []models.ShareLink
+	for rows.Next() {
+		var s models.ShareLink
+		if err := rows.Scan(shareLinkScanFields(&s)...); err != nil {
+			return nil, errs.NewRepoError(err)
+		}
+		links = append(links, s)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, errs.NewRepoError(err)
+	}
+	return links, nil
+}
+
+func (r *shareLinkRepo) FindByHash(ctx context.Context, tokenHash string) (*models.ShareLink, error) {
+	var s models.ShareLink
+	err := r.db.QueryRow(
+		ctx,
+		`SELECT `+shareLinkColumns+`, n.workspace_id, COALESCE(s.locked_until > CURRENT_TIMESTAMP, FALSE)
+			FROM share_links s JOIN notes n ON n.id = s.note_id
+			WHERE s.token_hash = $1 AND (s.expires_at IS NULL OR s.expires_at > now()) AND n.deleted_at IS NULL`,
+		tokenHash,
+	).Scan(append(shareLinkScanFields(&s), &s.WorkspaceID, &s.Locked)...)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrShareLinkNotFound
+	}
+	if err != nil {
+		return nil, errs.NewRepoError(err)
+	}
+	return &s, nil
+}
+
+func (r *shareLinkRepo) CountView(ctx context.Context, id int64) error {
+	if _, err := r.db.Exec(ctx, "UPDATE share_links SET view_count = view_count + 1 WHERE id = $1", id); err != nil {
+		return errs.NewRepoError(err)
+	}
+	return nil
+}
+
+func (r *shareLinkRepo) RecordFailure(ctx context.Context, id int64, window time.Duration, maxFailures int, lockout time.Duration) (bool, error) {
+	// the count is read locked and updated in a single statement, so concurrent failures are all counted
+	query := `WITH f AS (
+			SELECT CASE WHEN first_failed_at IS NULL OR first_failed_at + make_interval(secs => $2) < CURRENT_TIMESTAMP
+				THEN 1 ELSE failed_attempts + 1 END AS n
+			FROM share_links WHERE id = $1 FOR UPDATE
+		)
+		UPDATE share_links SET
+			failed_attempts = CASE WHEN f.n >= $3 THEN 0 ELSE f.n END,
+			first_failed_at = CASE WHEN f.n >= $3 THEN NULL WHEN f.n = 1 THEN CURRENT_TIMESTAMP ELSE first_failed_at END,
+			locked_until = CASE WHEN f.n >= $3 THEN CURRENT_TIMESTAMP + make_interval(secs => $4) ELSE locked_until END
+		FROM f WHERE id = $1
+		RETURNING f.n >= $3`
+
+	var locked bool
+	err := r.db.QueryRow(ctx, query, id, window.Seconds(), maxFailures, lockout.Seconds()).Scan(&locked)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return false, ErrShareLinkNotFound
+	}
+	if err != nil {
+		return false, errs.NewRepoError(err)
+	}
+	return locked, nil
+}
+
+func (r *shareLinkRepo) ResetFailures(ctx context.Context, id int64) error {
+	_, err := r.db.Exec(ctx, "UPDATE share_links SET failed_attempts = 0, first_failed_at = NULL WHERE id = $1", id)
+	if err != nil {
+		return errs.NewRepoError(err)
+	}
+	return nil
+}
+
+func (r *shareLinkRepo) Delete(ctx context.Context, scope Scope, noteID, id int) error {
+	tag, err := r.db.Exec(
+		ctx,
+		"DELETE FROM share_links s USING notes n WHERE s.id = $1 AND s.note_id = $2 AND n.id = s.note_id AND n.workspace_id = $3",
+		id, noteID, scope.WorkspaceID,
+	)
+	if err != nil {
+		return errs.NewRepoError(err)
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrShareLinkNotFound
+	}
+	return nil
+}
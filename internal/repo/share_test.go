@@ -0,0 +1,80 @@
+package repo
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/LeandroDeJesus-S/quicknote/internal/models"
+)
+
+func TestShareLinkFailuresLockOut(t *testing.T) {
+	db := testDB(t)
+	ctx := context.Background()
+	links := NewShareLinkRepo(db)
+
+	const maxFailures = 3
+	if _, err := links.RecordFailure(ctx, 1, time.Minute, maxFailures, time.Minute); !errors.Is(err, ErrShareLinkNotFound) {
+		t.Fatalf("RecordFailure() without a link error = %v, want ErrShareLinkNotFound", err)
+	}
+
+	owner := testUser(t, db, "owner@example.com")
+	note, err := NewNoteRepo(db).Create(ctx, owner, models.NoteTypeText, "Shared", "", "#ffffff")
+	if err != nil {
+		t.Fatalf("creating note: %v", err)
+	}
+	password := "password hash"
+	link, err := links.Create(ctx, owner, int(note.ID.Int.Int64()), "token hash", &password, nil)
+	if err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+	id := link.ID.Int.Int64()
+
+	fail := func(window time.Duration) bool {
+		t.Helper()
+		locked, err := links.RecordFailure(ctx, id, window, maxFailures, time.Minute)
+		if err != nil {
+			t.Fatalf("RecordFailure() error = %v", err)
+		}
+		return locked
+	}
+	locked := func() bool {
+		t.Helper()
+		link, err := links.FindByHash(ctx, "token hash")
+		if err != nil {
+			t.Fatalf("FindByHash() error = %v", err)
+		}
+		return link.Locked
+	}
+
+	// failures older than the window are not counted
+	for range maxFailures + 1 {
+		if fail(time.Nanosecond) {
+			t.Fatalf("RecordFailure() locked the link with the failures out of the window")
+		}
+	}
+
+	// the right password starts the count over
+	for range maxFailures - 1 {
+		fail(time.Minute)
+	}
+	if err := links.ResetFailures(ctx, id); err != nil {
+		t.Fatalf("ResetFailures() error = %v", err)
+	}
+	for range maxFailures - 1 {
+		if fail(time.Minute) {
+			t.Fatalf("RecordFailure() counted the failures before ResetFailures")
+		}
+	}
+	if locked() {
+		t.Fatalf("FindByHash() locked before reaching the failures")
+	}
+
+	if !fail(time.Minute) {
+		t.Fatalf("RecordFailure() didn't lock the link on failure %d", maxFailures)
+	}
+	if !locked() {
+		t.Errorf("FindByHash() not locked after too many failures")
+	}
+}
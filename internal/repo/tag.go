@@ -0,0 +1,183 @@
+package repo
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	"github.com/LeandroDeJesus-S/quicknote/internal/errs"
+	"github.com/LeandroDeJesus-S/quicknote/internal/models"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+var (
+	ErrTagNotFound   = errs.NewRepoError(errors.New("tag not found"))
+	ErrDuplicatedTag = errs.NewRepoError(errors.New("tag name already in use"))
+)
+
+// TagRepository manages the workspace tags and how they are attached to notes.
+// Every operation is scoped to the workspace of the given [Scope], and operations
+// changing several notes at once run in a single transaction.
+type TagRepository interface {
+	List(ctx context.Context, scope Scope) ([]models.Tag, error)                             // lists the workspace tags by name, with how many notes out of the trash each one has
+	SetNoteTags(ctx context.Context, scope Scope, noteID, version int, names []string) error // replaces the tags of a note as a new version of it, creating the missing ones. Returns [ErrNoteNotFound] if the note is not in the workspace, or a [NoteConflictError] if version is not zero and the note is at another version
+	Rename(ctx context.Context, scope Scope, id int, name string) error                      // returns [ErrDuplicatedTag] if the workspace already has a tag with the name
+	Merge(ctx context.Context, scope Scope, sourceIDs []int, targetID int) (int64, error)    // tags the notes of the source tags with the target, deletes the sources and returns how many notes got the target tag
+	Delete(ctx context.Context, scope Scope, id int) error                                   // deletes a tag, removing it from every note
+}
+
+type tagRepo struct {
+	db *pgxpool.Pool
+}
+
+func NewTagRepo(db *pgxpool.Pool) TagRepository {
+	return &tagRepo{db: db}
+}
+
+func (r *tagRepo) List(ctx context.Context, scope Scope) ([]models.Tag, error) {
+	query := `SELECT t.id, t.user_id, t.name, t.created_at, count(n.id)
+		FROM tags t
+		LEFT JOIN note_tags nt ON nt.tag_id = t.id
+		LEFT JOIN notes n ON n.id = nt.note_id AND n.deleted_at IS NULL
+		WHERE t.workspace_id = $1
+		GROUP BY t.id
+		ORDER BY t.name`
+	rows, err := conn(ctx, r.db).Query(ctx, query, scope.WorkspaceID)
+	if err != nil {
+		return nil, errs.NewRepoError(err)
+	}
+	defer rows.Close()
+
+	var tags []models.Tag
+	for rows.Next() {
+		var t models.Tag
+		if err := rows.Scan(&t.ID, &t.UserID, &t.Name, &t.CreatedAt, &t.NoteCount); err != nil {
+			return nil, errs.NewRepoError(err)
+		}
+		tags = append(tags, t)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, errs.NewRepoError(err)
+	}
+	return tags, nil
+}
+
+func (r *tagRepo) SetNoteTags(ctx context.Context, scope Scope, noteID, version int, names []string) error {
+	tx, err := conn(ctx, r.db).Begin(ctx)
+	if err != nil {
+		return errs.NewRepoError(err)
+	}
+	defer tx.Rollback(ctx)
+
+	if _, err := bumpNoteVersion(ctx, tx, scope, noteID, version); err != nil {
+		return err
+	}
+
+	names = uniqueStrings(names)
+	if names == nil {
+		names = []string{}
+	}
+
+	_, err = tx.Exec(
+		ctx,
+		"INSERT INTO tags (user_id, workspace_id, name) SELECT $1::bigint, $2::bigint, unnest($3::text[]) ON CONFLICT (workspace_id, name) DO NOTHING",
+		scope.UserID, scope.WorkspaceID, names,
+	)
+	if err != nil {
+		return errs.NewRepoError(err)
+	}
+
+	_, err = tx.Exec(
+		ctx,
+		`DELETE FROM note_tags nt USING tags t
+			WHERE nt.tag_id = t.id AND nt.note_id = $1 AND NOT (t.name = ANY($2))`,
+		noteID, names,
+	)
+	if err != nil {
+		return errs.NewRepoError(err)
+	}
+
+	_, err = tx.Exec(
+		ctx,
+		`INSERT INTO note_tags (note_id, tag_id)
+			SELECT $1::bigint, t.id FROM tags t WHERE t.workspace_id = $2 AND t.name = ANY($3)
+			ON CONFLICT DO NOTHING`,
+		noteID, scope.WorkspaceID, names,
+	)
+	if err != nil {
+		return errs.NewRepoError(err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return errs.NewRepoError(err)
+	}
+	return nil
+}
+
+func (r *tagRepo) Rename(ctx context.Context, scope Scope, id int, name string) error {
+	tag, err := conn(ctx, r.db).Exec(ctx, "UPDATE tags SET name = $1 WHERE id = $2 AND workspace_id = $3", name, id, scope.WorkspaceID)
+	if err != nil {
+		if strings.Contains(err.Error(), "violates unique constraint") {
+			return ErrDuplicatedTag
+		}
+		return errs.NewRepoError(err)
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrTagNotFound
+	}
+	return nil
+}
+
+func (r *tagRepo) Merge(ctx context.Context, scope Scope, sourceIDs []int, targetID int) (int64, error) {
+	tx, err := conn(ctx, r.db).Begin(ctx)
+	if err != nil {
+		return 0, errs.NewRepoError(err)
+	}
+	defer tx.Rollback(ctx)
+
+	var id int64
+	err = tx.QueryRow(ctx, "SELECT id FROM tags WHERE id = $1 AND workspace_id = $2 FOR UPDATE", targetID, scope.WorkspaceID).Scan(&id)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return 0, ErrTagNotFound
+	}
+	if err != nil {
+		return 0, errs.NewRepoError(err)
+	}
+
+	moved, err := tx.Exec(
+		ctx,
+		`INSERT INTO note_tags (note_id, tag_id)
+			SELECT DISTINCT nt.note_id, $3::bigint FROM note_tags nt JOIN tags t ON t.id = nt.tag_id
+			WHERE t.workspace_id = $1 AND t.id = ANY($2) AND t.id <> $3
+			ON CONFLICT DO NOTHING`,
+		scope.WorkspaceID, sourceIDs, targetID,
+	)
+	if err != nil {
+		return 0, errs.NewRepoError(err)
+	}
+
+	deleted, err := tx.Exec(ctx, "DELETE FROM tags WHERE workspace_id = $1 AND id = ANY($2) AND id <> $3", scope.WorkspaceID, sourceIDs, targetID)
+	if err != nil {
+		return 0, errs.NewRepoError(err)
+	}
+	if deleted.RowsAffected() == 0 {
+		return 0, ErrTagNotFound
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return 0, errs.NewRepoError(err)
+	}
+	return moved.RowsAffected(), nil
+}
+
+func (r *tagRepo) Delete(ctx context.Context, scope Scope, id int) error {
+	tag, err := conn(ctx, r.db).Exec(ctx, "DELETE FROM tags WHERE id = $1 AND workspace_id = $2", id, scope.WorkspaceID)
+	if err != nil {
+		return errs.NewRepoError(err)
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrTagNotFound
+	}
+	return nil
+}
@@ -0,0 +1,180 @@
+package repo
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/LeandroDeJesus-S/quicknote/internal/errs"
+	"github.com/LeandroDeJesus-S/quicknote/internal/models"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+var (
+	ErrTOTPNotFound         = errs.NewRepoError(errors.New("two-factor authentication not set up"))
+	ErrTOTPEnabled          = errs.NewRepoError(errors.New("two-factor authentication already enabled"))
+	ErrTOTPStepUsed         = errs.NewRepoError(errors.New("code already used"))
+	ErrRecoveryCodeNotFound = errs.NewRepoError(errors.New("recovery code not found"))
+)
+
+// TOTPRepository stores the one-time password secrets of the users and their recovery codes.
+// The secrets are stored as given, already encrypted, and only the recovery code hashes are ever persisted.
+type TOTPRepository interface {
+	Find(ctx context.Context, userID int64) (*models.UserTOTP, error)                                                                       // returns [ErrTOTPNotFound] if the user never set it up
+	Begin(ctx context.Context, userID int64, secret string) error                                                                           // stores a new secret to be confirmed, returns [ErrTOTPEnabled] if the user already enabled one
+	Enable(ctx context.Context, userID int64, step int64, codeHashes []string) error                                                        // enables the secret confirmed with the code of step, replacing the recovery codes; returns [ErrTOTPNotFound] if there is no secret to be confirmed
+	UseStep(ctx context.Context, userID int64, step int64) error                                                                            // records the step of a code used to sign in, returns [ErrTOTPStepUsed] if it's not after the last one
+	UseRecoveryCode(ctx context.Context, userID int64, codeHash string) error                                                               // marks a recovery code as used, returns [ErrRecoveryCodeNotFound] if there is no unused code with the hash
+	Disable(ctx context.Context, userID int64) error                                                                                        // deletes the secret and the recovery codes of the user, returns [ErrTOTPNotFound] if there is none
+	RecordFailure(ctx context.Context, userID int64, window time.Duration, maxFailures int, lockout time.Duration) (locked bool, err error) // counts a wrong code typed to sign in, starting over when the first one counted is older than window; reaching maxFailures locks the user out for lockout, reporting so. Returns [ErrTOTPNotFound] if the user has no secret
+	ResetFailures(ctx context.Context, userID int64) error                                                                                  // forgets the wrong codes counted for the user, once a right one is typed
+}
+
+type totpRepo struct {
+	db *pgxpool.Pool
+}
+
+func NewTOTPRepo(db *pgxpool.Pool) TOTPRepository {
+	return &totpRepo{db: db}
+}
+
+func (r *totpRepo) Find(ctx context.Context, userID int64) (*models.UserTOTP, error) {
+	var t models.UserTOTP
+	query := `SELECT user_id, secret, last_used_step, enabled_at, created_at,
+			(SELECT count(*) FROM user_recovery_codes c WHERE c.user_id = t.user_id AND c.used_at IS NULL),
+			COALESCE(locked_until > CURRENT_TIMESTAMP, FALSE)
+		FROM user_totp t WHERE user_id = $1`
+	err := r.db.QueryRow(ctx, query, userID).Scan(&t.UserID, &t.Secret, &t.LastUsedStep, &t.EnabledAt, &t.CreatedAt, &t.RecoveryCodesLeft, &t.Locked)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrTOTPNotFound
+	}
+	if err != nil {
+		return nil, errs.NewRepoError(err)
+	}
+	return &t, nil
+}
+
+func (r *totpRepo) Begin(ctx context.Context, userID int64, secret string) error {
+	query := `INSERT INTO user_totp (user_id, secret) VALUES ($1, $2)
+		ON CONFLICT (user_id) DO UPDATE SET secret = EXCLUDED.secret, last_used_step = 0, created_at = CURRENT_TIMESTAMP
+		WHERE user_totp.enabled_at IS NULL`
+	tag, err := r.db.Exec(ctx, query, userID, secret)
+	if err != nil {
+		return errs.NewRepoError(err)
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrTOTPEnabled
+	}
+	return nil
+}
+
+func (r *totpRepo) Enable(ctx context.Context, userID int64, step int64, codeHashes []string) error {
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return errs.NewRepoError(err)
+	}
+	defer tx.Rollback(ctx)
+
+	tag, err := tx.Exec(
+		ctx,
+		"UPDATE user_totp SET enabled_at = CURRENT_TIMESTAMP, last_used_step = $2 WHERE user_id = $1 AND enabled_at IS NULL",
+		userID, step,
+	)
+	if err != nil {
+		return errs.NewRepoError(err)
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrTOTPNotFound
+	}
+
+	if _, err := tx.Exec(ctx, "DELETE FROM user_recovery_codes WHERE user_id = $1", userID); err != nil {
+		return errs.NewRepoError(err)
+	}
+	for _, hash := range codeHashes {
+		if _, err := tx.Exec(ctx, "INSERT INTO user_recovery_codes (user_id, code_hash) VALUES ($1, $2)", userID, hash); err != nil {
+			return errs.NewRepoError(err)
+		}
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return errs.NewRepoError(err)
+	}
+	return nil
+}
+
+func (r *totpRepo) UseStep(ctx context.Context, userID int64, step int64) error {
+	// the check and the update are a single statement, so a code can't be used by two concurrent sign-ins
+	tag, err := r.db.Exec(
+		ctx,
+		"UPDATE user_totp SET last_used_step = $2 WHERE user_id = $1 AND enabled_at IS NOT NULL AND last_used_step < $2",
+		userID, step,
+	)
+	if err != nil {
+		return errs.NewRepoError(err)
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrTOTPStepUsed
+	}
+	return nil
+}
+
+func (r *totpRepo) UseRecoveryCode(ctx context.Context, userID int64, codeHash string) error {
+	tag, err := r.db.Exec(
+		ctx,
+		"UPDATE user_recovery_codes SET used_at = CURRENT_TIMESTAMP WHERE user_id = $1 AND code_hash = $2 AND used_at IS NULL",
+		userID, codeHash,
+	)
+	if err != nil {
+		return errs.NewRepoError(err)
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrRecoveryCodeNotFound
+	}
+	return nil
+}
+
+func (r *totpRepo) Disable(ctx context.Context, userID int64) error {
+	// the recovery codes are deleted in cascade
+	tag, err := r.db.Exec(ctx, "DELETE FROM user_totp WHERE user_id = $1", userID)
+	if err != nil {
+		return errs.NewRepoError(err)
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrTOTPNotFound
+	}
+	return nil
+}
+
+func (r *totpRepo) RecordFailure(ctx context.Context, userID int64, window time.Duration, maxFailures int, lockout time.Duration) (bool, error) {
+	// the count is read locked and updated in a single statement, so concurrent failures are all counted
+	query := `WITH f AS (
+			SELECT CASE WHEN first_failed_at IS NULL OR first_failed_at + make_interval(secs => $2) < CURRENT_TIMESTAMP
+				THEN 1 ELSE failed_attempts + 1 END AS n
+			FROM user_totp WHERE user_id = $1 FOR UPDATE
+		)
+		UPDATE user_totp SET
+			failed_attempts = CASE WHEN f.n >= $3 THEN 0 ELSE f.n END,
+			first_failed_at = CASE WHEN f.n >= $3 THEN NULL WHEN f.n = 1 THEN CURRENT_TIMESTAMP ELSE first_failed_at END,
+			locked_until = CASE WHEN f.n >= $3 THEN CURRENT_TIMESTAMP + make_interval(secs => $4) ELSE locked_until END
+		FROM f WHERE user_id = $1
+		RETURNING f.n >= $3`
+
+	var locked bool
+	err := r.db.QueryRow(ctx, query, userID, window.Seconds(), maxFailures, lockout.Seconds()).Scan(&locked)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return false, ErrTOTPNotFound
+	}
+	if err != nil {
+		return false, errs.NewRepoError(err)
+	}
+	return locked, nil
+}
+
+func (r *totpRepo) ResetFailures(ctx context.Context, userID int64) error {
+	_, err := r.db.Exec(ctx, "UPDATE user_totp SET failed_attempts = 0, first_failed_at = NULL WHERE user_id = $1", userID)
+	if err != nil {
+		return errs.NewRepoError(err)
+	}
+	return nil
+}
@@ -0,0 +1,73 @@
+package repo
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestTOTPFailuresLockOut(t *testing.T) {
+	db := testDB(t)
+	ctx := context.Background()
+	totps := NewTOTPRepo(db)
+
+	const maxFailures = 3
+	user := testUser(t, db, "totp@example.com")
+	if _, err := totps.RecordFailure(ctx, user.UserID, time.Minute, maxFailures, time.Minute); !errors.Is(err, ErrTOTPNotFound) {
+		t.Fatalf("RecordFailure() without a secret error = %v, want ErrTOTPNotFound", err)
+	}
+	if err := totps.Begin(ctx, user.UserID, "encrypted secret"); err != nil {
+		t.Fatalf("Begin() error = %v", err)
+	}
+	if err := totps.Enable(ctx, user.UserID, 1, nil); err != nil {
+		t.Fatalf("Enable() error = %v", err)
+	}
+
+	fail := func(window time.Duration) bool {
+		t.Helper()
+		locked, err := totps.RecordFailure(ctx, user.UserID, window, maxFailures, time.Minute)
+		if err != nil {
+			t.Fatalf("RecordFailure() error = %v", err)
+		}
+		return locked
+	}
+	locked := func() bool {
+		t.Helper()
+		totp, err := totps.Find(ctx, user.UserID)
+		if err != nil {
+			t.Fatalf("Find() error = %v", err)
+		}
+		return totp.Locked
+	}
+
+	// failures older than the window are not counted
+	for range maxFailures + 1 {
+		if fail(time.Nanosecond) {
+			t.Fatalf("RecordFailure() locked the user out with the failures out of the window")
+		}
+	}
+
+	// a right code starts the count over
+	for range maxFailures - 1 {
+		fail(time.Minute)
+	}
+	if err := totps.ResetFailures(ctx, user.UserID); err != nil {
+		t.Fatalf("ResetFailures() error = %v", err)
+	}
+	for range maxFailures - 1 {
+		if fail(time.Minute) {
+			t.Fatalf("RecordFailure() counted the failures before ResetFailures")
+		}
+	}
+	if locked() {
+		t.Fatalf("Find() locked before reaching the failures")
+	}
+
+	if !fail(time.Minute) {
+		t.Fatalf("RecordFailure() didn't lock the user out on failure %d", maxFailures)
+	}
+	if !locked() {
+		t.Errorf("Find() not locked after too many failures")
+	}
+}
@@ -0,0 +1,59 @@
+package repo
+
+import (
+	"context"
+
+	"github.com/LeandroDeJesus-S/quicknote/internal/errs"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type queryContextKey struct{}
+
+// dbConn is what the repositories run their queries on, the pool or a transaction.
+type dbConn interface {
+	Begin(ctx context.Context) (pgx.Tx, error)
+	Exec(ctx context.Context, sql string, arguments ...any) (pgconn.CommandTag, error)
+	Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error)
+	QueryRow(ctx context.Context, sql string, args ...any) pgx.Row
+}
+
+// conn returns the transaction the repositories were given through ctx, or db if there is none.
+// The transactions the repositories begin on a transaction are savepoints of it, so their
+// changes are only committed along with it.
+func conn(ctx context.Context, db *pgxpool.Pool) dbConn {
+	if tx, ok := ctx.Value(queryContextKey{}).(pgx.Tx); ok {
+		return tx
+	}
+	return db
+}
+
+// Transactor runs the calls of several repositories in a single transaction.
+type Transactor interface {
+	InTx(ctx context.Context, fn func(ctx context.Context) error) error // calls fn with a context making the repositories run their queries in a transaction, which is committed if fn returns nil and rolled back otherwise
+}
+
+type transactor struct {
+	db *pgxpool.Pool
+}
+
+func NewTransactor(db *pgxpool.Pool) Transactor {
+	return &transactor{db: db}
+}
+
+func (t *transactor) InTx(ctx context.Context, fn func(ctx context.Context) error) error {
+	tx, err := conn(ctx, t.db).Begin(ctx)
+	if err != nil {
+		return errs.NewRepoError(err)
+	}
+	defer tx.Rollback(ctx)
+
+	if err := fn(context.WithValue(ctx, queryContextKey{}, tx)); err != nil {
+		return err
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return errs.NewRepoError(err)
+	}
+	return nil
+}
@@ -20,31 +20,82 @@ var (
 	ErrUserNotFound              = errs.NewRepoError(errors.New("user not found"))
 	ErrTokenExpired              = errs.NewRepoError(errors.New("token expired"))
 	ErrTokenAlreadyConfirmed     = errs.NewRepoError(errors.New("token already confirmed"))
-
-	pwTokenTTL = 1 * time.Minute
+	ErrTokenWrongPurpose         = errs.NewRepoError(errors.New("token not valid for this action"))
 )
 
+// TokenTTLs is how long the tokens of each purpose work after they are mailed.
+// The tokens of a purpose missing from it are always expired.
+type TokenTTLs map[models.TokenPurpose]time.Duration
+
+// UserRepository stores the users and the tokens mailed to them.
+// The tokens are looked up by the SHA-256 hashes the handlers get from [authutil.HashToken], as only
+// the links mailed know the tokens. Every method using a token refuses the ones mailed for another
+// purpose with [ErrTokenWrongPurpose], so e.g. the link confirming the signup can't reset the password.
 type UserRepository interface {
-	Create(ctx context.Context, email, password string) (*models.User, error)                                                   // creates a new user
-	CreateUserAndToken(ctx context.Context, email, password, token string) (*models.User, *models.UserConfirmationToken, error) // performs both user and token creation in a single transaction
-	CreateUserToken(ctx context.Context, userID int64, token string) (*models.UserConfirmationToken, error)                     // creates a new token for a user
-	ConfirmUserWithToken(ctx context.Context, token string) error                                                               // fetches a user's token where it's neither confirmed nor expired then marks it as confirmed
-	FindByEmail(ctx context.Context, email string) (*models.User, error)                                                        // finds a user by its email
-	CheckResetToken(ctx context.Context, token string) error                                                                    // returns [ErrConfirmationTokenNotFound] error if the token was not found, [ErrTokenAlreadyConfirmed] if it was already confirmed, and [ErrTokenExpired] if it's expired
-	UpdatePasswordByToken(ctx context.Context, token, newPassword string) (string, error)                                       // set the new password for the token owner and returns its email
-	UpdateUserToken(ctx context.Context, oldTokID int64, newTok string) error                                                   // updates the token for the new one
-	UserEmailByToken(ctx context.Context, token string) (string, error)                                                         // returns the user's email by the token
-	UserPendingToken(ctx context.Context, userID int64) (*models.UserConfirmationToken, error)                                  // returns the user's pending token
+	Create(ctx context.Context, email, password string) (*models.User, error)                                                                // creates a new user
+	CreateUserAndToken(ctx context.Context, email, password, tokenHash string) (*models.User, *models.UserConfirmationToken, error)          // performs both user and confirmation token creation in a single transaction
+	CreateUserToken(ctx context.Context, userID int64, tokenHash string, purpose models.TokenPurpose) (*models.UserConfirmationToken, error) // creates a new token for a user
+	ConfirmUserWithToken(ctx context.Context, tokenHash string) error                                                                        // activates the owner of a confirmation token and marks it as confirmed; returns the errors of [UserRepository.CheckToken]
+	FindByEmail(ctx context.Context, email string) (*models.User, error)                                                                     // finds a user by its email
+	FindByID(ctx context.Context, id int64) (*models.User, error)                                                                            // finds a user by its id, returns [ErrUserNotFound] if there is none
+	CheckToken(ctx context.Context, tokenHash string, purpose models.TokenPurpose) error                                                     // returns [ErrConfirmationTokenNotFound] error if the token was not found, [ErrTokenWrongPurpose] if it was mailed for another purpose, [ErrTokenAlreadyConfirmed] if it was already confirmed, and [ErrTokenExpired] if it's expired
+	UpdatePasswordByToken(ctx context.Context, tokenHash, newPassword string) (*models.User, error)                                          // set the new password for the owner of a password reset token, which is confirmed so it can't be used again, and returns the owner's id and email; returns the errors of [UserRepository.CheckToken]
+	UpdateUserToken(ctx context.Context, oldTokID int64, newTokHash string) error                                                            // replaces the token for the new one, which works for a whole TTL again
+	UserEmailByToken(ctx context.Context, tokenHash string, purpose models.TokenPurpose) (string, error)                                     // returns the user's email by the token mailed for the purpose, with the errors of [UserRepository.CheckToken]
+	UserPendingToken(ctx context.Context, userID int64, purpose models.TokenPurpose) (*models.UserConfirmationToken, error)                  // returns the user's pending token of the purpose
+	UpdatePassword(ctx context.Context, userID int64, newPassword string) error                                                              // sets the new password of the user
+	CreateEmailChangeToken(ctx context.Context, userID int64, newEmail, tokenHash string) (*models.UserConfirmationToken, error)             // creates the token confirming the switch to newEmail, replacing any pending one; returns [ErrDuplicatedEmail] if the email is taken
+	ScheduleDeletion(ctx context.Context, userID int64, at time.Time) error                                                                  // schedules the deletion of the user for good at the given time
+	CancelDeletion(ctx context.Context, userID int64) (bool, error)                                                                          // cancels the scheduled deletion of the user, reporting whether there was one
+	DeleteScheduledBefore(ctx context.Context, before time.Time) (int64, error)                                                              // deletes for good the users scheduled to be deleted before the given time, with their data in cascade but what they created in team workspaces, which passes to the owners, and returns how many were deleted
+	ConfirmEmailChange(ctx context.Context, tokenHash string) (string, error)                                                                // switches the email of the token owner to the one it was sent to and returns it; returns the errors of [UserRepository.CheckToken] or [ErrDuplicatedEmail]
 }
 
-type queryContextKey struct{}
-
 type UserRepo struct {
-	db *pgxpool.Pool
+	db        *pgxpool.Pool
+	tokenTTLs TokenTTLs
+}
+
+func NewUserRepo(db *pgxpool.Pool, tokenTTLs TokenTTLs) UserRepository {
+	return &UserRepo{db: db, tokenTTLs: tokenTTLs}
 }
 
-func NewUserRepo(db *pgxpool.Pool) UserRepository {
-	return &UserRepo{db: db}
+// findToken returns the token with the hash if it was mailed for the purpose and is neither confirmed nor expired,
+// locking it until the transaction ends when tx is not nil. It returns the errors of [UserRepository.CheckToken].
+func (r *UserRepo) findToken(ctx context.Context, tx pgx.Tx, tokenHash string, purpose models.TokenPurpose) (*models.UserConfirmationToken, error) {
+	var qCtx interface {
+		QueryRow(ctx context.Context, query string, args ...any) pgx.Row
+	}
+	qCtx = r.db
+	query := `SELECT id, user_id, token_hash, purpose, confirmed, new_email, created_at + make_interval(secs => $2) < CURRENT_TIMESTAMP
+		FROM user_tokens WHERE token_hash = $1`
+	if tx != nil {
+		qCtx = tx
+		query += " FOR UPDATE"
+	}
+
+	var (
+		t       models.UserConfirmationToken
+		expired bool
+	)
+	err := qCtx.QueryRow(ctx, query, tokenHash, r.tokenTTLs[purpose].Seconds()).
+		Scan(&t.ID, &t.UserID, &t.TokenHash, &t.Purpose, &t.Confirmed, &t.NewEmail, &expired)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrConfirmationTokenNotFound
+	}
+	if err != nil {
+		return nil, errs.NewRepoError(err)
+	}
+
+	switch {
+	case t.Purpose != purpose:
+		return nil, ErrTokenWrongPurpose
+	case t.Confirmed.Bool:
+		return nil, ErrTokenAlreadyConfirmed
+	case expired:
+		return nil, ErrTokenExpired
+	}
+	return &t, nil
 }
 
 func (r *UserRepo) Create(ctx context.Context, email, password string) (*models.User, error) {
@@ -70,7 +121,7 @@ func (r *UserRepo) Create(ctx context.Context, email, password string) (*models.
 	return &u, nil
 }
 
-func (r *UserRepo) CreateUserToken(ctx context.Context, userID int64, token string) (*models.UserConfirmationToken, error) {
+func (r *UserRepo) CreateUserToken(ctx context.Context, userID int64, tokenHash string, purpose models.TokenPurpose) (*models.UserConfirmationToken, error) {
 	var qCtx interface {
 		QueryRow(ctx context.Context, query string, args ...any) pgx.Row
 	}
@@ -80,16 +131,17 @@ func (r *UserRepo) CreateUserToken(ctx context.Context, userID int64, token stri
 	}
 	var u models.UserConfirmationToken
 
-	u.Token = pgtype.Text{String: token, Valid: true}
+	u.TokenHash = pgtype.Text{String: tokenHash, Valid: true}
+	u.Purpose = purpose
 	u.UserID = pgtype.Numeric{Int: big.NewInt(userID), Valid: true}
-	query := "INSERT INTO user_tokens (user_id, token) VALUES ($1, $2) RETURNING id, created_at, updated_at;"
-	if err := qCtx.QueryRow(ctx, query, u.UserID, u.Token).Scan(&u.ID, &u.CreatedAt, &u.UpdatedAt); err != nil {
+	query := "INSERT INTO user_tokens (user_id, token_hash, purpose) VALUES ($1, $2, $3) RETURNING id, created_at, updated_at;"
+	if err := qCtx.QueryRow(ctx, query, u.UserID, u.TokenHash, u.Purpose).Scan(&u.ID, &u.CreatedAt, &u.UpdatedAt); err != nil {
 		return nil, errs.NewRepoError(err)
 	}
 	return &u, nil
 }
 
-func (r *UserRepo) CreateUserAndToken(ctx context.Context, email, password, token string) (*models.User, *models.UserConfirmationToken, error) {
+func (r *UserRepo) CreateUserAndToken(ctx context.Context, email, password, tokenHash string) (*models.User, *models.UserConfirmationToken, error) {
 	tx, err := r.db.Begin(ctx)
 	if err != nil {
 		return nil, nil, errs.NewRepoError(err)
@@ -102,7 +154,7 @@ func (r *UserRepo) CreateUserAndToken(ctx context.Context, email, password, toke
 		return nil, nil, err
 	}
 
-	tok, err := r.CreateUserToken(queryContext, usr.ID.Int.Int64(), token)
+	tok, err := r.CreateUserToken(queryContext, usr.ID.Int.Int64(), tokenHash, models.TokenPurposeConfirmation)
 	if err != nil {
 		return nil, nil, err
 	}
@@ -110,28 +162,28 @@ func (r *UserRepo) CreateUserAndToken(ctx context.Context, email, password, toke
 	return usr, tok, nil
 }
 
-func (r *UserRepo) ConfirmUserWithToken(ctx context.Context, token string) error {
-	query := "SELECT u.id, t.id FROM users u INNER JOIN user_tokens t ON u.id = t.user_id WHERE t.confirmed = false AND t.token = $1 AND u.active = false"
-	var userID, tokenID pgtype.Numeric
-	if err := r.db.QueryRow(ctx, query, token).Scan(&userID, &tokenID); err != nil {
-		if errors.Is(err, pgx.ErrNoRows) {
-			return ErrConfirmationTokenNotFound
-		}
-		return errs.NewRepoError(err)
-	}
-
+func (r *UserRepo) ConfirmUserWithToken(ctx context.Context, tokenHash string) error {
 	tx, err := r.db.Begin(ctx)
 	if err != nil {
 		return errs.NewRepoError(err)
 	}
 	defer tx.Rollback(ctx)
 
-	if _, err := tx.Exec(ctx, "UPDATE users SET active = TRUE, updated_at = now() WHERE id = $1", userID); err != nil {
+	tok, err := r.findToken(ctx, tx, tokenHash, models.TokenPurposeConfirmation)
+	if err != nil {
+		return err
+	}
+
+	var email string
+	if err := tx.QueryRow(ctx, "UPDATE users SET active = TRUE, updated_at = now() WHERE id = $1 RETURNING email", tok.UserID).Scan(&email); err != nil {
 		return errs.NewRepoError(err)
 	}
-	if _, err := tx.Exec(ctx, "UPDATE user_tokens SET confirmed = TRUE, updated_at = now() WHERE id = $1", tokenID); err != nil {
+	if _, err := tx.Exec(ctx, "UPDATE user_tokens SET confirmed = TRUE, updated_at = now() WHERE id = $1", tok.ID); err != nil {
 		return errs.NewRepoError(err)
 	}
+	if err := claimNoteShares(ctx, tx, tok.UserID.Int.Int64(), email); err != nil {
+		return err
+	}
 	return tx.Commit(ctx)
 }
 
@@ -148,65 +200,78 @@ func (r *UserRepo) FindByEmail(ctx context.Context, email string) (*models.User,
 	return &u, nil
 }
 
-func (r *UserRepo) CheckResetToken(ctx context.Context, token string) error {
-	q := `SELECT confirmed, created_at FROM user_tokens WHERE token = $1`
-	var (
-		createdAt pgtype.Date
-		confirmed bool
-	)
-	err := r.db.QueryRow(ctx, q, token).Scan(&confirmed, &createdAt)
-	if errors.Is(err, pgx.ErrNoRows) {
-		return ErrConfirmationTokenNotFound
-	}
-	if err := err; err != nil {
-		return errs.NewRepoError(err)
+func (r *UserRepo) FindByID(ctx context.Context, id int64) (*models.User, error) {
+	var u models.User
+	query := "SELECT id, email, password, active, created_at, updated_at, delete_at FROM users WHERE id = $1"
+	if err := r.db.QueryRow(ctx, query, id).Scan(&u.ID, &u.Email, &u.Password, &u.Active, &u.CreatedAt, &u.UpdatedAt, &u.DeleteAt); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrUserNotFound
+		}
+		return nil, errs.NewRepoError(err)
 	}
+	return &u, nil
+}
+
+func (r *UserRepo) CheckToken(ctx context.Context, tokenHash string, purpose models.TokenPurpose) error {
+	_, err := r.findToken(ctx, nil, tokenHash, purpose)
+	return err
+}
 
-	if confirmed {
-		return ErrTokenAlreadyConfirmed
+func (r *UserRepo) UpdatePasswordByToken(ctx context.Context, tokenHash, newPassword string) (*models.User, error) {
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return nil, errs.NewRepoError(err)
 	}
+	defer tx.Rollback(ctx)
 
-	if time.Since(createdAt.Time) > pwTokenTTL {
-		return ErrTokenExpired
+	tok, err := r.findToken(ctx, tx, tokenHash, models.TokenPurposePasswordReset)
+	if err != nil {
+		return nil, err
 	}
 
-	return nil
-}
+	var u models.User
+	q := `UPDATE users SET password = $1, updated_at = now() WHERE id = $2 RETURNING id, email`
+	if err := tx.QueryRow(ctx, q, newPassword, tok.UserID).Scan(&u.ID, &u.Email); err != nil {
+		return nil, errs.NewRepoError(err)
+	}
+	if _, err := tx.Exec(ctx, "UPDATE user_tokens SET confirmed = TRUE, updated_at = now() WHERE id = $1", tok.ID); err != nil {
+		return nil, errs.NewRepoError(err)
+	}
 
-func (r *UserRepo) UpdatePasswordByToken(ctx context.Context, token, newPassword string) (string, error) {
-	q := `UPDATE users SET password = $1, updated_at = now() WHERE id = (SELECT user_id FROM user_tokens WHERE token = $2) RETURNING email`
-	var email string
-	err := r.db.QueryRow(ctx, q, newPassword, token).Scan(&email)
-	if err != nil {
-		return "", errs.NewRepoError(err)
+	if err := tx.Commit(ctx); err != nil {
+		return nil, errs.NewRepoError(err)
 	}
-	return email, nil
+	return &u, nil
 }
 
-func (r *UserRepo) UpdateUserToken(ctx context.Context, oldTokID int64, newTok string) error {
-	q := `UPDATE user_tokens SET updated_at = now(), token = $1 WHERE id = $2`
-	_, err := r.db.Exec(ctx, q, newTok, pgtype.Numeric{Int: big.NewInt(oldTokID), Valid: true})
+func (r *UserRepo) UpdateUserToken(ctx context.Context, oldTokID int64, newTokHash string) error {
+	q := `UPDATE user_tokens SET created_at = now(), updated_at = now(), token_hash = $1 WHERE id = $2`
+	_, err := r.db.Exec(ctx, q, newTokHash, pgtype.Numeric{Int: big.NewInt(oldTokID), Valid: true})
 	if err != nil {
 		return errs.NewRepoError(err)
 	}
 	return nil
 }
 
-func (r *UserRepo) UserEmailByToken(ctx context.Context, token string) (string, error) {
-	q := `SELECT u.email FROM users u INNER JOIN user_tokens ut ON u.id = ut.user_id AND token = $1`
-	var email string
-	err := r.db.QueryRow(ctx, q, token).Scan(&email)
+func (r *UserRepo) UserEmailByToken(ctx context.Context, tokenHash string, purpose models.TokenPurpose) (string, error) {
+	tok, err := r.findToken(ctx, nil, tokenHash, purpose)
 	if err != nil {
+		return "", err
+	}
+
+	var email string
+	if err := r.db.QueryRow(ctx, "SELECT email FROM users WHERE id = $1", tok.UserID).Scan(&email); err != nil {
 		return "", errs.NewRepoError(err)
 	}
 	return email, nil
 }
 
-func (r *UserRepo) UserPendingToken(ctx context.Context, userID int64) (*models.UserConfirmationToken, error) {
+func (r *UserRepo) UserPendingToken(ctx context.Context, userID int64, purpose models.TokenPurpose) (*models.UserConfirmationToken, error) {
 	var u models.UserConfirmationToken
 	u.UserID = pgtype.Numeric{Int: big.NewInt(userID), Valid: true}
-	query := "SELECT user_id, token, confirmed, created_at FROM user_tokens WHERE user_id = $1 AND confirmed = false"
-	if err := r.db.QueryRow(ctx, query, u.UserID).Scan(&u.UserID, &u.Token, &u.Confirmed, &u.CreatedAt); err != nil {
+	query := `SELECT id, user_id, token_hash, purpose, confirmed, created_at FROM user_tokens
+		WHERE user_id = $1 AND purpose = $2 AND confirmed = false ORDER BY created_at DESC LIMIT 1`
+	if err := r.db.QueryRow(ctx, query, u.UserID, purpose).Scan(&u.ID, &u.UserID, &u.TokenHash, &u.Purpose, &u.Confirmed, &u.CreatedAt); err != nil {
 		if errors.Is(err, pgx.ErrNoRows) {
 			return nil, ErrConfirmationTokenNotFound
 		}
@@ -214,3 +279,166 @@ func (r *UserRepo) UserPendingToken(ctx context.Context, userID int64) (*models.
 	}
 	return &u, nil
 }
+
+func (r *UserRepo) UpdatePassword(ctx context.Context, userID int64, newPassword string) error {
+	tag, err := r.db.Exec(ctx, "UPDATE users SET password = $1, updated_at = now() WHERE id = $2", newPassword, userID)
+	if err != nil {
+		return errs.NewRepoError(err)
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrUserNotFound
+	}
+	return nil
+}
+
+func (r *UserRepo) CreateEmailChangeToken(ctx context.Context, userID int64, newEmail, tokenHash string) (*models.UserConfirmationToken, error) {
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return nil, errs.NewRepoError(err)
+	}
+	defer tx.Rollback(ctx)
+
+	var taken bool
+	if err := tx.QueryRow(ctx, "SELECT EXISTS (SELECT 1 FROM users WHERE email = $1)", newEmail).Scan(&taken); err != nil {
+		return nil, errs.NewRepoError(err)
+	}
+	if taken {
+		return nil, ErrDuplicatedEmail
+	}
+
+	// only the link of the last change asked works
+	if _, err := tx.Exec(ctx, "DELETE FROM user_tokens WHERE user_id = $1 AND confirmed = false AND purpose = $2", userID, models.TokenPurposeEmailChange); err != nil {
+		return nil, errs.NewRepoError(err)
+	}
+
+	var t models.UserConfirmationToken
+	t.UserID = pgtype.Numeric{Int: big.NewInt(userID), Valid: true}
+	t.TokenHash = pgtype.Text{String: tokenHash, Valid: true}
+	t.Purpose = models.TokenPurposeEmailChange
+	t.NewEmail = pgtype.Text{String: newEmail, Valid: true}
+	query := "INSERT INTO user_tokens (user_id, token_hash, purpose, new_email) VALUES ($1, $2, $3, $4) RETURNING id, created_at, updated_at"
+	if err := tx.QueryRow(ctx, query, t.UserID, t.TokenHash, t.Purpose, t.NewEmail).Scan(&t.ID, &t.CreatedAt, &t.UpdatedAt); err != nil {
+		return nil, errs.NewRepoError(err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, errs.NewRepoError(err)
+	}
+	return &t, nil
+}
+
+func (r *UserRepo) ConfirmEmailChange(ctx context.Context, tokenHash string) (string, error) {
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return "", errs.NewRepoError(err)
+	}
+	defer tx.Rollback(ctx)
+
+	tok, err := r.findToken(ctx, tx, tokenHash, models.TokenPurposeEmailChange)
+	if err != nil {
+		return "", err
+	}
+
+	newEmail := tok.NewEmail.String
+	if _, err := tx.Exec(ctx, "UPDATE users SET email = $1, updated_at = now() WHERE id = $2", newEmail, tok.UserID); err != nil {
+		// someone signed up with the email after the change was asked
+		if strings.Contains(err.Error(), "violates unique constraint") {
+			return "", ErrDuplicatedEmail
+		}
+		return "", errs.NewRepoError(err)
+	}
+	if _, err := tx.Exec(ctx, "UPDATE user_tokens SET confirmed = TRUE, updated_at = now() WHERE id = $1", tok.ID); err != nil {
+		return "", errs.NewRepoError(err)
+	}
+	if err := claimNoteShares(ctx, tx, tok.UserID.Int.Int64(), newEmail); err != nil {
+		return "", err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return "", errs.NewRepoError(err)
+	}
+	return newEmail, nil
+}
+
+func (r *UserRepo) ScheduleDeletion(ctx context.Context, userID int64, at time.Time) error {
+	tag, err := r.db.Exec(ctx, "UPDATE users SET delete_at = $1, updated_at = now() WHERE id = $2", at, userID)
+	if err != nil {
+		return errs.NewRepoError(err)
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrUserNotFound
+	}
+	return nil
+}
+
+func (r *UserRepo) CancelDeletion(ctx context.Context, userID int64) (bool, error) {
+	tag, err := r.db.Exec(ctx, "UPDATE users SET delete_at = NULL, updated_at = now() WHERE id = $1 AND delete_at IS NOT NULL", userID)
+	if err != nil {
+		return false, errs.NewRepoError(err)
+	}
+	return tag.RowsAffected() > 0, nil
+}
+
+func (r *UserRepo) DeleteScheduledBefore(ctx context.Context, before time.Time) (int64, error) {
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return 0, errs.NewRepoError(err)
+	}
+	defer tx.Rollback(ctx)
+
+	rows, err := tx.Query(ctx, "SELECT id FROM users WHERE delete_at <= $1 FOR UPDATE", before)
+	if err != nil {
+		return 0, errs.NewRepoError(err)
+	}
+	userIDs, err := pgx.CollectRows(rows, pgx.RowTo[int64])
+	if err != nil {
+		return 0, errs.NewRepoError(err)
+	}
+	if len(userIDs) == 0 {
+		return 0, nil
+	}
+
+	// the personal workspaces and the rest of the users' data are deleted by the foreign keys in cascade,
+	// but the team workspaces are kept for the other members. Those left without members are deleted
+	q := `DELETE FROM workspaces w WHERE w.personal_user_id IS NULL
+		AND EXISTS (SELECT 1 FROM workspace_members m WHERE m.workspace_id = w.id AND m.user_id = ANY($1))
+		AND NOT EXISTS (SELECT 1 FROM workspace_members m WHERE m.workspace_id = w.id AND m.user_id <> ALL($1))`
+	if _, err := tx.Exec(ctx, q, userIDs); err != nil {
+		return 0, errs.NewRepoError(err)
+	}
+
+	// those left without owners get the admin, or else the member, who joined first as owner
+	q = `UPDATE workspace_members m SET role = $2 FROM (
+			SELECT DISTINCT ON (s.workspace_id) s.workspace_id, s.user_id FROM workspace_members s
+			WHERE s.user_id <> ALL($1)
+				AND EXISTS (SELECT 1 FROM workspace_members o WHERE o.workspace_id = s.workspace_id AND o.user_id = ANY($1) AND o.role = $2)
+				AND NOT EXISTS (SELECT 1 FROM workspace_members o WHERE o.workspace_id = s.workspace_id AND o.user_id <> ALL($1) AND o.role = $2)
+			ORDER BY s.workspace_id, s.role = $3 DESC, s.created_at, s.user_id
+		) successor
+		WHERE m.workspace_id = successor.workspace_id AND m.user_id = successor.user_id`
+	if _, err := tx.Exec(ctx, q, userIDs, models.WorkspaceRoleOwner, models.WorkspaceRoleAdmin); err != nil {
+		return 0, errs.NewRepoError(err)
+	}
+
+	// and the notes, notebooks and tags the users created in them pass to the first owner
+	for _, table := range []string{"notes", "notebooks", "tags"} {
+		q = `UPDATE ` + table + ` t SET user_id = (
+				SELECT o.user_id FROM workspace_members o
+				WHERE o.workspace_id = t.workspace_id AND o.user_id <> ALL($1) AND o.role = $2
+				ORDER BY o.created_at, o.user_id LIMIT 1
+			)
+			WHERE t.user_id = ANY($1) AND t.workspace_id IN (SELECT id FROM workspaces WHERE personal_user_id IS NULL)`
+		if _, err := tx.Exec(ctx, q, userIDs, models.WorkspaceRoleOwner); err != nil {
+			return 0, errs.NewRepoError(err)
+		}
+	}
+
+	tag, err := tx.Exec(ctx, "DELETE FROM users WHERE id = ANY($1)", userIDs)
+	if err != nil {
+		return 0, errs.NewRepoError(err)
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return 0, errs.NewRepoError(err)
+	}
+	return tag.RowsAffected(), nil
+}
@@ -0,0 +1,128 @@
+package repo
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/LeandroDeJesus-S/quicknote/internal/models"
+	"github.com/LeandroDeJesus-S/quicknote/internal/support/authutil"
+)
+
+func TestUserEmailByTokenChecksPurpose(t *testing.T) {
+	db := testDB(t)
+	ctx := context.Background()
+	users := NewUserRepo(db, TokenTTLs{
+		models.TokenPurposeConfirmation:  time.Hour,
+		models.TokenPurposePasswordReset: time.Hour,
+	})
+
+	user := testUser(t, db, "reset@example.com")
+	tokenHash := authutil.HashToken(authutil.GenerateToken())
+	if _, err := users.CreateUserToken(ctx, user.UserID, tokenHash, models.TokenPurposePasswordReset); err != nil {
+		t.Fatalf("CreateUserToken() error = %v", err)
+	}
+
+	if _, err := users.UserEmailByToken(ctx, tokenHash, models.TokenPurposeConfirmation); !errors.Is(err, ErrTokenWrongPurpose) {
+		t.Errorf("UserEmailByToken() for another purpose error = %v, want ErrTokenWrongPurpose", err)
+	}
+	if _, err := users.UserEmailByToken(ctx, authutil.HashToken(authutil.GenerateToken()), models.TokenPurposePasswordReset); !errors.Is(err, ErrConfirmationTokenNotFound) {
+		t.Errorf("UserEmailByToken() of an unknown token error = %v, want ErrConfirmationTokenNotFound", err)
+	}
+
+	email, err := users.UserEmailByToken(ctx, tokenHash, models.TokenPurposePasswordReset)
+	if err != nil {
+		t.Fatalf("UserEmailByToken() error = %v", err)
+	}
+	if email != "reset@example.com" {
+		t.Errorf("UserEmailByToken() = %q, want reset@example.com", email)
+	}
+}
+
+func TestDeleteScheduledKeepsTeamWorkspaces(t *testing.T) {
+	db := testDB(t)
+	ctx := context.Background()
+	users := NewUserRepo(db, nil)
+	workspaces := NewWorkspaceRepo(db)
+	notes := NewNoteRepo(db)
+
+	leaving := testUser(t, db, "leaving@example.com")
+	teammate := testUser(t, db, "teammate@example.com")
+
+	// join makes the user a member of the workspace through an invite
+	join := func(invitedBy, workspaceID int64, user Scope, email string) {
+		t.Helper()
+		token := authutil.HashToken(authutil.GenerateToken())
+		if _, err := workspaces.CreateInvite(ctx, invitedBy, workspaceID, email, models.WorkspaceRoleMember, token); err != nil {
+			t.Fatalf("CreateInvite() error = %v", err)
+		}
+		if _, err := workspaces.AcceptInvite(ctx, token, user.UserID, email); err != nil {
+			t.Fatalf("AcceptInvite() error = %v", err)
+		}
+	}
+	createWorkspace := func(owner Scope, name string) int64 {
+		t.Helper()
+		ws, err := workspaces.Create(ctx, owner.UserID, name)
+		if err != nil {
+			t.Fatalf("creating workspace %s: %v", name, err)
+		}
+		return ws.Workspace.ID.Int.Int64()
+	}
+	createNote := func(scope Scope, title string) int64 {
+		t.Helper()
+		note, err := notes.Create(ctx, scope, models.NoteTypeText, title, "", "#ffffff")
+		if err != nil {
+			t.Fatalf("creating note %s: %v", title, err)
+		}
+		return note.ID.Int.Int64()
+	}
+
+	owned := createWorkspace(leaving, "Owned by the leaving user")
+	join(leaving.UserID, owned, teammate, "teammate@example.com")
+	joined := createWorkspace(teammate, "Owned by the teammate")
+	join(teammate.UserID, joined, leaving, "leaving@example.com")
+	alone := createWorkspace(leaving, "Only the leaving user")
+
+	inOwned := createNote(Scope{WorkspaceID: owned, UserID: leaving.UserID}, "In the owned workspace")
+	inJoined := createNote(Scope{WorkspaceID: joined, UserID: leaving.UserID}, "In the joined workspace")
+	personal := createNote(leaving, "Personal")
+	createNote(Scope{WorkspaceID: alone, UserID: leaving.UserID}, "Alone")
+
+	if err := users.ScheduleDeletion(ctx, leaving.UserID, time.Now().Add(-time.Minute)); err != nil {
+		t.Fatalf("ScheduleDeletion() error = %v", err)
+	}
+	deleted, err := users.DeleteScheduledBefore(ctx, time.Now())
+	if err != nil {
+		t.Fatalf("DeleteScheduledBefore() error = %v", err)
+	}
+	if deleted != 1 {
+		t.Fatalf("DeleteScheduledBefore() = %d, want 1", deleted)
+	}
+
+	for id, want := range map[int64]bool{inOwned: true, inJoined: true, personal: false} {
+		var authorID int64
+		err := db.QueryRow(ctx, "SELECT user_id FROM notes WHERE id = $1", id).Scan(&authorID)
+		if found := err == nil; found != want {
+			t.Errorf("note %d found = %v, want %v", id, found, want)
+		} else if found && authorID != teammate.UserID {
+			t.Errorf("note %d author = %d, want the teammate %d", id, authorID, teammate.UserID)
+		}
+	}
+
+	member, err := workspaces.Membership(ctx, teammate.UserID, owned)
+	if err != nil {
+		t.Fatalf("Membership() of the teammate error = %v", err)
+	}
+	if member.Role != models.WorkspaceRoleOwner {
+		t.Errorf("teammate role = %q, want the ownership passed to them", member.Role)
+	}
+
+	var aloneExists bool
+	if err := db.QueryRow(ctx, "SELECT EXISTS (SELECT 1 FROM workspaces WHERE id = $1)", alone).Scan(&aloneExists); err != nil {
+		t.Fatalf("reading workspace: %v", err)
+	}
+	if aloneExists {
+		t.Errorf("workspace without members left was kept")
+	}
+}
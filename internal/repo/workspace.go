@@ -0,0 +1,447 @@
+package repo
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/LeandroDeJesus-S/quicknote/internal/errs"
+	"github.com/LeandroDeJesus-S/quicknote/internal/models"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+var (
+	ErrWorkspaceNotFound       = errs.NewRepoError(errors.New("workspace not found"))
+	ErrWorkspaceMemberNotFound = errs.NewRepoError(errors.New("workspace member not found"))
+	ErrWorkspaceInviteNotFound = errs.NewRepoError(errors.New("workspace invite not found"))
+	ErrPersonalWorkspace       = errs.NewRepoError(errors.New("personal workspaces can't be shared or deleted"))
+	ErrAlreadyWorkspaceMember  = errs.NewRepoError(errors.New("the user is already a member of the workspace"))
+	ErrLastWorkspaceOwner      = errs.NewRepoError(errors.New("the workspace must be left with an owner"))
+	ErrInviteEmailMismatch     = errs.NewRepoError(errors.New("the invite was sent to another email"))
+
+	inviteTokenTTL = 7 * 24 * time.Hour
+)
+
+// personalWorkspaceName is the name given to the personal workspaces.
+const personalWorkspaceName = "Pessoal"
+
+// Scope is the workspace a repository operation works on and the user acting on it,
+// who is recorded as the author of what they create or change.
+type Scope struct {
+	WorkspaceID int64
+	UserID      int64
+}
+
+// workspaceMemberColumns are the columns scanned by [workspaceMemberScanFields], where w is the
+// workspaces table, m the workspace_members one and u the member.
+const workspaceMemberColumns = "w.id, w.name, w.personal_user_id, w.created_at, m.user_id, u.email, m.role, m.created_at"
+
+func workspaceMemberScanFields(m *models.WorkspaceMember) []any {
+	return []any{
+		&m.Workspace.ID, &m.Workspace.Name, &m.Workspace.PersonalUserID, &m.Workspace.CreatedAt,
+		&m.UserID, &m.Email, &m.Role, &m.CreatedAt,
+	}
+}
+
+// workspaceInviteColumns are the columns scanned by [workspaceInviteScanFields], where i is the workspace_invites table.
+const workspaceInviteColumns = "i.id, i.workspace_id, i.email, i.role, i.token_hash, i.invited_by, i.confirmed, i.created_at, i.updated_at"
+
+func workspaceInviteScanFields(i *models.WorkspaceInvite) []any {
+	return []any{&i.ID, &i.WorkspaceID, &i.Email, &i.Role, &i.TokenHash, &i.InvitedBy, &i.Confirmed, &i.CreatedAt, &i.UpdatedAt}
+}
+
+// WorkspaceRepository manages the workspaces, their members and the invites to join them.
+// Checking whether the acting member's role allows an operation is up to the caller.
+type WorkspaceRepository interface {
+	Personal(ctx context.Context, userID int64) (*models.WorkspaceMember, error)                                                                                // returns the user's membership of their personal workspace, creating it if the user has none yet
+	Membership(ctx context.Context, userID, workspaceID int64) (*models.WorkspaceMember, error)                                                                 // returns [ErrWorkspaceNotFound] if the user is not a member of the workspace
+	ListForUser(ctx context.Context, userID int64) ([]models.WorkspaceMember, error)                                                                            // lists the user's memberships, the personal workspace first and then the others by name
+	Create(ctx context.Context, userID int64, name string) (*models.WorkspaceMember, error)                                                                     // creates a team workspace owned by the user
+	Rename(ctx context.Context, workspaceID int64, name string) error                                                                                           // returns [ErrWorkspaceNotFound] if the workspace does not exist
+	Delete(ctx context.Context, workspaceID int64) error                                                                                                        // deletes a team workspace along with its notes. Returns [ErrWorkspaceNotFound] if there is no team workspace with the ID
+	ListMembers(ctx context.Context, workspaceID int64) ([]models.WorkspaceMember, error)                                                                       // lists the members of the workspace by email
+	SetMemberRole(ctx context.Context, workspaceID, userID int64, role models.WorkspaceRole) error                                                              // returns [ErrWorkspaceMemberNotFound], or [ErrLastWorkspaceOwner] if it would leave the workspace without owners
+	RemoveMember(ctx context.Context, workspaceID, userID int64) error                                                                                          // returns [ErrWorkspaceMemberNotFound], or [ErrLastWorkspaceOwner] if it would leave the workspace without owners
+	CreateInvite(ctx context.Context, invitedBy, workspaceID int64, email string, role models.WorkspaceRole, tokenHash string) (*models.WorkspaceInvite, error) // invites the email to join a team workspace with the hash of the mailed token, replacing its pending invite. Returns [ErrPersonalWorkspace] or [ErrAlreadyWorkspaceMember]
+	ListInvites(ctx context.Context, workspaceID int64) ([]models.WorkspaceInvite, error)                                                                       // lists the pending invites of the workspace that haven't expired, the most recent first
+	RevokeInvite(ctx context.Context, workspaceID, id int64) error                                                                                              // deletes a pending invite, returns [ErrWorkspaceInviteNotFound] if it's not a pending invite of the workspace
+	FindInvite(ctx context.Context, tokenHash string) (*models.WorkspaceInvite, *models.Workspace, error)                                                       // returns the invite of the token hash and its workspace. Returns [ErrConfirmationTokenNotFound], [ErrTokenAlreadyConfirmed] or [ErrTokenExpired] like [UserRepository.CheckToken]
+	AcceptInvite(ctx context.Context, tokenHash string, userID int64, email string) (*models.WorkspaceMember, error)                                            // makes the user a member with the invite role and confirms it. Returns the errors of FindInvite, or [ErrInviteEmailMismatch] if it was sent to another email
+}
+
+type workspaceRepo struct {
+	db *pgxpool.Pool
+}
+
+func NewWorkspaceRepo(db *pgxpool.Pool) WorkspaceRepository {
+	return &workspaceRepo{db: db}
+}
+
+func (r *workspaceRepo) Personal(ctx context.Context, userID int64) (*models.WorkspaceMember, error) {
+	query := `SELECT ` + workspaceMemberColumns + ` FROM workspaces w
+		JOIN workspace_members m ON m.workspace_id = w.id
+		JOIN users u ON u.id = m.user_id
+		WHERE w.personal_user_id = $1 AND m.user_id = $1`
+
+	var m models.WorkspaceMember
+	err := r.db.QueryRow(ctx, query, userID).Scan(workspaceMemberScanFields(&m)...)
+	if err == nil {
+		return &m, nil
+	}
+	if !errors.Is(err, pgx.ErrNoRows) {
+		return nil, errs.NewRepoError(err)
+	}
+
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return nil, errs.NewRepoError(err)
+	}
+	defer tx.Rollback(ctx)
+
+	// both statements do nothing if a concurrent request created the workspace first
+	_, err = tx.Exec(
+		ctx,
+		"INSERT INTO workspaces (name, personal_user_id) VALUES ($1, $2) ON CONFLICT (personal_user_id) DO NOTHING",
+		personalWorkspaceName, userID,
+	)
+	if err != nil {
+		return nil, errs.NewRepoError(err)
+	}
+	_, err = tx.Exec(
+		ctx,
+		`INSERT INTO workspace_members (workspace_id, user_id, role)
+			SELECT id, $1::bigint, $2::text FROM workspaces WHERE personal_user_id = $1
+			ON CONFLICT DO NOTHING`,
+		userID, string(models.WorkspaceRoleOwner),
+	)
+	if err != nil {
+		return nil, errs.NewRepoError(err)
+	}
+
+	if err := tx.QueryRow(ctx, query, userID).Scan(workspaceMemberScanFields(&m)...); err != nil {
+		return nil, errs.NewRepoError(err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, errs.NewRepoError(err)
+	}
+	return &m, nil
+}
+
+func (r *workspaceRepo) Membership(ctx context.Context, userID, workspaceID int64) (*models.WorkspaceMember, error) {
+	var m models.WorkspaceMember
+	err := r.db.QueryRow(
+		ctx,
+		`SELECT `+workspaceMemberColumns+` FROM workspaces w
+			JOIN workspace_members m ON m.workspace_id = w.id
+			JOIN users u ON u.id = m.user_id
+			WHERE w.id = $1 AND m.user_id = $2`,
+		workspaceID, userID,
+	).Scan(workspaceMemberScanFields(&m)...)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrWorkspaceNotFound
+	}
+	if err != nil {
+		return nil, errs.NewRepoError(err)
+	}
+	return &m, nil
+}
+
+// queryMembers runs a query selecting [workspaceMemberColumns] and scans all the returned members.
+func (r *workspaceRepo) queryMembers(ctx context.Context, query string, args ...any) ([]models.WorkspaceMember, error) {
+	rows, err := r.db.Query(ctx, query, args...)
+	if err != nil {
+		return nil, errs.NewRepoError(err)
+	}
+	defer rows.Close()
+
+	var members []models.WorkspaceMember
+	for rows.Next() {
+		var m models.WorkspaceMember
+		if err := rows.Scan(workspaceMemberScanFields(&m)...); err != nil {
+			return nil, errs.NewRepoError(err)
+		}
+		members = append(members, m)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, errs.NewRepoError(err)
+	}
+	return members, nil
+}
+
+func (r *workspaceRepo) ListForUser(ctx context.Context, userID int64) ([]models.WorkspaceMember, error) {
+	return r.queryMembers(
+		ctx,
+		`SELECT `+workspaceMemberColumns+` FROM workspaces w
+			JOIN workspace_members m ON m.workspace_id = w.id
+			JOIN users u ON u.id = m.user_id
+			WHERE m.user_id = $1
+			ORDER BY w.personal_user_id IS NULL, w.name, w.id`,
+		userID,
+	)
+}
+
+func (r *workspaceRepo) Create(ctx context.Context, userID int64, name string) (*models.WorkspaceMember, error) {
+	var m models.WorkspaceMember
+	err := r.db.QueryRow(
+		ctx,
+		`WITH w AS (
+			INSERT INTO workspaces (name) VALUES ($1) RETURNING *
+		), m AS (
+			INSERT INTO workspace_members (workspace_id, user_id, role) SELECT w.id, $2::bigint, $3::text FROM w RETURNING *
+		)
+		SELECT `+workspaceMemberColumns+` FROM w, m JOIN users u ON u.id = m.user_id`,
+		name, userID, string(models.WorkspaceRoleOwner),
+	).Scan(workspaceMemberScanFields(&m)...)
+	if err != nil {
+		return nil, errs.NewRepoError(err)
+	}
+	return &m, nil
+}
+
+func (r *workspaceRepo) Rename(ctx context.Context, workspaceID int64, name string) error {
+	tag, err := r.db.Exec(ctx, "UPDATE workspaces SET name = $1 WHERE id = $2", name, workspaceID)
+	if err != nil {
+		return errs.NewRepoError(err)
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrWorkspaceNotFound
+	}
+	return nil
+}
+
+func (r *workspaceRepo) Delete(ctx context.Context, workspaceID int64) error {
+	tag, err := r.db.Exec(ctx, "DELETE FROM workspaces WHERE id = $1 AND personal_user_id IS NULL", workspaceID)
+	if err != nil {
+		return errs.NewRepoError(err)
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrWorkspaceNotFound
+	}
+	return nil
+}
+
+func (r *workspaceRepo) ListMembers(ctx context.Context, workspaceID int64) ([]models.WorkspaceMember, error) {
+	return r.queryMembers(
+		ctx,
+		`SELECT `+workspaceMemberColumns+` FROM workspaces w
+			JOIN workspace_members m ON m.workspace_id = w.id
+			JOIN users u ON u.id = m.user_id
+			WHERE w.id = $1
+			ORDER BY u.email`,
+		workspaceID,
+	)
+}
+
+// changeMember runs the statement changing or removing a member, given the workspace and the user IDs
+// as its first parameters, making sure the workspace is left with an owner.
+func (r *workspaceRepo) changeMember(ctx context.Context, workspaceID, userID int64, stmt string, args ...any) error {
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return errs.NewRepoError(err)
+	}
+	defer tx.Rollback(ctx)
+
+	// locks the members so concurrent changes can't remove the last owners together
+	if _, err := tx.Exec(ctx, "SELECT user_id FROM workspace_members WHERE workspace_id = $1 FOR UPDATE", workspaceID); err != nil {
+		return errs.NewRepoError(err)
+	}
+
+	tag, err := tx.Exec(ctx, stmt, append([]any{workspaceID, userID}, args...)...)
+	if err != nil {
+		return errs.NewRepoError(err)
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrWorkspaceMemberNotFound
+	}
+
+	var owners int64
+	err = tx.QueryRow(
+		ctx,
+		"SELECT count(*) FROM workspace_members WHERE workspace_id = $1 AND role = $2",
+		workspaceID, models.WorkspaceRoleOwner,
+	).Scan(&owners)
+	if err != nil {
+		return errs.NewRepoError(err)
+	}
+	if owners == 0 {
+		return ErrLastWorkspaceOwner
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return errs.NewRepoError(err)
+	}
+	return nil
+}
+
+func (r *workspaceRepo) SetMemberRole(ctx context.Context, workspaceID, userID int64, role models.WorkspaceRole) error {
+	return r.changeMember(
+		ctx, workspaceID, userID,
+		"UPDATE workspace_members SET role = $3 WHERE workspace_id = $1 AND user_id = $2",
+		role,
+	)
+}
+
+func (r *workspaceRepo) RemoveMember(ctx context.Context, workspaceID, userID int64) error {
+	return r.changeMember(ctx, workspaceID, userID, "DELETE FROM workspace_members WHERE workspace_id = $1 AND user_id = $2")
+}
+
+func (r *workspaceRepo) CreateInvite(ctx context.Context, invitedBy, workspaceID int64, email string, role models.WorkspaceRole, tokenHash string) (*models.WorkspaceInvite, error) {
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return nil, errs.NewRepoError(err)
+	}
+	defer tx.Rollback(ctx)
+
+	var personal, member bool
+	err = tx.QueryRow(
+		ctx,
+		`SELECT w.personal_user_id IS NOT NULL, EXISTS (
+				SELECT 1 FROM workspace_members m JOIN users u ON u.id = m.user_id WHERE m.workspace_id = w.id AND u.email = $2
+			)
+			FROM workspaces w WHERE w.id = $1 FOR UPDATE`,
+		workspaceID, email,
+	).Scan(&personal, &member)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrWorkspaceNotFound
+	}
+	if err != nil {
+		return nil, errs.NewRepoError(err)
+	}
+	if personal {
+		return nil, ErrPersonalWorkspace
+	}
+	if member {
+		return nil, ErrAlreadyWorkspaceMember
+	}
+
+	if _, err := tx.Exec(ctx, "DELETE FROM workspace_invites WHERE workspace_id = $1 AND email = $2 AND NOT confirmed", workspaceID, email); err != nil {
+		return nil, errs.NewRepoError(err)
+	}
+
+	var inv models.WorkspaceInvite
+	err = tx.QueryRow(
+		ctx,
+		`INSERT INTO workspace_invites AS i (workspace_id, email, role, token_hash, invited_by) VALUES ($1, $2, $3, $4, $5)
+			RETURNING `+workspaceInviteColumns,
+		workspaceID, email, role, tokenHash, invitedBy,
+	).Scan(workspaceInviteScanFields(&inv)...)
+	if err != nil {
+		return nil, errs.NewRepoError(err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, errs.NewRepoError(err)
+	}
+	return &inv, nil
+}
+
+func (r *workspaceRepo) ListInvites(ctx context.Context, workspaceID int64) ([]models.WorkspaceInvite, error) {
+	rows, err := r.db.Query(
+		ctx,
+		`SELECT `+workspaceInviteColumns+` FROM workspace_invites i
+			WHERE i.workspace_id = $1 AND NOT i.confirmed AND i.created_at > $2
+			ORDER BY i.created_at DESC, i.id DESC`,
+		workspaceID, time.Now().Add(-inviteTokenTTL),
+	)
+	if err != nil {
+		return nil, errs.NewRepoError(err)
+	}
+	defer rows.Close()
+
+	var invites []models.WorkspaceInvite
+	for rows.Next() {
+		var inv models.WorkspaceInvite
+		if err := rows.Scan(workspaceInviteScanFields(&inv)...); err != nil {
+			return nil, errs.NewRepoError(err)
+		}
+		invites = append(invites, inv)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, errs.NewRepoError(err)
+	}
+	return invites, nil
+}
+
+func (r *workspaceRepo) RevokeInvite(ctx context.Context, workspaceID, id int64) error {
+	tag, err := r.db.Exec(ctx, "DELETE FROM workspace_invites WHERE id = $1 AND workspace_id = $2 AND NOT confirmed", id, workspaceID)
+	if err != nil {
+		return errs.NewRepoError(err)
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrWorkspaceInviteNotFound
+	}
+	return nil
+}
+
+// findInvite reads the invite of the token hash along with its workspace, checking it's still pending.
+func findInvite(ctx context.Context, q interface {
+	QueryRow(ctx context.Context, query string, args ...any) pgx.Row
+}, tokenHash string, lock bool) (*models.WorkspaceInvite, *models.Workspace, error) {
+	query := `SELECT ` + workspaceInviteColumns + `, w.id, w.name, w.personal_user_id, w.created_at
+		FROM workspace_invites i JOIN workspaces w ON w.id = i.workspace_id
+		WHERE i.token_hash = $1`
+	if lock {
+		query += " FOR UPDATE OF i"
+	}
+
+	var (
+		inv models.WorkspaceInvite
+		ws  models.Workspace
+	)
+	err := q.QueryRow(ctx, query, tokenHash).
+		Scan(append(workspaceInviteScanFields(&inv), &ws.ID, &ws.Name, &ws.PersonalUserID, &ws.CreatedAt)...)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil, ErrConfirmationTokenNotFound
+	}
+	if err != nil {
+		return nil, nil, errs.NewRepoError(err)
+	}
+
+	if inv.Confirmed.Bool {
+		return nil, nil, ErrTokenAlreadyConfirmed
+	}
+	if time.Since(inv.CreatedAt.Time) > inviteTokenTTL {
+		return nil, nil, ErrTokenExpired
+	}
+	return &inv, &ws, nil
+}
+
+func (r *workspaceRepo) FindInvite(ctx context.Context, tokenHash string) (*models.WorkspaceInvite, *models.Workspace, error) {
+	return findInvite(ctx, r.db, tokenHash, false)
+}
+
+func (r *workspaceRepo) AcceptInvite(ctx context.Context, tokenHash string, userID int64, email string) (*models.WorkspaceMember, error) {
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return nil, errs.NewRepoError(err)
+	}
+	defer tx.Rollback(ctx)
+
+	inv, _, err := findInvite(ctx, tx, tokenHash, true)
+	if err != nil {
+		return nil, err
+	}
+	if !strings.EqualFold(inv.Email.String, email) {
+		return nil, ErrInviteEmailMismatch
+	}
+
+	_, err = tx.Exec(
+		ctx,
+		"INSERT INTO workspace_members (workspace_id, user_id, role) VALUES ($1, $2, $3) ON CONFLICT DO NOTHING",
+		inv.WorkspaceID, userID, inv.Role,
+	)
+	if err != nil {
+		return nil, errs.NewRepoError(err)
+	}
+	if _, err := tx.Exec(ctx, "UPDATE workspace_invites SET confirmed = TRUE, updated_at = now() WHERE id = $1", inv.ID); err != nil {
+		return nil, errs.NewRepoError(err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, errs.NewRepoError(err)
+	}
+	return r.Membership(ctx, userID, inv.WorkspaceID.Int.Int64())
+}
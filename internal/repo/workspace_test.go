@@ -0,0 +1,56 @@
+package repo
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/LeandroDeJesus-S/quicknote/internal/models"
+	"github.com/LeandroDeJesus-S/quicknote/internal/support/authutil"
+)
+
+func TestInviteFoundByTokenHash(t *testing.T) {
+	db := testDB(t)
+	ctx := context.Background()
+	workspaces := NewWorkspaceRepo(db)
+
+	owner := testUser(t, db, "owner@example.com")
+	invited := testUser(t, db, "invited@example.com")
+	team, err := workspaces.Create(ctx, owner.UserID, "Team")
+	if err != nil {
+		t.Fatalf("creating team workspace: %v", err)
+	}
+	teamID := team.Workspace.ID.Int.Int64()
+
+	token := authutil.GenerateToken()
+	invite, err := workspaces.CreateInvite(ctx, owner.UserID, teamID, "invited@example.com", models.WorkspaceRoleMember, authutil.HashToken(token))
+	if err != nil {
+		t.Fatalf("CreateInvite() error = %v", err)
+	}
+	if invite.TokenHash.String == token {
+		t.Fatalf("CreateInvite() stored the plain token")
+	}
+
+	if _, _, err := workspaces.FindInvite(ctx, token); !errors.Is(err, ErrConfirmationTokenNotFound) {
+		t.Errorf("FindInvite(plain token) error = %v, want ErrConfirmationTokenNotFound", err)
+	}
+	if _, err := workspaces.AcceptInvite(ctx, token, invited.UserID, "invited@example.com"); !errors.Is(err, ErrConfirmationTokenNotFound) {
+		t.Errorf("AcceptInvite(plain token) error = %v, want ErrConfirmationTokenNotFound", err)
+	}
+
+	found, ws, err := workspaces.FindInvite(ctx, authutil.HashToken(token))
+	if err != nil {
+		t.Fatalf("FindInvite() error = %v", err)
+	}
+	if found.ID.Int.Cmp(invite.ID.Int) != 0 || ws.ID.Int.Int64() != teamID {
+		t.Errorf("FindInvite() = invite %v of workspace %v, want invite %v of workspace %d", found.ID.Int, ws.ID.Int, invite.ID.Int, teamID)
+	}
+
+	member, err := workspaces.AcceptInvite(ctx, authutil.HashToken(token), invited.UserID, "invited@example.com")
+	if err != nil {
+		t.Fatalf("AcceptInvite() error = %v", err)
+	}
+	if member.Role != models.WorkspaceRoleMember {
+		t.Errorf("AcceptInvite() role = %q, want %q", member.Role, models.WorkspaceRoleMember)
+	}
+}
@@ -0,0 +1,65 @@
+package authutil
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hkdf"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+)
+
+// Cipher is an interface for encrypting the secrets stored at rest.
+type Cipher interface {
+	// Encrypt encrypts a secret, returning it base64 encoded.
+	Encrypt(plaintext []byte) (string, error)
+	// Decrypt decrypts a secret returned by Encrypt, failing if it was tampered with.
+	Decrypt(ciphertext string) ([]byte, error)
+}
+
+// aesCipher is a Cipher implementation that uses AES-256 in GCM mode.
+type aesCipher struct {
+	aead cipher.AEAD
+}
+
+// NewAESCipher creates a new aesCipher with a key derived from the application secret key,
+// so the key encrypting the secrets is not the one used for other purposes.
+func NewAESCipher(secretKey string) Cipher {
+	key, err := hkdf.Key(sha256.New, []byte(secretKey), nil, "quicknote secrets at rest", 32)
+	if err != nil {
+		panic(err)
+	}
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		panic(err)
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		panic(err)
+	}
+	return &aesCipher{aead: aead}
+}
+
+// Encrypt encrypts a secret with a random nonce, which is prepended to the ciphertext.
+func (c *aesCipher) Encrypt(plaintext []byte) (string, error) {
+	nonce := make([]byte, c.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(c.aead.Seal(nonce, nonce, plaintext, nil)), nil
+}
+
+// Decrypt decrypts a secret returned by Encrypt.
+func (c *aesCipher) Decrypt(ciphertext string) ([]byte, error) {
+	data, err := base64.StdEncoding.DecodeString(ciphertext)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) < c.aead.NonceSize() {
+		return nil, errors.New("ciphertext too short")
+	}
+	nonce, sealed := data[:c.aead.NonceSize()], data[c.aead.NonceSize():]
+	return c.aead.Open(nil, nonce, sealed, nil)
+}
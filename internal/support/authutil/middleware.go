@@ -1,6 +1,7 @@
 package authutil
 
 import (
+	"encoding/json"
 	"log/slog"
 	"net/http"
 
@@ -45,3 +46,28 @@ func (am *authMiddleware) RequireAuth(next http.Handler) http.Handler {
 		next.ServeHTTP(w, r)
 	})
 }
+
+// RequireAuthAPI is like RequireAuth but, instead of redirecting to the login page,
+// answers unauthenticated requests with a 401 JSON error as expected by API clients.
+// Both session and API token authenticated requests are accepted.
+func (am *authMiddleware) RequireAuthAPI(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		uID := RequestUserID(am.sessionMng, r)
+
+		if uID <= 0 {
+			slog.Debug("[authMiddleware] api user not logged in", "user_id", uID)
+			writeJSONError(w, http.StatusUnauthorized, "authentication required")
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// writeJSONError writes an error in the same shape as the API error responses.
+func writeJSONError(w http.ResponseWriter, status int, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]any{
+		"error": map[string]any{"status": status, "message": message},
+	})
+}
@@ -0,0 +1,51 @@
+package authutil
+
+import (
+	"context"
+
+	"github.com/LeandroDeJesus-S/quicknote/internal/models"
+	"github.com/alexedwards/scs/v2"
+)
+
+// SessionLister lists and revokes the sessions of the users from the session store.
+//
+// The store only knows the sessions by their token, with the user id inside the session data, so every
+// method reads and decodes all the sessions of the store, the ones of every user. This takes time in
+// the number of sessions rather than in the ones of the user, which is fine for the occasional account
+// changes calling it, but it is not meant for the path of every request.
+type SessionLister struct {
+	sessionMng *scs.SessionManager
+	userIDkey  string
+}
+
+// NewSessionLister creates a new SessionLister of the sessions managed by sesMng.
+func NewSessionLister(sesMng *scs.SessionManager) *SessionLister {
+	return &SessionLister{sessionMng: sesMng, userIDkey: DefaultUserIDKey}
+}
+
+// UserSessions returns the sessions signed in as the user, reading every session of the store.
+func (l *SessionLister) UserSessions(ctx context.Context, userID int64) ([]models.Session, error) {
+	var sessions []models.Session
+	err := l.sessionMng.Iterate(ctx, func(ctx context.Context) error {
+		if l.sessionMng.GetInt64(ctx, l.userIDkey) != userID {
+			return nil
+		}
+		sessions = append(sessions, models.Session{
+			ID:        HashToken(l.sessionMng.Token(ctx))[:16],
+			ExpiresAt: l.sessionMng.Deadline(ctx),
+		})
+		return nil
+	})
+	return sessions, err
+}
+
+// RevokeUserSessions signs out the sessions of the user but the one with the token keep, which is
+// left empty to sign out all of them. Like UserSessions, it reads every session of the store.
+func (l *SessionLister) RevokeUserSessions(ctx context.Context, userID int64, keep string) error {
+	return l.sessionMng.Iterate(ctx, func(ctx context.Context) error {
+		if l.sessionMng.GetInt64(ctx, l.userIDkey) != userID || l.sessionMng.Token(ctx) == keep {
+			return nil
+		}
+		return l.sessionMng.Destroy(ctx)
+	})
+}
@@ -0,0 +1,57 @@
+package authutil
+
+import (
+	"context"
+	"testing"
+
+	"github.com/alexedwards/scs/v2"
+	"github.com/alexedwards/scs/v2/memstore"
+)
+
+func TestRevokeUserSessions(t *testing.T) {
+	sesMng := scs.New()
+	sesMng.Store = memstore.New()
+	ctx := context.Background()
+
+	// signIn stores a new session of the user, returning its token.
+	signIn := func(userID int64) string {
+		t.Helper()
+		ctx, err := sesMng.Load(ctx, "")
+		if err != nil {
+			t.Fatalf("Load() error = %v", err)
+		}
+		sesMng.Put(ctx, DefaultUserIDKey, userID)
+		token, _, err := sesMng.Commit(ctx)
+		if err != nil {
+			t.Fatalf("Commit() error = %v", err)
+		}
+		return token
+	}
+	current, other, stranger := signIn(1), signIn(1), signIn(2)
+
+	lister := NewSessionLister(sesMng)
+	if err := lister.RevokeUserSessions(ctx, 1, current); err != nil {
+		t.Fatalf("RevokeUserSessions() error = %v", err)
+	}
+
+	for token, want := range map[string]bool{current: true, other: false, stranger: true} {
+		_, found, err := sesMng.Store.Find(token)
+		if err != nil {
+			t.Fatalf("Find() error = %v", err)
+		}
+		if found != want {
+			t.Errorf("session %s found = %v, want %v", token, found, want)
+		}
+	}
+
+	if err := lister.RevokeUserSessions(ctx, 1, ""); err != nil {
+		t.Fatalf("RevokeUserSessions() error = %v", err)
+	}
+	sessions, err := lister.UserSessions(ctx, 1)
+	if err != nil {
+		t.Fatalf("UserSessions() error = %v", err)
+	}
+	if len(sessions) != 0 {
+		t.Errorf("UserSessions() = %d sessions after revoking all of them, want none", len(sessions))
+	}
+}
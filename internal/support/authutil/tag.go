@@ -18,3 +18,9 @@ func TagIsAuthenticated(ses *scs.SessionManager) render.DynamicTag {
 func TagCSRFField(r *http.Request) any {
 	return func() template.HTML { return csrf.TemplateField(r) }
 }
+
+// TagCSRFToken returns the CSRF token for the scripts sending requests on their own,
+// which must send it in the X-CSRF-Token header.
+func TagCSRFToken(r *http.Request) any {
+	return func() string { return csrf.Token(r) }
+}
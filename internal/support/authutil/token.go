@@ -2,7 +2,9 @@ package authutil
 
 import (
 	"crypto/rand"
+	"crypto/sha256"
 	"encoding/base64"
+	"encoding/hex"
 )
 
 func GenerateToken() string {
@@ -10,3 +12,10 @@ func GenerateToken() string {
 	rand.Read(tok)
 	return base64.URLEncoding.EncodeToString(tok)
 }
+
+// HashToken returns the hex encoded SHA-256 hash of a token.
+// Tokens are random enough that a fast hash is safe to store and look up.
+func HashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,14 @@
+package support
+
+import "net/http"
+
+// MaxBodySize is a middleware that limits the request bodies to n bytes,
+// so uploads can't fill the memory or the disk before reaching the handlers.
+func MaxBodySize(n int64) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			r.Body = http.MaxBytesReader(w, r.Body, n)
+			next.ServeHTTP(w, r)
+		})
+	}
+}
@@ -0,0 +1,145 @@
+// Package diff computes line-level differences between two texts.
+package diff
+
+import "strings"
+
+// Op is the kind of change of a line.
+type Op int
+
+const (
+	Equal  Op = iota // the line is in both texts
+	Delete           // the line is only in the old text
+	Insert           // the line is only in the new text
+)
+
+// maxTableSize bounds the memory used by the longest common subsequence table.
+// Past it, the lines that differ are reported as entirely replaced.
+const maxTableSize = 4_000_000
+
+// Line is a line of a diff.
+type Line struct {
+	Op      Op
+	Text    string
+	OldLine int // the line number in the old text, zero for inserted lines
+	NewLine int // the line number in the new text, zero for deleted lines
+}
+
+// Lines returns the line-level differences needed to turn oldText into newText.
+// Deleted lines come before the inserted lines replacing them.
+func Lines(oldText, newText string) []Line {
+	a, b := splitLines(oldText), splitLines(newText)
+	ops := Ops(a, b)
+
+	lines := make([]Line, 0, len(ops))
+	i, j := 0, 0
+	for _, op := range ops {
+		switch op {
+		case Equal:
+			lines = append(lines, Line{Op: Equal, Text: a[i], OldLine: i + 1, NewLine: j + 1})
+			i++
+			j++
+		case Delete:
+			lines = append(lines, Line{Op: Delete, Text: a[i], OldLine: i + 1})
+			i++
+		case Insert:
+			lines = append(lines, Line{Op: Insert, Text: b[j], NewLine: j + 1})
+			j++
+		}
+	}
+	return lines
+}
+
+// Ops returns the operations turning the lines a into b, one for each line of either, keeping their
+// longest common subsequence. Deleted lines come before the inserted lines replacing them.
+func Ops(a, b []string) []Op {
+	// the common prefix and suffix are trimmed to keep the table small
+	prefix := 0
+	for prefix < len(a) && prefix < len(b) && a[prefix] == b[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(a)-prefix && suffix < len(b)-prefix && a[len(a)-1-suffix] == b[len(b)-1-suffix] {
+		suffix++
+	}
+
+	ops := make([]Op, 0, len(a)+len(b))
+	for range prefix {
+		ops = append(ops, Equal)
+	}
+	ops = append(ops, lcsOps(a[prefix:len(a)-suffix], b[prefix:len(b)-suffix])...)
+	for range suffix {
+		ops = append(ops, Equal)
+	}
+	return ops
+}
+
+// Changed reports whether any of the lines is not [Equal].
+func Changed(lines []Line) bool {
+	for _, l := range lines {
+		if l.Op != Equal {
+			return true
+		}
+	}
+	return false
+}
+
+// lcsOps returns the operations turning a into b, keeping their longest common subsequence.
+func lcsOps(a, b []string) []Op {
+	ops := make([]Op, 0, len(a)+len(b))
+	if len(a)*len(b) > maxTableSize {
+		for range a {
+			ops = append(ops, Delete)
+		}
+		for range b {
+			ops = append(ops, Insert)
+		}
+		return ops
+	}
+
+	// table[i][j] is the length of the longest common subsequence of a[i:] and b[j:]
+	table := make([][]int, len(a)+1)
+	for i := range table {
+		table[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				table[i][j] = table[i+1][j+1] + 1
+			} else {
+				table[i][j] = max(table[i+1][j], table[i][j+1])
+			}
+		}
+	}
+
+	i, j := 0, 0
+	for i < len(a) && j < len(b) {
+		switch {
+		case a[i] == b[j]:
+			ops = append(ops, Equal)
+			i++
+			j++
+		case table[i+1][j] >= table[i][j+1]:
+			ops = append(ops, Delete)
+			i++
+		default:
+			ops = append(ops, Insert)
+			j++
+		}
+	}
+	for ; i < len(a); i++ {
+		ops = append(ops, Delete)
+	}
+	for ; j < len(b); j++ {
+		ops = append(ops, Insert)
+	}
+	return ops
+}
+
+// splitLines splits a text into lines, ignoring the line endings.
+func splitLines(text string) []string {
+	if text == "" {
+		return nil
+	}
+	text = strings.ReplaceAll(text, "\r\n", "\n")
+	return strings.Split(strings.TrimSuffix(text, "\n"), "\n")
+}
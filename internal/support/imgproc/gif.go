@@ -0,0 +1,107 @@
+package imgproc
+
+import (
+	"bytes"
+	"slices"
+)
+
+// The blocks of a GIF image.
+const (
+	gifExtension      = 0x21
+	gifImage          = 0x2C
+	gifTrailer        = 0x3B
+	gifCommentLabel   = 0xFE
+	gifAppLabel       = 0xFF
+	gifFlagColorTable = 0x80
+)
+
+// gifLoopApps are the application extensions telling how many times an animation is played,
+// the only ones kept.
+var gifLoopApps = []string{"NETSCAPE2.0", "ANIMEXTS1.0"}
+
+// stripGIFMetadata removes the comment and application extensions of a GIF image, such as XMP, but the ones
+// looping the animation, keeping every frame as it is. See https://www.w3.org/Graphics/GIF/spec-gif89a.txt.
+func stripGIFMetadata(data []byte) ([]byte, error) {
+	if len(data) < 13 || (string(data[:6]) != "GIF87a" && string(data[:6]) != "GIF89a") {
+		return nil, ErrUnsupported
+	}
+
+	var out bytes.Buffer
+	head := 13 + gifColorTableSize(data[10])
+	if head > len(data) {
+		return nil, ErrUnsupported
+	}
+	out.Write(data[:head])
+
+	for rest := data[head:]; ; {
+		if len(rest) == 0 {
+			return nil, ErrUnsupported
+		}
+
+		var block []byte
+		switch rest[0] {
+		case gifTrailer:
+			out.WriteByte(gifTrailer)
+			return out.Bytes(), nil
+		case gifExtension:
+			if len(rest) < 2 {
+				return nil, ErrUnsupported
+			}
+			end, ok := gifSubBlocksEnd(rest, 2)
+			if !ok {
+				return nil, ErrUnsupported
+			}
+			block, rest = rest[:end], rest[end:]
+			if !keepGIFExtension(block) {
+				continue
+			}
+		case gifImage:
+			if len(rest) < 10 {
+				return nil, ErrUnsupported
+			}
+			// the descriptor is followed by the local color table and the LZW minimum code size
+			end, ok := gifSubBlocksEnd(rest, 10+gifColorTableSize(rest[9])+1)
+			if !ok {
+				return nil, ErrUnsupported
+			}
+			block, rest = rest[:end], rest[end:]
+		default:
+			return nil, ErrUnsupported
+		}
+		out.Write(block)
+	}
+}
+
+// gifColorTableSize returns the size of the color table the packed fields of a descriptor tell there is.
+func gifColorTableSize(packed byte) int {
+	if packed&gifFlagColorTable == 0 {
+		return 0
+	}
+	return 3 << ((packed & 0x07) + 1)
+}
+
+// gifSubBlocksEnd returns where the data sub-blocks of a block starting at start end, after their terminator.
+func gifSubBlocksEnd(block []byte, start int) (int, bool) {
+	for i := start; i < len(block); {
+		size := int(block[i])
+		i++
+		if size == 0 {
+			return i, true
+		}
+		i += size
+	}
+	return 0, false
+}
+
+// keepGIFExtension reports whether an extension is kept, as it isn't a comment or an application extension
+// other than the looping ones.
+func keepGIFExtension(ext []byte) bool {
+	switch ext[1] {
+	case gifCommentLabel:
+		return false
+	case gifAppLabel:
+		// the first sub-block holds the application identifier and authentication code
+		return len(ext) >= 14 && ext[2] == 11 && slices.Contains(gifLoopApps, string(ext[3:14]))
+	}
+	return true
+}
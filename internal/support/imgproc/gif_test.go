@@ -0,0 +1,47 @@
+package imgproc
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/gif"
+	"strings"
+	"testing"
+)
+
+func TestStripGIFMetadata(t *testing.T) {
+	frame := image.NewPaletted(image.Rect(0, 0, 2, 2), color.Palette{color.Black, color.White})
+	var buf bytes.Buffer
+	if err := gif.EncodeAll(&buf, &gif.GIF{Image: []*image.Paletted{frame, frame}, Delay: []int{10, 10}}); err != nil {
+		t.Fatalf("encoding gif: %v", err)
+	}
+	encoded := buf.Bytes()
+
+	comment := append([]byte{gifExtension, gifCommentLabel, 6}, "secret\x00"...)
+	xmp := append([]byte{gifExtension, gifAppLabel, 11}, "XMP DataXMP\x07<x:gps>\x00"...)
+	head := 13 + gifColorTableSize(encoded[10])
+	data := append(append(append(bytes.Clone(encoded[:head]), comment...), xmp...), encoded[head:]...)
+
+	stripped, err := stripGIFMetadata(data)
+	if err != nil {
+		t.Fatalf("stripGIFMetadata() error = %v", err)
+	}
+	if s := string(stripped); strings.Contains(s, "secret") || strings.Contains(s, "XMP") {
+		t.Errorf("stripGIFMetadata() kept the comment or the XMP: %q", s)
+	}
+	if !bytes.Equal(stripped, encoded) {
+		t.Errorf("stripGIFMetadata() = %q, want the image as encoded %q", stripped, encoded)
+	}
+
+	decoded, err := gif.DecodeAll(bytes.NewReader(stripped))
+	if err != nil {
+		t.Fatalf("decoding the stripped gif: %v", err)
+	}
+	if len(decoded.Image) != 2 {
+		t.Errorf("stripped gif has %d frames, want 2", len(decoded.Image))
+	}
+
+	if _, err := stripGIFMetadata(encoded[:len(encoded)-4]); err != ErrUnsupported {
+		t.Errorf("stripGIFMetadata() of a truncated gif error = %v, want ErrUnsupported", err)
+	}
+}
@@ -0,0 +1,149 @@
+// Package imgproc prepares uploaded images to be stored and served:
+// it strips their metadata and generates their resized variants.
+package imgproc
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	_ "image/gif" // registers the decoders of the supported formats
+	_ "image/png"
+
+	"github.com/HugoSmits86/nativewebp"
+	"github.com/disintegration/imaging"
+	_ "golang.org/x/image/webp"
+)
+
+// jpegQuality is the quality JPEG images and variants are encoded with.
+const jpegQuality = 85
+
+// maxPixels bounds the dimensions of the images decoded, so small files
+// declaring huge dimensions can't exhaust the memory.
+const maxPixels = 40_000_000
+
+// The variants generated for every image.
+const (
+	VariantThumb  = "thumb"  // a small preview, in the format of the original
+	VariantMedium = "medium" // the size shown in notes, in the format of the original
+	VariantWebP   = "webp"   // the medium variant in WebP
+)
+
+// variantWidths are the maximum widths of the variants. Smaller images are not enlarged.
+var variantWidths = map[string]int{
+	VariantThumb:  256,
+	VariantMedium: 1024,
+	VariantWebP:   1024,
+}
+
+// ErrUnsupported is returned for images that can't be decoded or are too large to be.
+var ErrUnsupported = errors.New("unsupported image")
+
+// Supported reports whether images of the content type can be processed.
+func Supported(contentType string) bool {
+	switch contentType {
+	case "image/png", "image/jpeg", "image/gif", "image/webp":
+		return true
+	}
+	return false
+}
+
+// Variant is a resized copy of an image.
+type Variant struct {
+	Name        string
+	ContentType string
+	Data        []byte
+}
+
+// Image is an image ready to be stored, along with its variants.
+type Image struct {
+	Data     []byte // the original image without its metadata
+	Variants []Variant
+}
+
+// VariantContentType returns the content type of a variant of an image of the content type.
+func VariantContentType(contentType, variant string) string {
+	if variant == VariantWebP {
+		return "image/webp"
+	}
+	if contentType == "image/jpeg" {
+		return "image/jpeg"
+	}
+	return "image/png"
+}
+
+// Process strips the metadata of an image of the content type, such as the EXIF with the location
+// a photo was taken, and generates its variants.
+//
+// JPEG and PNG images are re-encoded, which drops every metadata, after the EXIF orientation is applied
+// to the pixels. GIF images have their comment and XMP extensions removed, keeping their frames as they are
+// so animations are not lost, and WebP images have their metadata chunks removed, as there is no lossy WebP
+// encoder to re-encode them with.
+func Process(data []byte, contentType string) (*Image, error) {
+	conf, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil || conf.Width*conf.Height > maxPixels {
+		return nil, ErrUnsupported
+	}
+
+	img, err := imaging.Decode(bytes.NewReader(data), imaging.AutoOrientation(true))
+	if err != nil {
+		return nil, ErrUnsupported
+	}
+
+	var original []byte
+	switch contentType {
+	case "image/jpeg":
+		original, err = encode(img, imaging.JPEG)
+	case "image/png":
+		original, err = encode(img, imaging.PNG)
+	case "image/gif":
+		original, err = stripGIFMetadata(data)
+	case "image/webp":
+		original, err = stripWebPMetadata(data)
+	default:
+		return nil, ErrUnsupported
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	processed := &Image{Data: original}
+	for _, name := range []string{VariantThumb, VariantMedium, VariantWebP} {
+		variant, err := resize(img, name, contentType)
+		if err != nil {
+			return nil, fmt.Errorf("generating %s variant: %w", name, err)
+		}
+		processed.Variants = append(processed.Variants, variant)
+	}
+	return processed, nil
+}
+
+// resize generates the variant of a decoded image of the content type.
+func resize(img image.Image, name, contentType string) (Variant, error) {
+	if width := variantWidths[name]; img.Bounds().Dx() > width {
+		img = imaging.Resize(img, width, 0, imaging.Lanczos)
+	}
+
+	variant := Variant{Name: name, ContentType: VariantContentType(contentType, name)}
+
+	var err error
+	switch variant.ContentType {
+	case "image/webp":
+		var buf bytes.Buffer
+		err = nativewebp.Encode(&buf, img, nil)
+		variant.Data = buf.Bytes()
+	case "image/jpeg":
+		variant.Data, err = encode(img, imaging.JPEG)
+	default:
+		variant.Data, err = encode(img, imaging.PNG)
+	}
+	return variant, err
+}
+
+func encode(img image.Image, format imaging.Format) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := imaging.Encode(&buf, img, format, imaging.JPEGQuality(jpegQuality)); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
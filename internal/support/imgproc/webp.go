@@ -0,0 +1,51 @@
+package imgproc
+
+import (
+	"bytes"
+	"encoding/binary"
+)
+
+// The flags of the VP8X chunk telling a WebP image has metadata.
+const (
+	vp8xFlagEXIF = 0x08
+	vp8xFlagXMP  = 0x04
+)
+
+// stripWebPMetadata removes the EXIF and XMP chunks of a WebP image, keeping the image data as it is.
+// See https://developers.google.com/speed/webp/docs/riff_container.
+func stripWebPMetadata(data []byte) ([]byte, error) {
+	if len(data) < 12 || string(data[:4]) != "RIFF" || string(data[8:12]) != "WEBP" {
+		return nil, ErrUnsupported
+	}
+
+	var out bytes.Buffer
+	out.Write(data[:12])
+	for rest := data[12:]; len(rest) > 0; {
+		if len(rest) < 8 {
+			return nil, ErrUnsupported
+		}
+		fourCC := string(rest[:4])
+		size := int(binary.LittleEndian.Uint32(rest[4:8]))
+		end := 8 + size + size%2 // chunks are padded to an even size
+		if end > len(rest) {
+			return nil, ErrUnsupported
+		}
+		chunk := rest[:end]
+		rest = rest[end:]
+
+		switch fourCC {
+		case "EXIF", "XMP ":
+			continue
+		case "VP8X":
+			chunk = bytes.Clone(chunk)
+			if len(chunk) > 8 {
+				chunk[8] &^= vp8xFlagEXIF | vp8xFlagXMP
+			}
+		}
+		out.Write(chunk)
+	}
+
+	stripped := out.Bytes()
+	binary.LittleEndian.PutUint32(stripped[4:8], uint32(len(stripped)-8))
+	return stripped, nil
+}
@@ -0,0 +1,32 @@
+package support
+
+import (
+	"net/http"
+	"strings"
+)
+
+// MethodOverrideField is the form field HTML forms use to send methods other than GET and POST.
+const MethodOverrideField = "_method"
+
+// overridableMethods are the methods a form can override POST with.
+var overridableMethods = map[string]bool{
+	http.MethodPut:    true,
+	http.MethodPatch:  true,
+	http.MethodDelete: true,
+}
+
+// MethodOverride is a middleware that lets HTML forms reach the routes of other methods,
+// by posting the desired method in the [MethodOverrideField] field.
+// Only url-encoded forms are checked, so large multipart bodies are not read here.
+func MethodOverride(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		contentType := r.Header.Get("Content-Type")
+		if r.Method == http.MethodPost && strings.HasPrefix(contentType, "application/x-www-form-urlencoded") {
+			method := strings.ToUpper(r.PostFormValue(MethodOverrideField))
+			if overridableMethods[method] {
+				r.Method = method
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
@@ -5,11 +5,11 @@ import (
 )
 
 type FormValidator interface {
-	ValidateForm(form url.Values)                       // perform the validations and apply the error messages
-	AddError(field, message string)                     // add a new error for a field
-	AddValidator(field string, validator ValidatorFunc) // registers a new validator for a field
-	Ok() bool                                           // returns true if there are no errors
-	FieldErrors() map[string]string                     // returns the errors for each field
+	ValidateForm(form url.Values)                          // perform the validations and apply the error messages
+	AddError(field, message string)                        // add a new error for a field
+	AddValidator(fields []string, validator ValidatorFunc) // registers a new validator for the fields
+	Ok() bool                                              // returns true if there are no errors
+	FieldErrors() map[string]string                        // returns the errors for each field
 }
 
 type formValidator struct {
@@ -0,0 +1,6 @@
+ALTER TABLE notes ADD COLUMN search_vector TSVECTOR GENERATED ALWAYS AS (
+    setweight(to_tsvector('simple', coalesce(title, '')), 'A') ||
+    setweight(to_tsvector('simple', coalesce(content, '')), 'B')
+) STORED;
+
+CREATE INDEX notes_search_vector_idx ON notes USING GIN (search_vector);
@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS note_tags_tag_id_idx;
+DROP TABLE IF EXISTS note_tags;
@@ -0,0 +1,9 @@
+CREATE TABLE IF NOT EXISTS note_tags (
+    note_id BIGINT NOT NULL,
+    tag_id BIGINT NOT NULL,
+    PRIMARY KEY (note_id, tag_id),
+    CONSTRAINT note_tags_note_id_fk FOREIGN KEY (note_id) REFERENCES notes (id) ON DELETE CASCADE,
+    CONSTRAINT note_tags_tag_id_fk FOREIGN KEY (tag_id) REFERENCES tags (id) ON DELETE CASCADE
+);
+
+CREATE INDEX note_tags_tag_id_idx ON note_tags (tag_id);
@@ -0,0 +1,12 @@
+CREATE TABLE IF NOT EXISTS notebooks (
+    id BIGSERIAL PRIMARY KEY,
+    user_id BIGINT NOT NULL,
+    parent_id BIGINT,
+    name VARCHAR(100) NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT notebooks_user_id_fk FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE,
+    CONSTRAINT notebooks_parent_id_fk FOREIGN KEY (parent_id) REFERENCES notebooks (id) ON DELETE CASCADE,
+    CONSTRAINT notebooks_user_id_parent_id_name_key UNIQUE NULLS NOT DISTINCT (user_id, parent_id, name)
+);
+
+CREATE INDEX notebooks_parent_id_idx ON notebooks (parent_id);
@@ -0,0 +1,2 @@
+ALTER TABLE notes ADD COLUMN notebook_id BIGINT REFERENCES notebooks(id) ON DELETE SET NULL;
+CREATE INDEX notes_notebook_id_idx ON notes (notebook_id);
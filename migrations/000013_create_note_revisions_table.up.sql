@@ -0,0 +1,17 @@
+CREATE TABLE IF NOT EXISTS note_revisions (
+    id BIGSERIAL PRIMARY KEY,
+    note_id BIGINT NOT NULL,
+    user_id BIGINT,
+    title VARCHAR(255) NOT NULL,
+    content TEXT,
+    color VARCHAR(7) NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT note_revisions_note_id_fk FOREIGN KEY (note_id) REFERENCES notes (id) ON DELETE CASCADE,
+    CONSTRAINT note_revisions_user_id_fk FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE SET NULL
+);
+
+CREATE INDEX note_revisions_note_id_idx ON note_revisions (note_id, id);
+
+-- the current state of the existing notes is their first revision
+INSERT INTO note_revisions (note_id, user_id, title, content, color, created_at)
+SELECT id, user_id, title, content, color, updated_at FROM notes;
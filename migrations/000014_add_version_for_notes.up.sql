@@ -0,0 +1 @@
+ALTER TABLE notes ADD COLUMN version INTEGER NOT NULL DEFAULT 1;
@@ -0,0 +1,2 @@
+ALTER TABLE notes ADD COLUMN note_type VARCHAR(10) NOT NULL DEFAULT 'text';
+ALTER TABLE notes ADD CONSTRAINT notes_note_type_check CHECK (note_type IN ('text', 'checklist'));
@@ -0,0 +1,11 @@
+CREATE TABLE IF NOT EXISTS checklist_items (
+    id BIGSERIAL PRIMARY KEY,
+    note_id BIGINT NOT NULL,
+    text VARCHAR(500) NOT NULL,
+    checked BOOLEAN NOT NULL DEFAULT FALSE,
+    position INTEGER NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT checklist_items_note_id_fk FOREIGN KEY (note_id) REFERENCES notes (id) ON DELETE CASCADE
+);
+
+CREATE INDEX checklist_items_note_id_position_idx ON checklist_items (note_id, position);
@@ -0,0 +1,16 @@
+CREATE TABLE IF NOT EXISTS attachments (
+    id BIGSERIAL PRIMARY KEY,
+    note_id BIGINT,
+    user_id BIGINT,
+    filename VARCHAR(255) NOT NULL,
+    content_type VARCHAR(100) NOT NULL,
+    size BIGINT NOT NULL,
+    storage_key VARCHAR(255) NOT NULL UNIQUE,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    -- attachments of purged notes are kept until their blobs are deleted, see jobs.AttachmentSweeper
+    CONSTRAINT attachments_note_id_fk FOREIGN KEY (note_id) REFERENCES notes (id) ON DELETE SET NULL,
+    CONSTRAINT attachments_user_id_fk FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE SET NULL
+);
+
+CREATE INDEX attachments_note_id_idx ON attachments (note_id);
+CREATE INDEX attachments_user_id_idx ON attachments (user_id);
@@ -0,0 +1 @@
+ALTER TABLE attachments ADD COLUMN variants TEXT[] NOT NULL DEFAULT '{}';
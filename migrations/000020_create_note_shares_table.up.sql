@@ -0,0 +1,12 @@
+CREATE TABLE IF NOT EXISTS note_shares (
+    id BIGSERIAL PRIMARY KEY,
+    note_id BIGINT NOT NULL,
+    user_id BIGINT NOT NULL,
+    role VARCHAR(10) NOT NULL CHECK (role IN ('viewer', 'editor')),
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT note_shares_note_id_fk FOREIGN KEY (note_id) REFERENCES notes (id) ON DELETE CASCADE,
+    CONSTRAINT note_shares_user_id_fk FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE,
+    CONSTRAINT note_shares_note_user_uq UNIQUE (note_id, user_id)
+);
+
+CREATE INDEX note_shares_user_id_idx ON note_shares (user_id);
@@ -0,0 +1,8 @@
+CREATE TABLE IF NOT EXISTS workspaces (
+    id BIGSERIAL PRIMARY KEY,
+    name VARCHAR(100) NOT NULL,
+    personal_user_id BIGINT,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT workspaces_personal_user_id_fk FOREIGN KEY (personal_user_id) REFERENCES users (id) ON DELETE CASCADE,
+    CONSTRAINT workspaces_personal_user_id_key UNIQUE (personal_user_id)
+);
@@ -0,0 +1,16 @@
+CREATE TABLE IF NOT EXISTS workspace_invites (
+    id BIGSERIAL PRIMARY KEY,
+    workspace_id BIGINT NOT NULL,
+    email VARCHAR(255) NOT NULL,
+    role VARCHAR(10) NOT NULL CHECK (role IN ('member', 'admin')),
+    token TEXT NOT NULL,
+    invited_by BIGINT,
+    confirmed BOOLEAN NOT NULL DEFAULT FALSE,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT workspace_invites_workspace_id_fk FOREIGN KEY (workspace_id) REFERENCES workspaces (id) ON DELETE CASCADE,
+    CONSTRAINT workspace_invites_invited_by_fk FOREIGN KEY (invited_by) REFERENCES users (id) ON DELETE SET NULL,
+    CONSTRAINT workspace_invites_token_key UNIQUE (token)
+);
+
+CREATE INDEX workspace_invites_workspace_id_idx ON workspace_invites (workspace_id);
@@ -0,0 +1,9 @@
+ALTER TABLE tags DROP CONSTRAINT tags_workspace_id_name_key;
+ALTER TABLE tags ADD CONSTRAINT tags_user_id_name_key UNIQUE (user_id, name);
+ALTER TABLE tags DROP COLUMN workspace_id;
+
+ALTER TABLE notebooks DROP CONSTRAINT notebooks_workspace_id_parent_id_name_key;
+ALTER TABLE notebooks ADD CONSTRAINT notebooks_user_id_parent_id_name_key UNIQUE NULLS NOT DISTINCT (user_id, parent_id, name);
+ALTER TABLE notebooks DROP COLUMN workspace_id;
+
+ALTER TABLE notes DROP COLUMN workspace_id;
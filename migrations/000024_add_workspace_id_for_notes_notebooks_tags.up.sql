@@ -0,0 +1,25 @@
+-- every user gets a personal workspace owning the notes, notebooks and tags they already have
+INSERT INTO workspaces (name, personal_user_id)
+    SELECT 'Pessoal', id FROM users
+    ON CONFLICT (personal_user_id) DO NOTHING;
+
+INSERT INTO workspace_members (workspace_id, user_id, role)
+    SELECT id, personal_user_id, 'owner' FROM workspaces WHERE personal_user_id IS NOT NULL
+    ON CONFLICT DO NOTHING;
+
+ALTER TABLE notes ADD COLUMN workspace_id BIGINT REFERENCES workspaces (id) ON DELETE CASCADE;
+UPDATE notes n SET workspace_id = w.id FROM workspaces w WHERE w.personal_user_id = n.user_id;
+ALTER TABLE notes ALTER COLUMN workspace_id SET NOT NULL;
+CREATE INDEX notes_workspace_id_idx ON notes (workspace_id);
+
+ALTER TABLE notebooks ADD COLUMN workspace_id BIGINT REFERENCES workspaces (id) ON DELETE CASCADE;
+UPDATE notebooks nb SET workspace_id = w.id FROM workspaces w WHERE w.personal_user_id = nb.user_id;
+ALTER TABLE notebooks ALTER COLUMN workspace_id SET NOT NULL;
+ALTER TABLE notebooks DROP CONSTRAINT notebooks_user_id_parent_id_name_key;
+ALTER TABLE notebooks ADD CONSTRAINT notebooks_workspace_id_parent_id_name_key UNIQUE NULLS NOT DISTINCT (workspace_id, parent_id, name);
+
+ALTER TABLE tags ADD COLUMN workspace_id BIGINT REFERENCES workspaces (id) ON DELETE CASCADE;
+UPDATE tags t SET workspace_id = w.id FROM workspaces w WHERE w.personal_user_id = t.user_id;
+ALTER TABLE tags ALTER COLUMN workspace_id SET NOT NULL;
+ALTER TABLE tags DROP CONSTRAINT tags_user_id_name_key;
+ALTER TABLE tags ADD CONSTRAINT tags_workspace_id_name_key UNIQUE (workspace_id, name);
@@ -0,0 +1,8 @@
+DROP INDEX IF EXISTS notes_remind_next_at_idx;
+ALTER TABLE notes DROP CONSTRAINT IF EXISTS notes_remind_recurrence_check;
+ALTER TABLE notes DROP COLUMN IF EXISTS reminded_at;
+ALTER TABLE notes DROP COLUMN IF EXISTS remind_next_at;
+ALTER TABLE notes DROP COLUMN IF EXISTS remind_user_id;
+ALTER TABLE notes DROP COLUMN IF EXISTS remind_recurrence;
+ALTER TABLE notes DROP COLUMN IF EXISTS remind_timezone;
+ALTER TABLE notes DROP COLUMN IF EXISTS remind_at;
@@ -0,0 +1,10 @@
+-- the reminder is mailed to the user who set it at remind_at, then on every recurrence counted from it.
+-- remind_next_at is the occurrence to be sent next, null once a reminder that doesn't recur is sent.
+ALTER TABLE notes ADD COLUMN remind_at TIMESTAMPTZ;
+ALTER TABLE notes ADD COLUMN remind_timezone VARCHAR(64) NOT NULL DEFAULT 'UTC';
+ALTER TABLE notes ADD COLUMN remind_recurrence VARCHAR(10) NOT NULL DEFAULT '';
+ALTER TABLE notes ADD COLUMN remind_user_id BIGINT REFERENCES users (id) ON DELETE SET NULL;
+ALTER TABLE notes ADD COLUMN remind_next_at TIMESTAMPTZ;
+ALTER TABLE notes ADD COLUMN reminded_at TIMESTAMPTZ;
+ALTER TABLE notes ADD CONSTRAINT notes_remind_recurrence_check CHECK (remind_recurrence IN ('', 'daily', 'weekly', 'monthly', 'yearly'));
+CREATE INDEX notes_remind_next_at_idx ON notes (remind_next_at) WHERE remind_next_at IS NOT NULL AND deleted_at IS NULL;
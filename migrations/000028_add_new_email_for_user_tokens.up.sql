@@ -0,0 +1,2 @@
+-- the email a user asked to switch to, which only takes effect once the token sent to it is confirmed.
+ALTER TABLE user_tokens ADD COLUMN new_email VARCHAR(365);
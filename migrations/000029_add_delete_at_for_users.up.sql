@@ -0,0 +1,3 @@
+-- when the account is deleted for good, set by the user and cleared by signing in before it.
+ALTER TABLE users ADD COLUMN delete_at TIMESTAMP;
+CREATE INDEX users_delete_at_idx ON users (delete_at) WHERE delete_at IS NOT NULL;
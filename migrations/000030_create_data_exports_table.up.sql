@@ -0,0 +1,15 @@
+-- the archives are kept while the user is deleted, with no user, so they are removed with their blob by the cleanup.
+CREATE TABLE IF NOT EXISTS data_exports (
+    id BIGSERIAL PRIMARY KEY,
+    user_id BIGINT,
+    status VARCHAR(10) NOT NULL DEFAULT 'pending',
+    blob_key TEXT,
+    size BIGINT,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    finished_at TIMESTAMP,
+    expires_at TIMESTAMP,
+    CONSTRAINT data_exports_user_id_fk FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE SET NULL,
+    CONSTRAINT data_exports_status_check CHECK (status IN ('pending', 'ready', 'failed'))
+);
+
+CREATE UNIQUE INDEX data_exports_pending_user_id_idx ON data_exports (user_id) WHERE status = 'pending';
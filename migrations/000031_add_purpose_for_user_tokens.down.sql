@@ -0,0 +1,5 @@
+-- the hashes can't be turned back into the tokens, so the links mailed before stop working.
+ALTER TABLE user_tokens DROP CONSTRAINT IF EXISTS user_tokens_token_hash_key;
+ALTER TABLE user_tokens ALTER COLUMN token_hash TYPE TEXT;
+ALTER TABLE user_tokens RENAME COLUMN token_hash TO token;
+ALTER TABLE user_tokens DROP COLUMN IF EXISTS purpose;
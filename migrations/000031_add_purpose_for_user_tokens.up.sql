@@ -0,0 +1,18 @@
+-- what a token was mailed for, so it can't be used for anything else. The tokens were told apart only by
+-- the new email before, and the pending ones of inactive users can only be confirming the signup.
+ALTER TABLE user_tokens ADD COLUMN purpose VARCHAR(20);
+UPDATE user_tokens t SET purpose = CASE
+    WHEN t.new_email IS NOT NULL THEN 'email_change'
+    WHEN t.confirmed OR EXISTS (SELECT 1 FROM users u WHERE u.id = t.user_id AND NOT u.active) THEN 'confirmation'
+    ELSE 'password_reset'
+END;
+ALTER TABLE user_tokens
+    ALTER COLUMN purpose SET NOT NULL,
+    ADD CONSTRAINT user_tokens_purpose_check CHECK (purpose IN ('confirmation', 'password_reset', 'email_change'));
+
+-- only the SHA-256 hashes of the tokens are stored, like the other tokens, so the links already mailed keep working.
+ALTER TABLE user_tokens RENAME COLUMN token TO token_hash;
+UPDATE user_tokens SET token_hash = encode(sha256(convert_to(token_hash, 'UTF8')), 'hex');
+ALTER TABLE user_tokens
+    ALTER COLUMN token_hash TYPE VARCHAR(64),
+    ADD CONSTRAINT user_tokens_token_hash_key UNIQUE (token_hash);
@@ -0,0 +1,5 @@
+-- the hashes can't be turned back into the tokens, so the invites mailed before stop working.
+ALTER TABLE workspace_invites DROP CONSTRAINT IF EXISTS workspace_invites_token_hash_key;
+ALTER TABLE workspace_invites ALTER COLUMN token_hash TYPE TEXT;
+ALTER TABLE workspace_invites RENAME COLUMN token_hash TO token;
+ALTER TABLE workspace_invites ADD CONSTRAINT workspace_invites_token_key UNIQUE (token);
@@ -0,0 +1,7 @@
+-- only the SHA-256 hashes of the invite tokens are stored, like the other tokens, so the invites already mailed keep working.
+ALTER TABLE workspace_invites DROP CONSTRAINT IF EXISTS workspace_invites_token_key;
+ALTER TABLE workspace_invites RENAME COLUMN token TO token_hash;
+UPDATE workspace_invites SET token_hash = encode(sha256(convert_to(token_hash, 'UTF8')), 'hex');
+ALTER TABLE workspace_invites
+    ALTER COLUMN token_hash TYPE VARCHAR(64),
+    ADD CONSTRAINT workspace_invites_token_hash_key UNIQUE (token_hash);
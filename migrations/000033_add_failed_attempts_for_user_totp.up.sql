@@ -0,0 +1,6 @@
+-- the wrong codes typed to sign in are counted for the user rather than the session, which a new
+-- sign-in would start over, and locking the user out for a while once there are too many.
+ALTER TABLE user_totp
+    ADD COLUMN failed_attempts INT NOT NULL DEFAULT 0,
+    ADD COLUMN first_failed_at TIMESTAMP,
+    ADD COLUMN locked_until TIMESTAMP;
@@ -0,0 +1,6 @@
+DELETE FROM note_shares WHERE user_id IS NULL;
+ALTER TABLE note_shares
+    DROP CONSTRAINT IF EXISTS note_shares_user_id_email_check,
+    DROP CONSTRAINT IF EXISTS note_shares_note_email_uq,
+    DROP COLUMN IF EXISTS email;
+ALTER TABLE note_shares ALTER COLUMN user_id SET NOT NULL;
@@ -0,0 +1,7 @@
+-- a note shared with an email of no active user is kept by the email, with no user, and passes to the
+-- user who activates an account with it, so sharing doesn't tell which emails have an account.
+ALTER TABLE note_shares ALTER COLUMN user_id DROP NOT NULL;
+ALTER TABLE note_shares
+    ADD COLUMN email VARCHAR(365),
+    ADD CONSTRAINT note_shares_note_email_uq UNIQUE (note_id, email),
+    ADD CONSTRAINT note_shares_user_id_email_check CHECK ((user_id IS NULL) <> (email IS NULL));
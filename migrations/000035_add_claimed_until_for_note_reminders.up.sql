@@ -0,0 +1,4 @@
+-- a reminder being delivered is claimed until remind_claimed_until, so the mail is sent without holding a lock
+-- on the note, and no other instance delivers it meanwhile. It's delivered again once the claim runs out
+-- without the reminder being marked as sent, as when the instance delivering it stopped.
+ALTER TABLE notes ADD COLUMN remind_claimed_until TIMESTAMPTZ;
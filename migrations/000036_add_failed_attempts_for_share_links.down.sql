@@ -0,0 +1,4 @@
+ALTER TABLE share_links
+    DROP COLUMN IF EXISTS locked_until,
+    DROP COLUMN IF EXISTS first_failed_at,
+    DROP COLUMN IF EXISTS failed_attempts;
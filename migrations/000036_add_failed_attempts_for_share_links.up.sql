@@ -0,0 +1,6 @@
+-- the wrong passwords typed to unlock a link are counted for the link rather than the session, which
+-- a new one would start over, and locking the link for a while once there are too many.
+ALTER TABLE share_links
+    ADD COLUMN failed_attempts INT NOT NULL DEFAULT 0,
+    ADD COLUMN first_failed_at TIMESTAMP,
+    ADD COLUMN locked_until TIMESTAMP;
@@ -0,0 +1 @@
+ALTER TABLE attachments DROP COLUMN IF EXISTS variants_size;
@@ -0,0 +1,3 @@
+-- the resized copies of images take space in the blob store too, so they count in the attachment quota
+-- along with the attachment size. The images attached before are counted without them.
+ALTER TABLE attachments ADD COLUMN variants_size BIGINT NOT NULL DEFAULT 0;
@@ -0,0 +1,25 @@
+// Toggles the items of checklist notes without leaving the page.
+$(function () {
+    const token = $('meta[name="csrf-token"]').attr("content")
+
+    $(document).on("change", ".checklist .toggle-item input[type=checkbox]", function () {
+        const checkbox = $(this)
+        const form = checkbox.closest("form")
+        const checklist = checkbox.closest(".checklist")
+
+        $.ajax({
+            url: form.attr("action"),
+            method: "POST",
+            headers: { "X-CSRF-Token": token },
+            dataType: "json",
+        }).done(function (res) {
+            checkbox.prop("checked", res.checked)
+            checkbox.closest("li").toggleClass("checked", res.checked)
+            checklist.find(".progress .done").text(res.done)
+            checklist.find(".progress .total").text(res.total)
+        }).fail(function () {
+            checkbox.prop("checked", !checkbox.prop("checked"))
+            alert("Não foi possível atualizar o item.")
+        })
+    })
+})
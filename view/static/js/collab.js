@@ -0,0 +1,351 @@
+// Edits the note content together with the other people editing it, merging the concurrent edits
+// with operational transformation. The operations are lists where a positive number retains characters,
+// a negative one deletes them and a string is inserted, the same as the server.
+$(function () {
+    const content = $("#content")
+    const noteID = content.data("noteid")
+    if (!noteID || !window.WebSocket) {
+        return
+    }
+
+    // operations
+
+    function op() {
+        return { steps: [], baseLength: 0, targetLength: 0 }
+    }
+
+    function retain(o, n) {
+        if (n <= 0) return o
+        o.baseLength += n
+        o.targetLength += n
+        const last = o.steps[o.steps.length - 1]
+        if (typeof last === "number" && last > 0) {
+            o.steps[o.steps.length - 1] += n
+        } else {
+            o.steps.push(n)
+        }
+        return o
+    }
+
+    function insert(o, text) {
+        if (text === "") return o
+        o.targetLength += text.length
+        const steps = o.steps
+        const last = steps[steps.length - 1]
+        if (typeof last === "string") {
+            steps[steps.length - 1] += text
+        } else if (typeof last === "number" && last < 0) {
+            if (typeof steps[steps.length - 2] === "string") {
+                steps[steps.length - 2] += text
+            } else {
+                steps[steps.length - 1] = text
+                steps.push(last)
+            }
+        } else {
+            steps.push(text)
+        }
+        return o
+    }
+
+    function remove(o, n) {
+        if (n <= 0) return o
+        o.baseLength += n
+        const last = o.steps[o.steps.length - 1]
+        if (typeof last === "number" && last < 0) {
+            o.steps[o.steps.length - 1] -= n
+        } else {
+            o.steps.push(-n)
+        }
+        return o
+    }
+
+    function fromSteps(steps) {
+        const o = op()
+        steps.forEach((s) => {
+            if (typeof s === "string") insert(o, s)
+            else if (s > 0) retain(o, s)
+            else remove(o, -s)
+        })
+        return o
+    }
+
+    function apply(o, text) {
+        if (text.length !== o.baseLength) {
+            throw new Error("the operation doesn't apply to the text")
+        }
+        const parts = []
+        let i = 0
+        o.steps.forEach((s) => {
+            if (typeof s === "string") {
+                parts.push(s)
+            } else if (s > 0) {
+                parts.push(text.slice(i, i + s))
+                i += s
+            } else {
+                i -= s
+            }
+        })
+        return parts.join("")
+    }
+
+    // transforms the concurrent operations a and b into [a', b'], the text inserted by a coming first
+    function transform(a, b) {
+        const aPrime = op()
+        const bPrime = op()
+        const as = a.steps.slice()
+        const bs = b.steps.slice()
+        let x = as.shift()
+        let y = bs.shift()
+
+        while (x !== undefined || y !== undefined) {
+            if (typeof x === "string") {
+                insert(aPrime, x)
+                retain(bPrime, x.length)
+                x = as.shift()
+                continue
+            }
+            if (typeof y === "string") {
+                retain(aPrime, y.length)
+                insert(bPrime, y)
+                y = bs.shift()
+                continue
+            }
+            if (x === undefined || y === undefined) {
+                throw new Error("the operations are of different lengths")
+            }
+
+            const n = Math.min(Math.abs(x), Math.abs(y))
+            if (x > 0 && y > 0) {
+                retain(aPrime, n)
+                retain(bPrime, n)
+            } else if (x < 0 && y > 0) {
+                remove(aPrime, n)
+            } else if (x > 0 && y < 0) {
+                remove(bPrime, n)
+            }
+
+            x = x > 0 ? x - n : x + n
+            y = y > 0 ? y - n : y + n
+            if (x === 0) x = as.shift()
+            if (y === 0) y = bs.shift()
+        }
+        return [aPrime, bPrime]
+    }
+
+    function transformIndex(o, index) {
+        let moved = index
+        for (const s of o.steps) {
+            if (typeof s === "string") {
+                moved += s.length
+            } else if (s > 0) {
+                index -= s
+            } else {
+                moved -= Math.min(index, -s)
+                index += s
+            }
+            if (index < 0) break
+        }
+        return moved
+    }
+
+    // the operation turning the text before into the text after, assuming a single edit
+    function diff(before, after) {
+        let start = 0
+        const max = Math.min(before.length, after.length)
+        while (start < max && before[start] === after[start]) start++
+
+        let end = 0
+        while (end < max - start && before[before.length - 1 - end] === after[after.length - 1 - end]) end++
+
+        // never split a surrogate pair
+        if (start > 0 && /[\ud800-\udbff]/.test(before[start - 1])) start--
+        if (end > 0 && /[\udc00-\udfff]/.test(before[before.length - end])) end--
+
+        const o = op()
+        retain(o, start)
+        remove(o, before.length - start - end)
+        insert(o, after.slice(start, after.length - end))
+        retain(o, end)
+        return o
+    }
+
+    // presence
+
+    const colors = ["#e6194b", "#3cb44b", "#4363d8", "#f58231", "#911eb4", "#008080", "#9a6324"]
+    const peers = {}
+    const presence = $('<p class="collab-presence"></p>').insertBefore(content)
+    content.wrap('<div class="collab-editor"></div>')
+    const overlay = $('<div class="collab-cursors" aria-hidden="true"></div>').insertAfter(content)
+
+    function escapeHTML(text) {
+        return text.replace(/[&<>"']/g, (c) => "&#" + c.charCodeAt(0) + ";")
+    }
+
+    function renderPeers() {
+        const list = Object.values(peers)
+        presence.html(list.length === 0 ? "" : "Editando agora: " + list.map((p) =>
+            '<span class="collab-peer" style="border-color: ' + p.color + '">' + escapeHTML(p.email) + "</span>"
+        ).join(" "))
+
+        const style = window.getComputedStyle(content.get(0))
+        ;["fontFamily", "fontSize", "fontWeight", "lineHeight", "letterSpacing", "boxSizing",
+            "paddingTop", "paddingRight", "paddingBottom", "paddingLeft",
+            "borderTopWidth", "borderRightWidth", "borderBottomWidth", "borderLeftWidth"].forEach((prop) => {
+            overlay.css(prop, style[prop])
+        })
+        overlay.css({ width: content.outerWidth(), height: content.outerHeight() })
+
+        const text = content.val()
+        const carets = list.filter((p) => p.cursor).sort((a, b) => a.cursor.head - b.cursor.head)
+        let html = ""
+        let at = 0
+        carets.forEach((p) => {
+            const head = Math.min(p.cursor.head, text.length)
+            html += escapeHTML(text.slice(at, head))
+            html += '<span class="collab-caret" style="border-color: ' + p.color + '" title="' + escapeHTML(p.email) + '"></span>'
+            at = head
+        })
+        overlay.html(html + escapeHTML(text.slice(at)) + "\n")
+        overlay.scrollTop(content.scrollTop())
+    }
+
+    function addPeer(client, email, cursor) {
+        peers[client] = { email: email, cursor: cursor, color: colors[client % colors.length] }
+    }
+
+    function movePeers(o) {
+        Object.values(peers).forEach((p) => {
+            if (p.cursor) {
+                p.cursor = { anchor: transformIndex(o, p.cursor.anchor), head: transformIndex(o, p.cursor.head) }
+            }
+        })
+    }
+
+    // synchronization
+
+    const scheme = window.location.protocol === "https:" ? "wss:" : "ws:"
+    const socket = new WebSocket(scheme + "//" + window.location.host + "/notes/" + noteID + "/collab")
+    let text = null // the content as last synchronized, null until the document is received
+    let rev = 0 // the revision of the server the pending operations are based on
+    let pending = [] // the local operations not acknowledged yet, the first one sent
+    let inflight = false
+
+    function send(msg) {
+        if (socket.readyState === WebSocket.OPEN) {
+            socket.send(JSON.stringify(msg))
+        }
+    }
+
+    function flush() {
+        if (!inflight && pending.length > 0) {
+            inflight = true
+            send({ type: "op", rev: rev, op: pending[0].steps })
+        }
+    }
+
+    // the cursor is only meaningful to the others once the server has every local edit
+    function sendCursor() {
+        if (text !== null && pending.length === 0) {
+            const el = content.get(0)
+            send({ type: "cursor", rev: rev, cursor: { anchor: el.selectionStart, head: el.selectionEnd } })
+        }
+    }
+
+    function disconnect(message) {
+        text = null
+        presence.text(message)
+        overlay.empty()
+    }
+
+    content.on("input", function () {
+        if (text === null) return
+        const value = content.val()
+        if (value === text) return
+
+        const o = diff(text, value)
+        text = value
+        movePeers(o)
+        pending.push(o)
+        flush()
+        renderPeers()
+    })
+
+    content.on("keyup click select", sendCursor)
+    content.on("scroll", () => overlay.scrollTop(content.scrollTop()))
+
+    socket.onmessage = function (event) {
+        const msg = JSON.parse(event.data)
+        switch (msg.type) {
+        case "init": {
+            rev = msg.rev
+            text = msg.doc || ""
+            const el = content.get(0)
+            const start = el.selectionStart
+            const end = el.selectionEnd
+            content.val(text)
+            el.setSelectionRange(Math.min(start, text.length), Math.min(end, text.length))
+            ;(msg.peers || []).forEach((p) => addPeer(p.client, p.email, p.cursor))
+            content.trigger("input")
+            break
+        }
+        case "ack":
+            rev = msg.rev
+            pending.shift()
+            inflight = false
+            flush()
+            sendCursor()
+            break
+        case "op": {
+            rev = msg.rev
+            let o = fromSteps(msg.op)
+            for (let i = 0; i < pending.length; i++) {
+                [pending[i], o] = transform(pending[i], o)
+            }
+
+            const el = content.get(0)
+            const start = transformIndex(o, el.selectionStart)
+            const end = transformIndex(o, el.selectionEnd)
+            const scroll = el.scrollTop
+            text = apply(o, text)
+            content.val(text)
+            el.setSelectionRange(start, end)
+            el.scrollTop = scroll
+            movePeers(o)
+            // refreshes the preview, the content being in sync it's not taken as a local edit
+            content.trigger("input")
+            break
+        }
+        case "cursor":
+            if (peers[msg.client]) {
+                // the pending operations are based on the revision of the cursor
+                peers[msg.client].cursor = pending.reduce((c, o) => ({
+                    anchor: transformIndex(o, c.anchor),
+                    head: transformIndex(o, c.head),
+                }), msg.cursor)
+            }
+            break
+        case "join":
+            addPeer(msg.client, msg.email, null)
+            break
+        case "leave":
+            delete peers[msg.client]
+            break
+        case "saved":
+            $('input[name="version"]').val(msg.version)
+            break
+        case "error":
+            disconnect("A edição em conjunto foi interrompida. Recarregue a página para continuar com os outros.")
+            socket.close()
+            return
+        }
+        renderPeers()
+    }
+
+    socket.onclose = function () {
+        if (text !== null) {
+            disconnect("A edição em conjunto foi desconectada. Recarregue a página para continuar com os outros.")
+        }
+    }
+
+    $(window).on("resize", renderPeers)
+})
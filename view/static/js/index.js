@@ -1,3 +1,8 @@
 $(document).ready(() => {
     console.log('JQuery is ready')
+
+    // switches the workspace as soon as another one is chosen
+    $(".workspace-switcher select").on("change", function () {
+        this.form.submit()
+    })
 })
@@ -0,0 +1,41 @@
+// Keeps the notes on the page up to date with the changes made by other people, streamed by the server.
+// The note page follows its own note, while the other pages reload their list of notes on any change.
+$(function () {
+    if (!window.EventSource) {
+        return
+    }
+
+    const view = $(".note-view")
+    const noteID = view.data("noteid")
+    const source = new EventSource("/notes/events")
+    let timer
+
+    // reloads the part of the page matching the selector, once the changes stop coming in
+    const reload = (selector) => {
+        clearTimeout(timer)
+        timer = setTimeout(() => {
+            $(selector).load(window.location.href + " " + selector + " > *")
+        }, 300)
+    }
+
+    const onChange = (event) => {
+        const data = JSON.parse(event.data)
+
+        if (noteID === undefined) {
+            reload(".notes-container")
+            return
+        }
+        if (data.note_id !== noteID) {
+            return
+        }
+        if (event.type === "note.deleted") {
+            source.close()
+            view.find(".buttons, .move-note").remove()
+            view.prepend('<p class="flash-message warning">Esta anotação foi movida para a lixeira.</p>')
+            return
+        }
+        reload(".note-view")
+    }
+
+    ["note.created", "note.updated", "note.deleted"].forEach((type) => source.addEventListener(type, onChange))
+})
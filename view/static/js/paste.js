@@ -0,0 +1,51 @@
+// Uploads the images pasted in the note content field and embeds them in the markdown.
+$(function () {
+    const content = $("#content")
+    const noteID = content.data("noteid")
+    const token = $('meta[name="csrf-token"]').attr("content")
+
+    function insertAtCursor(text) {
+        const el = content.get(0)
+        const start = el.selectionStart
+        const end = el.selectionEnd
+        el.value = el.value.slice(0, start) + text + el.value.slice(end)
+        el.selectionStart = el.selectionEnd = start + text.length
+        content.trigger("input")
+    }
+
+    content.on("paste", function (event) {
+        const items = (event.originalEvent.clipboardData || {}).items || []
+        const images = Array.from(items).filter(function (item) {
+            return item.kind === "file" && item.type.startsWith("image/")
+        })
+        if (images.length === 0) {
+            return
+        }
+        event.preventDefault()
+
+        if (!noteID) {
+            alert("Salve a anotação antes de colar imagens.")
+            return
+        }
+
+        images.forEach(function (item) {
+            const data = new FormData()
+            data.append("image", item.getAsFile(), item.getAsFile().name || "imagem.png")
+
+            $.ajax({
+                url: "/notes/" + noteID + "/images",
+                method: "POST",
+                headers: { "X-CSRF-Token": token },
+                data: data,
+                processData: false,
+                contentType: false,
+                dataType: "json",
+            }).done(function (res) {
+                insertAtCursor(res.markdown + "\n")
+            }).fail(function (xhr) {
+                const err = xhr.responseJSON && xhr.responseJSON.error
+                alert("Não foi possível enviar a imagem" + (err ? ": " + err.message : "."))
+            })
+        })
+    })
+})
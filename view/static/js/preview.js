@@ -0,0 +1,28 @@
+// Live preview of the markdown typed in the note content field.
+$(function () {
+    const content = $("#content")
+    const preview = $("#preview")
+    const token = $('meta[name="csrf-token"]').attr("content")
+    let timer = null
+
+    function render() {
+        $.ajax({
+            url: "/notes/preview",
+            method: "POST",
+            headers: { "X-CSRF-Token": token },
+            data: { content: content.val() },
+            dataType: "html",
+        }).done(function (html) {
+            preview.html(html)
+        }).fail(function () {
+            preview.text("Não foi possível gerar a pré-visualização.")
+        })
+    }
+
+    content.on("input", function () {
+        clearTimeout(timer)
+        timer = setTimeout(render, 300)
+    })
+
+    render()
+})
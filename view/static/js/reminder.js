@@ -0,0 +1,8 @@
+// Fills in the time zone of the browser for the reminders set without one,
+// so they are sent at the time chosen wherever the server is.
+$(function () {
+    const timezone = $("#remind_timezone")
+    if (timezone.length > 0 && !timezone.val() && window.Intl) {
+        timezone.val(Intl.DateTimeFormat().resolvedOptions().timeZone)
+    }
+})